@@ -0,0 +1,110 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"os"
+	"unsafe"
+)
+
+// Allocator supplies the transient buffers a framer uses internally: the
+// scratch buffers of WriteTo, ReadTo, ReadFrom, WriteFrom, Writev, WriteTyped
+// and CopyFrames, and the Forwarder buffer. Applications with arena or region
+// allocators use it to keep these buffers out of the GC heap.
+//
+// Alloc returns a slice of at least n bytes. Free hands back a buffer the
+// framer no longer uses: one replaced by a larger buffer, or all of them on
+// Close, or when an operation still running at Close returns. Buffers of a
+// framer dropped without Close are never freed, and a Forwarder frees only
+// buffers it replaces. An Allocator shared by several framers must be safe
+// for concurrent use.
+type Allocator interface {
+	Alloc(n int) []byte
+	Free(p []byte)
+}
+
+// WithAllocator makes the framer take its internal buffers from a. A nil a
+// restores heap allocation.
+func WithAllocator(a Allocator) Option {
+	return func(o *Options) { o.Allocator = a }
+}
+
+// WithAlignedBuffers makes the framer allocate its internal buffers from the
+// heap aligned to the operating system page size, which DMA engines, splice
+// and O_DIRECT style I/O prefer. It replaces any Allocator set before it.
+func WithAlignedBuffers() Option {
+	return WithAllocator(alignedAllocator{align: os.Getpagesize()})
+}
+
+// WithHugePageBuffers makes the framer carve its internal buffers, page
+// aligned, out of 2MiB arenas that the kernel is asked to back with
+// transparent huge pages, so many per-connection relay buffers share a few
+// TLB entries. The arenas are shared by every framer of the process, and
+// freed buffers are recycled, up to a bound, rather than returned to the
+// kernel; use WithAllocator(NewHugePageAllocator()) and Release it to
+// reclaim them. Where huge pages are unavailable it behaves like
+// WithAlignedBuffers.
+func WithHugePageBuffers() Option {
+	return WithAllocator(hugePages)
+}
+
+// alignedAllocator allocates heap buffers starting at a multiple of align,
+// a power of two.
+type alignedAllocator struct{ align int }
+
+func (a alignedAllocator) Alloc(n int) []byte {
+	b := make([]byte, n+a.align-1)
+	off := int(-uintptr(unsafe.Pointer(unsafe.SliceData(b)))) & (a.align - 1)
+	return b[off : off+n : off+n]
+}
+
+func (alignedAllocator) Free([]byte) {}
+
+// newBuf returns a buffer of length n from the configured Allocator, or from
+// the heap when there is none.
+func (fr *framer) newBuf(n int) []byte {
+	p := fr.allocBuf(n)
+	fr.charge(cap(p))
+	return p
+}
+
+// allocBuf is newBuf without memory budget accounting.
+func (fr *framer) allocBuf(n int) []byte {
+	if fr.allocator != nil {
+		return fr.allocator.Alloc(n)[:n]
+	}
+	return make([]byte, n)
+}
+
+// freeBuf returns p to the configured Allocator.
+func (fr *framer) freeBuf(p []byte) {
+	fr.charge(-cap(p))
+	if fr.allocator != nil && p != nil {
+		fr.allocator.Free(p[:cap(p)])
+	}
+}
+
+// releaseBufs frees the scratch buffers of fr.
+func (fr *framer) releaseBufs() {
+	fr.freeBuf(fr.rbuf)
+	fr.freeBuf(fr.wbuf)
+	fr.freeBuf(fr.tbuf)
+	fr.freeBuf(fr.cbuf)
+	fr.freeBuf(fr.pf)
+	fr.freeBuf(fr.rtxBuf)
+	fr.freeBuf(fr.dbuf)
+	fr.freeBuf(fr.ebuf)
+	fr.rbuf, fr.wbuf, fr.tbuf, fr.cbuf, fr.pf, fr.rtxBuf = nil, nil, nil, nil, nil, nil
+	fr.dbuf, fr.ebuf = nil, nil
+	fr.doff, fr.dlen = 0, 0
+	fr.charge(-fr.packetBufs())
+	if c := fr.coal; c != nil {
+		c.drop()
+		c.mu.Lock()
+		fr.freeBuf(c.buf)
+		c.buf = nil
+		c.mu.Unlock()
+	}
+}
@@ -0,0 +1,146 @@
+//go:build linux
+
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const hugePageSize = 2 << 20
+
+// hugePageFreeLimit bounds the bytes of freed buffers a HugePageAllocator
+// keeps for reuse.
+const hugePageFreeLimit = 64 << 20
+
+// hugePages is the process-wide allocator of WithHugePageBuffers.
+var hugePages = NewHugePageAllocator()
+
+// HugePageAllocator is the Allocator of WithHugePageBuffers: it hands out
+// page-aligned buffers carved from 2MiB-aligned anonymous mappings advised
+// with MADV_HUGEPAGE. Buffers larger than half an arena get mappings of
+// their own. Where a mapping fails it falls back to page-aligned heap
+// buffers, which are left to the garbage collector when freed.
+//
+// Freed mapped buffers are kept per size for reuse, up to 64MiB in all;
+// beyond that, a buffer with a mapping of its own is unmapped and an arena
+// buffer is dropped until Release. Release unmaps every mapping, so a
+// long-running process can reclaim the memory once the framers using the
+// allocator are closed. A HugePageAllocator is safe for concurrent use.
+type HugePageAllocator struct {
+	mu        sync.Mutex
+	arena     []byte // unused tail of the current arena
+	maps      []hugeMapping
+	free      map[int][][]byte
+	freeBytes int
+}
+
+// hugeMapping is one mapping of a HugePageAllocator.
+type hugeMapping struct {
+	m   []byte // as returned by mmap, for munmap
+	own bool   // holds a single buffer
+}
+
+// NewHugePageAllocator returns an empty HugePageAllocator, for use with
+// WithAllocator when its memory must be released independently of the
+// process-wide allocator of WithHugePageBuffers.
+func NewHugePageAllocator() *HugePageAllocator {
+	return &HugePageAllocator{free: make(map[int][][]byte)}
+}
+
+func (a *HugePageAllocator) Alloc(n int) []byte {
+	page := os.Getpagesize()
+	size := (max(n, 1) + page - 1) &^ (page - 1)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if l := a.free[size]; len(l) > 0 {
+		b := l[len(l)-1]
+		a.free[size] = l[:len(l)-1]
+		a.freeBytes -= size
+		clear(b)
+		return b
+	}
+	if size > hugePageSize/2 {
+		if b := a.mapHuge((size+hugePageSize-1)&^(hugePageSize-1), true); b != nil {
+			return b[:size:size]
+		}
+		return alignedAllocator{align: page}.Alloc(size)
+	}
+	if len(a.arena) < size {
+		if a.arena = a.mapHuge(hugePageSize, false); a.arena == nil {
+			return alignedAllocator{align: page}.Alloc(size)
+		}
+	}
+	b := a.arena[:size:size]
+	a.arena = a.arena[size:]
+	return b
+}
+
+func (a *HugePageAllocator) Free(p []byte) {
+	p = p[:cap(p)]
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	i := a.mapping(p)
+	if i < 0 {
+		// A heap fallback buffer, or one mapped before Release.
+		return
+	}
+	if a.freeBytes+len(p) > hugePageFreeLimit {
+		if a.maps[i].own {
+			_ = syscall.Munmap(a.maps[i].m)
+			a.maps = append(a.maps[:i], a.maps[i+1:]...)
+		}
+		return
+	}
+	a.free[len(p)] = append(a.free[len(p)], p)
+	a.freeBytes += len(p)
+}
+
+// Release unmaps every mapping of a and forgets the freed buffers. Buffers
+// handed out before must no longer be used: call it only after closing the
+// framers that use a. Later Alloc calls map new arenas.
+func (a *HugePageAllocator) Release() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, hm := range a.maps {
+		_ = syscall.Munmap(hm.m)
+	}
+	a.maps, a.arena = nil, nil
+	clear(a.free)
+	a.freeBytes = 0
+}
+
+// mapping returns the index of the mapping holding p, or -1.
+func (a *HugePageAllocator) mapping(p []byte) int {
+	addr := uintptr(unsafe.Pointer(unsafe.SliceData(p)))
+	for i, hm := range a.maps {
+		base := uintptr(unsafe.Pointer(unsafe.SliceData(hm.m)))
+		if addr >= base && addr < base+uintptr(len(hm.m)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// mapHuge maps size bytes, a multiple of hugePageSize, aligned to
+// hugePageSize so the kernel can back them with huge pages, and records the
+// mapping. It over-maps by one huge page to find the alignment; the unused
+// part is never touched and so costs no memory. It returns nil when the
+// mapping fails.
+func (a *HugePageAllocator) mapHuge(size int, own bool) []byte {
+	m, err := syscall.Mmap(-1, 0, size+hugePageSize, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE|syscall.MAP_ANON)
+	if err != nil {
+		return nil
+	}
+	a.maps = append(a.maps, hugeMapping{m: m, own: own})
+	off := int(-uintptr(unsafe.Pointer(unsafe.SliceData(m)))) & (hugePageSize - 1)
+	b := m[off : off+size : off+size]
+	_ = syscall.Madvise(b, syscall.MADV_HUGEPAGE)
+	return b
+}
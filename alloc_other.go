@@ -0,0 +1,33 @@
+//go:build !linux
+
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import "os"
+
+// hugePages is the process-wide allocator of WithHugePageBuffers.
+var hugePages = NewHugePageAllocator()
+
+// HugePageAllocator is the Allocator of WithHugePageBuffers. On this
+// platform it hands out page-aligned heap buffers, left to the garbage
+// collector when freed, and Release has nothing to unmap.
+type HugePageAllocator struct {
+	aligned alignedAllocator
+}
+
+// NewHugePageAllocator returns an empty HugePageAllocator, for use with
+// WithAllocator when its memory must be released independently of the
+// process-wide allocator of WithHugePageBuffers.
+func NewHugePageAllocator() *HugePageAllocator {
+	return &HugePageAllocator{aligned: alignedAllocator{align: os.Getpagesize()}}
+}
+
+func (a *HugePageAllocator) Alloc(n int) []byte { return a.aligned.Alloc(n) }
+
+func (a *HugePageAllocator) Free([]byte) {}
+
+// Release does nothing on this platform.
+func (a *HugePageAllocator) Release() {}
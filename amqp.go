@@ -0,0 +1,97 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// HeaderAMQP framing: a 7-byte header and the frame-end octet.
+const (
+	amqpHeaderLen = 7
+	amqpFrameEnd  = 0xCE
+)
+
+// amqpTrailer is the frame-end octet written after each HeaderAMQP payload.
+var amqpTrailer = []byte{amqpFrameEnd}
+
+// A HeaderAMQP frame is an AMQP 0-9-1 frame: a type byte, a 2-byte
+// big-endian channel, a 4-byte big-endian payload size, the payload and the
+// frame-end octet 0xCE. Type and channel are not part of the payload: the
+// Reader records them in Meta, and the Writer takes them from WriteMeta. The
+// Writer appends the frame-end octet and the Reader checks it once the
+// payload is read, failing with ErrInvalidFrameEnd when it is not 0xCE, which
+// AMQP treats as a fatal frame error. A message is complete only after its
+// frame-end octet, so Read may return ErrWouldBlock after the last payload
+// byte; retry with the same buffer as usual.
+//
+// The 8-byte protocol header ("AMQP" 0 0 9 1) that opens a connection is not
+// a frame: read or write it on the transport before framing it, without
+// WithPrefetch on the Reader.
+
+// putAMQPHeader encodes the header of a v-byte payload.
+func putAMQPHeader(dst []byte, typ byte, channel uint16, v int64) {
+	dst[0] = typ
+	binary.BigEndian.PutUint16(dst[1:3], channel)
+	binary.BigEndian.PutUint32(dst[3:amqpHeaderLen], uint32(v))
+}
+
+// readAMQPHeader parses a HeaderAMQP header and records its type and channel
+// in fr.rmeta. The header size is kept in fr.hlen so it is parsed once per
+// frame.
+func (fr *framer) readAMQPHeader() (int64, error) {
+	if fr.hlen > 0 {
+		return fr.hlen, nil
+	}
+	if err := fr.readHeaderBytes(amqpHeaderLen); err != nil {
+		return 0, err
+	}
+	v := int64(binary.BigEndian.Uint32(fr.header[3:amqpHeaderLen]))
+	if err := fr.parsedLength(v, amqpHeaderLen); err != nil {
+		return 0, err
+	}
+	fr.rmeta = Meta{Type: fr.header[0], Channel: binary.BigEndian.Uint16(fr.header[1:3])}
+	fr.hlen = amqpHeaderLen
+	return fr.hlen, nil
+}
+
+// readFrameEnd reads and checks the frame-end octet of a HeaderAMQP frame
+// whose payload is complete. Other formats have none.
+func (fr *framer) readFrameEnd() error {
+	if fr.rhf != HeaderAMQP {
+		return nil
+	}
+	// The header bytes are parsed, so the byte after them is free.
+	end := fr.header[amqpHeaderLen : amqpHeaderLen+1]
+	rn, re := fr.readOnce(end)
+	if rn == 0 {
+		if re == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return re
+	}
+	if end[0] != amqpFrameEnd {
+		fr.reset()
+		return ErrInvalidFrameEnd
+	}
+	return nil
+}
+
+// writeFrameEnd writes the frame-end octet of a HeaderAMQP frame whose
+// payload is written. Other formats have none.
+func (fr *framer) writeFrameEnd() error {
+	if fr.whf != HeaderAMQP {
+		return nil
+	}
+	wn, we := fr.writeOnce(amqpTrailer)
+	if wn == 1 {
+		return nil
+	}
+	if we == nil {
+		return io.ErrShortWrite
+	}
+	return we
+}
@@ -0,0 +1,139 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"slices"
+	"sync/atomic"
+)
+
+// defaultAutoDetect are the formats WithAutoDetect tries when given none.
+var defaultAutoDetect = []HeaderFormat{HeaderCompact, HeaderFixed32}
+
+// WithAutoDetect makes a stream Reader sniff the header of the first message
+// and lock onto the first of formats that reads it as a plausible header,
+// so a server accepts clients speaking different framing dialects on one
+// port, e.g. WithAutoDetect(HeaderCompact, HeaderFixed32) for framer peers
+// and 4-byte length-prefixed ones. Without formats it tries HeaderCompact
+// and HeaderFixed32. The byte order is the configured one.
+//
+// A header is plausible when it is complete, minimally encoded and announces
+// a non-empty payload of at most ReadLimit bytes, or 64KiB when there is
+// none, so the first message must not be empty: a 4-byte big-endian length
+// starts with a zero byte, which the compact and varint formats read as an
+// empty message. Formats that read a different plausible header from the
+// same bytes cannot be told apart, and the first listed wins; this is the
+// case of HeaderCompact and HeaderUvarint, so list only one of them, e.g.
+// WithAutoDetect(HeaderFixed32, HeaderUvarint). A format listed before the
+// one that fits waits for its whole header. When no format fits, Read fails
+// with ErrInvalidHeader.
+//
+// Sniffed bytes are read again as the message, and ReadFormat reports the
+// format once locked. The Writer of a ReadWriter switches to the detected
+// format for the messages it starts afterwards, so replies use the client's
+// dialect.
+func WithAutoDetect(formats ...HeaderFormat) Option {
+	return func(o *Options) {
+		if len(formats) == 0 {
+			formats = defaultAutoDetect
+		}
+		o.AutoDetect = slices.Clone(formats)
+	}
+}
+
+// ReadFormat returns the header format of the Reader and whether it is
+// settled: false while WithAutoDetect has not locked onto a format yet.
+func (r *Reader) ReadFormat() (HeaderFormat, bool) {
+	return r.fr.rhf, r.fr.auto == nil
+}
+
+// newDetected returns the detected format shared by the framers of a
+// ReadWriter with WithAutoDetect, or nil.
+func newDetected(o *Options) *atomic.Int32 {
+	if len(o.AutoDetect) == 0 {
+		return nil
+	}
+	d := new(atomic.Int32)
+	d.Store(-1)
+	return d
+}
+
+// linkDetected makes the Writer of a ReadWriter follow the format detected
+// by its Reader.
+func linkDetected(r, w *framer) { w.detected = r.detected }
+
+// followDetected switches the write-side header format to the detected one
+// before a message starts.
+func (fr *framer) followDetected() {
+	if v := fr.detected.Load(); v >= 0 {
+		fr.whf, fr.detected = HeaderFormat(v), nil
+	}
+}
+
+// detectHeader sniffs transport bytes into fr.sniff until a format of
+// fr.auto fits, then locks onto it. The sniffed bytes are served again by
+// readSource.
+func (fr *framer) detectHeader() error {
+	for {
+		eof := false
+		if fr.snLen < len(fr.sniff) {
+			n, err := fr.readOnce(fr.sniff[fr.snLen:])
+			fr.snLen += n
+			if err == io.EOF {
+				if fr.snLen == 0 {
+					return io.EOF
+				}
+				eof = true
+			} else if err != nil && n == 0 {
+				return err
+			}
+		}
+		full := eof || fr.snLen == len(fr.sniff)
+		f, err := detectFormat(fr.auto, fr.rbo, fr.sniff[:fr.snLen], int64(fr.scratchSize()), full)
+		if err == errNeedMore {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		fr.rhf, fr.auto = f, nil
+		if fr.detected != nil {
+			fr.detected.Store(int32(f))
+		}
+		return nil
+	}
+}
+
+// errNeedMore tells detectHeader to sniff more bytes.
+var errNeedMore = errors.New("framer: header incomplete")
+
+// detectFormat returns the first of formats whose header at the start of b
+// is plausible, or errNeedMore when a format tried before needs more bytes.
+// When none fits it returns ErrInvalidHeader, or io.ErrUnexpectedEOF when b
+// is all there is and ends inside a header.
+func detectFormat(formats []HeaderFormat, bo binary.ByteOrder, b []byte, limit int64, full bool) (HeaderFormat, error) {
+	short := false
+	for _, f := range formats {
+		h := Header{Format: f, ByteOrder: bo}
+		err := h.decode(b)
+		if err == io.ErrUnexpectedEOF {
+			if !full {
+				return 0, errNeedMore
+			}
+			short = true
+			continue
+		}
+		if err == nil && h.PayloadLen > 0 && h.PayloadLen <= limit && f.headerLen(h.PayloadLen) == int64(h.HeaderLen) {
+			return f, nil
+		}
+	}
+	if short {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return 0, ErrInvalidHeader
+}
@@ -0,0 +1,74 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+// ReadAvailable delivers to fn, in order, every message that can be read
+// without waiting: the messages already buffered by the Reader (see
+// Buffered) and those completed by at most one transport read. It returns
+// the number of messages delivered and ErrWouldBlock once nothing more is
+// available, so an event loop processes all ready messages per readiness
+// event in one call and re-arms readiness afterwards.
+//
+// The payload passed to fn is valid only until fn returns. An error from fn
+// stops ReadAvailable and is returned; the message has been consumed. A
+// message that is still incomplete is kept and resumed by the next
+// ReadAvailable call; do not interleave other read methods in between.
+// Transport errors such as io.EOF are returned as they occur.
+//
+// ReadAvailable never waits, whatever the RetryDelay or WaitFunc: the one
+// transport read is made only when no buffered message is complete, or to
+// read ahead after one with WithPrefetch, and the transport should be
+// nonblocking so that this read returns ErrWouldBlock when there is nothing
+// to read. In stream mode only WithPrefetch lets one read complete several
+// messages; without it, headers and payloads are read directly into place.
+// The transport may still hold data when ReadAvailable returns, so use
+// level-triggered readiness. Read interceptors apply to the delivered
+// messages.
+func (r *Reader) ReadAvailable(fn func(payload []byte) error) (int, error) {
+	fr := r.fr
+	if !fr.enter() {
+		return 0, ErrConcurrentUse
+	}
+	defer fr.leave()
+	if fn == nil {
+		return 0, ErrInvalidArgument
+	}
+	return fr.readAvailable(fn)
+}
+
+func (fr *framer) readAvailable(fn func(payload []byte) error) (int, error) {
+	delay, wait := fr.retryDelay, fr.waitFunc
+	fr.retryDelay, fr.waitFunc = -1, nil
+	fr.avOn, fr.avSpent = true, false
+	defer func() {
+		fr.retryDelay, fr.waitFunc = delay, wait
+		fr.avOn, fr.avSpent = false, false
+	}()
+	count := 0
+	for {
+		if _, err := fr.readBuffer(&fr.avBuf); err != nil {
+			if err != ErrWouldBlock && err != ErrMore {
+				fr.avBuf.Reset()
+			}
+			return count, err
+		}
+		count++
+		err := fn(fr.avBuf.Bytes())
+		fr.avBuf.Reset()
+		if err != nil {
+			return count, err
+		}
+	}
+}
+
+// spendRead reports whether a transport read may be made. Within
+// ReadAvailable only the first one may.
+func (fr *framer) spendRead() bool {
+	if fr.avSpent {
+		return false
+	}
+	fr.avSpent = fr.avOn
+	return true
+}
@@ -0,0 +1,108 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// MemoryBudget bounds the internal buffer memory of any number of framers,
+// Forwarders and helpers sharing it, for processes serving many connections
+// that need a global rather than a per-connection limit. It is safe for
+// concurrent use.
+//
+// Buffers a framer grows on demand (the scratch buffers of WriteTo, ReadTo,
+// ReadFrom, WriteFrom, Writev, WriteTyped and CopyFrames, and the control
+// frame buffer) are denied with a *BudgetError when they would exceed the
+// limit; the call fails and can be retried once memory is released. Buffers
+// allocated by constructors and SetReadLimit, including the reassembly
+// buffers of packet mode, are counted but never denied. Close returns a
+// framer's buffers to the budget; the buffer of a Forwarder, which has no
+// Close, stays counted.
+type MemoryBudget struct {
+	limit int64
+	used  atomic.Int64
+}
+
+// NewMemoryBudget returns a MemoryBudget of limit bytes.
+func NewMemoryBudget(limit int64) *MemoryBudget { return &MemoryBudget{limit: limit} }
+
+// WithMemoryBudget makes the framer account its internal buffers against b.
+func WithMemoryBudget(b *MemoryBudget) Option {
+	return func(o *Options) { o.MemoryBudget = b }
+}
+
+// Limit returns the budget size in bytes.
+func (b *MemoryBudget) Limit() int64 { return b.limit }
+
+// Used returns the bytes currently accounted.
+func (b *MemoryBudget) Used() int64 { return b.used.Load() }
+
+// reserve accounts n bytes if they fit in the limit.
+func (b *MemoryBudget) reserve(n int64) bool {
+	for {
+		used := b.used.Load()
+		if used+n > b.limit {
+			return false
+		}
+		if b.used.CompareAndSwap(used, used+n) {
+			return true
+		}
+	}
+}
+
+// BudgetError reports a buffer denied by a MemoryBudget. It matches
+// ErrMemoryBudget with errors.Is.
+type BudgetError struct {
+	Requested int64 // size of the denied buffer
+	Used      int64 // bytes accounted when it was denied
+	Limit     int64
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("framer: memory budget exceeded: %d bytes requested, %d of %d in use", e.Requested, e.Used, e.Limit)
+}
+
+func (e *BudgetError) Is(target error) bool { return target == ErrMemoryBudget }
+
+// charge accounts n bytes, or releases -n, against the memory budget.
+func (fr *framer) charge(n int) {
+	if fr.mem != nil {
+		fr.mem.used.Add(int64(n))
+	}
+}
+
+// packetBufs returns the size of the packet-mode reassembly buffers, which
+// are allocated with the framer and kept until it is dropped.
+func (fr *framer) packetBufs() int {
+	n := 0
+	if fr.rbun != nil {
+		n += cap(fr.rbun.buf)
+	}
+	if fr.rseg != nil {
+		n += cap(fr.rseg.buf)
+	}
+	if fr.rfec != nil {
+		n += cap(fr.rfec.buf)
+	}
+	return n
+}
+
+// growBuf replaces old with a buffer of length n reserved against the
+// memory budget, or reports a *BudgetError and keeps old.
+func (fr *framer) growBuf(old []byte, n int) ([]byte, error) {
+	if fr.mem == nil {
+		fr.freeBuf(old)
+		return fr.allocBuf(n), nil
+	}
+	if !fr.mem.reserve(int64(n)) {
+		return old, &BudgetError{Requested: int64(n), Used: fr.mem.Used(), Limit: fr.mem.limit}
+	}
+	p := fr.allocBuf(n)
+	fr.mem.used.Add(int64(cap(p) - n))
+	fr.freeBuf(old)
+	return p, nil
+}
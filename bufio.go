@@ -0,0 +1,152 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+)
+
+// BufioReader reads stream-mode messages from a *bufio.Reader the caller
+// already wraps its transport in. Headers are parsed in place with Peek, and
+// payloads are returned as views of the bufio buffer by Next or copied once
+// into the caller's buffer by Read, so the bytes are not buffered twice.
+//
+// Of the read options, the header format, byte order, ReadLimit and
+// WithLengthIncludesHeader apply. Transport errors, including ErrWouldBlock,
+// are returned as they come out of the bufio.Reader; after ErrWouldBlock or
+// ErrMore the call can be retried, with the same buffer for Read.
+type BufioReader struct {
+	br     *bufio.Reader
+	hf     HeaderFormat
+	bo     binary.ByteOrder
+	limit  int64
+	incl   bool
+	length int64 // payload length of the message being read, -1 between messages
+	off    int64 // payload bytes of it read so far
+	done   int   // bytes of the message returned by Next, consumed on the next call
+}
+
+// FromBufioReader returns a BufioReader reading framed messages from br.
+func FromBufioReader(br *bufio.Reader, opts ...Option) *BufioReader {
+	o := defaultOptions
+	for _, fn := range opts {
+		fn(&o)
+	}
+	return &BufioReader{
+		br:     br,
+		hf:     o.ReadHeader,
+		bo:     o.ReadByteOrder,
+		limit:  int64(o.ReadLimit),
+		incl:   o.ReadLengthIncludesHeader,
+		length: -1,
+	}
+}
+
+// Next returns the payload of the next message as a view of the bufio
+// buffer, valid until the next call on r or br. A message that does not fit
+// in the buffer with its header cannot be viewed: Next returns
+// bufio.ErrBufferFull and leaves it to be read with Read. Next must not be
+// called while a Read is in the middle of a message.
+func (r *BufioReader) Next() ([]byte, error) {
+	r.consume()
+	if r.length >= 0 {
+		return nil, ErrInvalidArgument
+	}
+	hlen, length, err := r.header()
+	if err != nil {
+		return nil, err
+	}
+	total := int64(hlen) + length
+	if total > int64(r.br.Size()) {
+		return nil, bufio.ErrBufferFull
+	}
+	b, err := r.br.Peek(int(total))
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	r.done = int(total)
+	return b[hlen:], nil
+}
+
+// Read reads the payload of the next message into p, which must hold all of
+// it, and returns io.ErrShortBuffer otherwise. Like Reader.Read, it reports
+// the bytes read by this call and returns a nil error once the message is
+// complete.
+func (r *BufioReader) Read(p []byte) (int, error) {
+	r.consume()
+	if r.length < 0 {
+		hlen, length, err := r.header()
+		if err != nil {
+			return 0, err
+		}
+		if int64(len(p)) < length {
+			return 0, io.ErrShortBuffer
+		}
+		_, _ = r.br.Discard(hlen) // buffered by header
+		r.length, r.off = length, 0
+	}
+	n := 0
+	for r.off < r.length {
+		rn, err := r.br.Read(p[r.off:r.length])
+		r.off += int64(rn)
+		n += rn
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return n, err
+		}
+		if rn == 0 {
+			return n, io.ErrNoProgress
+		}
+	}
+	r.length = -1
+	return n, nil
+}
+
+// consume discards the message last returned by Next.
+func (r *BufioReader) consume() {
+	if r.done > 0 {
+		_, _ = r.br.Discard(r.done) // buffered by Next
+		r.done = 0
+	}
+}
+
+// header peeks at the next message header and returns its size and the
+// payload length, without consuming it.
+func (r *BufioReader) header() (int, int64, error) {
+	b, _ := r.br.Peek(r.br.Buffered())
+	h := Header{ByteOrder: r.bo, Format: r.hf}
+	for {
+		err := h.decode(b)
+		if err == nil {
+			break
+		}
+		if err != io.ErrUnexpectedEOF {
+			return 0, 0, err
+		}
+		if b, err = r.br.Peek(len(b) + 1); err != nil {
+			if err == io.EOF && len(b) > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, 0, err
+		}
+	}
+	length := h.PayloadLen
+	if r.incl {
+		if length -= int64(h.HeaderLen); length < 0 {
+			return 0, 0, ErrInvalidHeader
+		}
+	}
+	if r.limit > 0 && length > r.limit {
+		return 0, 0, ErrTooLong
+	}
+	return h.HeaderLen, length, nil
+}
@@ -0,0 +1,68 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// WithBundling packs small messages in packet-preserving modes: the Writer
+// buffers every packet behind a uvarint length and sends the buffer as one
+// datagram of at most size bytes when the next packet does not fit, after
+// latency (when positive) from the first buffered packet, on Flush and on
+// Close. The Reader unpacks the datagrams and returns one message per Read.
+// A packet longer than size is sent alone. Both peers must enable it.
+//
+// Write then reports success once the message is buffered, as with
+// WithWriteBuffer in stream mode; WriteUrgent sends the buffered datagram
+// and then its message without waiting. The other packet options apply per
+// message, before bundling.
+func WithBundling(size int, latency time.Duration) Option {
+	return func(o *Options) {
+		o.BundleSize = size
+		o.FlushLatency = latency
+	}
+}
+
+// bundleWriter prefixes packets with their length for the write buffer.
+type bundleWriter struct {
+	pkt []byte // prefixed packet scratch
+}
+
+func (b *bundleWriter) write(fr *framer, p []byte) (int, error) {
+	b.pkt = binary.AppendUvarint(b.pkt[:0], uint64(len(p)))
+	b.pkt = append(b.pkt, p...)
+	n, err := fr.writeTransport(b.pkt)
+	if n < len(b.pkt) {
+		// Datagrams are written whole or not at all.
+		return 0, err
+	}
+	return len(p), err
+}
+
+// bundleReader holds the received datagram being unpacked.
+type bundleReader struct {
+	buf  []byte
+	data []byte // packets not yet returned
+}
+
+func (b *bundleReader) read(fr *framer, p []byte) (int, error) {
+	for len(b.data) == 0 {
+		n, err := fr.recvDatagram(b.buf)
+		if err != nil {
+			return 0, err
+		}
+		b.data = b.buf[:n]
+	}
+	size, k := binary.Uvarint(b.data)
+	if k <= 0 || size > uint64(len(b.data)-k) {
+		b.data = nil
+		return 0, ErrInvalidHeader
+	}
+	msg := b.data[k : k+int(size)]
+	b.data = b.data[k+int(size):]
+	return deliverPacket(p, msg)
+}
@@ -0,0 +1,98 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer_test
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+	"time"
+
+	fr "code.hybscloud.com/framer"
+)
+
+func TestBundling_PacksAndUnpacks(t *testing.T) {
+	a, b := lossyPair(nil)
+	opts := []fr.Option{fr.WithProtocol(fr.Datagram), fr.WithBundling(100, 0)}
+	w := fr.NewWriter(a, opts...).(*fr.Writer)
+	var msgs [][]byte
+	for i := range 5 {
+		msgs = append(msgs, bytes.Repeat([]byte{'a' + byte(i)}, 40))
+	}
+	msgs = append(msgs, nil, bytes.Repeat([]byte{'z'}, 150))
+	for _, m := range msgs {
+		if n, err := w.Write(m); err != nil || n != len(m) {
+			t.Fatalf("Write: n=%d err=%v", n, err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	// 41-byte entries: two per datagram, then the fifth with the empty
+	// message, then the oversized message alone.
+	var sizes []int
+	for _, p := range a.out.q {
+		sizes = append(sizes, len(p))
+	}
+	if want := []int{82, 82, 42, 152}; !slices.Equal(sizes, want) {
+		t.Fatalf("datagram sizes=%v want %v", sizes, want)
+	}
+
+	r := fr.NewReader(b, opts...).(*fr.Reader)
+	buf := make([]byte, 200)
+	for i, want := range msgs {
+		n, err := r.Read(buf)
+		if err != nil || !bytes.Equal(buf[:n], want) {
+			t.Fatalf("message %d: %d bytes err=%v", i, n, err)
+		}
+	}
+	if _, err := r.Read(buf); err != fr.ErrWouldBlock {
+		t.Fatalf("drained: err=%v", err)
+	}
+	// As with any empty datagram, the empty message is not counted.
+	if st := r.Stats(); st.FramesRead != uint64(len(msgs)-1) {
+		t.Fatalf("frames=%d", st.FramesRead)
+	}
+}
+
+func TestBundling_LatencyAndUrgent(t *testing.T) {
+	a, b := lossyPair(nil)
+	opts := []fr.Option{fr.WithProtocol(fr.Datagram), fr.WithBundling(1000, 5*time.Millisecond), fr.WithDedup(0)}
+	w := fr.NewWriter(a, opts...).(*fr.Writer)
+	_, _ = w.Write([]byte("tick"))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		a.out.mu.Lock()
+		sent := len(a.out.q)
+		a.out.mu.Unlock()
+		if sent == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("latency flush did not happen")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	_, _ = w.Write([]byte("bulk"))
+	if _, err := w.WriteUrgent([]byte("now")); err != nil {
+		t.Fatalf("WriteUrgent: %v", err)
+	}
+	a.out.mu.Lock()
+	sent := len(a.out.q)
+	a.out.mu.Unlock()
+	if sent != 3 {
+		t.Fatalf("datagrams after WriteUrgent=%d want 3", sent)
+	}
+	r := fr.NewReader(b, opts...)
+	buf := make([]byte, 16)
+	for _, want := range []string{"tick", "bulk", "now"} {
+		n, err := r.Read(buf)
+		if err != nil || string(buf[:n]) != want {
+			t.Fatalf("Read: %q err=%v want %q", buf[:n], err, want)
+		}
+	}
+	_ = w.Close()
+}
@@ -0,0 +1,203 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import "io"
+
+// SLIP special bytes (RFC 1055).
+const (
+	slipEnd    = 0xC0
+	slipEsc    = 0xDB
+	slipEscEnd = 0xDC
+	slipEscEsc = 0xDD
+)
+
+var (
+	cobsDelimiter = []byte{0}
+	slipDelimiter = []byte{slipEnd}
+)
+
+// WithCOBS selects the COBS protocol for both directions: each message is
+// encoded with Consistent Overhead Byte Stuffing, which removes every zero
+// byte at a cost of one byte per 254, and terminated by a zero byte. It
+// frames messages over serial links and raw byte pipes where a length
+// prefix cannot be resynchronized after line noise.
+//
+// Messages are read and written whole, like with WithDelimiter: Read
+// decodes the next frame into p and returns io.ErrShortBuffer, keeping the
+// frame, when it does not fit, and Write encodes p into a buffer it keeps
+// for later messages and reports len(p) once the frame is written. Empty
+// frames, such as a zero byte sent to flush a line, are skipped, and a frame
+// that does not decode fails with ErrInvalidEncoding and is dropped.
+func WithCOBS() Option {
+	return func(o *Options) { o.ReadProto, o.WriteProto = COBS, COBS }
+}
+
+// WithSLIP selects the SLIP protocol (RFC 1055) for both directions: each
+// message is terminated by an END byte (0xC0), and END and ESC (0xDB) bytes
+// within it are escaped. It behaves like WithCOBS; SLIP frames carry no
+// overhead when the payload has no special bytes but may double in size
+// when it has many. An empty message is a lone END byte, which the Reader
+// skips, so empty messages are not delivered.
+func WithSLIP() Option {
+	return func(o *Options) { o.ReadProto, o.WriteProto = SLIP, SLIP }
+}
+
+// encodedLimit returns the largest encoded frame the Reader scans for, the
+// encoding of a limit-byte payload.
+func encodedLimit(proto Protocol, limit int) int {
+	switch proto {
+	case COBS:
+		return cobsMaxLen(limit)
+	case SLIP:
+		return 2 * limit
+	}
+	return limit
+}
+
+// decodeFrame decodes the frame src, without its delimiter, into p.
+func (fr *framer) decodeFrame(p, src []byte) (int, error) {
+	switch fr.rpr {
+	case COBS:
+		return cobsDecode(p, src)
+	case SLIP:
+		return slipDecode(p, src)
+	}
+	if len(src) > len(p) {
+		return 0, io.ErrShortBuffer
+	}
+	return copy(p, src), nil
+}
+
+// writeEncoded encodes p into fr.ebuf and writes it with its delimiter.
+func (fr *framer) writeEncoded(p []byte) (int, error) {
+	if fr.offset == 0 {
+		size := 2*len(p) + 1
+		if fr.wpr == COBS {
+			size = cobsMaxLen(len(p)) + 1
+		}
+		if len(fr.ebuf) < size {
+			nb, err := fr.growBuf(fr.ebuf, max(size, delimInitBuf))
+			if err != nil {
+				return 0, err
+			}
+			fr.ebuf = nb
+		}
+		if fr.wpr == COBS {
+			fr.length = int64(cobsEncode(fr.ebuf, p))
+		} else {
+			fr.length = int64(slipEncode(fr.ebuf, p))
+		}
+		fr.ebuf[fr.length] = fr.wdelim[0]
+		fr.length++
+	}
+	for fr.offset < fr.length {
+		wn, we := fr.writeOnce(fr.ebuf[fr.offset:fr.length])
+		fr.offset += int64(wn)
+		if we != nil {
+			if we == ErrMore && wn > 0 {
+				continue
+			}
+			return 0, we
+		}
+	}
+	fr.reset()
+	return len(p), nil
+}
+
+// cobsMaxLen returns the largest COBS encoding of an n-byte payload.
+func cobsMaxLen(n int) int { return n + n/254 + 1 }
+
+// cobsEncode encodes src into dst, which holds cobsMaxLen(len(src)) bytes,
+// and returns the encoded length.
+func cobsEncode(dst, src []byte) int {
+	code, ci, n := byte(1), 0, 1
+	for i, b := range src {
+		if b != 0 {
+			dst[n] = b
+			n++
+			code++
+			if code < 0xFF || i == len(src)-1 {
+				continue
+			}
+		}
+		dst[ci] = code
+		code, ci = 1, n
+		n++
+	}
+	dst[ci] = code
+	return n
+}
+
+// cobsDecode decodes the COBS frame src into dst.
+func cobsDecode(dst, src []byte) (int, error) {
+	n := 0
+	for i := 0; i < len(src); {
+		code := int(src[i])
+		if code == 0 || i+code > len(src) {
+			return 0, ErrInvalidEncoding
+		}
+		run := src[i+1 : i+code]
+		if n+len(run) > len(dst) {
+			return 0, io.ErrShortBuffer
+		}
+		n += copy(dst[n:], run)
+		if i += code; code < 0xFF && i < len(src) {
+			if n == len(dst) {
+				return 0, io.ErrShortBuffer
+			}
+			dst[n] = 0
+			n++
+		}
+	}
+	return n, nil
+}
+
+// slipEncode escapes src into dst, which holds 2*len(src) bytes, and returns
+// the encoded length.
+func slipEncode(dst, src []byte) int {
+	n := 0
+	for _, b := range src {
+		switch b {
+		case slipEnd:
+			dst[n], dst[n+1] = slipEsc, slipEscEnd
+			n += 2
+		case slipEsc:
+			dst[n], dst[n+1] = slipEsc, slipEscEsc
+			n += 2
+		default:
+			dst[n] = b
+			n++
+		}
+	}
+	return n
+}
+
+// slipDecode unescapes the SLIP frame src into dst.
+func slipDecode(dst, src []byte) (int, error) {
+	n := 0
+	for i := 0; i < len(src); i++ {
+		b := src[i]
+		if b == slipEsc {
+			if i++; i == len(src) {
+				return 0, ErrInvalidEncoding
+			}
+			switch src[i] {
+			case slipEscEnd:
+				b = slipEnd
+			case slipEscEsc:
+				b = slipEsc
+			default:
+				return 0, ErrInvalidEncoding
+			}
+		}
+		if n == len(dst) {
+			return 0, io.ErrShortBuffer
+		}
+		dst[n] = b
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,69 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import "io"
+
+// File utilities for framed logs. They stream with bounded memory and read
+// and write with the same opts, so header formats, byte orders and the other
+// wire options are preserved. Transport errors, ErrWouldBlock included, are
+// returned; with files as sources and destinations there are none.
+
+// ConcatFrames writes the messages of srcs to dst in order, as one framed
+// stream, and returns the number of messages written. Payloads are relayed in
+// chunks, so message size is bounded only by ReadLimit. Each source must end
+// at a message boundary; a truncated one fails with io.ErrUnexpectedEOF.
+func ConcatFrames(dst io.Writer, srcs []io.Reader, opts ...Option) (int, error) {
+	w := NewWriter(dst, opts...).(*Writer)
+	total := 0
+	for _, src := range srcs {
+		frames, _, err := CopyFrames(w, NewReader(src, opts...).(*Reader), -1)
+		total += frames
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, w.Flush()
+}
+
+// FilterFrames copies the messages of src for which keep returns true to dst
+// and returns the numbers of messages kept and dropped. keep must not retain
+// payload. Each message is read whole into a buffer of ReadLimit bytes, or
+// 64KiB when there is none; a longer one fails with ErrTooLong.
+func FilterFrames(dst io.Writer, src io.Reader, keep func(payload []byte) bool, opts ...Option) (kept, dropped int, err error) {
+	r := NewReader(src, opts...).(*Reader)
+	w := NewWriter(dst, opts...).(*Writer)
+	buf, err := r.fr.scratch()
+	if err != nil {
+		return 0, 0, err
+	}
+	for {
+		n, err := r.Read(buf)
+		switch err {
+		case nil:
+		case io.EOF:
+			return kept, dropped, w.Flush()
+		case io.ErrShortBuffer:
+			return kept, dropped, ErrTooLong
+		default:
+			return kept, dropped, err
+		}
+		if !keep(buf[:n]) {
+			dropped++
+			continue
+		}
+		if _, err := w.Write(buf[:n]); err != nil {
+			return kept, dropped, err
+		}
+		kept++
+	}
+}
+
+// CompactFrames rewrites src to dst without the messages tombstoned reports
+// as deleted, for retention jobs, and returns the numbers of messages kept
+// and dropped. It has the limits of FilterFrames.
+func CompactFrames(dst io.Writer, src io.Reader, tombstoned func(payload []byte) bool, opts ...Option) (kept, dropped int, err error) {
+	return FilterFrames(dst, src, func(p []byte) bool { return !tombstoned(p) }, opts...)
+}
@@ -0,0 +1,72 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"net"
+	"strings"
+)
+
+// Conn is a framed network connection.
+//
+// Reads and writes go through the embedded ReadWriter; Close closes the
+// underlying net.Conn.
+type Conn struct {
+	*ReadWriter
+	nc net.Conn
+}
+
+// NewConn frames an established connection. Transport helpers matching
+// nc.LocalAddr().Network() are applied first (see Dial), followed by opts.
+func NewConn(nc net.Conn, opts ...Option) *Conn {
+	var network string
+	if addr := nc.LocalAddr(); addr != nil {
+		network = addr.Network()
+	}
+	all := append(networkOptions(network), opts...)
+	return &Conn{ReadWriter: NewReadWriter(nc, nc, all...).(*ReadWriter), nc: nc}
+}
+
+// Dial connects to address on the named network and returns a framed
+// connection.
+//
+// Transport helpers are selected from the network name and applied before
+// opts, so explicit options take precedence:
+//   - "tcp", "tcp4", "tcp6" → WithReadTCP / WithWriteTCP
+//   - "udp", "udp4", "udp6" → WithReadUDP / WithWriteUDP
+//   - "unix"                → WithReadUnix / WithWriteUnix
+//   - "unixgram"            → WithReadUnixPacket / WithWriteUnixPacket
+//   - "unixpacket"          → WithReadUnixSeqpacket / WithWriteUnixSeqpacket
+func Dial(network, address string, opts ...Option) (*Conn, error) {
+	nc, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(nc, opts...), nil
+}
+
+// Close closes the underlying connection. Later operations return ErrClosed.
+func (c *Conn) Close() error { return c.ReadWriter.Close() }
+
+// NetConn returns the underlying connection.
+func (c *Conn) NetConn() net.Conn { return c.nc }
+
+// networkOptions maps a net package network name to transport helpers.
+func networkOptions(network string) []Option {
+	switch strings.ToLower(network) {
+	case "tcp", "tcp4", "tcp6":
+		return []Option{WithReadTCP(), WithWriteTCP()}
+	case "udp", "udp4", "udp6":
+		return []Option{WithReadUDP(), WithWriteUDP()}
+	case "unix":
+		return []Option{WithReadUnix(), WithWriteUnix()}
+	case "unixgram":
+		return []Option{WithReadUnixPacket(), WithWriteUnixPacket()}
+	case "unixpacket":
+		return []Option{WithReadUnixSeqpacket(), WithWriteUnixSeqpacket()}
+	default:
+		return nil
+	}
+}
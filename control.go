@@ -0,0 +1,46 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+// flagControl marks a control frame in the flags byte. Control frames may
+// also carry flagPing or flagPong (see ping.go); the other bits are reserved:
+// writers leave them zero and readers ignore them.
+const flagControl = 0x01
+
+// WithControlFrames carries control frames, such as keepalives, window
+// updates or close notifications, on the same stream as data frames.
+//
+// Every stream frame then has a flags byte after its length prefix, counted
+// in the length, so both peers must enable it. Writer.WriteControl sends a
+// control frame. On the read side control frames never reach Read, WriteTo or
+// the other data paths: handler receives each payload as it arrives, and a
+// non-nil error from handler is returned by the read in progress. The payload
+// is valid only during the call. A nil handler discards control frames.
+//
+// It has no effect in packet-preserving modes.
+func WithControlFrames(handler func(payload []byte) error) Option {
+	return func(o *Options) {
+		o.ControlFrames = true
+		o.ControlHandler = handler
+	}
+}
+
+// WriteControl writes p as one control frame. It returns ErrInvalidArgument
+// unless WithControlFrames is enabled in stream mode. On ErrWouldBlock or
+// ErrMore, retry with the same p before writing anything else.
+func (w *Writer) WriteControl(p []byte) (int, error) {
+	fr := w.fr
+	if !fr.enter() {
+		return 0, ErrConcurrentUse
+	}
+	defer fr.leave()
+	if !fr.wflags || fr.wpr.preserveBoundary() {
+		return 0, ErrInvalidArgument
+	}
+	fr.wctl = flagControl
+	n, err := fr.write(p)
+	fr.wctl = 0
+	return n, err
+}
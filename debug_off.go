@@ -0,0 +1,16 @@
+//go:build !framerdebug
+
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+// Invariant checks are compiled in only with the framerdebug build tag; see
+// debug_on.go.
+
+func (fr *framer) checkIO(Direction, int, int) {}
+
+func (fr *framer) checkFrame(Direction, int64) {}
+
+func (f *Forwarder) checkState(uint8) {}
@@ -0,0 +1,75 @@
+//go:build framerdebug
+
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import "fmt"
+
+// Builds with the framerdebug tag assert internal invariants and panic with
+// the framer state when one is violated. This catches transports that break
+// the io.Reader/io.Writer contract during integration testing:
+//
+//	go test -tags framerdebug ./...
+//
+// Without the tag every check below compiles to nothing.
+
+// invariant panics with a description of the violated invariant.
+func invariant(format string, args ...any) {
+	panic(fmt.Sprintf("framer: invariant violated: "+format, args...))
+}
+
+// checkIO asserts that a transport call on dir reported 0 <= n <= size.
+func (fr *framer) checkIO(dir Direction, n, size int) {
+	if n < 0 || n > size {
+		invariant("%v transport returned n=%d for a %d-byte buffer (%s)", dir, n, size, fr.debugState())
+	}
+}
+
+// checkFrame asserts that the in-flight stream message is consistent with a
+// header of hdrSize bytes: its length is non-negative and the progress offset
+// never runs past the header and payload.
+func (fr *framer) checkFrame(dir Direction, hdrSize int64) {
+	if fr.length < 0 || fr.offset < 0 || hdrSize < 0 || fr.offset > hdrSize+fr.length {
+		invariant("%v frame out of bounds, header=%d (%s)", dir, hdrSize, fr.debugState())
+	}
+}
+
+func (fr *framer) debugState() string {
+	return fmt.Sprintf("offset=%d length=%d hlen=%d budget=%d", fr.offset, fr.length, fr.hlen, fr.budget)
+}
+
+// checkState asserts that one ForwardOnce call moved the Forwarder from state
+// from to a reachable state with consistent progress counters. A call may
+// complete a message and return to state 0, but otherwise never moves
+// backwards nor between the whole-message (1, 2) and chunked (3, 4) paths.
+func (f *Forwarder) checkState(from uint8) {
+	to := f.state
+	ok := to <= 4 && (to == 0 || from == 0 || to >= from && (from >= 3) == (to >= 3))
+	if !ok {
+		invariant("forwarder state %d -> %d (%s)", from, to, f.debugState())
+	}
+	if f.need < 0 || f.got < 0 {
+		invariant("forwarder negative progress (%s)", f.debugState())
+	}
+	switch to {
+	case 0:
+		if f.need != 0 || f.got != 0 {
+			invariant("forwarder idle with progress (%s)", f.debugState())
+		}
+	case 2:
+		if f.need > cap(f.buf) {
+			invariant("forwarder message exceeds its buffer (%s)", f.debugState())
+		}
+	case 3, 4:
+		if f.got > f.need || f.coff < 0 || f.coff > f.cn || f.cn > cap(f.buf) {
+			invariant("forwarder chunk out of bounds (%s)", f.debugState())
+		}
+	}
+}
+
+func (f *Forwarder) debugState() string {
+	return fmt.Sprintf("state=%d need=%d got=%d cn=%d coff=%d buf=%d", f.state, f.need, f.got, f.cn, f.coff, cap(f.buf))
+}
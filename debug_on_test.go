@@ -0,0 +1,45 @@
+//go:build framerdebug
+
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer_test
+
+import (
+	"strings"
+	"testing"
+
+	fr "code.hybscloud.com/framer"
+)
+
+// overReader claims to have read more bytes than the buffer holds.
+type overReader struct{}
+
+func (overReader) Read(p []byte) (int, error) { return len(p) + 1, nil }
+
+// overWriter claims to have written more bytes than it was given.
+type overWriter struct{}
+
+func (overWriter) Write(p []byte) (int, error) { return len(p) + 1, nil }
+
+func expectInvariant(t *testing.T, name string, fn func()) {
+	t.Helper()
+	defer func() {
+		r := recover()
+		msg, _ := r.(string)
+		if !strings.Contains(msg, "invariant violated") || !strings.Contains(msg, "offset=") {
+			t.Fatalf("%s: recovered %v, want an invariant panic with framer state", name, r)
+		}
+	}()
+	fn()
+}
+
+func TestDebug_TransportContractViolation(t *testing.T) {
+	expectInvariant(t, "Read", func() {
+		_, _ = fr.NewReader(overReader{}).Read(make([]byte, 8))
+	})
+	expectInvariant(t, "Write", func() {
+		_, _ = fr.NewWriter(overWriter{}).Write([]byte("hello"))
+	})
+}
@@ -0,0 +1,95 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import "encoding/binary"
+
+// dedupHeader is the big-endian sequence number WithDedup puts before each
+// packet payload.
+const dedupHeader = 4
+
+// WithDedup suppresses duplicate datagrams in packet-preserving modes, as
+// produced by multipath or retransmitting networks. The Writer numbers every
+// packet; the Reader remembers the last window sequence numbers and silently
+// drops a packet it has seen, or one older than the window, counting it in
+// Stats.Duplicates. Both peers must enable it; it costs 4 bytes per packet.
+// A non-positive window selects 1024. It has no effect in stream mode.
+func WithDedup(window int) Option {
+	return func(o *Options) {
+		if window <= 0 {
+			window = 1024
+		}
+		o.DedupWindow = window
+	}
+}
+
+// dedupWriter numbers outgoing packets.
+type dedupWriter struct {
+	seq uint32
+	pkt []byte // packet scratch
+}
+
+// stamp returns p behind the next sequence number.
+func (d *dedupWriter) stamp(p []byte) []byte {
+	d.pkt = binary.BigEndian.AppendUint32(d.pkt[:0], d.seq)
+	d.pkt = append(d.pkt, p...)
+	d.seq++
+	return d.pkt
+}
+
+// dedupWindow records the sequence numbers seen within size of the highest.
+type dedupWindow struct {
+	size   uint32
+	top    uint32
+	bits   []uint64 // ring bitmap indexed by seq % size
+	active bool
+}
+
+func newDedupWindow(size int) *dedupWindow {
+	return &dedupWindow{size: uint32(size), bits: make([]uint64, (size+63)/64)}
+}
+
+// check records seq and reports whether it is new.
+func (d *dedupWindow) check(seq uint32) bool {
+	if !d.active {
+		d.active = true
+		d.top = seq
+		d.set(seq)
+		return true
+	}
+	diff := int32(seq - d.top)
+	if diff > 0 {
+		if uint32(diff) >= d.size {
+			clear(d.bits)
+		} else {
+			for s := d.top + 1; s != seq; s++ {
+				d.unset(s)
+			}
+		}
+		d.top = seq
+		d.set(seq)
+		return true
+	}
+	if uint32(-diff) >= d.size || d.has(seq) {
+		return false
+	}
+	d.set(seq)
+	return true
+}
+
+func (d *dedupWindow) set(seq uint32) {
+	i := seq % d.size
+	d.bits[i/64] |= 1 << (i % 64)
+}
+
+func (d *dedupWindow) unset(seq uint32) {
+	i := seq % d.size
+	d.bits[i/64] &^= 1 << (i % 64)
+}
+
+func (d *dedupWindow) has(seq uint32) bool {
+	i := seq % d.size
+	return d.bits[i/64]&(1<<(i%64)) != 0
+}
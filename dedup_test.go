@@ -0,0 +1,72 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer_test
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	fr "code.hybscloud.com/framer"
+)
+
+func TestDedup_DropsDuplicatesAndStalePackets(t *testing.T) {
+	a, b := lossyPair(nil)
+	w := fr.NewWriter(a, fr.WithProtocol(fr.Datagram), fr.WithDedup(4))
+	for i := range 10 {
+		if _, err := w.Write([]byte(strconv.Itoa(i))); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if p := a.out.q[3]; !bytes.Equal(p, []byte{0, 0, 0, 3, '3'}) {
+		t.Fatalf("wire=% x", p)
+	}
+
+	// Reorder 2 and 3, repeat 1 in the window, and replay 0 and 8 later.
+	q := a.out.q
+	a.out.q = [][]byte{q[0], q[1], q[3], q[2], q[1], q[4], q[5], q[6], q[7], q[8], q[9], q[0], q[8]}
+	r := fr.NewReader(b, fr.WithProtocol(fr.Datagram), fr.WithDedup(4)).(*fr.Reader)
+	var got []byte
+	buf := make([]byte, 8)
+	for {
+		n, err := r.Read(buf)
+		if err == fr.ErrWouldBlock {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+		got = append(got, buf[:n]...)
+	}
+	if string(got) != "0132456789" {
+		t.Fatalf("delivered %q", got)
+	}
+	if st := r.Stats(); st.Duplicates != 3 || st.FramesRead != 10 {
+		t.Fatalf("duplicates=%d frames=%d", st.Duplicates, st.FramesRead)
+	}
+}
+
+func TestDedup_WithFEC(t *testing.T) {
+	a, b := lossyPair(func(n int) bool { return n == 1 })
+	opts := []fr.Option{fr.WithProtocol(fr.Datagram), fr.WithDedup(0), fr.WithFEC(2)}
+	w := fr.NewWriter(a, opts...)
+	_, _ = w.Write([]byte("lost"))
+	_, _ = w.Write([]byte("kept"))
+	a.out.q = append(a.out.q, a.out.q[0]) // replay "kept"
+
+	r := fr.NewReader(b, opts...)
+	var got []string
+	buf := make([]byte, 8)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			break
+		}
+		got = append(got, string(buf[:n]))
+	}
+	if len(got) != 2 || got[0] != "kept" || got[1] != "lost" {
+		t.Fatalf("delivered %q", got)
+	}
+}
@@ -0,0 +1,164 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"bytes"
+	"io"
+)
+
+// defaultDelimiter frames Delimited messages when no delimiter is set.
+var defaultDelimiter = []byte{'\n'}
+
+// delimInitBuf is the initial size of the Delimited scan buffer.
+const delimInitBuf = 4096
+
+// WithDelimiter selects the Delimited protocol for both directions: each
+// message is terminated by delim, for line-oriented text protocols such as
+// Redis inline commands, SMTP and log streams. Pass '\n' for newline framing
+// and '\r', '\n' for CRLF. Without arguments the delimiter is '\n'.
+//
+// The Reader scans the stream for the delimiter and delivers the bytes
+// before it, without the delimiter, as one message, like a packet: Read
+// returns a whole line or ErrWouldBlock, and io.ErrShortBuffer, keeping the
+// line, when p is too small for it. Bytes read past the delimiter stay
+// buffered for the next message (see Buffered and RawRemainder). A line
+// longer than ReadLimit, or 64KiB when there is none, fails with ErrTooLong
+// and is discarded up to its delimiter, so the next Read resumes with the
+// following line; trailing bytes without a delimiter at the end of the
+// stream fail with io.ErrUnexpectedEOF.
+//
+// The Writer appends the delimiter to each message and rejects a payload
+// containing it with ErrInvalidArgument. Messages are written whole: Write
+// reports len(p) once the delimiter is written and 0 until then; retry with
+// the same p. Forwarder and CopyFrames relay complete lines. The packet
+// layers (WithSegmentation, WithFEC, WithDedup, WithBundling), control
+// frames and header formats do not apply.
+func WithDelimiter(delim ...byte) Option {
+	return func(o *Options) {
+		o.ReadProto, o.WriteProto = Delimited, Delimited
+		o.Delimiter = bytes.Clone(delim)
+	}
+}
+
+// delimiterOf returns the byte sequence ending each message of proto, or
+// nil when proto is not delimited.
+func delimiterOf(o *Options, proto Protocol) []byte {
+	switch proto {
+	case Delimited:
+		if len(o.Delimiter) == 0 {
+			return defaultDelimiter
+		}
+		return o.Delimiter
+	case COBS:
+		return cobsDelimiter
+	case SLIP:
+		return slipDelimiter
+	}
+	return nil
+}
+
+// readDelimited delivers the next delimited message into p, decoding the
+// COBS and SLIP protocols.
+func (fr *framer) readDelimited(p []byte) (int, error) {
+	limit := encodedLimit(fr.rpr, fr.scratchSize())
+	for {
+		line := fr.dbuf[fr.doff:fr.dlen]
+		if i := bytes.Index(line[fr.dscan:], fr.rdelim); i >= 0 {
+			end := fr.dscan + i
+			if fr.dskip {
+				fr.doff += end + len(fr.rdelim)
+				fr.dscan, fr.dskip = 0, false
+				continue
+			}
+			if end == 0 && fr.rpr != Delimited {
+				// An empty escaped frame only separates frames.
+				fr.doff += len(fr.rdelim)
+				continue
+			}
+			n, err := fr.decodeFrame(p, line[:end])
+			if err == io.ErrShortBuffer {
+				fr.dscan = end
+				return 0, err
+			}
+			fr.doff += end + len(fr.rdelim)
+			fr.dscan = 0
+			return n, err
+		}
+		// The last len(delim)-1 bytes may start a delimiter; the rest is
+		// payload.
+		fr.dscan = max(0, len(line)-len(fr.rdelim)+1)
+		if fr.dskip {
+			fr.doff += fr.dscan
+			fr.dscan = 0
+		} else if fr.dscan > limit {
+			fr.doff += fr.dscan
+			fr.dscan, fr.dskip = 0, true
+			return 0, ErrTooLong
+		}
+		if err := fr.fillDelimited(limit); err != nil {
+			if err == io.EOF && fr.dlen > fr.doff {
+				return 0, io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+	}
+}
+
+// fillDelimited reads more stream bytes into the scan buffer, moving the
+// unread bytes to its start and growing it up to a limit-byte line and its
+// delimiter.
+func (fr *framer) fillDelimited(limit int) error {
+	if fr.doff > 0 {
+		fr.dlen = copy(fr.dbuf, fr.dbuf[fr.doff:fr.dlen])
+		fr.doff = 0
+	}
+	if fr.dlen == len(fr.dbuf) {
+		size := min(max(2*len(fr.dbuf), delimInitBuf), limit+len(fr.rdelim))
+		nb, err := fr.growBuf(nil, size)
+		if err != nil {
+			return err
+		}
+		copy(nb, fr.dbuf[:fr.dlen])
+		fr.freeBuf(fr.dbuf)
+		fr.dbuf = nb
+	}
+	n, err := fr.readOnce(fr.dbuf[fr.dlen:])
+	fr.dlen += n
+	if n > 0 {
+		return nil
+	}
+	return err
+}
+
+// writeDelimited writes p followed by the delimiter.
+func (fr *framer) writeDelimited(p []byte) (int, error) {
+	if fr.offset == 0 {
+		if bytes.Contains(p, fr.wdelim) {
+			return 0, ErrInvalidArgument
+		}
+		fr.length = int64(len(p))
+	} else if fr.length != int64(len(p)) {
+		// The caller changed the message buffer mid-frame.
+		return 0, io.ErrShortWrite
+	}
+	end := fr.length + int64(len(fr.wdelim))
+	for fr.offset < end {
+		chunk := fr.wdelim[max(fr.offset-fr.length, 0):]
+		if fr.offset < fr.length {
+			chunk = p[fr.offset:]
+		}
+		wn, we := fr.writeOnce(chunk)
+		fr.offset += int64(wn)
+		if we != nil {
+			if we == ErrMore && wn > 0 {
+				continue
+			}
+			return 0, we
+		}
+	}
+	fr.reset()
+	return len(p), nil
+}
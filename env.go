@@ -0,0 +1,153 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.hybscloud.com/framer/internal/bo"
+)
+
+// WithEnv applies options from environment variables named prefix + "_" + KEY.
+// An empty prefix means "FRAMER".
+//
+// Recognized keys:
+//   - READ_LIMIT: maximum accepted payload size in bytes (see WithReadLimit).
+//   - RETRY_DELAY: "nonblock", "block", or a time.ParseDuration value (see WithRetryDelay).
+//   - BYTE_ORDER, READ_BYTE_ORDER, WRITE_BYTE_ORDER: "big", "little", or "native".
+//   - PROTOCOL, READ_PROTOCOL, WRITE_PROTOCOL: "stream", "seqpacket", or "datagram".
+//
+// Variables are read when the option is applied, i.e. at construction time.
+// Unset values, and malformed ones such as a negative READ_LIMIT or an
+// unknown BYTE_ORDER, leave the corresponding setting unchanged; call
+// CheckEnv at startup to report them. Options apply in order, so place
+// WithEnv last to let the environment override settings chosen in code.
+func WithEnv(prefix string) Option {
+	return func(o *Options) { _ = applyEnv(prefix, o) }
+}
+
+// CheckEnv reports the variables that WithEnv(prefix) would ignore because
+// their values are malformed, each as an error wrapping ErrInvalidArgument
+// and naming the variable. It returns nil when every set variable is valid.
+func CheckEnv(prefix string) error {
+	var o Options
+	return applyEnv(prefix, &o)
+}
+
+// applyEnv applies the variables of WithEnv to o and returns the malformed
+// ones joined.
+func applyEnv(prefix string, o *Options) error {
+	if prefix == "" {
+		prefix = "FRAMER"
+	}
+	var errs []error
+	invalid := func(key, v string) {
+		errs = append(errs, fmt.Errorf("%w: %s_%s=%q", ErrInvalidArgument, prefix, key, v))
+	}
+	if v, ok := lookupEnv(prefix, "READ_LIMIT"); ok {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			o.ReadLimit = n
+		} else {
+			invalid("READ_LIMIT", v)
+		}
+	}
+	if v, ok := lookupEnv(prefix, "RETRY_DELAY"); ok {
+		if d, ok := parseRetryDelay(v); ok {
+			o.RetryDelay = d
+		} else {
+			invalid("RETRY_DELAY", v)
+		}
+	}
+	for _, key := range [...]string{"BYTE_ORDER", "READ_BYTE_ORDER", "WRITE_BYTE_ORDER"} {
+		v, ok := lookupEnv(prefix, key)
+		if !ok {
+			continue
+		}
+		order := parseByteOrder(v)
+		if order == nil {
+			invalid(key, v)
+			continue
+		}
+		if key != "WRITE_BYTE_ORDER" {
+			o.ReadByteOrder = order
+		}
+		if key != "READ_BYTE_ORDER" {
+			o.WriteByteOrder = order
+		}
+	}
+	for _, key := range [...]string{"PROTOCOL", "READ_PROTOCOL", "WRITE_PROTOCOL"} {
+		v, ok := lookupEnv(prefix, key)
+		if !ok {
+			continue
+		}
+		p := parseProtocol(v)
+		if p == 0 {
+			invalid(key, v)
+			continue
+		}
+		if key != "WRITE_PROTOCOL" {
+			o.ReadProto = p
+		}
+		if key != "READ_PROTOCOL" {
+			o.WriteProto = p
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func lookupEnv(prefix, key string) (string, bool) {
+	v, ok := os.LookupEnv(prefix + "_" + key)
+	if !ok {
+		return "", false
+	}
+	v = strings.TrimSpace(v)
+	return v, v != ""
+}
+
+func parseRetryDelay(v string) (time.Duration, bool) {
+	switch strings.ToLower(v) {
+	case "nonblock":
+		return -1, true
+	case "block":
+		return 0, true
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, false
+	}
+	return d, true
+}
+
+func parseByteOrder(v string) binary.ByteOrder {
+	switch strings.ToLower(v) {
+	case "big", "bigendian", "be":
+		return binary.BigEndian
+	case "little", "littleendian", "le":
+		return binary.LittleEndian
+	case "native":
+		return bo.Native()
+	default:
+		return nil
+	}
+}
+
+func parseProtocol(v string) Protocol {
+	switch strings.ToLower(v) {
+	case "stream", "binarystream":
+		return BinaryStream
+	case "seqpacket":
+		return SeqPacket
+	case "datagram":
+		return Datagram
+	default:
+		return 0
+	}
+}
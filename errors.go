@@ -4,7 +4,11 @@
 
 package framer
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"io"
+)
 
 var (
 	// ErrInvalidArgument reports an invalid configuration or nil reader/writer.
@@ -12,4 +16,111 @@ var (
 
 	// ErrTooLong reports that a frame length exceeds limits or the supported wire format.
 	ErrTooLong = errors.New("framer: message too long")
+
+	// ErrInvalidHeader reports a stream length prefix that cannot describe a frame.
+	ErrInvalidHeader = errors.New("framer: invalid header")
+
+	// ErrInvalidEncoding reports a COBS or SLIP frame that does not decode.
+	ErrInvalidEncoding = errors.New("framer: invalid encoding")
+
+	// ErrInvalidFrameEnd reports a HeaderAMQP frame not terminated by the
+	// frame-end octet 0xCE.
+	ErrInvalidFrameEnd = errors.New("framer: invalid frame end")
+
+	// ErrTruncated reports a message cut short by the end of the stream or by
+	// a short packet. Match it with errors.Is; the concrete error is a
+	// *TruncatedError.
+	ErrTruncated = errors.New("framer: truncated message")
+
+	// ErrClosed is returned by operations on a Reader, Writer or helper type
+	// after its Close, including calls that were waiting on the transport.
+	ErrClosed = errors.New("framer: closed")
+
+	// ErrConcurrentUse reports a call on a Reader or Writer that overlaps
+	// another call on the same direction from a different goroutine. A
+	// Reader or Writer serves one goroutine at a time; Close is the
+	// exception.
+	ErrConcurrentUse = errors.New("framer: concurrent use of a Reader or Writer")
+
+	// ErrTimeout reports a message that missed its deadline (see
+	// WithMessageDeadline). WithErrorMapper can normalize transport-specific
+	// timeouts to it as well.
+	ErrTimeout = errors.New("framer: i/o timeout")
+
+	// ErrTooSlow reports a message that arrived below the minimum read rate.
+	// Match it with errors.Is; the concrete error is a *RateError.
+	ErrTooSlow = errors.New("framer: message too slow")
+
+	// ErrSlowConsumer reports a destination that did not accept a message
+	// within the write stall limit. Match it with errors.Is; the concrete
+	// error is a *StallError.
+	ErrSlowConsumer = errors.New("framer: slow consumer")
+
+	// ErrMemoryBudget reports a buffer denied by a MemoryBudget. Match it
+	// with errors.Is; the concrete error is a *BudgetError.
+	ErrMemoryBudget = errors.New("framer: memory budget exceeded")
+
+	// ErrNegotiation reports that the peer did not answer Negotiate with a
+	// handshake frame.
+	ErrNegotiation = errors.New("framer: negotiation failed")
+
+	// ErrNotAcknowledged reports that a Reliable peer did not acknowledge a
+	// message within the retransmission budget.
+	ErrNotAcknowledged = errors.New("framer: message not acknowledged")
+
+	// ErrServerClosed is returned by Server.Serve after Shutdown or Close.
+	ErrServerClosed = errors.New("framer: server closed")
 )
+
+// OversizeError reports a stream frame refused by WithStrictHeaders from its
+// first header byte, which shows the payload exceeds ReadLimit before its
+// length is read. It matches ErrTooLong with errors.Is.
+//
+// The rest of the frame stays unread, and the Reader keeps refusing it. To
+// resync, the LengthBytes extended length bytes must be read and dropped,
+// followed by the payload length they encode; with an untrusted peer,
+// closing the connection is usually the better answer.
+type OversizeError struct {
+	MinLength   int64 // smallest payload length the header byte allows
+	LengthBytes int64 // extended length bytes still unread
+}
+
+func (e *OversizeError) Error() string {
+	return fmt.Sprintf("framer: message of at least %d bytes too long; %d length bytes and the payload they encode must be discarded to resync", e.MinLength, e.LengthBytes)
+}
+
+func (e *OversizeError) Is(target error) bool { return target == ErrTooLong }
+
+// LengthOverflowError reports a HeaderFixed64 length prefix carrying a
+// payload length above MaxPayloadLen, which the header can encode but a
+// framer cannot handle. It matches ErrTooLong with errors.Is.
+type LengthOverflowError struct {
+	Length uint64 // length value of the header
+}
+
+func (e *LengthOverflowError) Error() string {
+	return fmt.Sprintf("framer: message length %d exceeds the maximum of %d bytes", e.Length, uint64(MaxPayloadLen))
+}
+
+func (e *LengthOverflowError) Is(target error) bool { return target == ErrTooLong }
+
+// TruncatedError reports a message whose payload ended early. The Received
+// bytes were delivered to the caller. It matches ErrTruncated and
+// io.ErrUnexpectedEOF with errors.Is.
+//
+// In packet mode it reports a datagram longer than the read buffer; Length
+// is then the original datagram size. Detection relies on the kernel
+// reporting the size (MSG_TRUNC) and is available for UDP and Unix datagram
+// sockets on Linux; elsewhere such datagrams are cut silently.
+type TruncatedError struct {
+	Length   int64 // declared payload length
+	Received int64 // payload bytes received before the end
+}
+
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("framer: truncated message: received %d of %d bytes", e.Received, e.Length)
+}
+
+func (e *TruncatedError) Is(target error) bool {
+	return target == ErrTruncated || target == io.ErrUnexpectedEOF
+}
@@ -0,0 +1,181 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"io"
+	"sync"
+)
+
+// EventConn is a framed connection over a callback-driven transport, where
+// incoming data arrives as events instead of through a Read call: a browser
+// WebSocket or WebTransport stream under js/wasm (see NewWebSocketConn and
+// NewWebTransportConn), or any similar message API.
+//
+// The transport's event handlers pass each received message to Deliver,
+// which queues it without blocking. The embedded ReadWriter reads from that
+// queue and returns ErrWouldBlock when it is empty, so the same framed
+// protocol code runs over an event-driven transport as over a nonblocking
+// socket. Ready signals when queued data or the end of input arrives.
+//
+// With a read protocol that preserves boundaries (SeqPacket, Datagram), each
+// delivered message is one transport packet. With BinaryStream, delivered
+// messages are concatenated into a byte stream, so frames may span events.
+type EventConn struct {
+	*ReadWriter
+	in    eventQueue
+	send  func(p []byte) error
+	close func() error
+	ready chan struct{}
+}
+
+// NewEventConn returns an EventConn that writes through send and closes the
+// transport with close, which may be nil. send is called with one transport
+// write at a time and must not retain p; it may return ErrWouldBlock to
+// apply backpressure, and the write is retried like on a nonblocking socket.
+func NewEventConn(send func(p []byte) error, close func() error, opts ...Option) *EventConn {
+	o := defaultOptions
+	for _, fn := range opts {
+		fn(&o)
+	}
+	c := &EventConn{send: send, close: close, ready: make(chan struct{}, 1)}
+	c.in.packet = o.ReadProto.preserveBoundary()
+	c.ReadWriter = NewReadWriter(&c.in, eventWriter{c}, opts...).(*ReadWriter)
+	return c
+}
+
+// Deliver queues a received message. It copies msg and never blocks, so it
+// is safe to call from transport event handlers. Messages delivered after
+// CloseRead are dropped.
+func (c *EventConn) Deliver(msg []byte) { c.deliver(append([]byte(nil), msg...)) }
+
+// deliver queues msg without copying it.
+func (c *EventConn) deliver(msg []byte) {
+	if c.in.push(msg) {
+		c.notify()
+	}
+}
+
+// CloseRead ends the input, typically from the transport's close or error
+// handler. Reads return the queued data and then err, or io.EOF when err is
+// nil. Only the first call takes effect.
+func (c *EventConn) CloseRead(err error) {
+	if c.in.closeWithError(err) {
+		c.notify()
+	}
+}
+
+// Ready returns a channel that receives a value when data has been
+// delivered or the input has ended since the last receive. It never
+// blocks the transport: several events may be coalesced into one signal,
+// so read until ErrWouldBlock after each.
+func (c *EventConn) Ready() <-chan struct{} { return c.ready }
+
+// Close closes the ReadWriter and the transport.
+func (c *EventConn) Close() error {
+	c.CloseRead(ErrClosed)
+	err := c.ReadWriter.Close()
+	if c.close != nil {
+		if cerr := c.close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (c *EventConn) notify() {
+	select {
+	case c.ready <- struct{}{}:
+	default:
+	}
+}
+
+// eventWriter adapts the send callback of an EventConn to io.Writer.
+type eventWriter struct{ c *EventConn }
+
+func (w eventWriter) Write(p []byte) (int, error) {
+	if err := w.c.send(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// eventQueue holds delivered messages until they are read.
+type eventQueue struct {
+	mu       sync.Mutex
+	msgs     [][]byte
+	off      int  // bytes of msgs[0] already read in stream mode
+	packet   bool // one message per Read
+	closeErr error
+}
+
+func (q *eventQueue) push(msg []byte) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closeErr != nil {
+		return false
+	}
+	q.msgs = append(q.msgs, msg)
+	return true
+}
+
+func (q *eventQueue) closeWithError(err error) bool {
+	if err == nil {
+		err = io.EOF
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closeErr != nil {
+		return false
+	}
+	q.closeErr = err
+	return true
+}
+
+// Read returns one queued message in packet mode, leaving it queued with
+// io.ErrShortBuffer when p is too small, and as many queued bytes as fit in
+// stream mode. It returns ErrWouldBlock when the queue is empty.
+func (q *eventQueue) Read(p []byte) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.msgs) == 0 {
+		if q.closeErr != nil {
+			return 0, q.closeErr
+		}
+		return 0, ErrWouldBlock
+	}
+	if q.packet {
+		msg := q.msgs[0]
+		if len(p) < len(msg) {
+			return 0, io.ErrShortBuffer
+		}
+		q.pop()
+		return copy(p, msg), nil
+	}
+	n := 0
+	for n < len(p) && len(q.msgs) > 0 {
+		c := copy(p[n:], q.msgs[0][q.off:])
+		n += c
+		if q.off += c; q.off == len(q.msgs[0]) {
+			q.pop()
+		}
+	}
+	if n == 0 && len(p) > 0 {
+		// Only empty messages were queued; they carry no stream bytes.
+		if q.closeErr != nil {
+			return 0, q.closeErr
+		}
+		return 0, ErrWouldBlock
+	}
+	return n, nil
+}
+
+func (q *eventQueue) pop() {
+	q.msgs[0] = nil
+	q.msgs, q.off = q.msgs[1:], 0
+	if len(q.msgs) == 0 {
+		q.msgs = nil
+	}
+}
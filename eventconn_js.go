@@ -0,0 +1,197 @@
+//go:build js && wasm
+
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"syscall/js"
+)
+
+// browserHighWater is the amount of data queued in the browser above which
+// writes report ErrWouldBlock.
+const browserHighWater = 1 << 20
+
+// WebSocket ready states.
+const (
+	wsConnecting = 0
+	wsOpen       = 1
+)
+
+// WebSocketCloseError reports a WebSocket closed with a code other than
+// 1000 (normal closure).
+type WebSocketCloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *WebSocketCloseError) Error() string {
+	return fmt.Sprintf("framer: websocket closed: %d %s", e.Code, e.Reason)
+}
+
+// NewWebSocketConn frames a browser WebSocket object (a js.Value created
+// with new WebSocket(url)). WithReadWebSocket and WithWriteWebSocket are
+// applied before opts, so each framed message is one WebSocket message.
+//
+// Binary and text messages are both delivered as bytes. Writes send binary
+// messages; they return ErrWouldBlock while the socket is connecting or more
+// than 1MiB is waiting in bufferedAmount, and ErrClosed once it is closing.
+// A close with a code other than 1000 ends reads with a
+// *WebSocketCloseError, otherwise with io.EOF. Close closes the socket and
+// releases the event handlers.
+func NewWebSocketConn(ws js.Value, opts ...Option) *EventConn {
+	ws.Set("binaryType", "arraybuffer")
+	send := func(p []byte) error {
+		switch ws.Get("readyState").Int() {
+		case wsConnecting:
+			return ErrWouldBlock
+		case wsOpen:
+		default:
+			return ErrClosed
+		}
+		if ws.Get("bufferedAmount").Int() > browserHighWater {
+			return ErrWouldBlock
+		}
+		ws.Call("send", bytesToJS(p))
+		return nil
+	}
+	var funcs jsFuncs
+	all := append([]Option{WithReadWebSocket(), WithWriteWebSocket()}, opts...)
+	c := NewEventConn(send, func() error {
+		ws.Call("close")
+		ws.Set("onmessage", js.Null())
+		ws.Set("onclose", js.Null())
+		funcs.release()
+		return nil
+	}, all...)
+	ws.Set("onmessage", funcs.add(func(_ js.Value, args []js.Value) any {
+		data := args[0].Get("data")
+		if data.Type() == js.TypeString {
+			c.deliver([]byte(data.String()))
+		} else {
+			c.deliver(bytesFromJS(js.Global().Get("Uint8Array").New(data)))
+		}
+		return nil
+	}))
+	ws.Set("onclose", funcs.add(func(_ js.Value, args []js.Value) any {
+		if code := args[0].Get("code").Int(); code != 1000 {
+			c.CloseRead(&WebSocketCloseError{Code: code, Reason: args[0].Get("reason").String()})
+		} else {
+			c.CloseRead(nil)
+		}
+		return nil
+	}))
+	return c
+}
+
+// NewWebTransportConn frames a WebTransport bidirectional stream (a js.Value
+// obtained from WebTransport.createBidirectionalStream or
+// incomingBidirectionalStreams). The stream is a byte stream, so frames use
+// the stream protocol unless opts select another one.
+//
+// Chunks read from stream.readable are delivered as they arrive. Writes go
+// to stream.writable and return ErrWouldBlock while its desiredSize is not
+// positive; a failed write or read ends reads with the JavaScript error.
+// Close closes the writable side and cancels the readable side.
+func NewWebTransportConn(stream js.Value, opts ...Option) *EventConn {
+	reader := stream.Get("readable").Call("getReader")
+	writer := stream.Get("writable").Call("getWriter")
+	var (
+		funcs jsFuncs
+		mu    sync.Mutex
+		werr  error
+	)
+	var c *EventConn
+	fail := funcs.add(func(_ js.Value, args []js.Value) any {
+		err := jsError(args)
+		mu.Lock()
+		if werr == nil {
+			werr = err
+		}
+		mu.Unlock()
+		c.CloseRead(err)
+		return nil
+	})
+	send := func(p []byte) error {
+		mu.Lock()
+		err := werr
+		mu.Unlock()
+		if err != nil {
+			return err
+		}
+		size := writer.Get("desiredSize")
+		if size.IsNull() {
+			return ErrClosed
+		}
+		if size.Int() <= 0 {
+			return ErrWouldBlock
+		}
+		writer.Call("write", bytesToJS(p)).Call("catch", fail)
+		return nil
+	}
+	c = NewEventConn(send, func() error {
+		writer.Call("close").Call("catch", fail)
+		reader.Call("cancel")
+		funcs.release()
+		return nil
+	}, opts...)
+	var pump, next js.Func
+	pump = funcs.add(func(js.Value, []js.Value) any {
+		reader.Call("read").Call("then", next, fail)
+		return nil
+	})
+	next = funcs.add(func(_ js.Value, args []js.Value) any {
+		if args[0].Get("done").Bool() {
+			c.CloseRead(nil)
+			return nil
+		}
+		c.deliver(bytesFromJS(args[0].Get("value")))
+		pump.Invoke()
+		return nil
+	})
+	pump.Invoke()
+	return c
+}
+
+// jsFuncs tracks the js.Func callbacks of a connection for release.
+type jsFuncs []js.Func
+
+func (fs *jsFuncs) add(fn func(this js.Value, args []js.Value) any) js.Func {
+	f := js.FuncOf(fn)
+	*fs = append(*fs, f)
+	return f
+}
+
+func (fs *jsFuncs) release() {
+	for _, f := range *fs {
+		f.Release()
+	}
+	*fs = nil
+}
+
+// bytesToJS copies p into a new Uint8Array.
+func bytesToJS(p []byte) js.Value {
+	u8 := js.Global().Get("Uint8Array").New(len(p))
+	js.CopyBytesToJS(u8, p)
+	return u8
+}
+
+// bytesFromJS copies a Uint8Array into a new slice.
+func bytesFromJS(u8 js.Value) []byte {
+	p := make([]byte, u8.Get("length").Int())
+	js.CopyBytesToGo(p, u8)
+	return p
+}
+
+// jsError converts the first callback argument to an error.
+func jsError(args []js.Value) error {
+	if len(args) == 0 || args[0].IsUndefined() || args[0].IsNull() {
+		return errors.New("framer: webtransport stream failed")
+	}
+	return js.Error{Value: args[0]}
+}
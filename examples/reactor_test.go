@@ -0,0 +1,56 @@
+//go:build examples
+// +build examples
+
+package examples_test
+
+import (
+	"testing"
+
+	"code.hybscloud.com/framer"
+	"code.hybscloud.com/iox"
+)
+
+// readyReader is a nonblocking transport fed by an event loop: bytes become
+// readable only after the loop delivers them.
+type readyReader struct {
+	ready chan []byte
+	buf   []byte
+}
+
+func (r *readyReader) Read(p []byte) (int, error) {
+	if len(r.buf) == 0 {
+		return 0, iox.ErrWouldBlock
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// wait parks until the loop reports readiness, like a poller wait on the fd.
+func (r *readyReader) wait(dir framer.Direction) error {
+	r.buf = append(r.buf, <-r.ready...)
+	return nil
+}
+
+func TestExample_Reactor_WaitFunc(t *testing.T) {
+	t.Parallel()
+
+	src := &readyReader{ready: make(chan []byte)}
+	r := framer.NewReader(src, framer.WithReadTCP(), framer.WithWaitFunc(src.wait))
+
+	go func() {
+		// The frame arrives in pieces; the in-flight message resumes after each wait.
+		src.ready <- []byte{5, 'h'}
+		src.ready <- []byte{'e', 'l'}
+		src.ready <- []byte{'l', 'o'}
+	}()
+
+	buf := make([]byte, 16)
+	n, err := r.Read(buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if got := string(buf[:n]); got != "hello" {
+		t.Fatalf("got %q want %q", got, "hello")
+	}
+}
@@ -0,0 +1,160 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import "encoding/binary"
+
+// FEC packet header: kind, big-endian group number and index in the group.
+// A parity packet carries the group size as its index and, as payload, the
+// XOR of the group's messages, each prefixed with its 4-byte length and
+// zero-padded to the longest.
+const (
+	fecHeader   = 6
+	fecData     = 0
+	fecParity   = 1
+	fecMaxGroup = 255
+)
+
+// WithFEC adds forward error correction to packet-preserving modes: after
+// every k messages the Writer sends one XOR parity packet, from which the
+// Reader rebuilds a single lost message of the group without
+// retransmission. It costs one packet per group plus a 6-byte header per
+// packet, and recovers up to one loss in every k. k is capped at 255; both
+// peers must enable it.
+//
+// A recovered message is returned when the parity packet arrives, after the
+// group's later messages. A parity packet that the transport refuses with
+// ErrWouldBlock is skipped, leaving that group unprotected. It has no effect
+// in stream mode.
+func WithFEC(k int) Option {
+	return func(o *Options) { o.FECGroup = min(k, fecMaxGroup) }
+}
+
+// fecWriter accumulates the parity of the current group.
+type fecWriter struct {
+	k      int
+	group  uint32
+	index  int
+	parity []byte // XOR of the length-prefixed messages so far
+	pkt    []byte // packet scratch
+}
+
+func (f *fecWriter) write(fr *framer, p []byte) (int, error) {
+	f.pkt = append(f.pkt[:0], fecData, 0, 0, 0, 0, byte(f.index))
+	binary.BigEndian.PutUint32(f.pkt[1:], f.group)
+	f.pkt = append(f.pkt, p...)
+	if _, err := fr.sendPacket(f.pkt); err != nil {
+		return 0, err
+	}
+	f.parity = fecAccumulate(f.parity, p)
+	if f.index++; f.index < f.k {
+		return len(p), nil
+	}
+
+	f.pkt = append(f.pkt[:0], fecParity, 0, 0, 0, 0, byte(f.k))
+	binary.BigEndian.PutUint32(f.pkt[1:], f.group)
+	f.pkt = append(f.pkt, f.parity...)
+	_, err := fr.sendPacket(f.pkt)
+	clear(f.parity)
+	f.parity = f.parity[:0]
+	f.index = 0
+	f.group++
+	if err != nil && err != ErrWouldBlock {
+		return len(p), err
+	}
+	return len(p), nil
+}
+
+// fecReader tracks the current group and rebuilds a lost message.
+type fecReader struct {
+	buf    []byte // packet scratch
+	active bool
+	group  uint32
+	seen   [fecMaxGroup]bool
+	count  int
+	done   bool   // the group was recovered or needed no recovery
+	lost   int    // index of the recovered message, or -1
+	parity []byte // XOR of the length-prefixed messages received
+}
+
+func (f *fecReader) read(fr *framer, p []byte) (int, error) {
+	for {
+		n, err := fr.readDatagram(f.buf)
+		if err != nil {
+			return 0, err
+		}
+		if n < fecHeader {
+			continue
+		}
+		pkt := f.buf[:n]
+		group, index := binary.BigEndian.Uint32(pkt[1:]), int(pkt[5])
+		if !f.active || int32(group-f.group) > 0 {
+			f.begin(group)
+		}
+		current := group == f.group
+		if pkt[0] == fecData {
+			if current && index == f.lost {
+				continue // the late original of a recovered message
+			}
+			if current && index < len(f.seen) && !f.seen[index] {
+				f.seen[index] = true
+				f.count++
+				f.parity = fecAccumulate(f.parity, pkt[fecHeader:])
+			}
+			return deliverPacket(p, pkt[fecHeader:])
+		}
+		if !current || f.done || index == 0 || f.count != index-1 {
+			f.done = f.done || (current && f.count == index)
+			continue
+		}
+		f.done = true
+		for f.lost = 0; f.seen[f.lost]; f.lost++ {
+		}
+		rec := fecXor(pkt[fecHeader:], f.parity)
+		if len(rec) < 4 {
+			continue
+		}
+		size := binary.BigEndian.Uint32(rec)
+		if uint64(size) > uint64(len(rec)-4) {
+			continue
+		}
+		return deliverPacket(p, rec[4:4+size])
+	}
+}
+
+func (f *fecReader) begin(group uint32) {
+	f.active = true
+	f.group = group
+	f.seen = [fecMaxGroup]bool{}
+	f.count = 0
+	f.done = false
+	f.lost = -1
+	clear(f.parity)
+	f.parity = f.parity[:0]
+}
+
+// fecAccumulate XORs the length-prefixed msg into parity, growing it as needed.
+func fecAccumulate(parity, msg []byte) []byte {
+	if need := 4 + len(msg); len(parity) < need {
+		parity = append(parity, make([]byte, need-len(parity))...)
+	}
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(msg)))
+	for i, b := range size {
+		parity[i] ^= b
+	}
+	for i, b := range msg {
+		parity[4+i] ^= b
+	}
+	return parity
+}
+
+// fecXor XORs acc into the parity payload in place and returns it.
+func fecXor(parity, acc []byte) []byte {
+	for i := range min(len(parity), len(acc)) {
+		parity[i] ^= acc[i]
+	}
+	return parity
+}
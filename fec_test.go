@@ -0,0 +1,80 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	fr "code.hybscloud.com/framer"
+)
+
+func TestFEC_RecoversOneLossPerGroup(t *testing.T) {
+	// Groups of 4 take 5 packets. Lose data #1 of group 0, the parity of
+	// group 1 and two data packets of group 2.
+	lost := map[int]bool{2: true, 10: true, 12: true, 13: true}
+	a, b := lossyPair(func(n int) bool { return lost[n] })
+	w := fr.NewWriter(a, fr.WithProtocol(fr.Datagram), fr.WithFEC(4))
+	r := fr.NewReader(b, fr.WithProtocol(fr.Datagram), fr.WithFEC(4))
+
+	msg := func(i int) []byte { return bytes.Repeat([]byte{'a' + byte(i)}, i+1) }
+	for i := range 12 {
+		if _, err := w.Write(msg(i)); err != nil {
+			t.Fatalf("Write %d: %v", i, err)
+		}
+	}
+	var got []string
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		if err == fr.ErrWouldBlock {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+		got = append(got, string(buf[:n]))
+	}
+	var want []string
+	for _, i := range []int{0, 2, 3, 1, 4, 5, 6, 7, 8, 11} {
+		want = append(want, string(msg(i)))
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %q\nwant %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("message %d: %q want %q", i, got[i], want[i])
+		}
+	}
+	if st := r.(*fr.Reader).Stats(); st.FramesRead != uint64(len(want)) {
+		t.Fatalf("read frames=%d want %d", st.FramesRead, len(want))
+	}
+}
+
+func TestFEC_ShortBufferAndStreamMode(t *testing.T) {
+	a, b := lossyPair(func(n int) bool { return n == 1 })
+	w := fr.NewWriter(a, fr.WithProtocol(fr.Datagram), fr.WithFEC(2))
+	r := fr.NewReader(b, fr.WithProtocol(fr.Datagram), fr.WithFEC(2))
+	_, _ = w.Write([]byte("recovered"))
+	_, _ = w.Write([]byte("x"))
+
+	buf := make([]byte, 4)
+	if n, err := r.Read(buf); err != nil || string(buf[:n]) != "x" {
+		t.Fatalf("first: %q err=%v", buf[:n], err)
+	}
+	n, err := r.Read(buf)
+	var te *fr.TruncatedError
+	if !errors.As(err, &te) || te.Length != 9 || string(buf[:n]) != "reco" {
+		t.Fatalf("recovered into short buffer: %q err=%v", buf[:n], err)
+	}
+
+	var wire bytes.Buffer
+	_, _ = fr.NewWriter(&wire, fr.WithFEC(2)).Write([]byte("hi"))
+	if !bytes.Equal(wire.Bytes(), []byte{2, 'h', 'i'}) {
+		t.Fatalf("stream mode wire=% x", wire.Bytes())
+	}
+}
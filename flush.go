@@ -0,0 +1,353 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// defaultWriteBuffer is the coalescing buffer size when WithFlushLatency is
+// used without WithWriteBuffer.
+const defaultWriteBuffer = 64 * 1024
+
+// WithWriteBuffer makes a stream Writer coalesce frames in a buffer of size
+// bytes instead of writing each frame to the transport. The buffer is written
+// out when the next frame does not fit, on Flush, and on Close; frames larger
+// than the buffer bypass it. Write then reports success once the frame is
+// buffered. It has no effect in packet-preserving modes.
+func WithWriteBuffer(size int) Option {
+	return func(o *Options) { o.WriteBufferSize = size }
+}
+
+// WithFlushLatency bounds how long coalesced frames may wait in the write
+// buffer: d after the first byte enters an empty buffer, a background flush
+// writes it out. It enables the write buffer (64KiB unless WithWriteBuffer
+// sets a size). In packet-preserving modes it bounds the wait of
+// WithBundling instead.
+//
+// A background flush that meets ErrWouldBlock or ErrMore tries again after d.
+// Any other error is reported by the next Write or Flush.
+func WithFlushLatency(d time.Duration) Option {
+	return func(o *Options) { o.FlushLatency = d }
+}
+
+// Flush writes buffered frames to the transport. It returns nil when the
+// Writer has no write buffer. ErrWouldBlock follows the RetryDelay and
+// WaitFunc policy like any write.
+func (w *Writer) Flush() error { return w.fr.flush() }
+
+// WriteUrgent writes p as one message ahead of buffering: the frames already
+// buffered are flushed and p goes straight to the transport, so control
+// messages are not held back behind coalesced bulk frames. Without a write
+// buffer it is Write. On ErrWouldBlock or ErrMore, retry with the same p.
+func (w *Writer) WriteUrgent(p []byte) (int, error) {
+	fr := w.fr
+	if !fr.enter() {
+		return 0, ErrConcurrentUse
+	}
+	defer fr.leave()
+	if fr.coal == nil {
+		return fr.write(p)
+	}
+	if err := fr.flush(); err != nil {
+		return 0, err
+	}
+	fr.urgent = true
+	n, err := fr.write(p)
+	fr.urgent = false
+	return n, err
+}
+
+// coalescer is the write buffer of a stream framer. The framer's writer
+// goroutine and the latency timer share it under mu.
+type coalescer struct {
+	fr      *framer
+	mu      sync.Mutex
+	buf     []byte // pending bytes; cap is the buffer size
+	latency time.Duration
+	timer   *time.Timer
+	err     error    // failure of a background flush, reported once
+	fl      *flusher // running flusher goroutine, see Writer.StartFlusher
+}
+
+func newCoalescer(fr *framer, size int, latency time.Duration) *coalescer {
+	if size <= 0 {
+		size = defaultWriteBuffer
+	}
+	return &coalescer{fr: fr, buf: fr.newBuf(size)[:0], latency: latency}
+}
+
+// write buffers p, writing the pending bytes out first when p does not fit.
+func (c *coalescer) write(w io.Writer, p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.err; err != nil {
+		c.err = nil
+		return 0, err
+	}
+	if len(p) > cap(c.buf)-len(c.buf) {
+		if err := c.flushLocked(w); err != nil {
+			return 0, err
+		}
+		if len(p) >= cap(c.buf) {
+			return w.Write(p)
+		}
+	}
+	if len(c.buf) == 0 {
+		if c.fl != nil {
+			c.fl.kick()
+		} else if c.latency > 0 {
+			c.arm()
+		}
+	}
+	c.buf = append(c.buf, p...)
+	return len(p), nil
+}
+
+// flush writes the pending bytes to w.
+func (c *coalescer) flush(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.err; err != nil {
+		c.err = nil
+		return err
+	}
+	return c.flushLocked(w)
+}
+
+func (c *coalescer) flushLocked(w io.Writer) error {
+	for len(c.buf) > 0 {
+		n, err := w.Write(c.buf)
+		err = c.fr.mapErr(err)
+		c.buf = c.buf[:copy(c.buf, c.buf[n:])]
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return io.ErrShortWrite
+		}
+	}
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	return nil
+}
+
+func (c *coalescer) arm() {
+	if c.timer == nil {
+		c.timer = time.AfterFunc(c.latency, c.onTimer)
+		return
+	}
+	c.timer.Reset(c.latency)
+}
+
+// onTimer is the background flush bounding the buffering latency.
+func (c *coalescer) onTimer() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.fr.closed.Load() || len(c.buf) == 0 {
+		return
+	}
+	switch err := c.flushLocked(c.fr.wio); err {
+	case nil:
+	case ErrWouldBlock, ErrMore:
+		c.arm()
+	default:
+		c.err = err
+	}
+}
+
+// drop discards the pending bytes and stops the timer.
+func (c *coalescer) drop() {
+	c.mu.Lock()
+	c.buf = c.buf[:0]
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	c.mu.Unlock()
+}
+
+// flush writes the write buffer out, waiting on ErrWouldBlock according to
+// the retry policy.
+func (fr *framer) flush() error {
+	if fr.coal == nil {
+		return nil
+	}
+	if fr.closed.Load() {
+		return ErrClosed
+	}
+	for {
+		err := fr.coal.flush(fr.wio)
+		if err != ErrWouldBlock {
+			if err == nil {
+				fr.wstart, fr.wstalls = time.Time{}, 0
+			}
+			return err
+		}
+		if fr.stallTimeout > 0 || fr.stallRetries > 0 {
+			if serr := fr.checkStall(); serr != nil {
+				return serr
+			}
+		}
+		fr.wstats.retries.Add(1)
+		retry, werr := fr.waitOnceOnWouldBlock(DirWrite)
+		if werr != nil {
+			return werr
+		}
+		if !retry {
+			return err
+		}
+	}
+}
+
+// flusherRetry is the retry interval of a flusher after ErrWouldBlock when
+// there is no FlushLatency.
+const flusherRetry = 100 * time.Microsecond
+
+// StartFlusher starts a goroutine that owns the background flushing of the
+// write buffer, so synchronous callers get batching without a flush loop of
+// their own: FlushLatency after the first byte enters the empty buffer, or at
+// once without a latency, it writes the buffer out, and it keeps retrying
+// after ErrWouldBlock or ErrMore every FlushLatency (100µs without one). It
+// replaces the timer of WithFlushLatency while running.
+//
+// Other errors are passed to onError, when non-nil, from the flusher
+// goroutine, and otherwise reported by the next Write or Flush. onError may
+// call Close but not StopFlusher. StartFlusher
+// returns ErrInvalidArgument when the Writer has no write buffer or a flusher
+// is already running. Close stops the flusher.
+func (w *Writer) StartFlusher(onError func(error)) error {
+	c := w.fr.coal
+	if c == nil {
+		return ErrInvalidArgument
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.fl != nil {
+		return ErrInvalidArgument
+	}
+	if w.fr.closed.Load() {
+		return ErrClosed
+	}
+	fl := &flusher{c: c, onError: onError, wake: make(chan struct{}, 1), stop: make(chan struct{}), done: make(chan struct{})}
+	c.fl = fl
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	if len(c.buf) > 0 {
+		fl.kick()
+	}
+	go fl.run()
+	return nil
+}
+
+// StopFlusher stops the flusher goroutine and makes one attempt to write the
+// write buffer out, whose error it returns; ErrWouldBlock leaves the bytes to
+// the FlushLatency timer or the next Flush. It returns nil when no flusher is
+// running.
+func (w *Writer) StopFlusher() error {
+	c := w.fr.coal
+	if c == nil || !c.stopFlusher(true) {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if w.fr.closed.Load() {
+		return ErrClosed
+	}
+	if err := c.err; err != nil {
+		c.err = nil
+		return err
+	}
+	err := c.flushLocked(w.fr.wio)
+	if len(c.buf) > 0 && c.latency > 0 {
+		c.arm()
+	}
+	return err
+}
+
+// stopFlusher stops the running flusher and reports whether there was one.
+// With wait it returns once the flusher goroutine has exited.
+func (c *coalescer) stopFlusher(wait bool) bool {
+	c.mu.Lock()
+	fl := c.fl
+	c.fl = nil
+	c.mu.Unlock()
+	if fl == nil {
+		return false
+	}
+	close(fl.stop)
+	if wait {
+		<-fl.done
+	}
+	return true
+}
+
+// flusher is the goroutine started by Writer.StartFlusher.
+type flusher struct {
+	c       *coalescer
+	onError func(error)
+	wake    chan struct{} // the buffer became non-empty
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// kick wakes the flusher; c.mu is held.
+func (fl *flusher) kick() {
+	select {
+	case fl.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (fl *flusher) run() {
+	defer close(fl.done)
+	c := fl.c
+	retry := c.latency
+	if retry <= 0 {
+		retry = flusherRetry
+	}
+	t := time.NewTimer(time.Hour)
+	t.Stop()
+	defer t.Stop()
+	for {
+		select {
+		case <-fl.stop:
+			return
+		case <-fl.wake:
+		}
+		delay := c.latency
+		for {
+			if delay > 0 {
+				t.Reset(delay)
+				select {
+				case <-fl.stop:
+					return
+				case <-t.C:
+				}
+			}
+			c.mu.Lock()
+			if c.fr.closed.Load() {
+				c.mu.Unlock()
+				return
+			}
+			err := c.flushLocked(c.fr.wio)
+			if err != nil && err != ErrWouldBlock && err != ErrMore && fl.onError == nil {
+				c.err = err
+			}
+			c.mu.Unlock()
+			if err == ErrWouldBlock || err == ErrMore {
+				delay = retry
+				continue
+			}
+			if err != nil && fl.onError != nil {
+				fl.onError(err)
+			}
+			break
+		}
+	}
+}
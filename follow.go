@@ -0,0 +1,60 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// defaultFollowPoll is the follow mode poll interval when none is given.
+const defaultFollowPoll = 100 * time.Millisecond
+
+// NewFollowReader returns a Reader of framed messages appended to a growing
+// file, in the manner of tail -f: where the file ends, including in the
+// middle of a message, it waits for more bytes instead of returning io.EOF,
+// checking every poll interval (100ms when poll is not positive). Cancelling
+// ctx ends the wait and makes Read return ctx.Err() from then on.
+//
+// r is typically an *os.File positioned at the first frame to read. Rotation
+// and truncation of the file are not detected.
+func NewFollowReader(ctx context.Context, r io.Reader, poll time.Duration, opts ...Option) io.Reader {
+	if poll <= 0 {
+		poll = defaultFollowPoll
+	}
+	return NewReader(&followReader{r: r, ctx: ctx, poll: poll}, opts...)
+}
+
+// followReader turns io.EOF of r into a wait for more data.
+type followReader struct {
+	r     io.Reader
+	ctx   context.Context
+	poll  time.Duration
+	timer *time.Timer
+}
+
+func (f *followReader) Read(p []byte) (int, error) {
+	for {
+		n, err := f.r.Read(p)
+		if err != io.EOF {
+			return n, err
+		}
+		if n > 0 {
+			return n, nil
+		}
+		if f.timer == nil {
+			f.timer = time.NewTimer(f.poll)
+		} else {
+			f.timer.Reset(f.poll)
+		}
+		select {
+		case <-f.ctx.Done():
+			f.timer.Stop()
+			return 0, f.ctx.Err()
+		case <-f.timer.C:
+		}
+	}
+}
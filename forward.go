@@ -5,7 +5,9 @@
 package framer
 
 import (
+	"errors"
 	"io"
+	"time"
 )
 
 // Forwarder relays framed messages from a source to a destination while
@@ -25,7 +27,8 @@ import (
 //     phase (read or write) but the forwarding of this message is incomplete.
 //   - Message boundaries are preserved: the destination sees exactly the same
 //     payload bytes as the source, encoded as one framed message on stream
-//     transports.
+//     transports. Header metadata such as a TLS record type (see Meta) is
+//     carried over.
 //
 // Semantics (SeqPacket/Datagram):
 //   - Treats one packet as one message unit per call. Reads one packet from src
@@ -34,12 +37,19 @@ import (
 //
 // Limits and buffer sizing:
 //   - The internal payload buffer is allocated during construction based on
-//     read-side limit (WithReadLimit). If ReadLimit is zero, a conservative
-//     default (64KiB) is used. There are no heap allocations in the steady-state
+//     read-side limit (WithReadLimit), or on the chunk size of
+//     WithChunkedForward. If neither is set, a conservative default (64KiB)
+//     is used. There are no heap allocations in the steady-state
 //     forwarding path.
 //   - If the current message exceeds the internal buffer capacity, ForwardOnce
-//     returns io.ErrShortBuffer. Callers can construct a new Forwarder with a
-//     larger ReadLimit to accommodate larger messages.
+//     returns io.ErrShortBuffer. Callers can raise the limit with SetReadLimit
+//     (or construct a new Forwarder with a larger ReadLimit) to accommodate
+//     larger messages, or enable WithChunkedForward.
+//   - With WithChunkedForward and stream protocols on both sides, an oversized
+//     message is relayed through the buffer in chunks instead: the destination
+//     header is written first, since the length is known up front, and the
+//     payload follows chunk by chunk with the same resume rules. n then counts
+//     payload bytes written to dst in the call.
 //   - If the current message exceeds the configured ReadLimit, ForwardOnce
 //     returns ErrTooLong.
 //
@@ -53,13 +63,31 @@ type Forwarder struct {
 	rr *framer // read-side state machine (uses rr.rd, rr.rpr)
 	ww *framer // write-side state machine (uses ww.wr, ww.wpr)
 
-	// Internal payload buffer reused across messages to ensure zero-alloc steady state.
-	buf []byte
+	// Internal payload buffer reused across messages to ensure zero-alloc
+	// steady state. buf follows room bytes of headroom for a routing prefix
+	// in head, the whole allocation.
+	head []byte
+	buf  []byte
+	out  []byte // framed payload of the message in the write phase
 
 	// Per-message state.
 	need  int   // payload length for current message
 	got   int   // bytes read into buf so far
-	state uint8 // 0: parse header, 1: read payload, 2: write frame
+	state uint8 // 0: parse header, 1: read payload, 2: write frame, 3: chunked header, 4: chunked payload
+
+	// Chunked relay of messages larger than buf (WithChunkedForward).
+	chunked bool
+	cn      int // bytes of the current chunk in buf
+	coff    int // bytes of the current chunk written to dst
+
+	// Message expiry (WithTTL).
+	ttl   time.Duration
+	stamp func(payload []byte) (time.Time, bool)
+
+	// Payload routing (WithRoutePrefix, WithRouteStrip).
+	room   int
+	prefix func(prefix, payload []byte)
+	strip  int
 
 	// EOF handling for packet-preserving protocols:
 	// some io.Reader implementations may return (n>0, io.EOF) on the final read.
@@ -73,12 +101,48 @@ type Forwarder struct {
 func NewForwarder(dst io.Writer, src io.Reader, opts ...Option) *Forwarder {
 	rr := newFramer(src, nil, opts...)
 	ww := newFramer(nil, dst, opts...)
+	var o Options
+	for _, fn := range opts {
+		fn(&o)
+	}
 	// Allocate internal buffer once to avoid allocations in steady state.
 	capHint := rr.readLimit
-	if capHint <= 0 {
+	if o.ForwardChunkSize > 0 {
+		capHint = int64(o.ForwardChunkSize)
+	} else if capHint <= 0 {
 		capHint = 64 * 1024
 	}
-	return &Forwarder{rr: rr, ww: ww, buf: make([]byte, capHint)}
+	room := 0
+	if o.RoutePrefix != nil {
+		room = max(o.RoutePrefixLen, 0)
+	}
+	head := rr.newBuf(room + int(capHint))
+	return &Forwarder{
+		rr: rr, ww: ww, head: head, buf: head[room:], chunked: o.ForwardChunkSize > 0,
+		ttl: o.TTL, stamp: o.TTLStamp,
+		room: room, prefix: o.RoutePrefix, strip: max(o.RouteStrip, 0),
+	}
+}
+
+// SetReadLimit changes the maximum accepted payload size of the source side.
+// Unless chunked relay is enabled, the internal buffer is grown, preserving
+// any in-flight bytes, when the new limit exceeds its capacity. In stream mode
+// a message already in flight completes under the previous limit.
+func (f *Forwarder) SetReadLimit(limit int) {
+	f.rr.readLimit = int64(limit)
+	if !f.chunked && limit > cap(f.buf) {
+		nb := f.rr.newBuf(f.room + limit)
+		copy(nb[f.room:], f.buf)
+		f.rr.freeBuf(f.head)
+		f.head, f.buf = nb, nb[f.room:]
+	}
+}
+
+// SetRetryDelay changes the would-block policy of both the source and the
+// destination side.
+func (f *Forwarder) SetRetryDelay(d time.Duration) {
+	f.rr.retryDelay = d
+	f.ww.retryDelay = d
 }
 
 // ForwardOnce forwards at most one message. See Forwarder docs for semantics.
@@ -89,11 +153,19 @@ func NewForwarder(dst io.Writer, src io.Reader, opts ...Option) *Forwarder {
 //   - During the write phase, n is the number of payload bytes written to dst
 //     in this call.
 func (f *Forwarder) ForwardOnce() (n int, err error) {
+	from := f.state
+	n, err = f.forwardOnce()
+	f.checkState(from)
+	return n, err
+}
+
+func (f *Forwarder) forwardOnce() (n int, err error) {
 	// If the source signaled EOF together with the previous (final) message,
 	// report EOF on the first idle call after that message was forwarded.
 	if f.state == 0 && f.eofPending {
 		return 0, io.EOF
 	}
+	entered := f.state
 
 	// Phase 0: drive header parse to learn payload length.
 	if f.state == 0 {
@@ -105,12 +177,17 @@ func (f *Forwarder) ForwardOnce() (n int, err error) {
 			if e != nil {
 				if e == io.ErrShortBuffer {
 					// Header parsed; rr.length holds the payload length.
-					if f.rr.length > int64(cap(f.buf)) {
+					oversized := f.rr.length > int64(cap(f.buf))
+					if oversized && (!f.chunked || f.ww.wpr.preserveBoundary() || f.routed()) {
 						return 0, io.ErrShortBuffer
 					}
 					f.need = int(f.rr.length)
 					f.got = 0
 					f.state = 1
+					if oversized {
+						f.cn, f.coff = 0, 0
+						f.state = 3
+					}
 				} else {
 					// EOF => no next message.
 					if e == io.EOF {
@@ -151,10 +228,15 @@ func (f *Forwarder) ForwardOnce() (n int, err error) {
 			rn, re := f.rr.read(f.buf[f.got:max])
 			f.got += rn
 			if re != nil {
-				switch re {
-				case ErrWouldBlock, ErrMore, ErrTooLong:
+				var te *TruncatedError
+				switch {
+				case re == ErrWouldBlock, re == ErrMore, re == ErrTooLong:
 					return rn, re
-				case io.EOF:
+				case errors.As(re, &te):
+					// Drop the cut-off packet.
+					f.got = 0
+					return 0, re
+				case re == io.EOF:
 					if f.got == 0 {
 						return 0, io.EOF
 					}
@@ -189,9 +271,68 @@ func (f *Forwarder) ForwardOnce() (n int, err error) {
 		}
 	}
 
+	// Phases 3 and 4: chunked relay of a message larger than the buffer.
+	if f.state == 3 {
+		f.ww.wmeta = f.rr.rmeta
+		if _, we := f.ww.writeHeader(int64(f.need)); we != nil {
+			return 0, we
+		}
+		f.state = 4
+	}
+	if f.state == 4 {
+		for {
+			if f.coff < f.cn {
+				wn, we := f.ww.writeChunk(f.buf[f.coff:f.cn])
+				f.coff += wn
+				n += wn
+				if we != nil {
+					return n, we
+				}
+				continue
+			}
+			if f.got == f.need {
+				break
+			}
+			rn, re := f.rr.readChunk(f.buf[:min(cap(f.buf), f.need-f.got)])
+			f.got += rn
+			f.cn, f.coff = rn, 0
+			if re != nil {
+				return n, re
+			}
+		}
+		f.state = 0
+		f.need = 0
+		f.got = 0
+		f.cn, f.coff = 0, 0
+		return n, nil
+	}
+
+	// Once a message has been read whole, drop it when it is older than
+	// the TTL, and apply the routing rules.
+	if f.state == 2 && entered != 2 {
+		var err error
+		drop := f.expired()
+		if !drop {
+			err = f.route()
+			drop = err != nil
+		}
+		if drop {
+			if f.eofAfterThis {
+				f.eofAfterThis = false
+				f.eofPending = true
+			}
+			f.state = 0
+			f.need = 0
+			f.got = 0
+			return 0, err
+		}
+	}
+
 	// Phase 2: write the payload as one framed message to destination.
 	if f.state == 2 {
-		wn, we := f.ww.write(f.buf[:f.need])
+		// The header metadata of the message read is written back.
+		f.ww.wmeta = f.rr.rmeta
+		wn, we := f.ww.write(f.out)
 		if we != nil {
 			if we == ErrWouldBlock || we == ErrMore {
 				return wn, we
@@ -212,3 +353,150 @@ func (f *Forwarder) ForwardOnce() (n int, err error) {
 	// If we reached here, the call advanced state but produced no I/O.
 	return 0, nil
 }
+
+// ForwardFor forwards complete messages until the time budget d is spent,
+// the source or destination would block, or an error occurs, and returns the
+// number of messages completed and the payload bytes written to dst in this
+// call. The budget is checked after every message, so one message is always
+// attempted and the call overruns d by at most one message. A scheduler can
+// thus interleave many Forwarders fairly on one goroutine.
+//
+// ErrWouldBlock, io.EOF and other errors are returned as from ForwardOnce; on
+// ErrWouldBlock the in-flight message resumes on the next call.
+func (f *Forwarder) ForwardFor(d time.Duration) (frames int, bytes int64, err error) {
+	deadline := time.Now().Add(d)
+	for {
+		n, err := f.ForwardOnce()
+		// n counts payload bytes written to dst when the message completed
+		// or the call stopped in a write phase.
+		if err == nil || f.state == 2 || f.state == 4 {
+			bytes += int64(n)
+		}
+		switch err {
+		case nil:
+			frames++
+		case ErrMore:
+			continue
+		default:
+			return frames, bytes, err
+		}
+		if !time.Now().Before(deadline) {
+			return frames, bytes, nil
+		}
+	}
+}
+
+// Transcode copies every message of src to dst until src reports io.EOF at
+// a message boundary, re-encoding the framing on the way, for gateways
+// between peers that use different header formats, byte orders or protocols:
+// each header is parsed in src's format and written in dst's, while the
+// payload streams through in chunks. It is CopyFrames with n < 0, and
+// resumes in the same way after ErrWouldBlock or ErrMore.
+func Transcode(dst *Writer, src *Reader) (frames int, bytes int64, err error) {
+	return CopyFrames(dst, src, -1)
+}
+
+// CopyFrames copies up to n messages from src to dst, writing each as exactly
+// one message, and returns the number of messages completed and the payload
+// bytes written to dst in this call. With n < 0 it copies until src reports
+// io.EOF at a message boundary, which is then not an error.
+//
+// Between stream transports the payload is relayed through the reader's
+// scratch buffer in chunks, so message size is bounded only by ReadLimit.
+// When either side preserves packet boundaries a message is copied whole and
+// must fit in the buffer (ReadLimit, or 64KiB), otherwise ErrTooLong.
+//
+// The header metadata of each message (see Meta) is copied with it, so
+// TLS records, PostgreSQL messages or AMQP frames keep their type, version
+// and channel.
+//
+// On ErrWouldBlock or ErrMore, CopyFrames returns the progress of this call;
+// calling it again with the same src and dst resumes the in-flight message.
+// The count n applies per call.
+func CopyFrames(dst *Writer, src *Reader, n int) (frames int, bytes int64, err error) {
+	if dst == nil || src == nil || dst.fr.wr == nil {
+		return 0, 0, ErrInvalidArgument
+	}
+	s, d := src.fr, dst.fr
+	if !s.enter() {
+		return 0, 0, ErrConcurrentUse
+	}
+	defer s.leave()
+	if !d.enter() {
+		return 0, 0, ErrConcurrentUse
+	}
+	defer d.leave()
+	if s.closed.Load() || d.closed.Load() {
+		return 0, 0, ErrClosed
+	}
+	defer func() { d.wmeta = Meta{} }()
+	whole := s.rpr.preserveBoundary() || d.wpr.preserveBoundary()
+	buf, err := s.scratch()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for {
+		if !s.cpOn {
+			if n >= 0 && frames >= n {
+				return frames, bytes, nil
+			}
+			var m int
+			var re error
+			if whole {
+				m, re = s.read(buf)
+				if re == io.ErrShortBuffer {
+					re = ErrTooLong
+				}
+				s.cpLen = int64(m)
+			} else {
+				m, re = s.readChunk(buf)
+				// readChunk resets the framer when the message completes.
+				s.cpLen = s.length
+				if s.offset == 0 {
+					s.cpLen = int64(m)
+				}
+			}
+			if re != nil && (whole || m == 0) {
+				if re == io.EOF && n < 0 {
+					re = nil
+				}
+				return frames, bytes, re
+			}
+			s.wtOff, s.wtLen, s.cpOn = 0, m, true
+		}
+
+		// The header metadata of the message being copied goes with it.
+		d.wmeta = s.rmeta
+		if d.wpr.preserveBoundary() {
+			wn, we := d.write(buf[:s.wtLen])
+			if we != nil {
+				return frames, bytes, we
+			}
+			bytes += int64(wn)
+			s.cpOn = false
+			frames++
+			continue
+		}
+		if _, we := d.writeHeader(s.cpLen); we != nil {
+			return frames, bytes, we
+		}
+		wn, we := d.writeChunk(buf[s.wtOff:s.wtLen])
+		s.wtOff += wn
+		bytes += int64(wn)
+		if we != nil {
+			return frames, bytes, we
+		}
+		if d.offset == 0 {
+			// writeChunk resets the framer when the frame completes.
+			s.cpOn = false
+			frames++
+			continue
+		}
+		m, re := s.readChunk(buf)
+		if re != nil && m == 0 {
+			return frames, bytes, re
+		}
+		s.wtOff, s.wtLen = 0, m
+	}
+}
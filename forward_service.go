@@ -0,0 +1,233 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// relayBudget bounds the messages a worker forwards, or the ErrMore results
+// it retries, on one relay before requeueing it, so a busy relay cannot
+// starve the others.
+const relayBudget = 64
+
+// Relay states.
+const (
+	relayIdle    uint32 = iota // parked until Wake
+	relayQueued                // in the run queue
+	relayRunning               // owned by a worker
+	relayWoken                 // owned by a worker and woken while running
+	relayDone
+)
+
+// ForwardService drives many Forwarders with a fixed number of worker
+// goroutines instead of one goroutine per relay.
+//
+// The service is readiness-driven but poller-agnostic: a relay runs until its
+// Forwarder reports ErrWouldBlock and is then parked. The application's event
+// loop calls Relay.Wake when the source becomes readable or the destination
+// becomes writable, and a worker resumes the in-flight message. Forwarders
+// added to the service must be nonblocking (the default RetryDelay), otherwise
+// a blocked relay occupies a worker.
+//
+// ErrMore is treated as progress and counts against the relay's turn like a
+// forwarded message. Any other error finishes the relay; io.EOF is reported
+// as a nil error. Relay.Remove stops a single relay.
+type ForwardService struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []*Relay
+	relays map[*Relay]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// Relay is a Forwarder registered with a ForwardService.
+type Relay struct {
+	f       *Forwarder
+	svc     *ForwardService
+	done    func(error)
+	state   atomic.Uint32
+	removed atomic.Bool
+}
+
+// NewForwardService starts a service with the given number of workers. A
+// non-positive count starts one worker.
+func NewForwardService(workers int) *ForwardService {
+	if workers <= 0 {
+		workers = 1
+	}
+	s := &ForwardService{relays: make(map[*Relay]struct{})}
+	s.cond = sync.NewCond(&s.mu)
+	s.wg.Add(workers)
+	for range workers {
+		go s.work()
+	}
+	return s
+}
+
+// Add registers f and schedules it to run. done, when non-nil, is called once
+// from a worker when the relay finishes, or from Close.
+func (s *ForwardService) Add(f *Forwarder, done func(error)) (*Relay, error) {
+	if f == nil {
+		return nil, ErrInvalidArgument
+	}
+	r := &Relay{f: f, svc: s, done: done}
+	r.state.Store(relayQueued)
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil, ErrClosed
+	}
+	s.relays[r] = struct{}{}
+	s.queue = append(s.queue, r)
+	s.mu.Unlock()
+	s.cond.Signal()
+	return r, nil
+}
+
+// Len returns the number of registered relays.
+func (s *ForwardService) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.relays)
+}
+
+// Close stops the workers after their current run and waits for them. Relays
+// still registered are dropped and their done callbacks receive
+// ErrClosed. Close does not close the relays' transports.
+func (s *ForwardService) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+	s.cond.Broadcast()
+	s.wg.Wait()
+
+	s.mu.Lock()
+	left := s.relays
+	s.relays = nil
+	s.queue = nil
+	s.mu.Unlock()
+	for r := range left {
+		r.state.Store(relayDone)
+		if r.done != nil {
+			r.done(ErrClosed)
+		}
+	}
+	return nil
+}
+
+// Wake reports that the relay's source or destination may be ready. It is
+// safe to call from any goroutine, at any time, any number of times; spurious
+// wakes only cost a ForwardOnce call.
+func (r *Relay) Wake() {
+	for {
+		switch st := r.state.Load(); st {
+		case relayIdle:
+			if r.state.CompareAndSwap(relayIdle, relayQueued) {
+				r.svc.enqueue(r)
+				return
+			}
+		case relayRunning:
+			if r.state.CompareAndSwap(relayRunning, relayWoken) {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// Remove deregisters the relay: a worker running it stops after the
+// ForwardOnce call in progress, and it is never run again. The done callback
+// receives ErrClosed, from Remove, unless the relay has finished already.
+// Remove does not close the relay's transports.
+func (r *Relay) Remove() {
+	r.removed.Store(true)
+	r.svc.finish(r, ErrClosed)
+}
+
+// Forwarder returns the relay's Forwarder.
+func (r *Relay) Forwarder() *Forwarder { return r.f }
+
+func (s *ForwardService) enqueue(r *Relay) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.queue = append(s.queue, r)
+	s.mu.Unlock()
+	s.cond.Signal()
+}
+
+func (s *ForwardService) work() {
+	defer s.wg.Done()
+	for {
+		s.mu.Lock()
+		for len(s.queue) == 0 && !s.closed {
+			s.cond.Wait()
+		}
+		if s.closed {
+			s.mu.Unlock()
+			return
+		}
+		r := s.queue[0]
+		s.queue[0] = nil
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+		s.run(r)
+	}
+}
+
+// run forwards on r until it would block, finishes, or exhausts its budget.
+func (s *ForwardService) run(r *Relay) {
+	r.state.Store(relayRunning)
+	for budget := relayBudget; ; {
+		if r.removed.Load() {
+			r.state.Store(relayDone)
+			return
+		}
+		_, err := r.f.ForwardOnce()
+		switch err {
+		case nil, ErrMore:
+			budget--
+			if budget > 0 {
+				continue
+			}
+			r.state.Store(relayQueued)
+			s.enqueue(r)
+			return
+		case ErrWouldBlock:
+			if r.state.CompareAndSwap(relayRunning, relayIdle) {
+				return
+			}
+			// Woken while running: the readiness may postdate the failed attempt.
+			r.state.Store(relayRunning)
+			continue
+		case io.EOF:
+			err = nil
+		}
+		s.finish(r, err)
+		return
+	}
+}
+
+func (s *ForwardService) finish(r *Relay, err error) {
+	r.state.Store(relayDone)
+	s.mu.Lock()
+	_, ok := s.relays[r]
+	delete(s.relays, r)
+	s.mu.Unlock()
+	if ok && r.done != nil {
+		r.done(err)
+	}
+}
@@ -0,0 +1,185 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	fr "code.hybscloud.com/framer"
+)
+
+// gatedSource is a nonblocking source fed by the test, standing in for a
+// socket whose readiness is reported by an event loop.
+type gatedSource struct {
+	mu  sync.Mutex
+	buf []byte
+	eof bool
+}
+
+func (s *gatedSource) Read(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.buf) == 0 {
+		if s.eof {
+			return 0, io.EOF
+		}
+		return 0, fr.ErrWouldBlock
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+func (s *gatedSource) feed(b []byte, eof bool) {
+	s.mu.Lock()
+	s.buf = append(s.buf, b...)
+	s.eof = eof
+	s.mu.Unlock()
+}
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.buf.Bytes()...)
+}
+
+func waitDone(t *testing.T, done <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("relay did not finish")
+		return nil
+	}
+}
+
+func TestForwardService_ParksAndResumesOnWake(t *testing.T) {
+	svc := fr.NewForwardService(2)
+	defer svc.Close()
+
+	const relays = 16
+	srcs := make([]*gatedSource, relays)
+	dsts := make([]*syncBuffer, relays)
+	handles := make([]*fr.Relay, relays)
+	done := make(chan error, relays)
+	for i := range relays {
+		srcs[i] = &gatedSource{}
+		dsts[i] = &syncBuffer{}
+		f := fr.NewForwarder(dsts[i], srcs[i], fr.WithProtocol(fr.BinaryStream))
+		h, err := svc.Add(f, func(err error) { done <- err })
+		if err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+		handles[i] = h
+	}
+	if svc.Len() != relays {
+		t.Fatalf("Len=%d want %d", svc.Len(), relays)
+	}
+
+	// Deliver each frame in two halves; every half is followed by a wake.
+	for i := range relays {
+		srcs[i].feed([]byte{3, 'a'}, false)
+		handles[i].Wake()
+	}
+	for i := range relays {
+		srcs[i].feed([]byte{'b', byte('0' + i%10)}, true)
+		handles[i].Wake()
+	}
+	for range relays {
+		if err := waitDone(t, done); err != nil {
+			t.Fatalf("relay finished with %v", err)
+		}
+	}
+	for i := range relays {
+		want := []byte{3, 'a', 'b', byte('0' + i%10)}
+		if got := dsts[i].Bytes(); !bytes.Equal(got, want) {
+			t.Fatalf("relay %d: dst=%v want %v", i, got, want)
+		}
+	}
+	if svc.Len() != 0 {
+		t.Fatalf("Len=%d after all relays finished", svc.Len())
+	}
+}
+
+func TestForwardService_ErrorAndClose(t *testing.T) {
+	svc := fr.NewForwardService(1)
+
+	errBoom := errors.New("boom")
+	done := make(chan error, 2)
+	bad := fr.NewForwarder(failWriter{err: errBoom}, bytes.NewReader([]byte{1, 'x'}), fr.WithProtocol(fr.BinaryStream))
+	if _, err := svc.Add(bad, func(err error) { done <- err }); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := waitDone(t, done); !errors.Is(err, errBoom) {
+		t.Fatalf("want errBoom, got %v", err)
+	}
+
+	idle := fr.NewForwarder(io.Discard, &gatedSource{}, fr.WithProtocol(fr.BinaryStream))
+	if _, err := svc.Add(idle, func(err error) { done <- err }); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := svc.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := waitDone(t, done); err != fr.ErrClosed {
+		t.Fatalf("parked relay: want ErrClosed, got %v", err)
+	}
+	if _, err := svc.Add(idle, nil); err != fr.ErrClosed {
+		t.Fatalf("Add after Close: want ErrClosed, got %v", err)
+	}
+}
+
+// moreWriter always asks to be called again without taking a byte.
+type moreWriter struct{}
+
+func (moreWriter) Write([]byte) (int, error) { return 0, fr.ErrMore }
+
+func TestForwardService_ErrMoreYieldsAndRemove(t *testing.T) {
+	svc := fr.NewForwardService(1)
+	defer svc.Close()
+
+	spinDone := make(chan error, 1)
+	spin := fr.NewForwarder(moreWriter{}, bytes.NewReader([]byte{1, 'x'}), fr.WithProtocol(fr.BinaryStream))
+	h, err := svc.Add(spin, func(err error) { spinDone <- err })
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	// The only worker must still get to the second relay.
+	done := make(chan error, 1)
+	var out syncBuffer
+	ok := fr.NewForwarder(&out, bytes.NewReader([]byte{1, 'y'}), fr.WithProtocol(fr.BinaryStream))
+	if _, err := svc.Add(ok, func(err error) { done <- err }); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := waitDone(t, done); err != nil || !bytes.Equal(out.Bytes(), []byte{1, 'y'}) {
+		t.Fatalf("starved relay: err=%v out=%v", err, out.Bytes())
+	}
+
+	h.Remove()
+	if err := waitDone(t, spinDone); err != fr.ErrClosed {
+		t.Fatalf("removed relay: want ErrClosed, got %v", err)
+	}
+	if svc.Len() != 0 {
+		t.Fatalf("Len=%d after Remove", svc.Len())
+	}
+	h.Remove() // no second callback
+}
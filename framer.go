@@ -27,12 +27,23 @@
 //   - 65536 <= L <= 2^56-1: header[0] = 0xFF; next 7 bytes encode lower 56 bits of L
 //     in the configured byte order
 // Maximum supported payload is 2^56-1; larger values produce ErrTooLong. A per-reader
-// limit can be set via WithReadLimit.
+// limit can be set via WithReadLimit. Other length prefixes (HeaderFixed16,
+// HeaderFixed32, HeaderFixed64, HeaderUvarint) are selected with
+// WithHeaderFormat or WithProfile.
+//
+// Event loops: iox defines the ErrWouldBlock/ErrMore semantics but provides no
+// poller, so there is no readiness registration in this package. A reactor
+// either keeps the default nonblocking mode and calls Read/Write again when its
+// poller reports the fd ready (message state is kept per instance), or installs
+// WithWaitFunc to park on its poller inside the call.
 
 package framer
 
 import (
+	"bytes"
+	"errors"
 	"io"
+	"time"
 
 	"code.hybscloud.com/iox"
 )
@@ -47,16 +58,68 @@ func NewWriter(w io.Writer, opts ...Option) io.Writer {
 	return &Writer{fr: newFramer(nil, w, opts...)}
 }
 
+// NewReaderSize is like NewReader but allocates the scratch buffer of WriteTo,
+// ReadTo and CopyFrames at construction, of at least size bytes and at least
+// ReadLimit (64KiB when there is none), so the first use of those fast paths
+// does not allocate.
+func NewReaderSize(r io.Reader, size int, opts ...Option) io.Reader {
+	fr := newFramer(r, nil, opts...)
+	fr.rbuf = fr.newBuf(max(size, fr.scratchSize()))
+	return &Reader{fr: fr}
+}
+
+// NewWriterSize is like NewWriter but allocates the scratch buffers of
+// ReadFrom, WriteFrom, WriteTyped and packet-mode Writev at construction:
+// ReadFrom and WriteFrom copy through size bytes at a time (32KiB when size
+// is not positive), and messages of WriteTyped and Writev up to size bytes
+// are assembled without allocating.
+func NewWriterSize(w io.Writer, size int, opts ...Option) io.Writer {
+	fr := newFramer(nil, w, opts...)
+	if size > 0 {
+		fr.wbuf, fr.tbuf = fr.newBuf(size), fr.newBuf(size)
+	} else {
+		fr.wbuf = fr.newBuf(32 * 1024)
+	}
+	return &Writer{fr: fr}
+}
+
+// NewReadCloser returns an io.ReadCloser that reads framed messages from rc.
+// Its Close abandons a message in flight and closes rc; see Reader.Close.
+func NewReadCloser(rc io.ReadCloser, opts ...Option) io.ReadCloser {
+	return &Reader{fr: newFramer(rc, nil, opts...)}
+}
+
+// NewWriteCloser returns an io.WriteCloser that writes framed messages to wc.
+// Its Close flushes buffered frames, abandons a message in flight and closes
+// wc; see Writer.Close.
+func NewWriteCloser(wc io.WriteCloser, opts ...Option) io.WriteCloser {
+	return &Writer{fr: newFramer(nil, wc, opts...)}
+}
+
 // NewReadWriter returns an io.ReadWriter that reads and writes framed messages.
+// Each direction keeps its own message state, so a message in flight on one
+// side is not affected by the other.
 func NewReadWriter(r io.Reader, w io.Writer, opts ...Option) io.ReadWriter {
-	fr := newFramer(r, w, opts...)
-	return &ReadWriter{Reader: &Reader{fr: fr}, Writer: &Writer{fr: fr}}
+	rw := &ReadWriter{
+		Reader: &Reader{fr: newFramer(r, nil, opts...)},
+		Writer: &Writer{fr: newFramer(nil, w, opts...)},
+	}
+	linkPing(rw.Reader.fr, rw.Writer.fr)
+	linkSequence(rw.Reader.fr, rw.Writer.fr)
+	linkDetected(rw.Reader.fr, rw.Writer.fr)
+	return rw
 }
 
 // NewPipe returns a synchronous in-memory framing pipe.
+//
+// Both returned values also implement io.Closer and
+// CloseWithError(error) error. Closing stops further writes; the reading
+// side observes io.EOF, or the given error, once the message in transit has
+// been read. A plain Close in the middle of a message surfaces as
+// io.ErrUnexpectedEOF.
 func NewPipe(opts ...Option) (reader io.Reader, writer io.Writer) {
 	r, w := io.Pipe()
-	pipe := NewReadWriter(r, w, opts...)
+	pipe := &syncPipe{ReadWriter: NewReadWriter(r, w, opts...).(*ReadWriter), pw: w}
 	return pipe, pipe
 }
 
@@ -69,7 +132,179 @@ type Reader struct{ fr *framer }
 // In SeqPacket/Datagram mode, WithReadLimit is enforced after one transport
 // read, so an oversized packet may return (n > limit, ErrTooLong); n still
 // reports consumed bytes for caller-side accounting.
-func (r *Reader) Read(p []byte) (int, error) { return r.fr.read(p) }
+func (r *Reader) Read(p []byte) (int, error) {
+	fr := r.fr
+	if !fr.enter() {
+		return 0, ErrConcurrentUse
+	}
+	defer fr.leave()
+	if fr.rx != nil {
+		return fr.readIntercepted(p)
+	}
+	return fr.read(p)
+}
+
+// ReadAlloc reads the next message into a newly allocated buffer of exactly
+// its size, for consumers that cannot predict message sizes. In stream mode
+// the buffer is sized from the header; in packet-preserving modes the packet
+// is read into the scratch buffer of WriteTo and copied. The buffer is a
+// heap slice owned by the caller, never taken from the Allocator.
+// Messages are bounded by ReadLimit, or 64KiB when there is none: a stream
+// header announcing more fails with ErrTooLong before anything is allocated,
+// so a peer cannot make ReadAlloc allocate what its header claims.
+//
+// On ErrWouldBlock or ErrMore the partial message is kept and the next
+// ReadAlloc call resumes it; do not interleave Read calls in between. Read
+// interceptors apply to the returned message.
+func (r *Reader) ReadAlloc() ([]byte, error) {
+	fr := r.fr
+	if !fr.enter() {
+		return nil, ErrConcurrentUse
+	}
+	defer fr.leave()
+	return fr.readAlloc()
+}
+
+// ReadBuffer appends the payload of the next message to b, growing it as
+// needed, and returns the number of bytes appended. The payload is read
+// straight into the spare capacity of b, without an intermediate buffer.
+// In packet-preserving modes b is first grown by ReadLimit, or 64KiB when
+// there is none, since the packet size is unknown until it is read. Messages
+// are bounded by the same size: a stream header announcing more fails with
+// ErrTooLong before b is grown.
+//
+// On ErrWouldBlock or ErrMore, nothing is appended yet: retry with the same
+// b, unchanged in between, and the message is resumed; a b changed in between
+// fails with ErrInvalidArgument. Read interceptors apply to the appended
+// message.
+func (r *Reader) ReadBuffer(b *bytes.Buffer) (int, error) {
+	fr := r.fr
+	if !fr.enter() {
+		return 0, ErrConcurrentUse
+	}
+	defer fr.leave()
+	if b == nil {
+		return 0, ErrInvalidArgument
+	}
+	return fr.readBuffer(b)
+}
+
+// Buffered returns the number of bytes already read from the transport but
+// not yet delivered: read-ahead bytes of WithPrefetch and the unread packets
+// of a bundled datagram (WithBundling). When it is positive, or InFrame
+// reports a message in progress, another Read can make progress without
+// waiting for the transport to become readable, so event loops should call
+// Read again before re-arming readiness. Like Read, it must not be called
+// concurrently with other reads.
+func (r *Reader) Buffered() int { return r.fr.buffered() }
+
+// InFrame reports whether a stream message is partially read: its header or
+// payload has started arriving and the next Read resumes it.
+func (r *Reader) InFrame() bool { return r.fr.offset > 0 }
+
+// SetReadLimit changes the maximum accepted payload size (see WithReadLimit).
+//
+// In stream mode the limit is checked when a message header is parsed, so a
+// message already in flight completes under the previous limit and the new
+// limit applies from the next message on. SetReadLimit must not be called
+// concurrently with Read or WriteTo.
+func (r *Reader) SetReadLimit(limit int) { r.fr.setReadLimit(limit) }
+
+// SetRetryDelay changes the would-block policy of the read side (see
+// WithRetryDelay). It must not be called concurrently with Read or WriteTo.
+func (r *Reader) SetRetryDelay(d time.Duration) { r.fr.retryDelay = d }
+
+// SwapReader replaces the underlying transport, e.g. after a reconnect, and
+// returns the previous one. Options, limits and reusable buffers are kept.
+//
+// A message partially received from the previous transport is discarded, so
+// the next Read starts at a message boundary of src. Payload bytes that WriteTo
+// has already read but not yet delivered to its destination are kept.
+// SwapReader must not be called concurrently with Read or WriteTo.
+func (r *Reader) SwapReader(src io.Reader) io.Reader { return r.fr.swapReader(src) }
+
+// Close closes the Reader and, when it implements io.Closer, the underlying
+// reader. Later operations, and calls waiting on the transport, return
+// ErrClosed.
+func (r *Reader) Close() error { return r.fr.close(r.fr.rd) }
+
+// ReadTo copies the payload of exactly one message into dst and returns the
+// number of payload bytes written. In stream mode the payload passes through
+// a reusable scratch buffer in chunks, so messages larger than the buffer are
+// streamed rather than held in memory; ReadLimit still bounds the accepted
+// length. A clean EOF before the next message returns io.EOF.
+//
+// On ErrWouldBlock or ErrMore from either side, ReadTo returns the progress
+// of this call; calling it again resumes the same message.
+func (r *Reader) ReadTo(dst io.Writer) (int64, error) {
+	fr := r.fr
+	if !fr.enter() {
+		return 0, ErrConcurrentUse
+	}
+	defer fr.leave()
+	if fr.closed.Load() {
+		return 0, ErrClosed
+	}
+	if dst == nil {
+		return 0, ErrInvalidArgument
+	}
+	if _, err := fr.scratch(); err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for {
+		// Drain the chunk held back by a previous ErrWouldBlock/ErrMore.
+		for fr.wtOff < fr.wtLen {
+			wn, we := dst.Write(fr.rbuf[fr.wtOff:fr.wtLen])
+			fr.wtOff += wn
+			total += int64(wn)
+			if we != nil {
+				if we != ErrWouldBlock && we != ErrMore {
+					fr.wtOff, fr.wtLen, fr.rtDone = 0, 0, false
+				}
+				return total, we
+			}
+			if wn == 0 {
+				fr.wtOff, fr.wtLen, fr.rtDone = 0, 0, false
+				return total, io.ErrShortWrite
+			}
+		}
+		fr.wtOff, fr.wtLen = 0, 0
+		if fr.rtDone {
+			fr.rtDone = false
+			return total, nil
+		}
+
+		if fr.rpr.preserveBoundary() {
+			n, err := fr.read(fr.rbuf)
+			if err != nil {
+				return total, err
+			}
+			fr.wtLen, fr.rtDone = n, true
+			continue
+		}
+		n, err := fr.readChunk(fr.rbuf)
+		if err != nil {
+			if n == 0 {
+				return total, err
+			}
+			// Hand over what was read, then report err.
+			wn, we := writeFull(dst, fr.rbuf[:n])
+			total += int64(wn)
+			if we == ErrWouldBlock || we == ErrMore {
+				fr.wtOff, fr.wtLen = wn, n
+				return total, we
+			}
+			if we != nil {
+				return total, we
+			}
+			return total, err
+		}
+		// readChunk resets the framer once the last payload byte is read.
+		fr.wtLen, fr.rtDone = n, fr.offset == 0
+	}
+}
 
 // WriteTo implements io.WriterTo.
 //
@@ -88,6 +323,13 @@ func (r *Reader) Read(p []byte) (int, error) { return r.fr.read(p) }
 // the same semantic error. Short writes on dst are handled per io.Writer contract.
 func (r *Reader) WriteTo(dst io.Writer) (int64, error) {
 	fr := r.fr
+	if !fr.enter() {
+		return 0, ErrConcurrentUse
+	}
+	defer fr.leave()
+	if fr.closed.Load() {
+		return 0, ErrClosed
+	}
 	var total int64
 
 	// Packet-preserving protocols: pass-through copy using a stack buffer.
@@ -129,13 +371,9 @@ func (r *Reader) WriteTo(dst io.Writer) (int64, error) {
 	}
 
 	// Stream protocol: copy one framed message at a time.
-	if fr.rbuf == nil {
-		// Allocate scratch buffer once per framer instance. Zero alloc steady-state.
-		capHint := fr.readLimit
-		if capHint <= 0 {
-			capHint = 64 * 1024
-		}
-		fr.rbuf = make([]byte, capHint)
+	// Allocate scratch buffer once per framer instance. Zero alloc steady-state.
+	if _, err := fr.scratch(); err != nil {
+		return 0, err
 	}
 
 	for {
@@ -213,6 +451,18 @@ func (r *Reader) WriteTo(dst io.Writer) (int64, error) {
 				if e == io.EOF {
 					return total, io.ErrUnexpectedEOF
 				}
+				if te, ok := e.(*TruncatedError); ok {
+					// Deliver the received part before reporting truncation.
+					wn, we := writeFull(dst, fr.rbuf[:te.Received])
+					total += int64(wn)
+					if we == ErrWouldBlock || we == ErrMore {
+						fr.wtOff = wn
+						fr.wtLen = int(te.Received)
+					}
+					if we != nil {
+						return total, we
+					}
+				}
 				return total, e
 			}
 			// readStream calls fr.reset() on completion, so fr.offset becomes 0.
@@ -252,7 +502,143 @@ func (r *Reader) WriteTo(dst io.Writer) (int64, error) {
 // Writer writes framed messages.
 type Writer struct{ fr *framer }
 
-func (w *Writer) Write(p []byte) (int, error) { return w.fr.write(p) }
+func (w *Writer) Write(p []byte) (int, error) {
+	fr := w.fr
+	if !fr.enter() {
+		return 0, ErrConcurrentUse
+	}
+	defer fr.leave()
+	if fr.wx != nil || fr.wvx != nil {
+		return fr.writeIntercepted(p)
+	}
+	return fr.write(p)
+}
+
+// WriteValue is Write with v attached to the message: the interceptors added
+// with WithWriteValueInterceptor receive v along with the payload, so
+// request-scoped data such as a trace span or an accounting key reaches the
+// interceptors of exactly this message without global maps. Those
+// interceptors are the only consumers of v: it is not sent, so the Reader,
+// its read interceptors and a Forwarder relaying the message never see it.
+// On ErrWouldBlock or ErrMore, retry with the same v and p; the interceptors
+// do not run again.
+func (w *Writer) WriteValue(v any, p []byte) (int, error) {
+	fr := w.fr
+	if !fr.enter() {
+		return 0, ErrConcurrentUse
+	}
+	defer fr.leave()
+	if fr.wx == nil && fr.wvx == nil {
+		return fr.write(p)
+	}
+	fr.wval = v
+	defer func() { fr.wval = nil }()
+	return fr.writeIntercepted(p)
+}
+
+// Writev writes the concatenation of bufs as one message without copying it
+// into a contiguous buffer in stream mode: the header is computed from the
+// total length and the slices follow one by one. Packet-preserving protocols
+// need the message in one transport write, so the slices are gathered in a
+// reusable scratch buffer first.
+//
+// The returned count is the number of payload bytes written in this call. On
+// ErrWouldBlock or ErrMore, retry with the same slices.
+func (w *Writer) Writev(bufs ...[]byte) (int, error) {
+	fr := w.fr
+	if !fr.enter() {
+		return 0, ErrConcurrentUse
+	}
+	defer fr.leave()
+	return fr.writev(bufs)
+}
+
+// SwapWriter replaces the underlying transport, e.g. after a reconnect, and
+// returns the previous one. Options and reusable buffers are kept.
+//
+// A message partially written to the previous transport is abandoned: the
+// next Write starts a new frame on dst, so a caller interrupted mid-message
+// must write that whole message again. SwapWriter must not be called
+// concurrently with Write or ReadFrom.
+func (w *Writer) SwapWriter(dst io.Writer) io.Writer { return w.fr.swapWriter(dst) }
+
+// SetRetryDelay changes the would-block policy of the write side (see
+// WithRetryDelay). It must not be called concurrently with Write or ReadFrom.
+func (w *Writer) SetRetryDelay(d time.Duration) { w.fr.retryDelay = d }
+
+// Close closes the Writer and, when it implements io.Closer, the underlying
+// writer. Buffered frames are flushed first; a message in flight is
+// abandoned, so the peer sees it truncated. Later operations, and calls
+// waiting on the transport, return ErrClosed.
+func (w *Writer) Close() error { return w.fr.close(w.fr.wr) }
+
+// WriteFrom writes one message of exactly n payload bytes copied from r,
+// without holding the payload in memory: the header for n is written first
+// and the payload follows through a reusable scratch buffer.
+//
+// If r ends before n bytes, WriteFrom returns io.ErrUnexpectedEOF; the
+// destination then holds an incomplete frame and the stream cannot be used
+// for further messages. In packet-preserving modes the message must go out
+// in one transport write, so the n bytes are gathered first.
+//
+// The returned count is the number of payload bytes written in this call. On
+// ErrWouldBlock or ErrMore, retry with the same r and n; bytes already taken
+// from r are kept.
+func (w *Writer) WriteFrom(r io.Reader, n int64) (int64, error) {
+	fr := w.fr
+	if !fr.enter() {
+		return 0, ErrConcurrentUse
+	}
+	defer fr.leave()
+	if fr.closed.Load() {
+		return 0, ErrClosed
+	}
+	if fr.wr == nil || r == nil || n < 0 {
+		return 0, ErrInvalidArgument
+	}
+	if fr.wpr.preserveBoundary() {
+		return fr.writePacketFrom(r, n)
+	}
+	if _, err := fr.writeHeader(n); err != nil {
+		return 0, err
+	}
+	if fr.wbuf == nil {
+		var err error
+		if fr.wbuf, err = fr.growBuf(nil, 32*1024); err != nil {
+			return 0, err
+		}
+	}
+
+	var total int64
+	// writeChunk resets the framer when the frame completes, so a zero
+	// offset after the header means done.
+	for fr.offset != 0 {
+		if fr.wfOff < fr.wfLen {
+			wn, we := fr.writeChunk(fr.wbuf[fr.wfOff:fr.wfLen])
+			fr.wfOff += wn
+			total += int64(wn)
+			if we != nil {
+				return total, we
+			}
+			continue
+		}
+		hdrSize, _ := fr.wireHeader(fr.length)
+		rem := hdrSize + fr.length - fr.offset
+		if rem == 0 {
+			_, err := fr.writeChunk(nil)
+			return total, err
+		}
+		rn, re := r.Read(fr.wbuf[:min(int64(len(fr.wbuf)), rem)])
+		fr.wfOff, fr.wfLen = 0, rn
+		if re != nil && rn == 0 {
+			if re == io.EOF {
+				return total, io.ErrUnexpectedEOF
+			}
+			return total, re
+		}
+	}
+	return total, nil
+}
 
 // ReadFrom implements io.ReaderFrom.
 //
@@ -271,9 +657,19 @@ func (w *Writer) Write(p []byte) (int, error) { return w.fr.write(p) }
 // before reading new data from src.
 func (w *Writer) ReadFrom(src io.Reader) (int64, error) {
 	fr := w.fr
+	if !fr.enter() {
+		return 0, ErrConcurrentUse
+	}
+	defer fr.leave()
+	if fr.closed.Load() {
+		return 0, ErrClosed
+	}
 	// Reuse a per-framer buffer to guarantee zero allocs/op.
 	if fr.wbuf == nil {
-		fr.wbuf = make([]byte, 32*1024)
+		var err error
+		if fr.wbuf, err = fr.growBuf(nil, 32*1024); err != nil {
+			return 0, err
+		}
 	}
 	buf := fr.wbuf
 
@@ -285,15 +681,7 @@ func (w *Writer) ReadFrom(src io.Reader) (int64, error) {
 		// We must also verify the write is actually incomplete by checking offset < totalSize.
 		if fr.offset > 0 && fr.length > 0 {
 			// Calculate expected total frame size to verify write is incomplete.
-			// Header size depends on payload length.
-			var hdrSize int64 = 1 // frameHeaderLen
-			if fr.length > 253 {  // framePayloadMaxLen8Bits
-				if fr.length <= 65535 { // framePayloadMaxLen16
-					hdrSize += 2
-				} else {
-					hdrSize += 7
-				}
-			}
+			hdrSize, _ := fr.wireHeader(fr.length)
 			totalSize := hdrSize + fr.length
 			if fr.offset < totalSize {
 				// Resume the in-flight write using the buffered data.
@@ -359,6 +747,21 @@ type ReadWriter struct {
 	*Writer
 }
 
+// Close closes both directions. A transport shared by both is closed once.
+func (rw *ReadWriter) Close() error {
+	if sameTransport(rw.Reader.fr.rd, rw.Writer.fr.wr) {
+		_ = rw.Writer.fr.close(nil)
+		return rw.Reader.Close()
+	}
+	return errors.Join(rw.Reader.Close(), rw.Writer.Close())
+}
+
+// SetRetryDelay changes the would-block policy of both directions.
+func (rw *ReadWriter) SetRetryDelay(d time.Duration) {
+	rw.Reader.SetRetryDelay(d)
+	rw.Writer.SetRetryDelay(d)
+}
+
 // These are provided as package-level aliases so callers can reference the
 // semantic control-flow errors without importing iox directly.
 var (
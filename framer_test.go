@@ -7,8 +7,13 @@ package framer_test
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"io"
 	"net"
+	"os"
+	"reflect"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 	"unsafe"
@@ -265,6 +270,16 @@ func TestNetOpts_AllHelpers(t *testing.T) {
 		t.Fatalf("WriteSCTP mismatch")
 	}
 
+	framer.WithReadKCP()(&o)
+	if o.ReadProto != framer.SeqPacket || o.ReadByteOrder != binary.BigEndian {
+		t.Fatalf("ReadKCP mismatch")
+	}
+
+	framer.WithWriteKCP()(&o)
+	if o.WriteProto != framer.SeqPacket || o.WriteByteOrder != binary.BigEndian {
+		t.Fatalf("WriteKCP mismatch")
+	}
+
 	framer.WithReadUnix()(&o)
 	if o.ReadProto != framer.BinaryStream || o.ReadByteOrder != binary.BigEndian {
 		t.Fatalf("ReadUnix mismatch")
@@ -285,6 +300,16 @@ func TestNetOpts_AllHelpers(t *testing.T) {
 		t.Fatalf("WriteUnixPacket mismatch")
 	}
 
+	framer.WithReadUnixSeqpacket()(&o)
+	if o.ReadProto != framer.SeqPacket || o.ReadByteOrder != binary.BigEndian {
+		t.Fatalf("ReadUnixSeqpacket mismatch")
+	}
+
+	framer.WithWriteUnixSeqpacket()(&o)
+	if o.WriteProto != framer.SeqPacket || o.WriteByteOrder != binary.BigEndian {
+		t.Fatalf("WriteUnixSeqpacket mismatch")
+	}
+
 	// Local (native endianness)
 	framer.WithReadLocal()(&o)
 	if o.ReadProto != framer.BinaryStream || o.ReadByteOrder != detectNative() {
@@ -295,4 +320,237 @@ func TestNetOpts_AllHelpers(t *testing.T) {
 	if o.WriteProto != framer.BinaryStream || o.WriteByteOrder != detectNative() {
 		t.Fatalf("WriteLocal mismatch")
 	}
+
+	framer.WithReadNamedPipe()(&o)
+	if o.ReadProto != framer.BinaryStream || o.ReadByteOrder != binary.BigEndian || o.ErrorMapper == nil {
+		t.Fatalf("ReadNamedPipe mismatch")
+	}
+
+	framer.WithWriteNamedPipe()(&o)
+	if o.WriteProto != framer.BinaryStream || o.WriteByteOrder != binary.BigEndian {
+		t.Fatalf("WriteNamedPipe mismatch")
+	}
+
+	framer.WithReadNamedPipeMessage()(&o)
+	if o.ReadProto != framer.SeqPacket || o.ReadByteOrder != binary.BigEndian {
+		t.Fatalf("ReadNamedPipeMessage mismatch")
+	}
+
+	framer.WithWriteNamedPipeMessage()(&o)
+	if o.WriteProto != framer.SeqPacket || o.WriteByteOrder != binary.BigEndian {
+		t.Fatalf("WriteNamedPipeMessage mismatch")
+	}
+}
+
+// overlappedPipe mimics a Windows pipe handle driven by overlapped I/O: each
+// step either returns data or a Win32 error wrapped like os.File does.
+type overlappedPipe struct {
+	steps []pipeStep
+	out   bytes.Buffer
+}
+
+type pipeStep struct {
+	data  string
+	errno syscall.Errno
+}
+
+func (p *overlappedPipe) Read(b []byte) (int, error) {
+	if len(p.steps) == 0 {
+		return 0, io.EOF
+	}
+	s := &p.steps[0]
+	n := copy(b, s.data)
+	if s.data = s.data[n:]; s.data != "" {
+		return n, nil
+	}
+	p.steps = p.steps[1:]
+	if s.errno != 0 {
+		return n, &os.PathError{Op: "read", Path: `\\.\pipe\agent`, Err: s.errno}
+	}
+	return n, nil
+}
+
+func (p *overlappedPipe) Write(b []byte) (int, error) { return p.out.Write(b) }
+
+func TestNamedPipe_MapsOverlappedConditions(t *testing.T) {
+	const (
+		errNoData     = syscall.Errno(232)
+		errMoreData   = syscall.Errno(234)
+		errIOPending  = syscall.Errno(997)
+		errBrokenPipe = syscall.Errno(109)
+	)
+	// Byte mode: pending and empty reads surface as ErrWouldBlock and the
+	// message resumes.
+	pipe := &overlappedPipe{steps: []pipeStep{{errno: errIOPending}, {data: "\x05he"}, {errno: errNoData}, {data: "llo"}}}
+	r := framer.NewReader(pipe, framer.WithReadNamedPipe())
+	buf := make([]byte, 16)
+	total := 0
+	var blocks int
+	for {
+		n, err := r.Read(buf)
+		total += n
+		if err == nil {
+			break
+		}
+		if err != framer.ErrWouldBlock {
+			t.Fatalf("Read: %v", err)
+		}
+		blocks++
+	}
+	if string(buf[:total]) != "hello" || blocks != 2 {
+		t.Fatalf("got %q after %d would-blocks", buf[:total], blocks)
+	}
+
+	// Message mode: the rest of a message larger than the buffer is ErrMore.
+	pipe = &overlappedPipe{steps: []pipeStep{{data: "abcd", errno: errMoreData}, {data: "ef"}}}
+	r = framer.NewReader(pipe, framer.WithReadNamedPipeMessage())
+	if n, err := r.Read(buf[:4]); n != 4 || err != framer.ErrMore {
+		t.Fatalf("partial message: n=%d err=%v", n, err)
+	}
+
+	// Other errors pass unchanged, and a user mapper still runs.
+	var seen error
+	r = framer.NewReader(&overlappedPipe{steps: []pipeStep{{errno: errBrokenPipe}}},
+		framer.WithErrorMapper(func(err error) error { seen = err; return err }), framer.WithReadNamedPipe())
+	if _, err := r.Read(buf); !errors.Is(err, errBrokenPipe) || seen == nil {
+		t.Fatalf("broken pipe: err=%v seen=%v", err, seen)
+	}
+	if framer.NamedPipeErrors(nil) != nil || framer.NamedPipeErrors(io.EOF) != io.EOF {
+		t.Fatal("non-pipe errors changed")
+	}
+}
+
+// kcpSession mimics a kcp-go UDPSession in message mode: each Write is one
+// message, each Read returns one whole message or io.ErrShortBuffer, and an
+// empty receive queue reports would-block as a nonblocking session would.
+type kcpSession struct{ msgs [][]byte }
+
+func (s *kcpSession) Write(p []byte) (int, error) {
+	s.msgs = append(s.msgs, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func (s *kcpSession) Read(p []byte) (int, error) {
+	if len(s.msgs) == 0 {
+		return 0, framer.ErrWouldBlock
+	}
+	if len(p) < len(s.msgs[0]) {
+		return 0, io.ErrShortBuffer
+	}
+	n := copy(p, s.msgs[0])
+	s.msgs = s.msgs[1:]
+	return n, nil
+}
+
+func TestKCP_MessageModeSession(t *testing.T) {
+	sess := &kcpSession{}
+	w := framer.NewWriter(sess, framer.WithWriteKCP())
+	r := framer.NewReader(sess, framer.WithReadKCP(), framer.WithReadLimit(8))
+	for _, m := range []string{"move", "attack"} {
+		if _, err := w.Write([]byte(m)); err != nil {
+			t.Fatalf("Write %q: %v", m, err)
+		}
+	}
+	// No length prefix is added: the session already keeps boundaries.
+	if len(sess.msgs) != 2 || string(sess.msgs[0]) != "move" {
+		t.Fatalf("session messages %q", sess.msgs)
+	}
+	buf := make([]byte, 16)
+	for _, want := range []string{"move", "attack"} {
+		n, err := r.Read(buf)
+		if err != nil || string(buf[:n]) != want {
+			t.Fatalf("Read %q err=%v want %q", buf[:n], err, want)
+		}
+	}
+	if _, err := r.Read(buf); err != framer.ErrWouldBlock {
+		t.Fatalf("empty session: err=%v", err)
+	}
+}
+
+func TestWithEnv_AppliesRecognizedKeys(t *testing.T) {
+	t.Setenv("FRAMER_READ_LIMIT", "4096")
+	t.Setenv("FRAMER_RETRY_DELAY", "250us")
+	t.Setenv("FRAMER_BYTE_ORDER", "little")
+	t.Setenv("FRAMER_WRITE_BYTE_ORDER", "big")
+	t.Setenv("FRAMER_PROTOCOL", "datagram")
+	t.Setenv("FRAMER_READ_PROTOCOL", "seqpacket")
+
+	var o framer.Options
+	framer.WithEnv("")(&o)
+	if o.ReadLimit != 4096 {
+		t.Fatalf("ReadLimit=%d want 4096", o.ReadLimit)
+	}
+	if o.RetryDelay != 250*time.Microsecond {
+		t.Fatalf("RetryDelay=%v want 250us", o.RetryDelay)
+	}
+	if o.ReadByteOrder != binary.LittleEndian || o.WriteByteOrder != binary.BigEndian {
+		t.Fatalf("byte order mismatch: read=%v write=%v", o.ReadByteOrder, o.WriteByteOrder)
+	}
+	if o.ReadProto != framer.SeqPacket || o.WriteProto != framer.Datagram {
+		t.Fatalf("protocol mismatch: read=%v write=%v", o.ReadProto, o.WriteProto)
+	}
+}
+
+func TestWithEnv_CustomPrefixAndRetryKeywords(t *testing.T) {
+	t.Setenv("APP_RETRY_DELAY", "block")
+	var o framer.Options
+	o.RetryDelay = -1
+	framer.WithEnv("APP")(&o)
+	if o.RetryDelay != 0 {
+		t.Fatalf("RetryDelay=%v want 0 (block)", o.RetryDelay)
+	}
+
+	t.Setenv("APP_RETRY_DELAY", "nonblock")
+	framer.WithEnv("APP")(&o)
+	if o.RetryDelay >= 0 {
+		t.Fatalf("RetryDelay=%v want negative (nonblock)", o.RetryDelay)
+	}
+}
+
+func TestWithEnv_MalformedValuesLeaveSettingsUnchanged(t *testing.T) {
+	t.Setenv("FRAMER_READ_LIMIT", "lots")
+	t.Setenv("FRAMER_RETRY_DELAY", "soon")
+	t.Setenv("FRAMER_BYTE_ORDER", "middle")
+	t.Setenv("FRAMER_PROTOCOL", "carrier-pigeon")
+
+	o := framer.Options{
+		ReadByteOrder:  binary.BigEndian,
+		WriteByteOrder: binary.BigEndian,
+		ReadProto:      framer.BinaryStream,
+		WriteProto:     framer.BinaryStream,
+		ReadLimit:      7,
+		RetryDelay:     -1,
+	}
+	want := o
+	framer.WithEnv("FRAMER")(&o)
+	if !reflect.DeepEqual(o, want) {
+		t.Fatalf("options changed on malformed input: got %+v want %+v", o, want)
+	}
+}
+
+func TestCheckEnv_ReportsMalformedValues(t *testing.T) {
+	t.Setenv("FRAMER_READ_LIMIT", "4096")
+	if err := framer.CheckEnv(""); err != nil {
+		t.Fatalf("CheckEnv valid: %v", err)
+	}
+	t.Setenv("FRAMER_READ_LIMIT", "-1")
+	t.Setenv("FRAMER_WRITE_PROTOCOL", "carrier-pigeon")
+	err := framer.CheckEnv("FRAMER")
+	if !errors.Is(err, framer.ErrInvalidArgument) {
+		t.Fatalf("CheckEnv: want ErrInvalidArgument, got %v", err)
+	}
+	for _, name := range []string{"FRAMER_READ_LIMIT", "FRAMER_WRITE_PROTOCOL"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Fatalf("CheckEnv: %q does not name %s", err, name)
+		}
+	}
+}
+
+func TestWithEnv_OverridesEarlierOptions(t *testing.T) {
+	t.Setenv("FRAMER_READ_LIMIT", "3")
+	r := framer.NewReader(bytes.NewReader([]byte{5, 'h', 'e', 'l', 'l', 'o'}), framer.WithReadLimit(1024), framer.WithEnv(""))
+	buf := make([]byte, 8)
+	if _, err := r.Read(buf); err != framer.ErrTooLong {
+		t.Fatalf("err=%v want ErrTooLong", err)
+	}
 }
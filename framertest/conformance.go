@@ -0,0 +1,198 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framertest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"code.hybscloud.com/framer"
+)
+
+// NewTransport returns the two ends of a fresh connection: bytes written to w
+// become readable from r. Ends implementing io.Closer are closed when the
+// subtest finishes; closing w must make r report io.EOF once drained.
+type NewTransport func(t *testing.T) (r io.Reader, w io.Writer)
+
+// Conformance runs the framer contract against a custom transport framed with
+// opts. Each subtest obtains a new connection from newTransport and writes
+// from a separate goroutine, so blocking transports such as io.Pipe or
+// net.Pipe work unchanged.
+//
+// Subtests:
+//   - RoundTrip: messages of boundary sizes arrive byte- and boundary-exact.
+//   - PartialReads: the transport delivers one to three bytes per read.
+//   - WouldBlockResume: both sides see iox.ErrWouldBlock and resume.
+//   - ShortWrites: the transport accepts a few bytes per write.
+//   - EOFBoundary: closing the writer yields io.EOF after the last message.
+//   - ReadLimit: a message above the read limit reports ErrTooLong.
+//
+// Fault-injecting subtests require a stream protocol and are skipped when
+// opts select SeqPacket or Datagram on the read side.
+func Conformance(t *testing.T, newTransport NewTransport, opts ...framer.Option) {
+	t.Helper()
+	var o framer.Options
+	for _, fn := range opts {
+		fn(&o)
+	}
+	packet := o.ReadProto == framer.SeqPacket || o.ReadProto == framer.Datagram
+
+	msgs := conformanceMessages(packet)
+	identity := func(r io.Reader) io.Reader { return r }
+	identityW := func(w io.Writer) io.Writer { return w }
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		runExchange(t, newTransport, opts, msgs, identity, identityW)
+	})
+	t.Run("PartialReads", func(t *testing.T) {
+		if packet {
+			t.Skip("packet protocol")
+		}
+		n := 0
+		runExchange(t, newTransport, opts, msgs, func(r io.Reader) io.Reader {
+			n++
+			return &ChunkReader{R: r, Max: 1 + n%3}
+		}, identityW)
+	})
+	t.Run("WouldBlockResume", func(t *testing.T) {
+		if packet {
+			t.Skip("packet protocol")
+		}
+		runExchange(t, newTransport, opts, msgs, func(r io.Reader) io.Reader {
+			return &WouldBlockReader{R: &ChunkReader{R: r, Max: 7}}
+		}, func(w io.Writer) io.Writer {
+			return &WouldBlockWriter{W: w}
+		})
+	})
+	t.Run("ShortWrites", func(t *testing.T) {
+		if packet {
+			t.Skip("packet protocol")
+		}
+		runExchange(t, newTransport, opts, msgs, identity, func(w io.Writer) io.Writer {
+			return &ShortWriter{W: w, Max: 3}
+		})
+	})
+	t.Run("EOFBoundary", func(t *testing.T) {
+		r, w := newTransport(t)
+		closeOnCleanup(t, r, w)
+		if _, ok := w.(io.Closer); !ok {
+			t.Skip("writer end is not an io.Closer")
+		}
+		done := writeAll(w, opts, msgs[:2], true)
+		fr := framer.NewReader(r, opts...)
+		readExpect(t, fr, msgs[:2])
+		if n, err := readMessage(fr, make([]byte, 16)); err != io.EOF {
+			t.Fatalf("after last message: n=%d err=%v want io.EOF", n, err)
+		}
+		if err := <-done; err != nil {
+			t.Fatalf("writer: %v", err)
+		}
+	})
+	t.Run("ReadLimit", func(t *testing.T) {
+		r, w := newTransport(t)
+		closeOnCleanup(t, r, w)
+		msg := bytes.Repeat([]byte{0xA5}, 100)
+		_ = writeAll(w, opts, [][]byte{msg}, false)
+		fr := framer.NewReader(r, append(opts[:len(opts):len(opts)], framer.WithReadLimit(10))...)
+		if _, err := readMessage(fr, make([]byte, 256)); err != framer.ErrTooLong {
+			t.Fatalf("oversized message: err=%v want ErrTooLong", err)
+		}
+	})
+}
+
+func conformanceMessages(packet bool) [][]byte {
+	sizes := []int{0, 1, 253, 254, 255, 1024, 65535, 65536, 70000}
+	if packet {
+		sizes = []int{1, 253, 254, 1024}
+	}
+	msgs := make([][]byte, len(sizes))
+	for i, n := range sizes {
+		m := make([]byte, n)
+		for j := range m {
+			m[j] = byte(i*31 + j)
+		}
+		msgs[i] = m
+	}
+	return msgs
+}
+
+func runExchange(t *testing.T, newTransport NewTransport, opts []framer.Option, msgs [][]byte, wrapR func(io.Reader) io.Reader, wrapW func(io.Writer) io.Writer) {
+	t.Helper()
+	r, w := newTransport(t)
+	closeOnCleanup(t, r, w)
+	done := writeAll(wrapW(w), opts, msgs, false)
+	readExpect(t, framer.NewReader(wrapR(r), opts...), msgs)
+	if err := <-done; err != nil {
+		t.Fatalf("writer: %v", err)
+	}
+}
+
+// writeAll writes msgs from a new goroutine, retrying on ErrWouldBlock and
+// ErrMore with the same message as the framer contract requires.
+func writeAll(w io.Writer, opts []framer.Option, msgs [][]byte, closeAfter bool) <-chan error {
+	done := make(chan error, 1)
+	fw := framer.NewWriter(w, opts...)
+	go func() {
+		for i, m := range msgs {
+			for {
+				_, err := fw.Write(m)
+				if err == nil {
+					break
+				}
+				if err != framer.ErrWouldBlock && err != framer.ErrMore {
+					done <- fmt.Errorf("write[%d]: %w", i, err)
+					return
+				}
+			}
+		}
+		if closeAfter {
+			if c, ok := w.(io.Closer); ok {
+				done <- c.Close()
+				return
+			}
+		}
+		done <- nil
+	}()
+	return done
+}
+
+// readMessage reads one message into buf, retrying on ErrWouldBlock and
+// ErrMore, and returns the payload length.
+func readMessage(r io.Reader, buf []byte) (int, error) {
+	total := 0
+	for {
+		n, err := r.Read(buf)
+		total += n
+		if err != framer.ErrWouldBlock && err != framer.ErrMore {
+			return total, err
+		}
+	}
+}
+
+func readExpect(t *testing.T, r io.Reader, msgs [][]byte) {
+	t.Helper()
+	buf := make([]byte, 128*1024)
+	for i, want := range msgs {
+		n, err := readMessage(r, buf)
+		if err != nil {
+			t.Fatalf("read[%d]: %v", i, err)
+		}
+		if !bytes.Equal(buf[:n], want) {
+			t.Fatalf("read[%d]: got %d bytes, want %d (payload mismatch)", i, n, len(want))
+		}
+	}
+}
+
+func closeOnCleanup(t *testing.T, ends ...any) {
+	t.Cleanup(func() {
+		for _, e := range ends {
+			if c, ok := e.(io.Closer); ok {
+				_ = c.Close()
+			}
+		}
+	})
+}
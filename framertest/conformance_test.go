@@ -0,0 +1,28 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framertest_test
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"code.hybscloud.com/framer"
+	"code.hybscloud.com/framer/framertest"
+)
+
+func TestConformance_IOPipe(t *testing.T) {
+	framertest.Conformance(t, func(t *testing.T) (io.Reader, io.Writer) {
+		return io.Pipe()
+	}, framer.WithProtocol(framer.BinaryStream))
+}
+
+func TestConformance_NetPipeLittleEndian(t *testing.T) {
+	framertest.Conformance(t, func(t *testing.T) (io.Reader, io.Writer) {
+		c1, c2 := net.Pipe()
+		return c2, c1
+	}, framer.WithReadTCP(), framer.WithWriteTCP(), framer.WithByteOrder(binary.LittleEndian))
+}
@@ -0,0 +1,10 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package framertest provides utilities for testing integrations of package
+// framer: a conformance suite for custom transports, fault-injecting
+// io.Reader/io.Writer wrappers, a scripted transport, ScriptedConn, in-memory
+// loopback endpoints with an Echo peer, and a load Generator with a
+// validating Sink.
+package framertest
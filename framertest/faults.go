@@ -0,0 +1,75 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framertest
+
+import (
+	"io"
+
+	"code.hybscloud.com/iox"
+)
+
+// ChunkReader returns at most Max bytes per Read from R, emulating a stream
+// transport that delivers data in small pieces. Max <= 0 means 1.
+type ChunkReader struct {
+	R   io.Reader
+	Max int
+}
+
+func (c *ChunkReader) Read(p []byte) (int, error) {
+	m := max(c.Max, 1)
+	if len(p) > m {
+		p = p[:m]
+	}
+	return c.R.Read(p)
+}
+
+// WouldBlockReader returns iox.ErrWouldBlock before every other Read of R,
+// emulating a nonblocking transport that is not ready on the first attempt.
+type WouldBlockReader struct {
+	R     io.Reader
+	calls int
+}
+
+func (b *WouldBlockReader) Read(p []byte) (int, error) {
+	b.calls++
+	if b.calls%2 == 1 {
+		return 0, iox.ErrWouldBlock
+	}
+	return b.R.Read(p)
+}
+
+// ShortWriter writes at most Max bytes per Write to W and reports a short
+// write as iox.ErrWouldBlock, emulating a nonblocking socket with a small send
+// buffer. Max <= 0 means 1.
+type ShortWriter struct {
+	W   io.Writer
+	Max int
+}
+
+func (s *ShortWriter) Write(p []byte) (int, error) {
+	m := max(s.Max, 1)
+	if len(p) <= m {
+		return s.W.Write(p)
+	}
+	n, err := s.W.Write(p[:m])
+	if err == nil {
+		err = iox.ErrWouldBlock
+	}
+	return n, err
+}
+
+// WouldBlockWriter returns iox.ErrWouldBlock before every other Write to W.
+type WouldBlockWriter struct {
+	W     io.Writer
+	calls int
+}
+
+func (b *WouldBlockWriter) Write(p []byte) (int, error) {
+	b.calls++
+	if b.calls%2 == 1 {
+		return 0, iox.ErrWouldBlock
+	}
+	return b.W.Write(p)
+}
@@ -0,0 +1,183 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framertest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"code.hybscloud.com/framer"
+)
+
+// fuzzReadLimit bounds payload sizes during fuzzing so adversarial length
+// prefixes cannot request huge buffers.
+const fuzzReadLimit = 1 << 20
+
+// FuzzDecodeStream fuzzes the stream decoder with opts. Call it from a fuzz
+// target in any package:
+//
+//	func FuzzDecode(f *testing.F) { framertest.FuzzDecodeStream(f) }
+//
+// The input is arbitrary wire bytes; its first byte also selects the transport
+// chunk size. The decoder must not panic or spin, must report a payload no
+// larger than the read limit, and must never return more payload bytes than
+// the input holds. Golden vectors are added as seeds.
+func FuzzDecodeStream(f *testing.F, opts ...framer.Option) {
+	f.Helper()
+	seedGolden(f)
+	f.Fuzz(func(t *testing.T, wire []byte) {
+		msgs, err := decodeChunked(wire, opts)
+		total := 0
+		for _, m := range msgs {
+			if len(m) > fuzzReadLimit {
+				t.Fatalf("payload of %d bytes exceeds read limit", len(m))
+			}
+			total += len(m)
+		}
+		if total > len(wire) {
+			t.Fatalf("decoded %d payload bytes from %d input bytes", total, len(wire))
+		}
+		if err == nil {
+			// A cleanly decoded input must survive a re-encode round trip.
+			re, eerr := EncodeWire(msgs, streamOptions(opts)...)
+			if eerr != nil {
+				t.Fatalf("re-encode: %v", eerr)
+			}
+			back, derr := DecodeWire(re, streamOptions(opts)...)
+			if derr != nil || len(back) != len(msgs) {
+				t.Fatalf("re-decode: n=%d err=%v want %d messages", len(back), derr, len(msgs))
+			}
+		}
+	})
+}
+
+// FuzzForwarder fuzzes a Forwarder with opts on both sides. Everything the
+// Forwarder delivers must decode to a prefix of what a Reader decodes from
+// the same input, message for message.
+func FuzzForwarder(f *testing.F, opts ...framer.Option) {
+	f.Helper()
+	seedGolden(f)
+	f.Fuzz(func(t *testing.T, wire []byte) {
+		sopts := streamOptions(opts)
+		direct, _ := DecodeWire(wire, sopts...)
+
+		var dst bytes.Buffer
+		fw := framer.NewForwarder(&dst, bytes.NewReader(wire), sopts...)
+		for range len(wire) + 1 {
+			if _, err := fw.ForwardOnce(); err != nil {
+				break
+			}
+		}
+		relayed, err := DecodeWire(dst.Bytes(), sopts...)
+		if err != nil {
+			t.Fatalf("forwarded wire does not decode: %v", err)
+		}
+		if len(relayed) > len(direct) {
+			t.Fatalf("forwarded %d messages, reader decoded %d", len(relayed), len(direct))
+		}
+		for i := range relayed {
+			if !bytes.Equal(relayed[i], direct[i]) {
+				t.Fatalf("message %d differs between Forwarder and Reader", i)
+			}
+		}
+	})
+}
+
+// AddRecorded adds seeds derived from recorded stream traffic framed with
+// opts: the whole recording, every single message, and every prefix ending at
+// a message boundary. Truncated recordings contribute their complete messages.
+func AddRecorded(f *testing.F, recorded []byte, opts ...framer.Option) {
+	f.Helper()
+	for _, seed := range RecordedSeeds(recorded, opts...) {
+		f.Add(seed)
+	}
+}
+
+// RecordedSeeds returns the seeds AddRecorded would add.
+func RecordedSeeds(recorded []byte, opts ...framer.Option) [][]byte {
+	seeds := [][]byte{bytes.Clone(recorded)}
+	msgs, _ := DecodeWire(recorded, opts...)
+	var prefix []byte
+	for _, m := range msgs {
+		one, err := EncodeWire([][]byte{m}, opts...)
+		if err != nil {
+			continue
+		}
+		seeds = append(seeds, one)
+		prefix = append(prefix, one...)
+		seeds = append(seeds, bytes.Clone(prefix))
+	}
+	return seeds
+}
+
+// WriteCorpusFile stores data as a seed in dir using the file format of
+// "go test -fuzz", named by its SHA-256. Use
+// testdata/fuzz/<FuzzTargetName> as dir to make seeds persistent.
+func WriteCorpusFile(dir string, data []byte) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	name := filepath.Join(dir, hex.EncodeToString(sum[:8]))
+	body := fmt.Sprintf("go test fuzz v1\n[]byte(%q)\n", data)
+	return os.WriteFile(name, []byte(body), 0o644)
+}
+
+func seedGolden(f *testing.F) {
+	vs, err := GoldenVectors()
+	if err != nil {
+		f.Fatalf("golden vectors: %v", err)
+	}
+	var all []byte
+	for _, v := range vs {
+		if len(v.Wire) <= 512 {
+			f.Add(v.Wire)
+			if v.Order == "big" {
+				all = append(all, v.Wire...)
+			}
+		}
+	}
+	f.Add(all)
+	f.Add([]byte{0xFE, 0x00})                   // truncated extended length
+	f.Add([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF}) // huge length prefix
+}
+
+func streamOptions(opts []framer.Option) []framer.Option {
+	return append([]framer.Option{framer.WithProtocol(framer.BinaryStream), framer.WithReadLimit(fuzzReadLimit)}, opts...)
+}
+
+// decodeChunked decodes wire delivered in chunks selected by its first byte.
+func decodeChunked(wire []byte, opts []framer.Option) ([][]byte, error) {
+	chunk := 1
+	if len(wire) > 0 {
+		chunk = 1 + int(wire[0]%16)
+	}
+	r := framer.NewReader(&ChunkReader{R: bytes.NewReader(wire), Max: chunk}, streamOptions(opts)...)
+	var msgs [][]byte
+	buf := make([]byte, 4096)
+	for {
+		n, err := r.Read(buf)
+		if err == io.ErrShortBuffer {
+			if len(buf) >= fuzzReadLimit {
+				return msgs, err
+			}
+			buf = make([]byte, min(2*len(buf), fuzzReadLimit))
+			continue
+		}
+		if err == io.EOF {
+			return msgs, nil
+		}
+		if err != nil {
+			return msgs, err
+		}
+		msgs = append(msgs, bytes.Clone(buf[:n]))
+	}
+}
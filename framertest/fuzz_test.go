@@ -0,0 +1,57 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framertest_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"code.hybscloud.com/framer"
+	"code.hybscloud.com/framer/framertest"
+)
+
+func FuzzDecodeStream(f *testing.F) { framertest.FuzzDecodeStream(f) }
+
+func FuzzForwarder(f *testing.F) {
+	rec, err := framertest.EncodeWire([][]byte{[]byte("a"), bytes.Repeat([]byte("b"), 300), nil})
+	if err != nil {
+		f.Fatal(err)
+	}
+	framertest.AddRecorded(f, rec)
+	framertest.FuzzForwarder(f)
+}
+
+func TestRecordedSeeds(t *testing.T) {
+	opts := []framer.Option{framer.WithProtocol(framer.BinaryStream)}
+	rec, err := framertest.EncodeWire([][]byte{[]byte("x"), []byte("yz")}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncated := append(bytes.Clone(rec), 0x05, 'p') // trailing partial message
+	seeds := framertest.RecordedSeeds(truncated, opts...)
+	// whole recording + 2 × (single message, boundary prefix)
+	if len(seeds) != 5 {
+		t.Fatalf("got %d seeds want 5", len(seeds))
+	}
+	if !bytes.Equal(seeds[4], rec) {
+		t.Fatalf("last boundary prefix=% x want % x", seeds[4], rec)
+	}
+
+	dir := filepath.Join(t.TempDir(), "testdata", "fuzz", "FuzzX")
+	if err := framertest.WriteCorpusFile(dir, seeds[1]); err != nil {
+		t.Fatalf("WriteCorpusFile: %v", err)
+	}
+	ents, err := os.ReadDir(dir)
+	if err != nil || len(ents) != 1 {
+		t.Fatalf("corpus dir: %v entries=%d", err, len(ents))
+	}
+	body, _ := os.ReadFile(filepath.Join(dir, ents[0].Name()))
+	if !strings.HasPrefix(string(body), "go test fuzz v1\n[]byte(") {
+		t.Fatalf("corpus file=%q", body)
+	}
+}
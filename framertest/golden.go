@@ -0,0 +1,208 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framertest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"code.hybscloud.com/framer"
+)
+
+// Vector is one golden stream-mode wire sample: Payload framed as a single
+// message with the given header format and byte order encodes to Wire.
+type Vector struct {
+	Name    string // unique, e.g. "compact/big/254"
+	Header  string // header format, e.g. "compact"
+	Order   string // "big" or "little"
+	Payload []byte
+	Wire    []byte
+}
+
+// Options returns the framer options that reproduce v.
+func (v Vector) Options() []framer.Option {
+	order := binary.ByteOrder(binary.BigEndian)
+	if v.Order == "little" {
+		order = binary.LittleEndian
+	}
+	return []framer.Option{
+		framer.WithProtocol(framer.BinaryStream),
+		framer.WithByteOrder(order),
+		framer.WithHeaderFormat(goldenHeaders[v.Header]),
+	}
+}
+
+// goldenHeaders maps Vector.Header names to header formats.
+var goldenHeaders = map[string]framer.HeaderFormat{
+	"compact": framer.HeaderCompact,
+	"fixed16": framer.HeaderFixed16,
+	"fixed32": framer.HeaderFixed32,
+	"fixed64": framer.HeaderFixed64,
+	"uvarint": framer.HeaderUvarint,
+}
+
+// GoldenSizes are the payload sizes of GoldenVectors: both sides of every
+// length-encoding boundary.
+var GoldenSizes = []int{0, 1, 253, 254, 255, 65535, 65536}
+
+// GoldenPayload returns the canonical payload of size n: byte i is i mod 251.
+// The prime modulus keeps the pattern from aligning with length boundaries.
+func GoldenPayload(n int) []byte {
+	p := make([]byte, n)
+	for i := range p {
+		p[i] = byte(i % 251)
+	}
+	return p
+}
+
+// GoldenVectors returns the vector matrix: every header format, both byte
+// orders and every size in GoldenSizes the format can encode, in a stable
+// order.
+func GoldenVectors() ([]Vector, error) {
+	var vs []Vector
+	for _, header := range []string{"compact", "fixed16", "fixed32", "fixed64", "uvarint"} {
+		for _, order := range []string{"big", "little"} {
+			for _, n := range GoldenSizes {
+				if !headerFits(goldenHeaders[header], n) {
+					continue
+				}
+				v := Vector{
+					Name:    fmt.Sprintf("%s/%s/%d", header, order, n),
+					Header:  header,
+					Order:   order,
+					Payload: GoldenPayload(n),
+				}
+				wire, err := EncodeWire([][]byte{v.Payload}, v.Options()...)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", v.Name, err)
+				}
+				v.Wire = wire
+				vs = append(vs, v)
+			}
+		}
+	}
+	return vs, nil
+}
+
+// headerFits reports whether format h can encode a payload of n bytes.
+func headerFits(h framer.HeaderFormat, n int) bool {
+	_, err := (&framer.Header{PayloadLen: int64(n), Format: h}).Encode(make([]byte, framer.MaxHeaderLen))
+	return err == nil
+}
+
+// EncodeWire frames msgs with opts and returns the concatenated wire bytes.
+func EncodeWire(msgs [][]byte, opts ...framer.Option) ([]byte, error) {
+	var buf bytes.Buffer
+	w := framer.NewWriter(&buf, opts...)
+	for _, m := range msgs {
+		if _, err := w.Write(m); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeWire parses wire framed with opts back into message payloads. Wire
+// must end at a message boundary. A header announcing more bytes than wire
+// holds fails with io.ErrUnexpectedEOF before any buffer of that size is
+// allocated.
+func DecodeWire(wire []byte, opts ...framer.Option) ([][]byte, error) {
+	r := framer.NewReader(bytes.NewReader(wire), opts...)
+	var msgs [][]byte
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := r.Read(buf)
+		if err == io.ErrShortBuffer {
+			// No message is longer than the wire it came from.
+			if len(buf) >= len(wire) {
+				return msgs, io.ErrUnexpectedEOF
+			}
+			buf = make([]byte, min(2*len(buf), len(wire)))
+			continue
+		}
+		if err == io.EOF {
+			return msgs, nil
+		}
+		if err != nil {
+			return msgs, err
+		}
+		msgs = append(msgs, bytes.Clone(buf[:n]))
+	}
+}
+
+// Verify checks that v encodes to v.Wire and that v.Wire decodes to exactly
+// one message equal to v.Payload.
+func (v Vector) Verify() error {
+	wire, err := EncodeWire([][]byte{v.Payload}, v.Options()...)
+	if err != nil {
+		return fmt.Errorf("%s: encode: %w", v.Name, err)
+	}
+	if !bytes.Equal(wire, v.Wire) {
+		return fmt.Errorf("%s: encoded wire differs from golden", v.Name)
+	}
+	msgs, err := DecodeWire(v.Wire, v.Options()...)
+	if err != nil {
+		return fmt.Errorf("%s: decode: %w", v.Name, err)
+	}
+	if len(msgs) != 1 || !bytes.Equal(msgs[0], v.Payload) {
+		return fmt.Errorf("%s: decoded payload differs from golden", v.Name)
+	}
+	return nil
+}
+
+type vectorJSON struct {
+	Name    string `json:"name"`
+	Header  string `json:"header"`
+	Order   string `json:"order"`
+	Length  int    `json:"length"`
+	Payload string `json:"payload"`
+	Wire    string `json:"wire"`
+}
+
+// WriteVectorsJSON writes vs as JSON Lines, one object per vector with
+// hex-encoded "payload" and "wire", for consumption by other implementations.
+func WriteVectorsJSON(w io.Writer, vs []Vector) error {
+	enc := json.NewEncoder(w)
+	for _, v := range vs {
+		if err := enc.Encode(vectorJSON{
+			Name:    v.Name,
+			Header:  v.Header,
+			Order:   v.Order,
+			Length:  len(v.Payload),
+			Payload: hex.EncodeToString(v.Payload),
+			Wire:    hex.EncodeToString(v.Wire),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadVectorsJSON parses vectors written by WriteVectorsJSON.
+func ReadVectorsJSON(r io.Reader) ([]Vector, error) {
+	dec := json.NewDecoder(r)
+	var vs []Vector
+	for {
+		var j vectorJSON
+		if err := dec.Decode(&j); err == io.EOF {
+			return vs, nil
+		} else if err != nil {
+			return vs, err
+		}
+		payload, err := hex.DecodeString(j.Payload)
+		if err != nil {
+			return vs, fmt.Errorf("%s: payload: %w", j.Name, err)
+		}
+		wire, err := hex.DecodeString(j.Wire)
+		if err != nil {
+			return vs, fmt.Errorf("%s: wire: %w", j.Name, err)
+		}
+		vs = append(vs, Vector{Name: j.Name, Header: j.Header, Order: j.Order, Payload: payload, Wire: wire})
+	}
+}
@@ -0,0 +1,89 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framertest_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"code.hybscloud.com/framer"
+	"code.hybscloud.com/framer/framertest"
+)
+
+func TestGoldenVectors_KnownHeaders(t *testing.T) {
+	vs, err := framertest.GoldenVectors()
+	if err != nil {
+		t.Fatalf("GoldenVectors: %v", err)
+	}
+	heads := map[string][]byte{
+		"compact/big/0":        {0x00},
+		"compact/big/253":      {0xFD},
+		"compact/big/254":      {0xFE, 0x00, 0xFE},
+		"compact/little/254":   {0xFE, 0xFE, 0x00},
+		"compact/big/65535":    {0xFE, 0xFF, 0xFF},
+		"compact/big/65536":    {0xFF, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00},
+		"compact/little/65536": {0xFF, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00},
+		"fixed16/big/254":      {0x00, 0xFE},
+		"fixed16/little/65535": {0xFF, 0xFF},
+		"fixed32/big/254":      {0x00, 0x00, 0x00, 0xFE},
+		"fixed32/little/254":   {0xFE, 0x00, 0x00, 0x00},
+		"fixed64/big/65536":    {0, 0, 0, 0, 0, 0x01, 0x00, 0x00},
+		"fixed64/little/1":     {0x01, 0, 0, 0, 0, 0, 0, 0},
+		"uvarint/big/0":        {0x00},
+		"uvarint/big/254":      {0xFE, 0x01},
+		"uvarint/little/65536": {0x80, 0x80, 0x04},
+	}
+	found := 0
+	for _, v := range vs {
+		if err := v.Verify(); err != nil {
+			t.Fatal(err)
+		}
+		if h, ok := heads[v.Name]; ok {
+			found++
+			if !bytes.HasPrefix(v.Wire, h) || len(v.Wire) != len(h)+len(v.Payload) {
+				t.Fatalf("%s: header % x, want % x", v.Name, v.Wire[:min(len(v.Wire), 8)], h)
+			}
+		}
+	}
+	if found != len(heads) {
+		t.Fatalf("matched %d of %d known vectors", found, len(heads))
+	}
+}
+
+func TestGoldenVectors_JSONRoundTrip(t *testing.T) {
+	vs, err := framertest.GoldenVectors()
+	if err != nil {
+		t.Fatalf("GoldenVectors: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := framertest.WriteVectorsJSON(&buf, vs); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	back, err := framertest.ReadVectorsJSON(&buf)
+	if err != nil || len(back) != len(vs) {
+		t.Fatalf("read: n=%d err=%v", len(back), err)
+	}
+	for i := range vs {
+		if back[i].Name != vs[i].Name || !bytes.Equal(back[i].Wire, vs[i].Wire) {
+			t.Fatalf("vector %d differs after JSON round trip", i)
+		}
+		if err := back[i].Verify(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDecodeWire_HostileLengthBounded(t *testing.T) {
+	// A compact header claiming nearly 2^56 payload bytes.
+	wire := []byte{0xFF, 0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 'x'}
+	if _, err := framertest.DecodeWire(wire); err != io.ErrUnexpectedEOF {
+		t.Fatalf("compact: err=%v", err)
+	}
+	wire = append([]byte{0x00, 0x10, 0x00, 0x00}, make([]byte, 70000)...)
+	if _, err := framertest.DecodeWire(wire, framer.WithFixedLengthHeader(4)); err != io.ErrUnexpectedEOF {
+		t.Fatalf("fixed32: err=%v", err)
+	}
+}
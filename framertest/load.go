@@ -0,0 +1,207 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framertest
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"math/rand/v2"
+	"time"
+
+	"code.hybscloud.com/framer"
+)
+
+// Load messages carry a 12-byte preamble, a big-endian sequence number and a
+// CRC-32 of the rest of the message, followed by a body derived from the
+// sequence number, so a Sink can validate each message on its own.
+const loadPreamble = 12
+
+// SizeDist draws message sizes for a Generator.
+type SizeDist func(rng *rand.Rand) int
+
+// FixedSize always draws n.
+func FixedSize(n int) SizeDist { return func(*rand.Rand) int { return n } }
+
+// UniformSize draws sizes uniformly from [lo, hi].
+func UniformSize(lo, hi int) SizeDist {
+	return func(rng *rand.Rand) int { return lo + rng.IntN(hi-lo+1) }
+}
+
+// ExpSize draws exponentially distributed sizes of the given mean, capped at
+// limit: mostly small messages with a long tail, as in request streams.
+func ExpSize(mean, limit int) SizeDist {
+	return func(rng *rand.Rand) int { return min(int(rng.ExpFloat64()*float64(mean)), limit) }
+}
+
+// LoadConfig configures a Generator.
+type LoadConfig struct {
+	// Seed makes runs repeatable.
+	Seed uint64
+
+	// Messages is the number of messages to generate. Zero selects 1000.
+	Messages int
+
+	// Size draws message sizes; sizes below the 12-byte preamble are
+	// raised to it. Nil selects UniformSize(0, 1024).
+	Size SizeDist
+
+	// Burst messages are written back to back, followed by a pause of Gap.
+	// Zero Gap writes all messages back to back.
+	Burst int
+	Gap   time.Duration
+
+	// LossRate is the probability that a message is skipped, and
+	// CorruptRate the probability that one byte of it is flipped after its
+	// checksum is computed.
+	LossRate    float64
+	CorruptRate float64
+}
+
+// LoadStats summarizes a Generator run.
+type LoadStats struct {
+	Sent      int   // messages written, corrupted ones included
+	Lost      int   // messages skipped by loss injection
+	Corrupted int   // messages written with a flipped byte
+	Bytes     int64 // payload bytes written
+}
+
+// Generator emits framed load messages with chosen size distributions,
+// burstiness and fault injection, for repeatable load tests of services
+// built on framer. Validate the receiving side with a Sink.
+type Generator struct {
+	cfg LoadConfig
+	rng *rand.Rand
+	seq uint64
+}
+
+// NewGenerator returns a Generator for cfg.
+func NewGenerator(cfg LoadConfig) *Generator {
+	if cfg.Messages <= 0 {
+		cfg.Messages = 1000
+	}
+	if cfg.Size == nil {
+		cfg.Size = UniformSize(0, 1024)
+	}
+	return &Generator{cfg: cfg, rng: rand.New(rand.NewPCG(cfg.Seed, cfg.Seed^0x9e3779b97f4a7c15))}
+}
+
+// Run writes the configured messages to w framed with opts, retrying
+// iox.ErrWouldBlock and iox.ErrMore, and stops at the first other error.
+func (g *Generator) Run(w io.Writer, opts ...framer.Option) (LoadStats, error) {
+	var st LoadStats
+	fw := framer.NewWriter(w, opts...)
+	for i := range g.cfg.Messages {
+		if i > 0 && g.cfg.Gap > 0 && i%max(g.cfg.Burst, 1) == 0 {
+			time.Sleep(g.cfg.Gap)
+		}
+		msg := g.next()
+		if g.rng.Float64() < g.cfg.LossRate {
+			st.Lost++
+			continue
+		}
+		if g.rng.Float64() < g.cfg.CorruptRate {
+			msg[g.rng.IntN(len(msg))] ^= 0xFF
+			st.Corrupted++
+		}
+		if err := retryWrite(fw, msg); err != nil {
+			return st, err
+		}
+		st.Sent++
+		st.Bytes += int64(len(msg))
+	}
+	if f, ok := fw.(*framer.Writer); ok {
+		return st, f.Flush()
+	}
+	return st, nil
+}
+
+// next builds the message with the next sequence number.
+func (g *Generator) next() []byte {
+	msg := make([]byte, max(g.cfg.Size(g.rng), loadPreamble))
+	binary.BigEndian.PutUint64(msg, g.seq)
+	for i := loadPreamble; i < len(msg); i++ {
+		msg[i] = byte(g.seq) + byte(i)
+	}
+	binary.BigEndian.PutUint32(msg[8:], loadChecksum(msg))
+	g.seq++
+	return msg
+}
+
+func loadChecksum(msg []byte) uint32 {
+	return crc32.Update(crc32.ChecksumIEEE(msg[:8]), crc32.IEEETable, msg[loadPreamble:])
+}
+
+// SinkStats summarizes the messages a Sink observed.
+type SinkStats struct {
+	Received   int   // valid messages
+	Corrupt    int   // messages failing validation
+	Duplicate  int   // valid messages with a sequence number seen before
+	OutOfOrder int   // valid messages with a sequence number below a later one
+	Missing    int   // sequence numbers below the highest seen that never arrived intact
+	Bytes      int64 // payload bytes of valid messages
+}
+
+// Sink validates the messages of a Generator on the read side. Losses after
+// the last received message cannot be detected; compare Received with
+// LoadStats.Sent for them.
+type Sink struct {
+	st   SinkStats
+	seen map[uint64]bool
+	next uint64 // highest sequence number seen, plus one
+}
+
+// NewSink returns an empty Sink.
+func NewSink() *Sink { return &Sink{seen: make(map[uint64]bool)} }
+
+// Observe validates one message payload.
+func (s *Sink) Observe(msg []byte) {
+	if len(msg) < loadPreamble || binary.BigEndian.Uint32(msg[8:]) != loadChecksum(msg) {
+		s.st.Corrupt++
+		return
+	}
+	seq := binary.BigEndian.Uint64(msg)
+	if s.seen[seq] {
+		s.st.Duplicate++
+		return
+	}
+	s.seen[seq] = true
+	if seq < s.next {
+		s.st.OutOfOrder++
+	} else {
+		s.next = seq + 1
+	}
+	s.st.Received++
+	s.st.Bytes += int64(len(msg))
+}
+
+// Stats returns the counts so far.
+func (s *Sink) Stats() SinkStats {
+	st := s.st
+	st.Missing = int(s.next) - len(s.seen)
+	return st
+}
+
+// Drain reads messages from r framed with opts and observes them until r
+// reports io.EOF at a message boundary, retrying iox.ErrWouldBlock and
+// iox.ErrMore. Messages must fit in the read limit of opts, or 1MiB.
+func (s *Sink) Drain(r io.Reader, opts ...framer.Option) error {
+	var o framer.Options
+	for _, fn := range opts {
+		fn(&o)
+	}
+	buf := make([]byte, max(o.ReadLimit, 1<<20))
+	fr := framer.NewReader(r, opts...)
+	for {
+		n, err := readMessage(fr, buf)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		s.Observe(buf[:n])
+	}
+}
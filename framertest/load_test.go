@@ -0,0 +1,78 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framertest_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"code.hybscloud.com/framer"
+	"code.hybscloud.com/framer/framertest"
+)
+
+func TestGenerator_SinkDetectsInjectedFaults(t *testing.T) {
+	cfg := framertest.LoadConfig{
+		Seed:        7,
+		Messages:    500,
+		Size:        framertest.ExpSize(200, 4096),
+		Burst:       100,
+		Gap:         time.Millisecond,
+		LossRate:    0.05,
+		CorruptRate: 0.05,
+	}
+	opts := []framer.Option{framer.WithReadTCP(), framer.WithWriteTCP()}
+	var wire bytes.Buffer
+	gen, err := framertest.NewGenerator(cfg).Run(&framertest.ShortWriter{W: &wire, Max: 7}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gen.Sent+gen.Lost != cfg.Messages || gen.Lost == 0 || gen.Corrupted == 0 {
+		t.Fatalf("generator stats: %+v", gen)
+	}
+
+	sink := framertest.NewSink()
+	if err := sink.Drain(bytes.NewReader(wire.Bytes()), opts...); err != nil {
+		t.Fatal(err)
+	}
+	st := sink.Stats()
+	if st.Corrupt != gen.Corrupted || st.Received != gen.Sent-gen.Corrupted {
+		t.Fatalf("sink stats %+v, generator %+v", st, gen)
+	}
+	if st.Missing < gen.Lost || st.Missing > gen.Lost+gen.Corrupted || st.Duplicate != 0 || st.OutOfOrder != 0 {
+		t.Fatalf("sink stats %+v, generator %+v", st, gen)
+	}
+
+	// The same seed reproduces the same wire bytes.
+	var again bytes.Buffer
+	if _, err := framertest.NewGenerator(cfg).Run(&again, opts...); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again.Bytes(), wire.Bytes()) {
+		t.Fatal("run not repeatable")
+	}
+
+	// Replayed and reordered messages are reported.
+	m0, m1 := loadMessage(t, 0), loadMessage(t, 1)
+	sink = framertest.NewSink()
+	for _, m := range [][]byte{m1, m0, m1} {
+		sink.Observe(m)
+	}
+	if st := sink.Stats(); st.Received != 2 || st.OutOfOrder != 1 || st.Duplicate != 1 || st.Missing != 0 {
+		t.Fatalf("reorder stats: %+v", st)
+	}
+}
+
+// loadMessage returns the message with sequence number seq of a fixed-size
+// generator.
+func loadMessage(t *testing.T, seq int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	cfg := framertest.LoadConfig{Messages: seq + 1, Size: framertest.FixedSize(32)}
+	if _, err := framertest.NewGenerator(cfg).Run(&buf, framer.WithWriteTCP()); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()[buf.Len()-32:]
+}
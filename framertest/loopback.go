@@ -0,0 +1,124 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framertest
+
+import (
+	"io"
+
+	"code.hybscloud.com/framer"
+)
+
+// loopbackCapacity is the framed bytes each loopback direction holds, and
+// loopbackChunk the largest piece queued at once.
+const (
+	loopbackCapacity = 64 * 1024
+	loopbackChunk    = 4096
+)
+
+// Endpoint is one end of a loopback pair: a framed reader and writer over
+// two in-memory pipes, one per direction.
+type Endpoint struct {
+	*framer.ReadWriter
+	out *framer.BufferedPipe
+}
+
+// NewLoopback returns a connected pair of framed endpoints backed by
+// framer.BufferedPipe, for integration tests of protocol logic without
+// net.Pipe and the goroutines it needs: what one endpoint writes, the other
+// reads, and both can be driven from a single goroutine.
+//
+//	a, b := framertest.NewLoopback()
+//	go framertest.Echo(b)
+//	a.Write([]byte("ping"))
+//	n, _ := a.Read(buf) // "ping"
+//
+// The pipes carry the framed byte stream, so opts select the wire format as
+// on a real connection. Each direction holds 64KiB of framed bytes; longer
+// messages need the peer to read concurrently. Reads and writes block
+// cooperatively, yielding until the peer makes progress, unless opts include
+// framer.WithNonblock.
+func NewLoopback(opts ...framer.Option) (a, b *Endpoint) {
+	ab := framer.NewBufferedPipe(loopbackCapacity)
+	ba := framer.NewBufferedPipe(loopbackCapacity)
+	return newEndpoint(ba, ab, opts), newEndpoint(ab, ba, opts)
+}
+
+func newEndpoint(in, out *framer.BufferedPipe, opts []framer.Option) *Endpoint {
+	opts = append([]framer.Option{framer.WithBlock()}, opts...)
+	rw := framer.NewReadWriter(&pipeStream{in: in}, &pipeStream{out: out}, opts...)
+	return &Endpoint{ReadWriter: rw.(*framer.ReadWriter), out: out}
+}
+
+// Close closes the sending direction; the peer reads the queued messages
+// and then io.EOF.
+func (e *Endpoint) Close() error { return e.out.Close() }
+
+// CloseWithError closes the sending direction; the peer reads the queued
+// messages and then err, or io.EOF when err is nil.
+func (e *Endpoint) CloseWithError(err error) error { return e.out.CloseWithError(err) }
+
+// pipeStream carries a byte stream over a BufferedPipe in pieces of at most
+// loopbackChunk bytes.
+type pipeStream struct {
+	in, out *framer.BufferedPipe
+	buf     []byte // piece being read
+	off     int    // bytes of buf already returned
+}
+
+func (s *pipeStream) Read(p []byte) (int, error) {
+	if s.off == len(s.buf) {
+		if s.buf == nil {
+			s.buf = make([]byte, loopbackChunk)
+		}
+		n, err := s.in.Read(s.buf[:cap(s.buf)])
+		if err != nil {
+			s.buf, s.off = s.buf[:0], 0
+			return 0, err
+		}
+		s.buf, s.off = s.buf[:n], 0
+	}
+	n := copy(p, s.buf[s.off:])
+	s.off += n
+	return n, nil
+}
+
+func (s *pipeStream) Write(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		m, err := s.out.Write(p[n:min(n+loopbackChunk, len(p))])
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Echo writes every message read from rw back to it until rw reports
+// io.EOF, which Echo returns as nil, or another error. It retries
+// iox.ErrWouldBlock and iox.ErrMore, and grows its buffer on
+// io.ErrShortBuffer, so it serves a loopback Endpoint or any message-level
+// io.ReadWriter; run it in a goroutine:
+//
+//	go framertest.Echo(b)
+func Echo(rw io.ReadWriter) error {
+	buf := make([]byte, 4096)
+	for {
+		n, err := readMessage(rw, buf)
+		if err == io.ErrShortBuffer && n == 0 {
+			buf = make([]byte, 2*len(buf))
+			continue
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := retryWrite(rw, buf[:n]); err != nil {
+			return err
+		}
+	}
+}
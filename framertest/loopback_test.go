@@ -0,0 +1,61 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framertest_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"code.hybscloud.com/framer"
+	"code.hybscloud.com/framer/framertest"
+)
+
+func TestLoopback_EchoAndClose(t *testing.T) {
+	a, b := framertest.NewLoopback()
+	done := make(chan error, 1)
+	go func() { done <- framertest.Echo(b) }()
+
+	buf := make([]byte, 32*1024)
+	for _, msg := range [][]byte{[]byte("ping"), {}, bytes.Repeat([]byte{'x'}, 20000)} {
+		if _, err := a.Write(msg); err != nil {
+			t.Fatal(err)
+		}
+		n, err := a.Read(buf)
+		if err != nil || !bytes.Equal(buf[:n], msg) {
+			t.Fatalf("echo of %d bytes: got %d bytes, %v", len(msg), n, err)
+		}
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Echo: %v", err)
+	}
+
+	// Single goroutine, nonblocking: writes queue, reads drain in order.
+	a, b = framertest.NewLoopback(framer.WithNonblock())
+	if _, err := b.Read(buf); err != framer.ErrWouldBlock {
+		t.Fatalf("empty read: %v", err)
+	}
+	a.Write([]byte("one"))
+	a.Write([]byte("two"))
+	wantErr := errors.New("reset")
+	a.CloseWithError(wantErr)
+	for _, want := range []string{"one", "two"} {
+		n, err := b.Read(buf)
+		if err != nil || string(buf[:n]) != want {
+			t.Fatalf("got %q, %v; want %q", buf[:n], err, want)
+		}
+	}
+	if _, err := b.Read(buf); err != wantErr {
+		t.Fatalf("after close: %v", err)
+	}
+	if _, err := a.Read(buf); err != framer.ErrWouldBlock {
+		t.Fatalf("other direction: %v", err)
+	}
+	var _ io.ReadWriteCloser = a
+}
@@ -0,0 +1,174 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framertest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"code.hybscloud.com/framer"
+	"code.hybscloud.com/iox"
+)
+
+// roundTripRounds is the number of randomized sequences per check.
+const roundTripRounds = 32
+
+// maxRoundTripMessage bounds generated message sizes.
+const maxRoundTripMessage = 70000
+
+// CheckRoundTrip pushes randomized message sequences through Writer→Reader
+// and through Writer→Forwarder→Reader with opts, using randomized transport
+// chunking and injected iox.ErrWouldBlock on every hop, and fails t unless
+// every message arrives byte-exact and boundary-exact.
+//
+// The seed is time-based and logged on failure; replay a failure with
+// CheckRoundTripSeed. opts must select the stream protocol and must not set a
+// read limit below 70000 bytes, the largest generated message.
+func CheckRoundTrip(t testing.TB, opts ...framer.Option) {
+	t.Helper()
+	CheckRoundTripSeed(t, uint64(time.Now().UnixNano()), opts...)
+}
+
+// CheckRoundTripSeed is CheckRoundTrip with a fixed seed.
+func CheckRoundTripSeed(t testing.TB, seed uint64, opts ...framer.Option) {
+	t.Helper()
+	var o framer.Options
+	for _, fn := range opts {
+		fn(&o)
+	}
+	if o.ReadProto == framer.SeqPacket || o.ReadProto == framer.Datagram ||
+		o.WriteProto == framer.SeqPacket || o.WriteProto == framer.Datagram {
+		t.Fatalf("framertest: CheckRoundTrip requires the stream protocol")
+	}
+	opts = append(opts[:len(opts):len(opts)], framer.WithNonblock())
+	// Size the Forwarder buffer for the largest generated message.
+	fwdOpts := append(opts[:len(opts):len(opts)], framer.WithReadLimit(maxRoundTripMessage))
+
+	rng := rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15))
+	for round := range roundTripRounds {
+		msgs := randomMessages(rng)
+
+		var wire bytes.Buffer
+		w := framer.NewWriter(&faultyWriter{w: &wire, rng: rng}, opts...)
+		for i, m := range msgs {
+			if err := retryWrite(w, m); err != nil {
+				t.Fatalf("seed=%d round=%d: write[%d]: %v", seed, round, i, err)
+			}
+		}
+
+		r := framer.NewReader(&faultyReader{r: bytes.NewReader(wire.Bytes()), rng: rng}, opts...)
+		if err := expectMessages(r, msgs); err != nil {
+			t.Fatalf("seed=%d round=%d: reader: %s", seed, round, err)
+		}
+
+		var relayed bytes.Buffer
+		fw := framer.NewForwarder(&faultyWriter{w: &relayed, rng: rng}, &faultyReader{r: bytes.NewReader(wire.Bytes()), rng: rng}, fwdOpts...)
+		for {
+			_, err := fw.ForwardOnce()
+			if err == io.EOF {
+				break
+			}
+			if err != nil && err != framer.ErrWouldBlock && err != framer.ErrMore {
+				t.Fatalf("seed=%d round=%d: forward: %v", seed, round, err)
+			}
+		}
+		r = framer.NewReader(&faultyReader{r: bytes.NewReader(relayed.Bytes()), rng: rng}, opts...)
+		if err := expectMessages(r, msgs); err != nil {
+			t.Fatalf("seed=%d round=%d: forwarder: %s", seed, round, err)
+		}
+	}
+}
+
+// randomMessages favors sizes around the length-encoding boundaries.
+func randomMessages(rng *rand.Rand) [][]byte {
+	sizes := []int{0, 1, 252, 253, 254, 255, 65535, 65536}
+	msgs := make([][]byte, 1+rng.IntN(16))
+	for i := range msgs {
+		var n int
+		switch rng.IntN(4) {
+		case 0:
+			n = sizes[rng.IntN(len(sizes))]
+		case 1:
+			n = rng.IntN(maxRoundTripMessage)
+		default:
+			n = rng.IntN(300)
+		}
+		m := make([]byte, n)
+		for j := range m {
+			m[j] = byte(rng.Uint32())
+		}
+		msgs[i] = m
+	}
+	return msgs
+}
+
+func retryWrite(w io.Writer, m []byte) error {
+	for {
+		_, err := w.Write(m)
+		if err != framer.ErrWouldBlock && err != framer.ErrMore {
+			return err
+		}
+	}
+}
+
+func expectMessages(r io.Reader, msgs [][]byte) error {
+	buf := make([]byte, maxRoundTripMessage)
+	for i, want := range msgs {
+		n, err := readMessage(r, buf)
+		if err != nil {
+			return fmt.Errorf("read[%d]: %w", i, err)
+		}
+		if !bytes.Equal(buf[:n], want) {
+			return fmt.Errorf("message %d mismatch: got %d bytes, want %d", i, n, len(want))
+		}
+	}
+	if n, err := readMessage(r, buf); err != io.EOF {
+		return fmt.Errorf("trailing data: n=%d err=%v", n, err)
+	}
+	return nil
+}
+
+// faultyReader delivers random-sized chunks and injects iox.ErrWouldBlock on
+// about a quarter of the calls.
+type faultyReader struct {
+	r   io.Reader
+	rng *rand.Rand
+}
+
+func (f *faultyReader) Read(p []byte) (int, error) {
+	if f.rng.IntN(4) == 0 {
+		return 0, iox.ErrWouldBlock
+	}
+	if len(p) > 1 {
+		p = p[:1+f.rng.IntN(len(p))]
+	}
+	return f.r.Read(p)
+}
+
+// faultyWriter accepts random-sized prefixes, reporting short writes as
+// iox.ErrWouldBlock, and injects iox.ErrWouldBlock on about a quarter of the calls.
+type faultyWriter struct {
+	w   io.Writer
+	rng *rand.Rand
+}
+
+func (f *faultyWriter) Write(p []byte) (int, error) {
+	if f.rng.IntN(4) == 0 {
+		return 0, iox.ErrWouldBlock
+	}
+	if len(p) <= 1 {
+		return f.w.Write(p)
+	}
+	k := 1 + f.rng.IntN(len(p))
+	n, err := f.w.Write(p[:k])
+	if err == nil && k < len(p) {
+		err = iox.ErrWouldBlock
+	}
+	return n, err
+}
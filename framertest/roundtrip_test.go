@@ -0,0 +1,18 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framertest_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"code.hybscloud.com/framer"
+	"code.hybscloud.com/framer/framertest"
+)
+
+func TestCheckRoundTrip(t *testing.T) {
+	framertest.CheckRoundTrip(t, framer.WithReadTCP(), framer.WithWriteTCP())
+	framertest.CheckRoundTripSeed(t, 1, framer.WithByteOrder(binary.LittleEndian))
+}
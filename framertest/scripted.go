@@ -0,0 +1,116 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framertest
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// Step scripts the result of transport calls on a ScriptedConn.
+type Step struct {
+	// Data is returned by Read. A Read with a smaller buffer returns it over
+	// several calls; Err comes with its last byte.
+	Data []byte
+
+	// N is the number of bytes Write accepts, at most len(p). A negative N
+	// accepts everything.
+	N int
+
+	// Err is returned with the last byte of Data, or on its own when Data is
+	// empty. For example iox.ErrWouldBlock or iox.ErrMore.
+	Err error
+}
+
+// ScriptedConn is a deterministic transport that replays scripted results,
+// for testing code built on framer against exact sequences of partial reads,
+// short writes, iox.ErrWouldBlock and iox.ErrMore:
+//
+//	conn := &framertest.ScriptedConn{Reads: []framertest.Step{
+//		{Data: []byte{5, 'h', 'e'}},
+//		{Err: iox.ErrWouldBlock},
+//		{Data: []byte("llo")},
+//	}}
+//	r := framer.NewReader(conn)
+//
+// Read consumes Reads in order and returns io.EOF once they are exhausted.
+// Write consumes one step of Writes per call and records the accepted bytes;
+// once Writes are exhausted it accepts everything. ScriptedConn is safe for
+// concurrent use.
+type ScriptedConn struct {
+	Reads  []Step
+	Writes []Step
+
+	mu      sync.Mutex
+	off     int // bytes of Reads[0].Data already returned
+	written bytes.Buffer
+	closed  bool
+}
+
+// Read returns the next scripted data and error.
+func (c *ScriptedConn) Read(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, io.ErrClosedPipe
+	}
+	if len(c.Reads) == 0 {
+		return 0, io.EOF
+	}
+	st := &c.Reads[0]
+	n := copy(p, st.Data[c.off:])
+	c.off += n
+	if c.off < len(st.Data) {
+		return n, nil
+	}
+	err := st.Err
+	c.Reads = c.Reads[1:]
+	c.off = 0
+	return n, err
+}
+
+// Write accepts the scripted number of bytes of p and returns the scripted
+// error.
+func (c *ScriptedConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, io.ErrClosedPipe
+	}
+	n, err := len(p), error(nil)
+	if len(c.Writes) > 0 {
+		st := c.Writes[0]
+		c.Writes = c.Writes[1:]
+		if st.N >= 0 {
+			n = min(st.N, len(p))
+		}
+		err = st.Err
+	}
+	c.written.Write(p[:n])
+	return n, err
+}
+
+// Written returns a copy of the bytes accepted by Write so far.
+func (c *ScriptedConn) Written() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return bytes.Clone(c.written.Bytes())
+}
+
+// Close makes later Read and Write calls fail with io.ErrClosedPipe.
+func (c *ScriptedConn) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+	return nil
+}
+
+// Closed reports whether Close was called.
+func (c *ScriptedConn) Closed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
@@ -0,0 +1,66 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framertest_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"code.hybscloud.com/framer"
+	"code.hybscloud.com/framer/framertest"
+	"code.hybscloud.com/iox"
+)
+
+func TestScriptedConn_ReadScript(t *testing.T) {
+	conn := &framertest.ScriptedConn{Reads: []framertest.Step{
+		{Data: []byte{5, 'h', 'e'}},
+		{Err: iox.ErrWouldBlock},
+		{Data: []byte("ll"), Err: iox.ErrMore},
+		{Data: []byte("o")},
+	}}
+	r := framer.NewReader(conn)
+	buf := make([]byte, 8)
+	n, err := r.Read(buf)
+	if err != iox.ErrWouldBlock || n != 2 {
+		t.Fatalf("first Read: n=%d err=%v", n, err)
+	}
+	n, err = r.Read(buf)
+	if err != nil || n != 3 || string(buf[:5]) != "hello" {
+		t.Fatalf("resumed Read: n=%d err=%v buf=%q", n, err, buf[:5])
+	}
+	if _, err := r.Read(buf); err != io.EOF {
+		t.Fatalf("exhausted script: err=%v", err)
+	}
+}
+
+func TestScriptedConn_WriteScript(t *testing.T) {
+	conn := &framertest.ScriptedConn{Writes: []framertest.Step{
+		{N: 2, Err: iox.ErrWouldBlock},
+		{Err: iox.ErrWouldBlock},
+		{N: -1},
+	}}
+	w := framer.NewWriter(conn)
+	// The header goes out first, so the first step takes only its byte.
+	n, err := w.Write([]byte("hello"))
+	if err != iox.ErrWouldBlock || n != 0 {
+		t.Fatalf("first Write: n=%d err=%v", n, err)
+	}
+	if _, err := w.Write([]byte("hello")); err != iox.ErrWouldBlock {
+		t.Fatalf("second Write: err=%v", err)
+	}
+	if _, err := w.Write([]byte("hello")); err != nil {
+		t.Fatalf("third Write: err=%v", err)
+	}
+	if !bytes.Equal(conn.Written(), []byte{5, 'h', 'e', 'l', 'l', 'o'}) {
+		t.Fatalf("written=% x", conn.Written())
+	}
+	if err := conn.Close(); err != nil || !conn.Closed() {
+		t.Fatal("Close not recorded")
+	}
+	if _, err := conn.Write([]byte("x")); err != io.ErrClosedPipe {
+		t.Fatalf("Write after Close: err=%v", err)
+	}
+}
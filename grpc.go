@@ -0,0 +1,55 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import "encoding/binary"
+
+// grpcHeaderLen is the size of a HeaderGRPC header.
+const grpcHeaderLen = 5
+
+// A HeaderGRPC header is the Length-Prefixed-Message prefix of gRPC over
+// HTTP/2: a Compressed-Flag byte, 0 or 1, and a 4-byte big-endian payload
+// length. The flag is not part of the payload: the Reader records it in
+// Meta.Compressed, and the Writer takes it from WriteMeta. The payload is
+// passed through as is; compressing it is left to the caller.
+
+// putGRPCHeader encodes the header of a v-byte payload.
+func putGRPCHeader(dst []byte, compressed bool, v int64) {
+	dst[0] = 0
+	if compressed {
+		dst[0] = 1
+	}
+	binary.BigEndian.PutUint32(dst[1:grpcHeaderLen], uint32(v))
+}
+
+// grpcLength returns the payload length of a complete HeaderGRPC header.
+func grpcLength(hdr []byte) (int64, error) {
+	if hdr[0] > 1 {
+		return 0, ErrInvalidHeader
+	}
+	return int64(binary.BigEndian.Uint32(hdr[1:grpcHeaderLen])), nil
+}
+
+// readGRPCHeader parses a HeaderGRPC header and records its flag in
+// fr.rmeta. The header size is kept in fr.hlen so it is parsed once per
+// message.
+func (fr *framer) readGRPCHeader() (int64, error) {
+	if fr.hlen > 0 {
+		return fr.hlen, nil
+	}
+	if err := fr.readHeaderBytes(grpcHeaderLen); err != nil {
+		return 0, err
+	}
+	v, err := grpcLength(fr.header[:grpcHeaderLen])
+	if err != nil {
+		return 0, err
+	}
+	if err := fr.parsedLength(v, grpcHeaderLen); err != nil {
+		return 0, err
+	}
+	fr.rmeta = Meta{Compressed: fr.header[0] == 1}
+	fr.hlen = grpcHeaderLen
+	return fr.hlen, nil
+}
@@ -0,0 +1,623 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// HeaderFormat selects the length prefix used in stream mode.
+// Packet-preserving protocols carry no header and ignore it.
+type HeaderFormat uint8
+
+const (
+	// HeaderCompact is the default adaptive 1/3/8-byte header described in
+	// the package documentation.
+	HeaderCompact HeaderFormat = iota
+
+	// HeaderFixed32 is a 4-byte unsigned payload length in the configured
+	// byte order. Payloads are limited to 2^32-1 bytes.
+	HeaderFixed32
+
+	// HeaderUvarint is an unsigned LEB128 varint payload length, as used by
+	// protobuf delimited streams. Payloads are limited to 2^56-1 bytes.
+	HeaderUvarint
+
+	// HeaderStdcopy is the 8-byte header of Docker's multiplexed attach and
+	// exec streams: a stream ID, three zero bytes and a 4-byte big-endian
+	// length. The stream ID is carried as the first payload byte, so messages
+	// are typed messages (see WriteTyped and Router) and ReadLimit counts it;
+	// see stdcopy.go.
+	HeaderStdcopy
+
+	// HeaderFixed16 is a 2-byte unsigned payload length in the configured
+	// byte order, as used by DNS over TCP, SOCKS and Erlang {packet, 2}.
+	// Payloads are limited to 65535 bytes.
+	HeaderFixed16
+
+	// HeaderFixed64 is an 8-byte unsigned payload length in the configured
+	// byte order. Payloads are limited to MaxPayloadLen (2^56-1) bytes like
+	// with the other formats; a Reader rejects larger lengths with a
+	// *LengthOverflowError.
+	HeaderFixed64
+
+	// HeaderMySQL is the 4-byte header of MySQL client/server packets: a
+	// 3-byte little-endian payload length and a sequence ID that the Writer
+	// increments and the Reader validates; see mysql.go and SetSequence.
+	// Payloads are limited to 2^24-1 bytes, and the byte order option does
+	// not apply.
+	HeaderMySQL
+
+	// HeaderGRPC is the 5-byte prefix of gRPC messages: a Compressed-Flag
+	// byte, exposed through Reader.Meta and Writer.WriteMeta, and a 4-byte
+	// big-endian payload length; see grpc.go. Payloads are limited to
+	// 2^32-1 bytes, and the byte order option does not apply.
+	HeaderGRPC
+
+	// HeaderMQTT is the fixed header of MQTT control packets: a packet type
+	// and flags byte, exposed through Reader.Meta and Writer.WriteMeta, and
+	// a 1 to 4-byte varint Remaining Length; see mqtt.go. Payloads are
+	// limited to 2^28-1 bytes.
+	HeaderMQTT
+
+	// HeaderTLS is the 5-byte TLS record header: a content type byte and a
+	// 2-byte record version, both exposed through Reader.Meta and
+	// Writer.WriteMeta, and a 2-byte big-endian length; see tls.go.
+	// Payloads are limited to 2^14+2048 bytes, and the byte order option
+	// does not apply.
+	HeaderTLS
+
+	// HeaderPostgres is the 5-byte header of PostgreSQL frontend/backend
+	// messages: a message type byte, exposed through Reader.Meta and
+	// Writer.WriteMeta, and a 4-byte big-endian length that counts itself;
+	// see postgres.go. Payloads are limited to 2^32-5 bytes, and the byte
+	// order option does not apply.
+	HeaderPostgres
+
+	// HeaderAMQP is the 7-byte header of AMQP 0-9-1 frames: a type byte and
+	// a 2-byte channel, both exposed through Reader.Meta and
+	// Writer.WriteMeta, and a 4-byte big-endian payload size. The payload is
+	// followed by the frame-end octet 0xCE, which the Writer appends and the
+	// Reader checks; see amqp.go. Payloads are limited to 2^32-1 bytes, and
+	// the byte order option does not apply.
+	HeaderAMQP
+)
+
+// maxValue returns the largest length value the format can encode.
+func (h HeaderFormat) maxValue() int64 {
+	switch h {
+	case HeaderFixed16:
+		return math.MaxUint16
+	case HeaderMySQL:
+		return 1<<24 - 1
+	case HeaderMQTT:
+		return mqttMaxLength
+	case HeaderTLS:
+		return tlsMaxRecord
+	case HeaderPostgres:
+		return pgMaxLength
+	case HeaderFixed32, HeaderStdcopy, HeaderGRPC, HeaderAMQP:
+		return math.MaxUint32
+	default:
+		return framePayloadMaxLen56
+	}
+}
+
+// headerLen returns the header size that encodes the length value v.
+func (h HeaderFormat) headerLen(v int64) int64 {
+	switch h {
+	case HeaderFixed16:
+		return 2
+	case HeaderFixed32:
+		return 4
+	case HeaderFixed64:
+		return 8
+	case HeaderMySQL:
+		return mysqlHeaderLen
+	case HeaderGRPC:
+		return grpcHeaderLen
+	case HeaderTLS:
+		return tlsHeaderLen
+	case HeaderPostgres:
+		return pgHeaderLen
+	case HeaderAMQP:
+		return amqpHeaderLen
+	case HeaderStdcopy:
+		return stdcopyHeaderLen
+	case HeaderUvarint, HeaderMQTT:
+		n := int64(1)
+		for u := uint64(v); u >= 0x80; u >>= 7 {
+			n++
+		}
+		if h == HeaderMQTT {
+			n++ // packet type byte
+		}
+		return n
+	default:
+		if v <= framePayloadMaxLen8Bits {
+			return frameHeaderLen
+		} else if v <= framePayloadMaxLen16 {
+			return frameHeaderLen + 2
+		}
+		return frameHeaderLen + 7
+	}
+}
+
+// wire returns the header size and the encoded length value for a payload of
+// length bytes. With inclusive, the value counts the header too; since the
+// header size may depend on the value, it is iterated to a fixed point, which
+// takes at most two steps because headerLen is monotonic.
+func (h HeaderFormat) wire(length int64, inclusive bool) (hdr, v int64) {
+	hdr = h.headerLen(length)
+	if !inclusive {
+		return hdr, length
+	}
+	for h.headerLen(length+hdr) != hdr {
+		hdr = h.headerLen(length + hdr)
+	}
+	return hdr, length + hdr
+}
+
+// put encodes the length value v at the start of dst, which must hold
+// h.headerLen(v) bytes, using byte order bo for multi-byte lengths.
+func (h HeaderFormat) put(dst []byte, bo binary.ByteOrder, v int64) {
+	switch h {
+	case HeaderFixed16:
+		bo.PutUint16(dst[:2], uint16(v))
+	case HeaderFixed32:
+		bo.PutUint32(dst[:4], uint32(v))
+	case HeaderFixed64:
+		bo.PutUint64(dst[:8], uint64(v))
+	case HeaderMySQL:
+		putMySQLHeader(dst, 0, v)
+	case HeaderGRPC:
+		putGRPCHeader(dst, false, v)
+	case HeaderMQTT:
+		putMQTTHeader(dst, 0, v)
+	case HeaderTLS:
+		putTLSHeader(dst, 0, 0, v)
+	case HeaderPostgres:
+		putPostgresHeader(dst, 0, v)
+	case HeaderAMQP:
+		putAMQPHeader(dst, 0, 0, v)
+	case HeaderStdcopy:
+		putStdcopyHeader(dst, 0, v)
+	case HeaderUvarint:
+		binary.PutUvarint(dst, uint64(v))
+	default:
+		if v <= framePayloadMaxLen8Bits {
+			dst[0] = byte(v)
+		} else if v <= framePayloadMaxLen16 {
+			dst[0] = framePayloadMaxLen8Bits + 1
+			bo.PutUint16(dst[frameHeaderLen:frameHeaderLen+2], uint16(v))
+		} else {
+			putWideHeader(dst, bo, v)
+		}
+	}
+}
+
+// putWideHeader encodes v as the MaxHeaderLen-byte compact header (0xFF
+// followed by a 56-bit length), which is valid for any length.
+func putWideHeader(dst []byte, bo binary.ByteOrder, v int64) {
+	if bo == binary.LittleEndian {
+		bo.PutUint64(dst[:8], uint64(v)<<8)
+	} else {
+		bo.PutUint64(dst[:8], uint64(v&framePayloadMaxLen56))
+	}
+	dst[0] = framePayloadMaxLen8Bits + 2
+}
+
+// compactLength returns the length value of a complete compact header.
+func compactLength(hdr []byte, bo binary.ByteOrder) int64 {
+	switch len(hdr) {
+	case frameHeaderLen + 2:
+		return int64(bo.Uint16(hdr[frameHeaderLen:]))
+	case frameHeaderLen + 7:
+		u64 := bo.Uint64(hdr)
+		if bo == binary.LittleEndian {
+			return int64(u64 >> 8)
+		}
+		return int64(u64 & framePayloadMaxLen56)
+	default:
+		return int64(hdr[0])
+	}
+}
+
+const (
+	// MaxHeaderLen is the largest header of the default compact format.
+	MaxHeaderLen = frameHeaderLen + 7
+
+	// MaxPayloadLen is the largest payload a stream message can carry.
+	MaxPayloadLen = framePayloadMaxLen56
+)
+
+// HeaderSize returns the size of the default compact header of a
+// payloadLen-byte message: 1, 3 or MaxHeaderLen bytes. A wire buffer holding
+// the message needs HeaderSize(payloadLen)+payloadLen bytes.
+func HeaderSize(payloadLen int) int {
+	return int(HeaderCompact.headerLen(int64(payloadLen)))
+}
+
+// Header describes the stream-mode length prefix of one message, for tools
+// such as indexers, proxies and analyzers that work on raw frames.
+type Header struct {
+	PayloadLen int64            // payload bytes that follow the header
+	HeaderLen  int              // encoded size of the header
+	ByteOrder  binary.ByteOrder // order of multi-byte lengths; nil means big-endian
+	Format     HeaderFormat     // length prefix format
+	StreamID   byte             // stream of a HeaderStdcopy message
+	Sequence   byte             // sequence ID of a HeaderMySQL packet
+	Compressed bool             // Compressed-Flag of a HeaderGRPC message
+	Type       byte             // HeaderMQTT, HeaderPostgres, HeaderTLS or HeaderAMQP type byte
+	Version    uint16           // record version of a HeaderTLS record
+	Channel    uint16           // channel of a HeaderAMQP frame
+}
+
+// Meta is the per-message metadata that some header formats carry next to
+// the payload length. Each field belongs to the formats named in its comment;
+// the others leave it zero.
+type Meta struct {
+	// Compressed is the Compressed-Flag of a HeaderGRPC message.
+	Compressed bool
+
+	// Type is the type byte: for HeaderMQTT the control packet type in the
+	// high nibble and its flags in the low one, for HeaderPostgres the
+	// message type, for HeaderTLS the record content type and for
+	// HeaderAMQP the frame type.
+	Type byte
+
+	// Version is the legacy record version of a HeaderTLS record, such as
+	// 0x0303.
+	Version uint16
+
+	// Channel is the channel of a HeaderAMQP frame.
+	Channel uint16
+}
+
+// Meta returns the metadata of the message last started by Read or one of
+// the other read methods, once its header has been parsed. It is the zero
+// Meta for header formats that carry none.
+func (r *Reader) Meta() Meta { return r.fr.rmeta }
+
+// WriteMeta is Write with the header metadata m for this message; Write
+// writes the zero Meta. Fields that the header format does not carry are
+// ignored. On ErrWouldBlock or ErrMore, retry with the same m and p.
+func (w *Writer) WriteMeta(m Meta, p []byte) (int, error) {
+	fr := w.fr
+	if !fr.enter() {
+		return 0, ErrConcurrentUse
+	}
+	defer fr.leave()
+	fr.wmeta = m
+	defer func() { fr.wmeta = Meta{} }()
+	if fr.wx != nil || fr.wvx != nil {
+		return fr.writeIntercepted(p)
+	}
+	return fr.write(p)
+}
+
+// DecodeHeader parses the length prefix at the start of b. The header format
+// and byte order are those a Reader built with opts would read; other
+// options, such as WithLengthIncludesHeader or WithControlFrames, are not
+// applied, so PayloadLen is the raw length value.
+//
+// DecodeHeader returns io.ErrUnexpectedEOF when b ends inside the header,
+// ErrTooLong when a uvarint length overflows and a *LengthOverflowError when
+// a HeaderFixed64 length exceeds MaxPayloadLen.
+func DecodeHeader(b []byte, opts ...Option) (Header, error) {
+	o := defaultOptions
+	for _, fn := range opts {
+		fn(&o)
+	}
+	h := Header{ByteOrder: o.ReadByteOrder, Format: o.ReadHeader}
+	err := h.decode(b)
+	return h, err
+}
+
+// decode parses the length prefix at the start of b in h's format and byte
+// order, setting PayloadLen and HeaderLen.
+func (h *Header) decode(b []byte) error {
+	if len(b) == 0 {
+		return io.ErrUnexpectedEOF
+	}
+	switch h.Format {
+	case HeaderFixed16:
+		if len(b) < 2 {
+			return io.ErrUnexpectedEOF
+		}
+		h.PayloadLen, h.HeaderLen = int64(h.ByteOrder.Uint16(b)), 2
+	case HeaderFixed32:
+		if len(b) < 4 {
+			return io.ErrUnexpectedEOF
+		}
+		h.PayloadLen, h.HeaderLen = int64(h.ByteOrder.Uint32(b)), 4
+	case HeaderFixed64:
+		if len(b) < 8 {
+			return io.ErrUnexpectedEOF
+		}
+		u64 := h.ByteOrder.Uint64(b)
+		if u64 > framePayloadMaxLen56 {
+			return &LengthOverflowError{Length: u64}
+		}
+		h.PayloadLen, h.HeaderLen = int64(u64), 8
+	case HeaderMySQL:
+		if len(b) < mysqlHeaderLen {
+			return io.ErrUnexpectedEOF
+		}
+		h.PayloadLen, h.HeaderLen, h.Sequence = mysqlLength(b), mysqlHeaderLen, b[3]
+	case HeaderGRPC:
+		if len(b) < grpcHeaderLen {
+			return io.ErrUnexpectedEOF
+		}
+		v, err := grpcLength(b)
+		if err != nil {
+			return err
+		}
+		h.PayloadLen, h.HeaderLen, h.Compressed = v, grpcHeaderLen, b[0] == 1
+	case HeaderMQTT:
+		v, k, err := mqttLength(b[1:])
+		if err != nil {
+			return err
+		}
+		h.PayloadLen, h.HeaderLen, h.Type = v, 1+k, b[0]
+	case HeaderTLS:
+		if len(b) < tlsHeaderLen {
+			return io.ErrUnexpectedEOF
+		}
+		v, err := tlsLength(b)
+		if err != nil {
+			return err
+		}
+		h.PayloadLen, h.HeaderLen = v, tlsHeaderLen
+		h.Type, h.Version = b[0], binary.BigEndian.Uint16(b[1:3])
+	case HeaderPostgres:
+		if len(b) < pgHeaderLen {
+			return io.ErrUnexpectedEOF
+		}
+		v, err := postgresLength(b)
+		if err != nil {
+			return err
+		}
+		h.PayloadLen, h.HeaderLen, h.Type = v, pgHeaderLen, b[0]
+	case HeaderAMQP:
+		if len(b) < amqpHeaderLen {
+			return io.ErrUnexpectedEOF
+		}
+		h.PayloadLen, h.HeaderLen = int64(binary.BigEndian.Uint32(b[3:amqpHeaderLen])), amqpHeaderLen
+		h.Type, h.Channel = b[0], binary.BigEndian.Uint16(b[1:3])
+	case HeaderStdcopy:
+		if len(b) < stdcopyHeaderLen {
+			return io.ErrUnexpectedEOF
+		}
+		v, err := stdcopyLength(b)
+		if err != nil {
+			return err
+		}
+		h.PayloadLen, h.HeaderLen, h.StreamID = v, stdcopyHeaderLen, b[0]
+	case HeaderUvarint:
+		u64, k := binary.Uvarint(b)
+		if k == 0 {
+			return io.ErrUnexpectedEOF
+		}
+		if k < 0 || u64 > framePayloadMaxLen56 {
+			return ErrTooLong
+		}
+		h.PayloadLen, h.HeaderLen = int64(u64), k
+	default:
+		n := frameHeaderLen
+		switch b[0] {
+		case framePayloadMaxLen8Bits + 1:
+			n += 2
+		case framePayloadMaxLen8Bits + 2:
+			n += 7
+		}
+		if len(b) < n {
+			return io.ErrUnexpectedEOF
+		}
+		h.PayloadLen, h.HeaderLen = compactLength(b[:n], h.ByteOrder), n
+	}
+	return nil
+}
+
+// Encode writes the length prefix of a PayloadLen-byte payload to dst in
+// h's format and byte order, and returns the number of bytes written, which
+// is also stored in h.HeaderLen. It returns ErrTooLong when the format cannot
+// encode PayloadLen and io.ErrShortBuffer when dst is too small.
+func (h *Header) Encode(dst []byte) (int, error) {
+	if h.PayloadLen < 0 || h.PayloadLen > h.Format.maxValue() {
+		return 0, ErrTooLong
+	}
+	bo := h.ByteOrder
+	if bo == nil {
+		bo = binary.BigEndian
+	}
+	n := h.Format.headerLen(h.PayloadLen)
+	if int64(len(dst)) < n {
+		return 0, io.ErrShortBuffer
+	}
+	h.Format.put(dst, bo, h.PayloadLen)
+	switch h.Format {
+	case HeaderStdcopy:
+		dst[0] = h.StreamID
+	case HeaderMySQL:
+		dst[3] = h.Sequence
+	case HeaderGRPC:
+		putGRPCHeader(dst, h.Compressed, h.PayloadLen)
+	case HeaderMQTT, HeaderPostgres:
+		dst[0] = h.Type
+	case HeaderTLS:
+		putTLSHeader(dst, h.Type, h.Version, h.PayloadLen)
+	case HeaderAMQP:
+		putAMQPHeader(dst, h.Type, h.Channel, h.PayloadLen)
+	}
+	h.HeaderLen = int(n)
+	return int(n), nil
+}
+
+// Profile names a well-known framing convention.
+type Profile uint8
+
+const (
+	// ProfileErlangPacket4 matches Erlang/OTP sockets with {packet, 4}:
+	// 4-byte big-endian length prefix.
+	ProfileErlangPacket4 Profile = iota + 1
+
+	// ProfileJavaDataStream matches DataOutputStream.writeInt(len) followed by
+	// the payload: 4-byte big-endian length prefix.
+	ProfileJavaDataStream
+
+	// ProfileProtobufDelimited matches protobuf writeDelimitedTo /
+	// parseDelimitedFrom: uvarint length prefix.
+	ProfileProtobufDelimited
+
+	// ProfileDockerStdcopy matches the multiplexed stdout/stderr stream of
+	// Docker's attach and exec endpoints: see HeaderStdcopy.
+	ProfileDockerStdcopy
+
+	// ProfileErlangPacket2 matches Erlang/OTP sockets with {packet, 2}:
+	// 2-byte big-endian length prefix.
+	ProfileErlangPacket2
+
+	// ProfileMySQL matches the MySQL client/server protocol: see HeaderMySQL.
+	ProfileMySQL
+
+	// ProfileGRPC matches gRPC length-prefixed messages: see HeaderGRPC.
+	ProfileGRPC
+
+	// ProfileMQTT matches MQTT control packets: see HeaderMQTT.
+	ProfileMQTT
+
+	// ProfileTLS matches the TLS record layer: see HeaderTLS.
+	ProfileTLS
+
+	// ProfilePostgres matches PostgreSQL frontend/backend messages after
+	// startup: see HeaderPostgres.
+	ProfilePostgres
+
+	// ProfileAMQP matches AMQP 0-9-1 frames after the protocol header: see
+	// HeaderAMQP.
+	ProfileAMQP
+)
+
+// WithProfile configures both directions for the framing convention p:
+// stream protocol, header format and byte order. Options applied after it
+// can override individual settings, e.g. WithReadLimit.
+func WithProfile(p Profile) Option {
+	return func(o *Options) {
+		var h HeaderFormat
+		switch p {
+		case ProfileErlangPacket2:
+			h = HeaderFixed16
+		case ProfileErlangPacket4, ProfileJavaDataStream:
+			h = HeaderFixed32
+		case ProfileProtobufDelimited:
+			h = HeaderUvarint
+		case ProfileDockerStdcopy:
+			h = HeaderStdcopy
+		case ProfileMySQL:
+			h = HeaderMySQL
+		case ProfileGRPC:
+			h = HeaderGRPC
+		case ProfileMQTT:
+			h = HeaderMQTT
+		case ProfileTLS:
+			h = HeaderTLS
+		case ProfilePostgres:
+			h = HeaderPostgres
+		case ProfileAMQP:
+			h = HeaderAMQP
+		default:
+			return
+		}
+		o.ReadProto, o.WriteProto = BinaryStream, BinaryStream
+		o.ReadHeader, o.WriteHeader = h, h
+		o.ReadByteOrder, o.WriteByteOrder = binary.BigEndian, binary.BigEndian
+	}
+}
+
+// WithFixedHeader makes the Writer emit the MaxHeaderLen-byte compact header
+// (0xFF and a 56-bit length) for every message, whatever its size, so the
+// payload always starts at a constant offset for zero-copy parsers and
+// offload engines. It costs up to 7 bytes per small message; Readers accept
+// the wide header for any length, so the peer needs no configuration. It has
+// no effect on the fixed-size or varint header formats.
+func WithFixedHeader() Option {
+	return func(o *Options) { o.FixedHeader = true }
+}
+
+// WithStrictHeaders makes the Reader accept only minimal compact headers, as
+// written by default: a header using 2 or 7 extended length bytes for a
+// length that fits in fewer fails with ErrInvalidHeader. In exchange, the
+// first header byte bounds the length, so a frame that ReadLimit rejects
+// anyway is refused with an *OversizeError right after that byte, without
+// waiting for the extended length bytes; with a ReadLimit below 64KiB, every
+// 0xFF-headed frame is refused this way, which shrinks the work a peer can
+// force with floods of oversized lengths. It cannot be used with peers that
+// set WithFixedHeader, and has no effect on the other header formats.
+func WithStrictHeaders() Option {
+	return func(o *Options) { o.StrictHeaders = true }
+}
+
+// compactMinValue returns the smallest length value a minimal compact header
+// with exLen extended length bytes carries.
+func compactMinValue(exLen int64) int64 {
+	switch exLen {
+	case 2:
+		return framePayloadMaxLen8Bits + 1
+	case 7:
+		return framePayloadMaxLen16 + 1
+	}
+	return 0
+}
+
+// WithHeaderFormat sets the stream-mode length prefix for both directions.
+func WithHeaderFormat(h HeaderFormat) Option {
+	return func(o *Options) {
+		o.ReadHeader = h
+		o.WriteHeader = h
+	}
+}
+
+// WithFixedLengthHeader selects a plain unsigned length prefix of size bytes
+// in the configured byte order (big-endian by default) for both directions,
+// as used by Thrift framed transport, Kafka and many RPC protocols (size 4)
+// or DNS over TCP (size 2). Sizes 2, 4 and 8 select HeaderFixed16,
+// HeaderFixed32 and HeaderFixed64; other sizes leave the header format
+// unchanged.
+func WithFixedLengthHeader(size int) Option {
+	return func(o *Options) {
+		var h HeaderFormat
+		switch size {
+		case 2:
+			h = HeaderFixed16
+		case 4:
+			h = HeaderFixed32
+		case 8:
+			h = HeaderFixed64
+		default:
+			return
+		}
+		o.ReadHeader, o.WriteHeader = h, h
+	}
+}
+
+// WithVarintLength selects the unsigned varint length prefix (HeaderUvarint)
+// for both directions, as used by protobuf delimited streams, libp2p and
+// multiformats. It is WithHeaderFormat(HeaderUvarint); the protocol and byte
+// order are left as configured.
+func WithVarintLength() Option { return WithHeaderFormat(HeaderUvarint) }
+
+// WithLengthIncludesHeader makes the length prefix count the header bytes as
+// well as the payload, on both the read and the write side, for peers that
+// encode the total frame length. A received length smaller than its own
+// header is rejected with ErrInvalidHeader.
+func WithLengthIncludesHeader() Option {
+	return func(o *Options) {
+		o.ReadLengthIncludesHeader = true
+		o.WriteLengthIncludesHeader = true
+	}
+}
@@ -0,0 +1,84 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import "io"
+
+// Hybrid streams start framed and switch to raw bytes, or back, at a frame
+// boundary, as in protocol upgrades and HTTP CONNECT tunnels: after the
+// message that announces the switch, RawRemainder and Raw hand the transport
+// over for unframed I/O. The framer reads past the message it delivers only
+// into the buffer of WithPrefetch, which RawRemainder drains first, so no
+// byte of the raw phase is lost. Framed I/O may resume when
+// the raw phase ends at a point both peers agree on.
+
+// RawRemainder returns a reader of the raw transport bytes that follow the
+// last message read, for switching a framed stream to pass-through. Its Read
+// fails with ErrInvalidArgument while a message is in flight, and with
+// ErrConcurrentUse during another Reader operation.
+func (r *Reader) RawRemainder() io.Reader { return rawRemainder{r.fr} }
+
+// Raw returns a writer of raw transport bytes that follow the last message
+// written. Its Write flushes the frames buffered by WithWriteBuffer or
+// WithBundling first, so raw bytes never overtake them. Write fails with
+// ErrInvalidArgument while a message is in flight, and with ErrConcurrentUse
+// during another Writer operation.
+func (w *Writer) Raw() io.Writer { return rawWriter{w.fr} }
+
+type rawRemainder struct{ fr *framer }
+
+func (r rawRemainder) Read(p []byte) (int, error) {
+	fr := r.fr
+	if !fr.enter() {
+		return 0, ErrConcurrentUse
+	}
+	defer fr.leave()
+	if fr.closed.Load() {
+		return 0, ErrClosed
+	}
+	if fr.offset != 0 || fr.rd == nil {
+		return 0, ErrInvalidArgument
+	}
+	if fr.snOff < fr.snLen {
+		n := copy(p, fr.sniff[fr.snOff:fr.snLen])
+		fr.snOff += n
+		return n, nil
+	}
+	if fr.doff < fr.dlen {
+		// Delimited lines are scanned ahead of the prefetch buffer.
+		n := copy(p, fr.dbuf[fr.doff:fr.dlen])
+		fr.doff += n
+		fr.dscan = 0
+		return n, nil
+	}
+	if fr.pfOff < fr.pfLen {
+		return fr.takePrefetched(p), nil
+	}
+	if err := fr.pfErr; err != nil {
+		fr.pfErr = nil
+		return 0, err
+	}
+	return fr.rd.Read(p)
+}
+
+type rawWriter struct{ fr *framer }
+
+func (w rawWriter) Write(p []byte) (int, error) {
+	fr := w.fr
+	if !fr.enter() {
+		return 0, ErrConcurrentUse
+	}
+	defer fr.leave()
+	if fr.closed.Load() {
+		return 0, ErrClosed
+	}
+	if fr.offset != 0 || fr.wr == nil {
+		return 0, ErrInvalidArgument
+	}
+	if err := fr.flush(); err != nil {
+		return 0, err
+	}
+	return fr.wr.Write(p)
+}
@@ -0,0 +1,113 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import "io"
+
+// Interceptor transforms a message payload; see WithWriteInterceptor and
+// WithReadInterceptor. It may modify payload in place and return it, or
+// return another slice.
+type Interceptor func(payload []byte) ([]byte, error)
+
+// WithWriteInterceptor adds fn to the interceptors that Writer.Write applies
+// to each payload before framing it, in the order they were added, so
+// cross-cutting concerns such as redaction, signing or size stamping are
+// attached to a Writer once instead of at every call site. An error aborts
+// the message and is returned by Write.
+//
+// Write reports len(p) once the transformed message is written and 0 until
+// then. After ErrWouldBlock or ErrMore, retry with the same p: the
+// interceptors are not run again and the slice they returned is written, so
+// it must stay unchanged until Write succeeds. The other write methods
+// (WriteTyped, Writev, WriteFrom, ReadFrom, WriteUrgent, WriteControl) and
+// Forwarder bypass the interceptors.
+func WithWriteInterceptor(fn Interceptor) Option {
+	return func(o *Options) { o.WriteInterceptors = append(o.WriteInterceptors, fn) }
+}
+
+// ValueInterceptor is an Interceptor that also receives the value attached
+// to the message by Writer.WriteValue, or nil for messages written with
+// Write.
+type ValueInterceptor func(v any, payload []byte) ([]byte, error)
+
+// WithWriteValueInterceptor adds fn to the interceptors that Writer.Write and
+// Writer.WriteValue apply to each payload, like WithWriteInterceptor. They
+// run in the order they were added, after all interceptors added with
+// WithWriteInterceptor, and are bypassed by the same write methods. The value
+// stays on the writing side; see WriteValue.
+func WithWriteValueInterceptor(fn ValueInterceptor) Option {
+	return func(o *Options) { o.WriteValueInterceptors = append(o.WriteValueInterceptors, fn) }
+}
+
+// WithReadInterceptor adds fn to the interceptors that Reader.Read applies,
+// in the order they were added, to each complete payload once it has been
+// read into p. The result is copied back into p, and Read returns its
+// length, or io.ErrShortBuffer when it does not fit. Read reports 0 until the
+// message is complete. An error drops the message and is returned by Read.
+// WriteTo, ReadTo, CopyFrames and Forwarder bypass the interceptors.
+func WithReadInterceptor(fn Interceptor) Option {
+	return func(o *Options) { o.ReadInterceptors = append(o.ReadInterceptors, fn) }
+}
+
+// writeIntercepted writes p through the write interceptors.
+func (fr *framer) writeIntercepted(p []byte) (int, error) {
+	if !fr.wxOn {
+		msg := p
+		for _, fn := range fr.wx {
+			var err error
+			if msg, err = fn(msg); err != nil {
+				return 0, err
+			}
+		}
+		for _, fn := range fr.wvx {
+			var err error
+			if msg, err = fn(fr.wval, msg); err != nil {
+				return 0, err
+			}
+		}
+		fr.wxMsg, fr.wxOn = msg, true
+	}
+	if _, err := fr.write(fr.wxMsg); err != nil {
+		if err != ErrWouldBlock && err != ErrMore {
+			fr.wxMsg, fr.wxOn = nil, false
+		}
+		return 0, err
+	}
+	fr.wxMsg, fr.wxOn = nil, false
+	return len(p), nil
+}
+
+// readIntercepted reads a message into p and passes it through the read
+// interceptors.
+func (fr *framer) readIntercepted(p []byte) (int, error) {
+	n, err := fr.read(p)
+	fr.rxLen += n
+	if err != nil {
+		if err != ErrWouldBlock && err != ErrMore {
+			fr.rxLen = 0
+		}
+		return 0, err
+	}
+	msg, err := fr.intercept(p[:fr.rxLen])
+	fr.rxLen = 0
+	if err != nil {
+		return 0, err
+	}
+	if len(msg) > len(p) {
+		return 0, io.ErrShortBuffer
+	}
+	return copy(p, msg), nil
+}
+
+// intercept passes a complete message through the read interceptors.
+func (fr *framer) intercept(msg []byte) ([]byte, error) {
+	for _, fn := range fr.rx {
+		var err error
+		if msg, err = fn(msg); err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
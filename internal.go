@@ -5,9 +5,13 @@
 package framer
 
 import (
+	"bytes"
 	"encoding/binary"
 	"io"
+	"log/slog"
+	"reflect"
 	"runtime"
+	"sync/atomic"
 	"time"
 )
 
@@ -26,14 +30,132 @@ type framer struct {
 	wbo binary.ByteOrder
 	wpr Protocol
 
+	// rio and wio perform the transport I/O: rd and wr themselves, or their
+	// file descriptor with WithRawConn.
+	rio io.Reader
+	wio io.Writer
+	raw bool
+
 	readLimit int64
+	budget    int64 // payload bytes a LimitedReader may still deliver; -1 when unlimited
+	rtrunc    bool  // deliver truncated messages, see WithTruncatedDelivery
+
+	// minimum read rate and message deadline, see WithMinReadRate and
+	// WithMessageDeadline
+	minRate     int64
+	rateGrace   time.Duration
+	msgDeadline time.Duration
+	rstart      time.Time // arrival of the first byte of the in-flight message
+	rabort      error     // the stream was aborted by checkPace
+
+	// write stall limit, see WithWriteStallLimit
+	stallTimeout time.Duration
+	stallRetries int
+	wstart       time.Time // first ErrWouldBlock met by the message being written
+	wstalls      int       // ErrWouldBlock results met by it
+
+	// interceptors, see WithWriteInterceptor and WithReadInterceptor
+	wx   []Interceptor
+	wvx  []ValueInterceptor
+	wval any // value attached by Writer.WriteValue to the message being written
+
+	// structured logging, see WithLogger
+	log        *slog.Logger
+	logErr     error     // last error logged
+	logErrNext time.Time // when a repeat of logErr may be logged
+	streak     int       // ErrWouldBlock results since the last progress
+	stormNext  time.Time // when the next retry storm may be logged
+
+	// retransmit buffer, see WithRetransmitBuffer
+	rtx       bool
+	rtxBuf    []byte // copy of the last message
+	rtxOn     bool   // rtxBuf holds a message
+	rewriting bool   // RewriteLast is writing rtxBuf
+	rx        []Interceptor
+	wxMsg     []byte // interceptor output of the message being written
+	wxOn      bool   // wxMsg is in flight
+	rxLen     int    // bytes of the intercepted message read so far
+
+	// read-ahead buffer, see WithPrefetch: pf[pfOff:pfLen] is unread
+	pf    []byte
+	pfOff int
+	pfLen int
+	pfErr error // transport error met while reading ahead
+
+	abuf  []byte // message being read by Reader.ReadAlloc
+	bufOn bool   // Reader.ReadBuffer has sized its Buffer for the message in flight
+
+	avBuf   bytes.Buffer // message being read by Reader.ReadAvailable
+	avOn    bool         // ReadAvailable is running: at most one transport read
+	avSpent bool         // ReadAvailable has made its transport read
+
+	closed atomic.Bool // set by Close; checked by every operation and retry loop
+	busy   atomic.Bool // an operation is in progress, see enter
+
+	released bool // buffers freed after Close, see releaseIdle
 
 	retryDelay time.Duration
+	spin       SpinPolicy
+	waits      int // consecutive waits without progress, see SpinPolicy
+	waitFunc   func(dir Direction) error
+	mem        *MemoryBudget     // see WithMemoryBudget
+	errMap     func(error) error // see WithErrorMapper
+	rmore      bool              // absorb ErrMore on the read side, see MoreAbsorb
+	wmore      bool              // absorb ErrMore on the write side
+	allocator  Allocator
+
+	// stream header formats
+	rhf  HeaderFormat
+	whf  HeaderFormat
+	rinc bool // length prefix includes the header on the read side
+	winc bool // length prefix includes the header on the write side
+
+	wfixed  bool // always write the MaxHeaderLen compact header, see WithFixedHeader
+	rstrict bool // reject non-minimal compact headers, see WithStrictHeaders
+
+	// control frames, see WithControlFrames: a flags byte follows the length
+	// prefix on both sides
+	rflags  bool
+	wflags  bool
+	wctl    byte // flags of the frame being written: flagControl for control frames
+	control func(payload []byte) error
+	cbuf    []byte     // control frame payload being read
+	ping    *pingState // shared with the other side of a ReadWriter, see Ping
+
+	seq *atomic.Uint32 // HeaderMySQL sequence ID, shared by a ReadWriter, see mysql.go
+
+	// WithAutoDetect: the candidate formats until one is locked, the bytes
+	// sniffed to choose, of which sniff[snOff:snLen] are unread, and the
+	// detected format shared with the Writer of a ReadWriter.
+	auto     []HeaderFormat
+	sniff    [16]byte
+	snOff    int
+	snLen    int
+	detected *atomic.Int32
+
+	rmeta Meta // header metadata of the last message read, see Meta
+	wmeta Meta // header metadata of the message being written
+
+	// Delimited, COBS and SLIP protocols, see WithDelimiter and WithCOBS:
+	// dbuf[doff:dlen] holds stream bytes not yet delivered, of which the
+	// first dscan hold no delimiter; ebuf holds the encoded message.
+	rdelim []byte
+	wdelim []byte
+	dbuf   []byte
+	ebuf   []byte
+	doff   int
+	dlen   int
+	dscan  int
+	dskip  bool // discarding the rest of an overlong line
 
 	// stream state
-	header [8]byte
+	header [16]byte
 	length int64 // payload length for current message
 	offset int64 // bytes processed in (header+payload)
+	hlen   int64 // parsed header size of a variable-width header, 0 until known
+	rid    bool  // the HeaderStdcopy stream ID is still to be delivered, see stdcopy.go
+	whold  bool  // the HeaderStdcopy header is held back for the stream ID
+	hoff   int   // bytes of the held-back header written
 
 	// reusable scratch buffer for Reader.WriteTo fast path
 	rbuf []byte
@@ -45,8 +167,49 @@ type framer struct {
 	wtOff int
 	wtLen int
 
-	// reusable scratch buffer for Writer.ReadFrom fast path
+	// ReadTo state: the in-flight message has been fully read and only the
+	// wtOff..wtLen tail remains to be written.
+	rtDone bool
+
+	// CopyFrames state: a message of cpLen payload bytes is being copied,
+	// with its current chunk held in rbuf at wtOff..wtLen.
+	cpOn  bool
+	cpLen int64
+
+	// reusable scratch buffer for Writer.ReadFrom fast path and WriteFrom
 	wbuf []byte
+
+	// WriteFrom resume state: wfOff..wfLen marks bytes read from the source
+	// into wbuf but not yet written.
+	wfOff int
+	wfLen int
+
+	// reusable scratch buffer for Writer.WriteTyped and packet-mode Writev
+	tbuf []byte
+
+	// write buffer coalescing stream frames, see WithWriteBuffer
+	coal   *coalescer
+	urgent bool // bypass coal for the frame of WriteUrgent
+
+	// cumulative counters, see Stats
+	rstats dirStats
+	wstats dirStats
+
+	// Forward error correction state in packet mode (see WithFEC).
+	rfec *fecReader
+	wfec *fecWriter
+
+	// Duplicate suppression state in packet mode (see WithDedup).
+	rdup *dedupWindow
+	wdup *dedupWriter
+
+	// Bundling state in packet mode (see WithBundling).
+	rbun *bundleReader
+	wbun *bundleWriter
+
+	// Segmentation state in packet mode (see WithSegmentation).
+	rseg *segReader
+	wseg *segWriter
 }
 
 func newFramer(r io.Reader, w io.Writer, opts ...Option) *framer {
@@ -63,15 +226,362 @@ func newFramer(r io.Reader, w io.Writer, opts ...Option) *framer {
 		rpr:       o.ReadProto,
 		wpr:       o.WriteProto,
 		readLimit: int64(o.ReadLimit),
+		budget:    -1,
+		rtrunc:    o.DeliverTruncated,
+		rateGrace: o.MinReadGrace,
+		rhf:       o.ReadHeader,
+		whf:       o.WriteHeader,
+		rinc:      o.ReadLengthIncludesHeader,
+		winc:      o.WriteLengthIncludesHeader,
+		wfixed:    o.FixedHeader && o.WriteHeader == HeaderCompact,
+		rstrict:   o.StrictHeaders,
+		rflags:    o.ControlFrames,
+		wflags:    o.ControlFrames,
+		control:   o.ControlHandler,
+		seq:       newSequence(&o),
 
 		retryDelay: o.RetryDelay,
+		log:        o.Logger,
+		spin:       o.SpinPolicy,
+		waitFunc:   o.WaitFunc,
+		errMap:     o.ErrorMapper,
+		rmore:      o.ReadMore == MoreAbsorb,
+		wmore:      o.WriteMore == MoreAbsorb,
+		allocator:  o.Allocator,
+		mem:        o.MemoryBudget,
+		raw:        o.RawConn,
 	}
+	fr.rio, fr.wio = fr.rawReader(r), fr.rawWriter(w)
+	if r != nil {
+		fr.rx = o.ReadInterceptors
+	}
+	if w != nil {
+		fr.wx = o.WriteInterceptors
+		fr.wvx = o.WriteValueInterceptors
+		fr.rtx = o.RetransmitBuffer
+	}
+	if r != nil {
+		fr.rdelim = delimiterOf(&o, o.ReadProto)
+	}
+	if w != nil {
+		fr.wdelim = delimiterOf(&o, o.WriteProto)
+	}
+	if r != nil && !o.ReadProto.preserveBoundary() && len(o.AutoDetect) > 0 {
+		fr.auto, fr.detected = o.AutoDetect, newDetected(&o)
+	}
+	if r != nil && !o.ReadProto.preserveBoundary() {
+		if o.Prefetch > 0 {
+			fr.pf = fr.newBuf(o.Prefetch)
+		}
+		fr.minRate = int64(max(o.MinReadRate, 0))
+		fr.msgDeadline = max(o.MessageDeadline, 0)
+	}
+	if w != nil {
+		fr.stallTimeout = max(o.WriteStallTimeout, 0)
+		fr.stallRetries = max(o.WriteStallRetries, 0)
+	}
+	if w != nil && !o.WriteProto.preserveBoundary() && (o.WriteBufferSize > 0 || o.FlushLatency > 0) {
+		fr.coal = newCoalescer(fr, o.WriteBufferSize, o.FlushLatency)
+	}
+	if o.BundleSize > 0 {
+		if r != nil && o.ReadProto.preserveBoundary() {
+			fr.rbun = &bundleReader{buf: make([]byte, 64*1024)}
+		}
+		if w != nil && o.WriteProto.preserveBoundary() {
+			fr.wbun = &bundleWriter{}
+			fr.coal = newCoalescer(fr, o.BundleSize, o.FlushLatency)
+		}
+	}
+	if o.SegmentMTU > 0 {
+		if r != nil && o.ReadProto.preserveBoundary() {
+			fr.rseg = &segReader{buf: make([]byte, 64*1024)}
+		}
+		if w != nil && o.WriteProto.preserveBoundary() {
+			fr.wseg = newSegWriter(o)
+		}
+	}
+	if o.DedupWindow > 0 {
+		if r != nil && o.ReadProto.preserveBoundary() {
+			fr.rdup = newDedupWindow(o.DedupWindow)
+		}
+		if w != nil && o.WriteProto.preserveBoundary() {
+			fr.wdup = &dedupWriter{}
+		}
+	}
+	if o.FECGroup > 0 {
+		if r != nil && o.ReadProto.preserveBoundary() {
+			fr.rfec = &fecReader{buf: make([]byte, 64*1024+fecHeader)}
+		}
+		if w != nil && o.WriteProto.preserveBoundary() {
+			fr.wfec = &fecWriter{k: o.FECGroup}
+		}
+	}
+	fr.charge(fr.packetBufs())
 	return fr
 }
 
+// setReadLimit updates the read-side payload limit. A reusable WriteTo
+// scratch buffer is grown, preserving its contents, so a raised limit is
+// honored without dropping bytes of an in-flight message.
+func (fr *framer) setReadLimit(limit int) {
+	fr.readLimit = int64(limit)
+	if fr.rbuf != nil && limit > cap(fr.rbuf) {
+		nb := fr.newBuf(limit)
+		copy(nb, fr.rbuf)
+		fr.freeBuf(fr.rbuf)
+		fr.rbuf = nb
+	}
+}
+
+func (fr *framer) swapReader(r io.Reader) io.Reader {
+	old := fr.rd
+	fr.rd, fr.rio = r, fr.rawReader(r)
+	fr.rabort, fr.rxLen, fr.abuf, fr.bufOn = nil, 0, nil, false
+	fr.avBuf.Reset()
+	fr.pfOff, fr.pfLen, fr.pfErr = 0, 0, nil
+	fr.doff, fr.dlen, fr.dscan, fr.dskip = 0, 0, 0, false
+	fr.snOff, fr.snLen = 0, 0
+	fr.logResync(DirRead)
+	fr.reset()
+	fr.wtOff, fr.wtLen, fr.rtDone, fr.cpOn = 0, 0, false, false
+	return old
+}
+
+func (fr *framer) swapWriter(w io.Writer) io.Writer {
+	old := fr.wr
+	if fr.coal != nil {
+		// Bytes buffered for the previous transport are dropped with it.
+		fr.coal.mu.Lock()
+		fr.wr, fr.wio = w, fr.rawWriter(w)
+		fr.coal.buf = fr.coal.buf[:0]
+		fr.coal.mu.Unlock()
+	} else {
+		fr.wr, fr.wio = w, fr.rawWriter(w)
+	}
+	fr.wxMsg, fr.wxOn = nil, false
+	fr.rewriting = false
+	fr.logResync(DirWrite)
+	fr.reset()
+	return old
+}
+
+// scratch returns the reusable read-side buffer shared by WriteTo, ReadTo
+// and CopyFrames, sized by ReadLimit or 64KiB when there is none.
+func (fr *framer) scratch() ([]byte, error) {
+	if fr.rbuf == nil {
+		var err error
+		if fr.rbuf, err = fr.growBuf(nil, fr.scratchSize()); err != nil {
+			return nil, err
+		}
+	}
+	return fr.rbuf, nil
+}
+
+// readAlloc reads the next message into a buffer of its size, see
+// Reader.ReadAlloc.
+func (fr *framer) readAlloc() ([]byte, error) {
+	if fr.rpr.preserveBoundary() {
+		buf, err := fr.scratch()
+		if err != nil {
+			return nil, err
+		}
+		n, err := fr.read(buf)
+		if err != nil {
+			return nil, err
+		}
+		return fr.intercept(append(make([]byte, 0, n), buf[:n]...))
+	}
+	if fr.abuf == nil {
+		// Drive header parse to learn the payload length.
+		if _, err := fr.read(nil); err != io.ErrShortBuffer {
+			if err != nil {
+				return nil, err
+			}
+			return fr.intercept([]byte{})
+		}
+		if fr.length > int64(fr.scratchSize()) {
+			return nil, ErrTooLong
+		}
+		fr.abuf = make([]byte, fr.length)
+	}
+	if _, err := fr.read(fr.abuf); err != nil {
+		if err != ErrWouldBlock && err != ErrMore {
+			fr.abuf = nil
+		}
+		return nil, err
+	}
+	msg := fr.abuf
+	fr.abuf = nil
+	return fr.intercept(msg)
+}
+
+// readBuffer appends the next message to b, see Reader.ReadBuffer.
+func (fr *framer) readBuffer(b *bytes.Buffer) (int, error) {
+	if fr.rpr.preserveBoundary() {
+		size := fr.scratchSize()
+		b.Grow(size)
+		p := b.AvailableBuffer()[:size]
+		n, err := fr.read(p)
+		if err != nil {
+			return 0, err
+		}
+		return fr.appendMsg(b, p[:n])
+	}
+	if !fr.bufOn {
+		// Drive header parse to learn the payload length.
+		if _, err := fr.read(nil); err != io.ErrShortBuffer {
+			if err != nil {
+				return 0, err
+			}
+			return fr.appendMsg(b, nil)
+		}
+		if fr.length > int64(fr.scratchSize()) {
+			return 0, ErrTooLong
+		}
+		b.Grow(int(fr.length))
+		fr.bufOn = true
+	}
+	p := b.AvailableBuffer()
+	if int64(cap(p)) < fr.length {
+		// b was changed between calls.
+		return 0, ErrInvalidArgument
+	}
+	p = p[:fr.length]
+	if _, err := fr.read(p); err != nil {
+		if err != ErrWouldBlock && err != ErrMore {
+			fr.bufOn = false
+		}
+		return 0, err
+	}
+	fr.bufOn = false
+	return fr.appendMsg(b, p)
+}
+
+// appendMsg appends a message read into the spare capacity of b, passing it
+// through the read interceptors.
+func (fr *framer) appendMsg(b *bytes.Buffer, msg []byte) (int, error) {
+	msg, err := fr.intercept(msg)
+	if err != nil {
+		return 0, err
+	}
+	return b.Write(msg)
+}
+
+// scratchSize returns the size scratch allocates.
+func (fr *framer) scratchSize() int {
+	if fr.readLimit > 0 {
+		return int(fr.readLimit)
+	}
+	return 64 * 1024
+}
+
+// close flushes the write buffer, marks the framer closed, frees its buffers
+// and closes t when it implements io.Closer. Only the first call has an
+// effect. An operation still running on another goroutine keeps the buffers
+// until it returns.
+func (fr *framer) close(t any) error {
+	if fr.coal != nil && !fr.closed.Load() {
+		fr.coal.stopFlusher(false)
+		_ = fr.flush()
+	}
+	if fr.closed.Swap(true) {
+		return nil
+	}
+	fr.releaseIdle()
+	return closeTransport(t)
+}
+
+// enter marks the start of an operation and reports false when another
+// operation is still in progress, which means the Reader or Writer is used
+// from several goroutines at once. Without the check, overlapping calls
+// would corrupt the shared message state.
+func (fr *framer) enter() bool { return fr.busy.CompareAndSwap(false, true) }
+
+// leave marks the end of an operation started by enter, and frees the
+// buffers when the framer was closed meanwhile.
+func (fr *framer) leave() {
+	fr.busy.Store(false)
+	if fr.closed.Load() {
+		fr.releaseIdle()
+	}
+}
+
+// releaseIdle frees the buffers of a closed framer once, unless an
+// operation is using them; that operation frees them in leave.
+func (fr *framer) releaseIdle() {
+	if !fr.enter() {
+		return
+	}
+	if !fr.released {
+		fr.released = true
+		fr.releaseBufs()
+	}
+	fr.busy.Store(false)
+}
+
+// sameTransport reports whether a and b are the same comparable transport.
+func sameTransport(a, b any) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	t := reflect.TypeOf(a)
+	return t == reflect.TypeOf(b) && t.Comparable() && a == b
+}
+
 func (fr *framer) reset() {
 	fr.offset = 0
 	fr.length = 0
+	fr.hlen = 0
+	fr.rid, fr.whold, fr.hoff = false, false, 0
+	fr.wfOff, fr.wfLen = 0, 0
+	fr.rstart = time.Time{}
+	fr.wstart, fr.wstalls = time.Time{}, 0
+}
+
+// rawReader returns the reader performing the I/O of r, see WithRawConn.
+func (fr *framer) rawReader(r io.Reader) io.Reader {
+	if fr.raw {
+		if x := newRawIO(r, fr.rpr.preserveBoundary()); x != nil {
+			return x
+		}
+	}
+	return r
+}
+
+// rawWriter returns the writer performing the I/O of w, see WithRawConn.
+func (fr *framer) rawWriter(w io.Writer) io.Writer {
+	if fr.raw {
+		if x := newRawIO(w, fr.wpr.preserveBoundary()); x != nil {
+			return x
+		}
+	}
+	return w
+}
+
+// mapErr translates a transport error with the mapper of WithErrorMapper.
+func (fr *framer) mapErr(err error) error {
+	if err == nil || fr.errMap == nil {
+		return err
+	}
+	if m := fr.errMap(err); m != nil {
+		return m
+	}
+	return err
+}
+
+// writeFull writes p to w, stopping at the first error or zero-progress write.
+func writeFull(w io.Writer, p []byte) (n int, err error) {
+	for n < len(p) {
+		wn, we := w.Write(p[n:])
+		n += wn
+		if we != nil {
+			return n, we
+		}
+		if wn == 0 {
+			return n, io.ErrShortWrite
+		}
+	}
+	return n, nil
 }
 
 func (fr *framer) yieldOnce() {
@@ -81,75 +591,204 @@ func (fr *framer) yieldOnce() {
 }
 
 func (fr *framer) read(p []byte) (n int, err error) {
+	if fr.closed.Load() {
+		return 0, ErrClosed
+	}
 	if fr.rd == nil {
 		return 0, ErrInvalidArgument
 	}
+	if fr.rabort != nil {
+		return 0, fr.rabort
+	}
 	if fr.rpr.preserveBoundary() {
-		return fr.readPacket(p)
+		n, err = fr.readPacket(p)
+	} else {
+		n, err = fr.readStream(p)
+	}
+	if err != nil && fr.log != nil {
+		fr.logError(DirRead, err)
 	}
-	return fr.readStream(p)
+	return n, err
 }
 
 func (fr *framer) write(p []byte) (n int, err error) {
+	if fr.closed.Load() {
+		return 0, ErrClosed
+	}
 	if fr.wr == nil {
 		return 0, ErrInvalidArgument
 	}
-	if fr.wpr.preserveBoundary() {
-		return fr.writePacket(p)
+	if fr.rtx && !fr.rewriting && fr.wctl == 0 && fr.offset == 0 {
+		if err := fr.retain(p); err != nil {
+			return 0, err
+		}
+	}
+	switch {
+	case fr.wpr.preserveBoundary():
+		n, err = fr.writePacket(p)
+	case fr.ping != nil && fr.ping.owed(fr):
+		// Ping and pong frames go out between messages.
+		if err = fr.ping.flush(fr); err == nil {
+			n, err = fr.writeStream(p)
+		}
+	default:
+		n, err = fr.writeStream(p)
+	}
+	if err != nil && fr.log != nil {
+		fr.logError(DirWrite, err)
 	}
-	return fr.writeStream(p)
+	return n, err
 }
 
-func (fr *framer) waitOnceOnWouldBlock() bool {
-	// returns whether the caller should retry
-	if fr.retryDelay < 0 {
-		return false
+// waitOnceOnWouldBlock reports whether the caller should retry after
+// ErrWouldBlock in direction dir. A non-nil error from the wait hook replaces
+// ErrWouldBlock as the result of the operation.
+func (fr *framer) waitOnceOnWouldBlock(dir Direction) (bool, error) {
+	fr.noteWouldBlock(dir)
+	if fr.waitFunc == nil && fr.retryDelay == 0 && fr.spin.enabled() {
+		fr.waits++
+		fr.spin.wait(fr.waits)
+		return true, nil
+	}
+	return waitOnce(fr.waitFunc, fr.retryDelay, dir)
+}
+
+// waitOnce applies the WaitFunc and RetryDelay policy once and reports
+// whether the caller should retry.
+func waitOnce(waitFunc func(Direction) error, retryDelay time.Duration, dir Direction) (bool, error) {
+	if waitFunc != nil {
+		if err := waitFunc(dir); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	if retryDelay < 0 {
+		return false, nil
 	}
-	if fr.retryDelay == 0 {
+	if retryDelay == 0 {
 		runtime.Gosched()
-		return true
+		return true, nil
 	}
-	time.Sleep(fr.retryDelay)
-	return true
+	time.Sleep(retryDelay)
+	return true, nil
 }
 
 func (fr *framer) readOnce(p []byte) (n int, err error) {
+	if fr.rid {
+		return fr.readStreamID(p), nil
+	}
 	for {
-		n, err = fr.rd.Read(p)
+		if fr.closed.Load() {
+			return 0, ErrClosed
+		}
+		n, err = fr.readSource(p)
+		fr.checkIO(DirRead, n, len(p))
+		err = fr.mapErr(err)
+		if fr.minRate > 0 || fr.msgDeadline > 0 {
+			if rerr := fr.checkPace(n); rerr != nil {
+				return n, rerr
+			}
+		}
+		if err != nil && fr.closed.Load() {
+			// The transport failed because Close tore it down.
+			return n, ErrClosed
+		}
 		// Guard against broken Readers that violate the io.Reader contract by
 		// returning (0, nil) on a non-empty buffer. Without this, the stream
 		// state machine can spin indefinitely.
 		if len(p) != 0 && n == 0 && err == nil {
 			return 0, io.ErrNoProgress
 		}
+		if err == ErrMore && fr.rmore {
+			// In packet mode the completion is a whole, possibly empty,
+			// packet.
+			if n == 0 && !fr.rpr.preserveBoundary() {
+				continue
+			}
+			err = nil
+		}
 		if n > 0 {
+			fr.rstats.touch()
+			fr.waits, fr.streak = 0, 0
 			return n, err
 		}
 		if err != ErrWouldBlock {
 			return n, err
 		}
-		if !fr.waitOnceOnWouldBlock() {
+		fr.rstats.retries.Add(1)
+		retry, werr := fr.waitOnceOnWouldBlock(DirRead)
+		if werr != nil {
+			return n, werr
+		}
+		if !retry {
 			return n, err
 		}
 	}
 }
 
 func (fr *framer) writeOnce(p []byte) (n int, err error) {
+	if fr.whold {
+		return fr.writeStdcopyHeader(p)
+	}
+	if fr.wbun != nil {
+		return fr.wbun.write(fr, p)
+	}
+	return fr.writeTransport(p)
+}
+
+// writeTransport writes p to the transport, or to the write buffer when
+// there is one.
+func (fr *framer) writeTransport(p []byte) (n int, err error) {
 	for {
-		n, err = fr.wr.Write(p)
+		if fr.closed.Load() {
+			return 0, ErrClosed
+		}
+		if fr.coal != nil && !fr.urgent {
+			n, err = fr.coal.write(fr.wio, p)
+		} else {
+			n, err = fr.wio.Write(p)
+		}
+		fr.checkIO(DirWrite, n, len(p))
+		err = fr.mapErr(err)
+		if err != nil && fr.closed.Load() {
+			// The transport failed because Close tore it down.
+			return n, ErrClosed
+		}
 		// Guard against broken Writers that violate the io.Writer contract by
 		// returning (0, nil) on a non-empty buffer. Without this, the stream
 		// writer can spin indefinitely.
 		if len(p) != 0 && n == 0 && err == nil {
 			return 0, io.ErrShortWrite
 		}
+		if err == ErrMore && fr.wmore && (n == len(p) || !fr.wpr.preserveBoundary()) {
+			if n == 0 && len(p) != 0 {
+				continue
+			}
+			err = nil
+		}
+		if err == ErrWouldBlock && (fr.stallTimeout > 0 || fr.stallRetries > 0) {
+			if serr := fr.checkStall(); serr != nil {
+				return n, serr
+			}
+		}
 		if n > 0 {
+			fr.wstats.touch()
+			fr.waits, fr.streak = 0, 0
+			if err == nil && fr.wpr.preserveBoundary() {
+				// A packet is complete once written.
+				fr.wstart, fr.wstalls = time.Time{}, 0
+			}
 			return n, err
 		}
 		if err != ErrWouldBlock {
 			return n, err
 		}
-		if !fr.waitOnceOnWouldBlock() {
+		fr.wstats.retries.Add(1)
+		retry, werr := fr.waitOnceOnWouldBlock(DirWrite)
+		if werr != nil {
+			return n, werr
+		}
+		if !retry {
 			return n, err
 		}
 	}
@@ -159,17 +798,140 @@ func (fr *framer) writeOnce(p []byte) (n int, err error) {
 // ReadLimit is checked after each transport read, so ErrTooLong can be returned
 // with n > limit; n is still the consumed-byte count for this call.
 func (fr *framer) readPacket(p []byte) (n int, err error) {
-	n, err = fr.readOnce(p)
+	if fr.rseg != nil {
+		n, err = fr.rseg.read(fr, p)
+	} else {
+		n, err = fr.readUnit(p)
+	}
+	if _, ok := err.(*TruncatedError); ok {
+		return n, err
+	}
+	if fr.budget >= 0 && int64(n) > fr.budget && (err == nil || err == ErrTooLong) {
+		return 0, &LimitError{Length: int64(n), Remaining: fr.budget}
+	}
+	if n > 0 {
+		fr.rstats.frame(int64(n))
+	}
 	if fr.readLimit > 0 && int64(n) > fr.readLimit {
 		return n, ErrTooLong
 	}
 	return n, err
 }
 
+// readUnit receives the next packet payload past the FEC and duplicate
+// suppression layers.
+func (fr *framer) readUnit(p []byte) (n int, err error) {
+	for {
+		if fr.rfec != nil {
+			n, err = fr.rfec.read(fr, p)
+		} else {
+			n, err = fr.readDatagram(p)
+		}
+		if fr.rdup == nil || (err != nil && n == 0) {
+			return n, err
+		}
+		if n < dedupHeader {
+			continue
+		}
+		if !fr.rdup.check(binary.BigEndian.Uint32(p)) {
+			fr.rstats.dups.Add(1)
+			continue
+		}
+		n = copy(p, p[dedupHeader:n])
+		if te, ok := err.(*TruncatedError); ok {
+			te.Length -= dedupHeader
+			te.Received = int64(n)
+		}
+		return n, err
+	}
+}
+
+// deliverPacket copies msg into p, reporting a message longer than p like a
+// truncated datagram.
+func deliverPacket(p, msg []byte) (int, error) {
+	n := copy(p, msg)
+	if n < len(msg) {
+		return n, &TruncatedError{Length: int64(len(msg)), Received: int64(n)}
+	}
+	return n, nil
+}
+
+// readDatagram receives one packet into p, unpacking bundles.
+func (fr *framer) readDatagram(p []byte) (n int, err error) {
+	if fr.rdelim != nil {
+		return fr.readDelimited(p)
+	}
+	if fr.rbun != nil {
+		return fr.rbun.read(fr, p)
+	}
+	return fr.recvDatagram(p)
+}
+
+// recvDatagram receives one datagram from the transport into p.
+func (fr *framer) recvDatagram(p []byte) (n int, err error) {
+	if _, raw := fr.rio.(*rawIO); raw {
+		return fr.readOnce(p)
+	}
+	if fr.avSpent {
+		return 0, ErrWouldBlock
+	}
+	size, ok := 0, false
+	if n, size, ok, err = recvPacket(fr.rd, p); ok {
+		fr.avSpent = fr.avOn
+		err = fr.mapErr(err)
+		if err != nil && fr.closed.Load() {
+			return n, ErrClosed
+		}
+		fr.rstats.touch()
+		if err == nil && size > n {
+			// The datagram did not fit in p; report it rather than deliver
+			// the cut-off payload as a whole message.
+			return n, &TruncatedError{Length: int64(size), Received: int64(n)}
+		}
+		return n, err
+	}
+	return fr.readOnce(p)
+}
+
 func (fr *framer) writePacket(p []byte) (n int, err error) {
 	if int64(len(p)) > framePayloadMaxLen56 {
 		return 0, ErrTooLong
 	}
+	switch {
+	case fr.wdelim != nil && fr.wpr == Delimited:
+		n, err = fr.writeDelimited(p)
+	case fr.wdelim != nil:
+		n, err = fr.writeEncoded(p)
+	case fr.wseg != nil:
+		n, err = fr.wseg.write(fr, p)
+	default:
+		n, err = fr.writeUnit(p)
+	}
+	if n == len(p) {
+		fr.wstats.frame(int64(n))
+	}
+	return n, err
+}
+
+// writeUnit sends p as one packet through the duplicate suppression and FEC
+// layers and returns how much of p was sent.
+func (fr *framer) writeUnit(p []byte) (n int, err error) {
+	msg := p
+	if fr.wdup != nil {
+		msg = fr.wdup.stamp(p)
+	}
+	var sent int
+	if fr.wfec != nil {
+		sent, err = fr.wfec.write(fr, msg)
+	} else {
+		sent, err = fr.sendPacket(msg)
+	}
+	return max(0, sent-(len(msg)-len(p))), err
+}
+
+// sendPacket writes p as one packet. A short write fails with
+// io.ErrShortWrite.
+func (fr *framer) sendPacket(p []byte) (n int, err error) {
 	n, err = fr.writeOnce(p)
 	if err != nil {
 		return n, err
@@ -185,155 +947,525 @@ func (fr *framer) readStream(p []byte) (n int, err error) {
 	// In Nonblock mode, partial progress may be returned with iox.ErrWouldBlock.
 	// The caller must retry with the same buffer to preserve already-copied bytes.
 
-	// 1) Read minimal header byte.
-	for fr.offset < frameHeaderLen {
-		rn, re := fr.readOnce(fr.header[fr.offset:frameHeaderLen])
+	// 1) Read and parse the length prefix.
+	hdrSize, err := fr.readHeader()
+	if err != nil {
+		return 0, err
+	}
+	if fr.budget >= 0 && fr.offset == hdrSize && fr.length > fr.budget {
+		return 0, &LimitError{Length: fr.length, Remaining: fr.budget}
+	}
+	if int64(len(p)) < fr.length {
+		return 0, io.ErrShortBuffer
+	}
+
+	// 2) Read payload directly into p.
+	for fr.offset < hdrSize+fr.length {
+		payloadOff := fr.offset - hdrSize
+		rn, re := fr.readOnce(p[payloadOff:fr.length])
 		fr.offset += int64(rn)
+		fr.checkFrame(DirRead, hdrSize)
+		n += rn
 		if re != nil {
 			if re == io.EOF {
-				if fr.offset == 0 {
-					// Clean EOF at message boundary.
-					return 0, io.EOF
-				}
-				if fr.offset < frameHeaderLen {
-					// Partial header read; stream truncated.
-					return 0, io.ErrUnexpectedEOF
+				if fr.offset < hdrSize+fr.length {
+					return n, fr.truncated(hdrSize)
 				}
 				break
 			}
+			// Absorb ErrMore during aggregation: more data is available
+			// right now, so keep reading toward the message boundary.
 			if re == ErrMore && rn > 0 {
 				continue
 			}
-			return 0, re
+			return n, re
 		}
 	}
 
-	// 2) Determine extended length bytes.
-	exLen := int64(0)
-	if fr.offset >= frameHeaderLen {
-		switch fr.header[0] {
-		case framePayloadMaxLen8Bits + 1:
-			exLen = 2
-		case framePayloadMaxLen8Bits + 2:
-			exLen = 7
+	if err := fr.readFrameEnd(); err != nil {
+		return n, err
+	}
+	fr.rstats.frame(fr.length)
+	fr.reset()
+	fr.prefetch()
+	return n, nil
+}
+
+// truncated reports the in-flight message as cut short by EOF. With
+// DeliverTruncated the framer resets so the next read sees the EOF.
+func (fr *framer) truncated(hdrSize int64) error {
+	if !fr.rtrunc {
+		return io.ErrUnexpectedEOF
+	}
+	err := &TruncatedError{Length: fr.length, Received: fr.offset - hdrSize}
+	fr.reset()
+	return err
+}
+
+// readHeader reads and parses the length prefix of the in-flight message and
+// returns the header size. Once the header is complete it returns without
+// reading from the transport.
+func (fr *framer) readHeader() (int64, error) {
+	for {
+		hdrSize, err := fr.readLengthPrefix()
+		if err != nil || !fr.rflags {
+			return hdrSize, err
+		}
+		// The flags byte completes the header.
+		if err := fr.readHeaderBytes(hdrSize + 1); err != nil {
+			return 0, err
+		}
+		hdrSize++
+		if fr.header[hdrSize-1]&flagControl == 0 {
+			return hdrSize, nil
+		}
+		if err := fr.readControl(hdrSize); err != nil {
+			return 0, err
 		}
 	}
+}
 
-	// 3) Read extended length bytes (if any).
-	for fr.offset < frameHeaderLen+exLen {
-		rn, re := fr.readOnce(fr.header[fr.offset : frameHeaderLen+exLen])
+// readControl reads the payload of the in-flight control frame and hands it
+// to the control handler, then resets for the next frame.
+func (fr *framer) readControl(hdrSize int64) error {
+	flags := fr.header[hdrSize-1]
+	if int64(cap(fr.cbuf)) < fr.length {
+		var err error
+		if fr.cbuf, err = fr.growBuf(fr.cbuf, int(fr.length)); err != nil {
+			return err
+		}
+	}
+	payload := fr.cbuf[:fr.length]
+	for fr.offset < hdrSize+fr.length {
+		rn, re := fr.readOnce(payload[fr.offset-hdrSize:])
 		fr.offset += int64(rn)
+		fr.checkFrame(DirRead, hdrSize)
 		if re != nil {
 			if re == io.EOF {
-				if fr.offset < frameHeaderLen+exLen {
-					return 0, io.ErrUnexpectedEOF
+				if fr.offset < hdrSize+fr.length {
+					return io.ErrUnexpectedEOF
 				}
 				break
 			}
 			if re == ErrMore && rn > 0 {
 				continue
 			}
-			return 0, re
+			return re
 		}
 	}
-
-	// 4) Parse payload length.
-	if fr.offset == frameHeaderLen+exLen {
-		if exLen == 2 {
-			fr.length = int64(fr.rbo.Uint16(fr.header[frameHeaderLen : frameHeaderLen+exLen]))
-		} else if exLen == 7 {
-			u64 := fr.rbo.Uint64(fr.header[:])
-			if fr.rbo == binary.LittleEndian {
-				fr.length = int64(u64 >> 8)
-			} else {
-				fr.length = int64(u64 & framePayloadMaxLen56)
-			}
-		} else {
-			fr.length = int64(fr.header[0])
+	fr.reset()
+	if flags&(flagPing|flagPong) != 0 {
+		// Answered or matched here; without a write side, dropped.
+		if fr.ping != nil {
+			fr.ping.received(flags, payload)
 		}
+		return nil
 	}
+	if fr.control != nil {
+		return fr.control(payload)
+	}
+	return nil
+}
 
+// readLengthPrefix reads and parses the length prefix in the configured
+// header format and returns its size.
+func (fr *framer) readLengthPrefix() (hdrSize int64, err error) {
+	if fr.auto != nil {
+		if err := fr.detectHeader(); err != nil {
+			return 0, err
+		}
+	}
+	switch fr.rhf {
+	case HeaderFixed16:
+		hdrSize, err = fr.readFixedHeader(2)
+	case HeaderFixed32:
+		hdrSize, err = fr.readFixedHeader(4)
+	case HeaderFixed64:
+		hdrSize, err = fr.readFixedHeader(8)
+	case HeaderUvarint:
+		hdrSize, err = fr.readUvarintHeader()
+	case HeaderStdcopy:
+		hdrSize, err = fr.readStdcopyHeader()
+	case HeaderMySQL:
+		hdrSize, err = fr.readMySQLHeader()
+	case HeaderGRPC:
+		hdrSize, err = fr.readGRPCHeader()
+	case HeaderMQTT:
+		hdrSize, err = fr.readMQTTHeader()
+	case HeaderTLS:
+		hdrSize, err = fr.readTLSHeader()
+	case HeaderPostgres:
+		hdrSize, err = fr.readPostgresHeader()
+	case HeaderAMQP:
+		hdrSize, err = fr.readAMQPHeader()
+	default:
+		hdrSize, err = fr.readCompactHeader()
+	}
+	if err != nil {
+		return 0, err
+	}
 	if fr.length < 0 || fr.length > framePayloadMaxLen56 {
 		return 0, ErrTooLong
 	}
-	if fr.readLimit > 0 && fr.length > fr.readLimit {
-		return 0, ErrTooLong
+	return hdrSize, nil
+}
+
+// readChunk reads up to len(p) payload bytes of the in-flight message,
+// parsing its header first when needed; p need not hold the whole payload.
+// The message completes, and the framer resets, when its last payload byte
+// has been read.
+func (fr *framer) readChunk(p []byte) (n int, err error) {
+	hdrSize, err := fr.readHeader()
+	if err != nil {
+		return 0, err
 	}
-	if int64(len(p)) < fr.length {
-		return 0, io.ErrShortBuffer
+	end := hdrSize + fr.length
+	if rem := end - fr.offset; int64(len(p)) > rem {
+		p = p[:rem]
 	}
-
-	// 5) Read payload directly into p.
-	hdrSize := frameHeaderLen + exLen
-	for fr.offset < hdrSize+fr.length {
-		payloadOff := fr.offset - hdrSize
-		rn, re := fr.readOnce(p[payloadOff:fr.length])
+	for len(p) > 0 {
+		rn, re := fr.readOnce(p)
 		fr.offset += int64(rn)
+		fr.checkFrame(DirRead, hdrSize)
 		n += rn
+		p = p[rn:]
 		if re != nil {
 			if re == io.EOF {
-				if fr.offset < hdrSize+fr.length {
+				if fr.offset < end {
 					return n, io.ErrUnexpectedEOF
 				}
 				break
 			}
-			// Absorb ErrMore during aggregation: more data is available
-			// right now, so keep reading toward the message boundary.
 			if re == ErrMore && rn > 0 {
 				continue
 			}
 			return n, re
 		}
+		break
+	}
+	if fr.offset == end {
+		if err := fr.readFrameEnd(); err != nil {
+			return n, err
+		}
+		fr.rstats.frame(fr.length)
+		fr.reset()
+	}
+	return n, nil
+}
+
+// readHeaderBytes reads header bytes until fr.offset reaches end. A clean EOF
+// before the first byte is io.EOF; EOF inside the header is io.ErrUnexpectedEOF.
+func (fr *framer) readHeaderBytes(end int64) error {
+	for fr.offset < end {
+		rn, re := fr.readOnce(fr.header[fr.offset:end])
+		fr.offset += int64(rn)
+		if re != nil {
+			if re == io.EOF {
+				if fr.offset == 0 {
+					// Clean EOF at message boundary.
+					return io.EOF
+				}
+				if fr.offset < end {
+					// Partial header read; stream truncated.
+					return io.ErrUnexpectedEOF
+				}
+				break
+			}
+			if re == ErrMore && rn > 0 {
+				continue
+			}
+			return re
+		}
+	}
+	return nil
+}
+
+// parsedLength records the payload length of a freshly parsed header of
+// hdrSize bytes carrying the length value v. ReadLimit is checked only here,
+// before any payload byte is consumed, so a limit changed mid-message applies
+// from the next message on.
+func (fr *framer) parsedLength(v, hdrSize int64) error {
+	if fr.rinc {
+		if v < hdrSize {
+			return ErrInvalidHeader
+		}
+		v -= hdrSize
+	}
+	if fr.rflags {
+		// The length counts the flags byte.
+		if v < 1 {
+			return ErrInvalidHeader
+		}
+		v--
+	}
+	fr.length = v
+	if fr.readLimit > 0 && fr.length > fr.readLimit {
+		return ErrTooLong
+	}
+	return nil
+}
+
+// readCompactHeader parses the 1/3/8-byte adaptive header and returns its size.
+func (fr *framer) readCompactHeader() (int64, error) {
+	// Read minimal header byte.
+	if err := fr.readHeaderBytes(frameHeaderLen); err != nil {
+		return 0, err
+	}
+
+	// Determine extended length bytes.
+	exLen := int64(0)
+	switch fr.header[0] {
+	case framePayloadMaxLen8Bits + 1:
+		exLen = 2
+	case framePayloadMaxLen8Bits + 2:
+		exLen = 7
+	}
+
+	if fr.rstrict {
+		if err := fr.checkStrictPrefix(exLen); err != nil {
+			return 0, err
+		}
+	}
+
+	// Read extended length bytes (if any).
+	if err := fr.readHeaderBytes(frameHeaderLen + exLen); err != nil {
+		return 0, err
 	}
 
+	// Parse payload length once, when the header has just completed.
+	if fr.offset == frameHeaderLen+exLen {
+		length := compactLength(fr.header[:frameHeaderLen+exLen], fr.rbo)
+		if fr.rstrict && length < compactMinValue(exLen) {
+			return 0, ErrInvalidHeader
+		}
+		if err := fr.parsedLength(length, frameHeaderLen+exLen); err != nil {
+			return 0, err
+		}
+	}
+	return frameHeaderLen + exLen, nil
+}
+
+// checkStrictPrefix refuses a minimal compact header, known by its first
+// byte to use exLen extended length bytes, whose length cannot fit in
+// ReadLimit.
+func (fr *framer) checkStrictPrefix(exLen int64) error {
+	if fr.readLimit <= 0 || exLen == 0 || fr.offset != frameHeaderLen {
+		return nil
+	}
+	minLen := compactMinValue(exLen)
+	if fr.rinc {
+		minLen -= frameHeaderLen + exLen
+	}
+	if fr.rflags {
+		minLen--
+	}
+	if minLen <= fr.readLimit {
+		return nil
+	}
+	return &OversizeError{MinLength: minLen, LengthBytes: exLen}
+}
+
+// readFixedHeader parses a size-byte unsigned length in the read byte order.
+func (fr *framer) readFixedHeader(size int64) (int64, error) {
+	if err := fr.readHeaderBytes(size); err != nil {
+		return 0, err
+	}
+	if fr.offset == size {
+		var v int64
+		switch size {
+		case 2:
+			v = int64(fr.rbo.Uint16(fr.header[:2]))
+		case 4:
+			v = int64(fr.rbo.Uint32(fr.header[:4]))
+		default:
+			u64 := fr.rbo.Uint64(fr.header[:8])
+			if u64 > framePayloadMaxLen56 {
+				return 0, &LengthOverflowError{Length: u64}
+			}
+			v = int64(u64)
+		}
+		if err := fr.parsedLength(v, size); err != nil {
+			return 0, err
+		}
+	}
+	return size, nil
+}
+
+// readUvarintHeader parses an unsigned LEB128 length, one byte per read so no
+// payload byte is consumed. The header size is kept in fr.hlen because it
+// cannot be recovered from fr.offset once payload reading has started.
+func (fr *framer) readUvarintHeader() (int64, error) {
+	if fr.hlen > 0 {
+		return fr.hlen, nil
+	}
+	for fr.offset == 0 || fr.header[fr.offset-1] >= 0x80 {
+		if fr.offset >= binary.MaxVarintLen64 {
+			return 0, ErrTooLong
+		}
+		if err := fr.readHeaderBytes(fr.offset + 1); err != nil {
+			return 0, err
+		}
+	}
+	u64, k := binary.Uvarint(fr.header[:fr.offset])
+	if k <= 0 || u64 > framePayloadMaxLen56 {
+		return 0, ErrTooLong
+	}
+	if err := fr.parsedLength(int64(u64), fr.offset); err != nil {
+		return 0, err
+	}
+	fr.hlen = fr.offset
+	return fr.hlen, nil
+}
+
+func (fr *framer) writeStream(p []byte) (n int, err error) {
+	hdrSize, err := fr.writeHeader(int64(len(p)))
+	if err != nil {
+		return 0, err
+	}
+
+	for fr.offset < hdrSize+fr.length {
+		payloadOff := fr.offset - hdrSize
+		wn, we := fr.writeOnce(p[payloadOff:])
+		fr.offset += int64(wn)
+		fr.checkFrame(DirWrite, hdrSize)
+		n += wn
+		if we != nil {
+			if we == ErrMore && wn > 0 {
+				continue
+			}
+			return n, we
+		}
+	}
+
+	if err := fr.writeFrameEnd(); err != nil {
+		return n, err
+	}
+	fr.wstats.frame(fr.length)
+	fr.advanceSequence()
 	fr.reset()
 	return n, nil
 }
 
-func (fr *framer) writeStream(p []byte) (n int, err error) {
-	if int64(len(p)) > framePayloadMaxLen56 {
+func (fr *framer) writev(bufs [][]byte) (n int, err error) {
+	if fr.closed.Load() {
+		return 0, ErrClosed
+	}
+	if fr.wr == nil {
+		return 0, ErrInvalidArgument
+	}
+	var total int64
+	for _, b := range bufs {
+		total += int64(len(b))
+	}
+	if fr.wpr.preserveBoundary() {
+		if int64(cap(fr.tbuf)) < total {
+			var err error
+			if fr.tbuf, err = fr.growBuf(fr.tbuf, int(total)); err != nil {
+				return 0, err
+			}
+		}
+		msg := fr.tbuf[:0]
+		for _, b := range bufs {
+			msg = append(msg, b...)
+		}
+		return fr.writePacket(msg)
+	}
+
+	hdrSize, err := fr.writeHeader(total)
+	if err != nil {
+		return 0, err
+	}
+	// Skip the slices already written by earlier calls.
+	skip := fr.offset - hdrSize
+	for _, b := range bufs {
+		if skip >= int64(len(b)) {
+			skip -= int64(len(b))
+			continue
+		}
+		wn, we := fr.writeChunk(b[skip:])
+		n += wn
+		skip = 0
+		if we != nil {
+			return n, we
+		}
+	}
+	if total == 0 || fr.offset == hdrSize+total {
+		// Empty, or only the frame end is left.
+		_, err = fr.writeChunk(nil)
+	}
+	return n, err
+}
+
+// writePacketFrom gathers n bytes from r and writes them as one packet.
+// Bytes gathered before an error are kept in tbuf for the retry.
+func (fr *framer) writePacketFrom(r io.Reader, n int64) (int64, error) {
+	if n > framePayloadMaxLen56 {
+		return 0, ErrTooLong
+	}
+	if int64(cap(fr.tbuf)) < n {
+		var err error
+		if fr.tbuf, err = fr.growBuf(fr.tbuf, int(n)); err != nil {
+			return 0, err
+		}
+	}
+	for int64(fr.wfLen) < n {
+		rn, re := r.Read(fr.tbuf[fr.wfLen:n])
+		fr.wfLen += rn
+		if re != nil && rn == 0 {
+			if re == io.EOF {
+				fr.wfLen = 0
+				return 0, io.ErrUnexpectedEOF
+			}
+			return 0, re
+		}
+	}
+	wn, err := fr.writePacket(fr.tbuf[:n])
+	if err != ErrWouldBlock && err != ErrMore {
+		fr.wfLen = 0
+	}
+	return int64(wn), err
+}
+
+// writeHeader starts the frame of a length-byte payload, or resumes it, and
+// returns the header size once the header has been fully written.
+func (fr *framer) writeHeader(length int64) (int64, error) {
+	if fr.detected != nil && fr.offset == 0 {
+		fr.followDetected()
+	}
+	hdrSize, v := fr.wireHeader(length)
+	if length > framePayloadMaxLen56 || v > fr.whf.maxValue() {
 		return 0, ErrTooLong
 	}
 
+	if fr.whf == HeaderStdcopy && length == 0 {
+		// An empty message has no stream ID.
+		return 0, ErrInvalidArgument
+	}
+
 	// Initialize per-message state on the first call.
 	if fr.offset == 0 {
-		fr.length = int64(len(p))
+		fr.length = length
 	}
-	if fr.length != int64(len(p)) {
+	if fr.length != length {
 		// The caller changed the message buffer mid-frame.
 		return 0, io.ErrShortWrite
 	}
 
-	exLen := int64(0)
-	if fr.length <= framePayloadMaxLen8Bits {
-		exLen = 0
-	} else if fr.length <= framePayloadMaxLen16 {
-		exLen = 2
-	} else {
-		exLen = 7
-	}
-
 	// Fill header once.
 	if fr.offset == 0 {
-		if fr.length <= framePayloadMaxLen8Bits {
-			fr.header[0] = byte(fr.length)
-		} else if fr.length <= framePayloadMaxLen16 {
-			fr.header[0] = framePayloadMaxLen8Bits + 1
-			fr.wbo.PutUint16(fr.header[frameHeaderLen:frameHeaderLen+exLen], uint16(fr.length))
-		} else {
-			if fr.wbo == binary.LittleEndian {
-				fr.wbo.PutUint64(fr.header[:], uint64(fr.length)<<8)
-			} else {
-				fr.wbo.PutUint64(fr.header[:], uint64(fr.length&framePayloadMaxLen56))
-			}
-			fr.header[0] = framePayloadMaxLen8Bits + 2
+		fr.putHeader(v)
+		if fr.wflags {
+			fr.header[hdrSize-1] = fr.wctl
+		}
+		if fr.whf == HeaderStdcopy {
+			fr.offset, fr.whold = hdrSize, true
 		}
 	}
 
-	hdrSize := frameHeaderLen + exLen
 	for fr.offset < hdrSize {
 		wn, we := fr.writeOnce(fr.header[fr.offset:hdrSize])
 		fr.offset += int64(wn)
+		fr.checkFrame(DirWrite, hdrSize)
 		if we != nil {
 			if we == ErrMore && wn > 0 {
 				continue
@@ -341,12 +1473,24 @@ func (fr *framer) writeStream(p []byte) (n int, err error) {
 			return 0, we
 		}
 	}
+	return hdrSize, nil
+}
 
-	for fr.offset < hdrSize+fr.length {
-		payloadOff := fr.offset - hdrSize
-		wn, we := fr.writeOnce(p[payloadOff:])
+// writeChunk writes up to len(p) payload bytes of the frame started by
+// writeHeader. The frame completes, and the framer resets, when its last
+// payload byte has been written; a zero-length frame completes on an empty p.
+func (fr *framer) writeChunk(p []byte) (n int, err error) {
+	hdrSize, _ := fr.wireHeader(fr.length)
+	end := hdrSize + fr.length
+	if rem := end - fr.offset; int64(len(p)) > rem {
+		p = p[:rem]
+	}
+	for len(p) > 0 {
+		wn, we := fr.writeOnce(p)
 		fr.offset += int64(wn)
+		fr.checkFrame(DirWrite, hdrSize)
 		n += wn
+		p = p[wn:]
 		if we != nil {
 			if we == ErrMore && wn > 0 {
 				continue
@@ -354,7 +1498,65 @@ func (fr *framer) writeStream(p []byte) (n int, err error) {
 			return n, we
 		}
 	}
-
-	fr.reset()
+	if fr.offset == end {
+		if err := fr.writeFrameEnd(); err != nil {
+			return n, err
+		}
+		fr.wstats.frame(fr.length)
+		fr.advanceSequence()
+		fr.reset()
+	}
 	return n, nil
 }
+
+// wireHeader returns the write-side header size of a length-byte payload,
+// the flags byte included, and the length value encoded in its prefix.
+func (fr *framer) wireHeader(length int64) (hdrSize, v int64) {
+	if fr.whf == HeaderStdcopy {
+		return stdcopyHeaderLen - 1, length - 1
+	}
+	if fr.wfixed {
+		hdrSize, v = MaxHeaderLen, length
+		if fr.winc {
+			v += hdrSize
+		}
+		if fr.wflags {
+			hdrSize, v = hdrSize+1, v+1
+		}
+		return hdrSize, v
+	}
+	if !fr.wflags {
+		return fr.whf.wire(length, fr.winc)
+	}
+	hdrSize, v = fr.whf.wire(length+1, fr.winc)
+	return hdrSize + 1, v
+}
+
+// putHeader encodes the length value v into fr.header.
+func (fr *framer) putHeader(v int64) {
+	if fr.wfixed {
+		putWideHeader(fr.header[:], fr.wbo, v)
+		return
+	}
+	switch fr.whf {
+	case HeaderMySQL:
+		putMySQLHeader(fr.header[:], fr.sequence(), v)
+		return
+	case HeaderGRPC:
+		putGRPCHeader(fr.header[:], fr.wmeta.Compressed, v)
+		return
+	case HeaderMQTT:
+		putMQTTHeader(fr.header[:], fr.wmeta.Type, v)
+		return
+	case HeaderTLS:
+		putTLSHeader(fr.header[:], fr.wmeta.Type, fr.wmeta.Version, v)
+		return
+	case HeaderPostgres:
+		putPostgresHeader(fr.header[:], fr.wmeta.Type, v)
+		return
+	case HeaderAMQP:
+		putAMQPHeader(fr.header[:], fr.wmeta.Type, fr.wmeta.Channel, v)
+		return
+	}
+	fr.whf.put(fr.header[:], fr.wbo, v)
+}
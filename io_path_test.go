@@ -5,11 +5,21 @@
 package framer_test
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
+	"unsafe"
 
 	"code.hybscloud.com/framer"
 	fr "code.hybscloud.com/framer"
@@ -532,6 +542,38 @@ func TestForward_WouldBlockOnWrite(t *testing.T) {
 	}
 }
 
+func TestForward_ForwardFor_Budget(t *testing.T) {
+	var wire bytes.Buffer
+	w := fr.NewWriter(&wire)
+	for i := 0; i < 5; i++ {
+		_, _ = w.Write([]byte("hello"))
+	}
+	var dst bytes.Buffer
+	fwd := fr.NewForwarder(&dst, bytes.NewReader(wire.Bytes()))
+
+	// An exhausted budget still forwards one message.
+	if frames, n, err := fwd.ForwardFor(0); frames != 1 || n != 5 || err != nil {
+		t.Fatalf("ForwardFor(0) = (%d, %d, %v), want (1, 5, nil)", frames, n, err)
+	}
+	if frames, n, err := fwd.ForwardFor(time.Hour); frames != 4 || n != 20 || err != io.EOF {
+		t.Fatalf("ForwardFor(1h) = (%d, %d, %v), want (4, 20, io.EOF)", frames, n, err)
+	}
+	if !bytes.Equal(dst.Bytes(), wire.Bytes()) {
+		t.Fatalf("relayed % x, want % x", dst.Bytes(), wire.Bytes())
+	}
+
+	// Would-block stops the call; written bytes of the partial message count.
+	bw := &fwWouldBlockWriter{limit: 2}
+	fwd = fr.NewForwarder(bw, bytes.NewReader(wire.Bytes()[:6]))
+	if frames, n, err := fwd.ForwardFor(time.Hour); frames != 0 || n != 1 || err != fr.ErrWouldBlock {
+		t.Fatalf("blocked ForwardFor = (%d, %d, %v), want (0, 1, ErrWouldBlock)", frames, n, err)
+	}
+	bw.limit = 100
+	if frames, n, err := fwd.ForwardFor(time.Hour); frames != 1 || n != 4 || err != io.EOF {
+		t.Fatalf("resumed ForwardFor = (%d, %d, %v), want (1, 4, io.EOF)", frames, n, err)
+	}
+}
+
 func TestForward_SeqPacket_Correctness(t *testing.T) {
 	msg := []byte("packet data")
 	var dst bytes.Buffer
@@ -2009,3 +2051,2413 @@ func TestWriter_ReadFrom_ResumeNonSemanticError(t *testing.T) {
 		t.Fatalf("second ReadFrom: want customErr, got (%d, %v)", n2, err2)
 	}
 }
+
+// --- Runtime setters ---
+
+func TestReader_SetReadLimit_AppliesFromNextMessage(t *testing.T) {
+	wire := []byte{5, 'h', 'e', 'l', 'l', 'o', 5, 'w', 'o', 'r', 'l', 'd'}
+	r := fr.NewReader(bytes.NewReader(wire), fr.WithReadTCP()).(*fr.Reader)
+	buf := make([]byte, 8)
+	if n, err := r.Read(buf); err != nil || n != 5 {
+		t.Fatalf("first read: n=%d err=%v", n, err)
+	}
+	r.SetReadLimit(3)
+	if n, err := r.Read(buf); n != 0 || !errors.Is(err, fr.ErrTooLong) {
+		t.Fatalf("second read: want (0, ErrTooLong), got (%d, %v)", n, err)
+	}
+}
+
+func TestReader_SetReadLimit_InFlightMessageCompletes(t *testing.T) {
+	wire := []byte{5, 'h', 'e', 'l', 'l', 'o'}
+	src := &wouldBlockMidPayloadReader{wire: wire, blockAfter: 3}
+	r := fr.NewReader(src, fr.WithReadTCP(), fr.WithNonblock()).(*fr.Reader)
+	buf := make([]byte, 8)
+	if _, err := r.Read(buf); !errors.Is(err, fr.ErrWouldBlock) {
+		t.Fatalf("first read: want ErrWouldBlock, got %v", err)
+	}
+	r.SetReadLimit(1)
+	if _, err := r.Read(buf); err != nil {
+		t.Fatalf("resumed read: %v", err)
+	}
+	if string(buf[:5]) != "hello" {
+		t.Fatalf("payload=%q want hello", buf[:5])
+	}
+}
+
+func TestReader_SetReadLimit_RaisedLimitGrowsWriteToBuffer(t *testing.T) {
+	payload := bytes.Repeat([]byte{'q'}, 40)
+	wire := append([]byte{4, 'a', 'b', 'c', 'd', 40}, payload...)
+	src := &wouldBlockMidPayloadReader{wire: wire, blockAfter: 5}
+	r := fr.NewReader(src, fr.WithReadTCP(), fr.WithReadLimit(4)).(*fr.Reader)
+	var dst bytes.Buffer
+	if _, err := r.WriteTo(&dst); !errors.Is(err, fr.ErrWouldBlock) {
+		t.Fatalf("first WriteTo: want ErrWouldBlock, got %v", err)
+	}
+	r.SetReadLimit(64)
+	if n, err := r.WriteTo(&dst); err != nil || n != 40 {
+		t.Fatalf("second WriteTo: n=%d err=%v", n, err)
+	}
+	if want := append([]byte("abcd"), payload...); !bytes.Equal(dst.Bytes(), want) {
+		t.Fatalf("dst=%q want %q", dst.Bytes(), want)
+	}
+}
+
+func TestForwarder_SetReadLimit_GrowsBuffer(t *testing.T) {
+	payload := bytes.Repeat([]byte{'f'}, 100)
+	wire := append([]byte{100}, payload...)
+	var dst bytes.Buffer
+	fwd := fr.NewForwarder(&dst, bytes.NewReader(wire), fr.WithProtocol(fr.BinaryStream), fr.WithReadLimit(10))
+	fwd.SetReadLimit(128)
+	n, err := fwd.ForwardOnce()
+	if err != nil || n != 100 {
+		t.Fatalf("ForwardOnce: n=%d err=%v", n, err)
+	}
+	if !bytes.Equal(dst.Bytes(), wire) {
+		t.Fatalf("forwarded wire mismatch")
+	}
+}
+
+func TestSetRetryDelay_SwitchesToBlocking(t *testing.T) {
+	src := &wbOnceReader{b: []byte{2, 'o', 'k'}}
+	r := fr.NewReader(src, fr.WithReadTCP(), fr.WithNonblock()).(*fr.Reader)
+	buf := make([]byte, 4)
+	r.SetRetryDelay(0)
+	n, err := r.Read(buf)
+	if err != nil || string(buf[:n]) != "ok" {
+		t.Fatalf("read: n=%d err=%v", n, err)
+	}
+
+	dst := &wbOnceWriter{}
+	rw := fr.NewReadWriter(nil, dst, fr.WithWriteTCP()).(*fr.ReadWriter)
+	rw.SetRetryDelay(0)
+	if n, err := rw.Write([]byte("xy")); err != nil || n != 2 {
+		t.Fatalf("write: n=%d err=%v", n, err)
+	}
+	if !bytes.Equal(dst.buf.Bytes(), []byte{2, 'x', 'y'}) {
+		t.Fatalf("wire=%v", dst.buf.Bytes())
+	}
+}
+
+type wbOnceWriter struct {
+	buf     bytes.Buffer
+	blocked bool
+}
+
+func (w *wbOnceWriter) Write(p []byte) (int, error) {
+	if !w.blocked {
+		w.blocked = true
+		return 0, iox.ErrWouldBlock
+	}
+	return w.buf.Write(p)
+}
+
+// --- Transport hot-swap ---
+
+func TestReader_SwapReader_DiscardsPartialMessage(t *testing.T) {
+	old := &wouldBlockMidPayloadReader{wire: []byte{5, 'h', 'e', 'l', 'l', 'o'}, blockAfter: 3}
+	r := fr.NewReader(old, fr.WithReadTCP(), fr.WithNonblock()).(*fr.Reader)
+	buf := make([]byte, 8)
+	if _, err := r.Read(buf); !errors.Is(err, fr.ErrWouldBlock) {
+		t.Fatalf("first read: want ErrWouldBlock, got %v", err)
+	}
+
+	if prev := r.SwapReader(bytes.NewReader([]byte{3, 'n', 'e', 'w'})); prev != io.Reader(old) {
+		t.Fatalf("SwapReader returned %v, want previous transport", prev)
+	}
+	n, err := r.Read(buf)
+	if err != nil || string(buf[:n]) != "new" {
+		t.Fatalf("read after swap: n=%d err=%v payload=%q", n, err, buf[:n])
+	}
+}
+
+func TestWriter_SwapWriter_StartsFreshFrame(t *testing.T) {
+	old := &wouldBlockWriter2{limit: 2}
+	w := fr.NewWriter(old, fr.WithWriteTCP(), fr.WithNonblock()).(*fr.Writer)
+	msg := []byte("hello")
+	if _, err := w.Write(msg); !errors.Is(err, fr.ErrWouldBlock) {
+		t.Fatalf("first write: want ErrWouldBlock, got %v", err)
+	}
+
+	var next bytes.Buffer
+	if prev := w.SwapWriter(&next); prev != io.Writer(old) {
+		t.Fatalf("SwapWriter returned %v, want previous transport", prev)
+	}
+	if n, err := w.Write(msg); err != nil || n != len(msg) {
+		t.Fatalf("write after swap: n=%d err=%v", n, err)
+	}
+	if want := append([]byte{5}, msg...); !bytes.Equal(next.Bytes(), want) {
+		t.Fatalf("wire=%v want %v", next.Bytes(), want)
+	}
+}
+
+func TestReadWriter_DirectionsKeepIndependentState(t *testing.T) {
+	dst := &wouldBlockWriter2{limit: 2}
+	src := bytes.NewReader([]byte{2, 'o', 'k'})
+	rw := fr.NewReadWriter(src, dst, fr.WithProtocol(fr.BinaryStream), fr.WithNonblock()).(*fr.ReadWriter)
+
+	msg := []byte("hello")
+	if _, err := rw.Write(msg); !errors.Is(err, fr.ErrWouldBlock) {
+		t.Fatalf("write: want ErrWouldBlock, got %v", err)
+	}
+	buf := make([]byte, 4)
+	if n, err := rw.Read(buf); err != nil || string(buf[:n]) != "ok" {
+		t.Fatalf("read: n=%d err=%v", n, err)
+	}
+	dst.limit = 16
+	if _, err := rw.Write(msg); err != nil {
+		t.Fatalf("resumed write: %v", err)
+	}
+	if want := append([]byte{5}, msg...); !bytes.Equal(dst.buf.Bytes(), want) {
+		t.Fatalf("wire=%v want %v", dst.buf.Bytes(), want)
+	}
+}
+
+// --- Stats ---
+
+func TestStats_CountsFramesBytesAndRetries(t *testing.T) {
+	var raw bytes.Buffer
+	w := fr.NewWriter(&raw, fr.WithWriteTCP()).(*fr.Writer)
+	for _, m := range []string{"a", "bc", ""} {
+		if _, err := w.Write([]byte(m)); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	ws := w.Stats()
+	if ws.FramesWritten != 3 || ws.BytesWritten != 3 || ws.LastWrite.IsZero() {
+		t.Fatalf("writer stats=%+v", ws)
+	}
+	if ws.FramesRead != 0 || !ws.LastRead.IsZero() {
+		t.Fatalf("writer reports read activity: %+v", ws)
+	}
+
+	r := fr.NewReader(&wbOnceReader{b: raw.Bytes()}, fr.WithReadTCP(), fr.WithNonblock()).(*fr.Reader)
+	buf := make([]byte, 4)
+	if _, err := r.Read(buf); !errors.Is(err, fr.ErrWouldBlock) {
+		t.Fatalf("first read: want ErrWouldBlock, got %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := r.Read(buf); err != nil {
+			t.Fatalf("read[%d]: %v", i, err)
+		}
+	}
+	rs := r.Stats()
+	if rs.FramesRead != 3 || rs.BytesRead != 3 || rs.ReadRetries != 1 || rs.LastRead.IsZero() {
+		t.Fatalf("reader stats=%+v", rs)
+	}
+}
+
+func TestStats_ReadWriterAndForwarder(t *testing.T) {
+	var out bytes.Buffer
+	rw := fr.NewReadWriter(bytes.NewReader([]byte{2, 'h', 'i'}), &out, fr.WithProtocol(fr.BinaryStream)).(*fr.ReadWriter)
+	buf := make([]byte, 4)
+	if _, err := rw.Read(buf); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if _, err := rw.Write([]byte("hey")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if st := rw.Stats(); st.FramesRead != 1 || st.BytesRead != 2 || st.FramesWritten != 1 || st.BytesWritten != 3 {
+		t.Fatalf("ReadWriter stats=%+v", st)
+	}
+
+	var dst bytes.Buffer
+	fwd := fr.NewForwarder(&dst, bytes.NewReader([]byte{1, 'x', 0}), fr.WithProtocol(fr.BinaryStream))
+	for {
+		if _, err := fwd.ForwardOnce(); err != nil {
+			break
+		}
+	}
+	if st := fwd.Stats(); st.FramesRead != 2 || st.FramesWritten != 2 || st.BytesRead != 1 || st.BytesWritten != 1 {
+		t.Fatalf("Forwarder stats=%+v", st)
+	}
+}
+
+func TestStats_PacketMode(t *testing.T) {
+	var out bytes.Buffer
+	w := fr.NewWriter(&out, fr.WithProtocol(fr.Datagram)).(*fr.Writer)
+	if _, err := w.Write([]byte("dgram")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	r := fr.NewReader(&out, fr.WithProtocol(fr.Datagram)).(*fr.Reader)
+	if _, err := r.Read(make([]byte, 16)); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if ws, rs := w.Stats(), r.Stats(); ws.BytesWritten != 5 || rs.BytesRead != 5 || rs.FramesRead != 1 {
+		t.Fatalf("writer=%+v reader=%+v", ws, rs)
+	}
+}
+
+// --- WaitFunc ---
+
+func TestWaitFunc_ResumesInFlightFrame(t *testing.T) {
+	wire := []byte{5, 'h', 'e', 'l', 'l', 'o'}
+	var dirs []fr.Direction
+	wait := func(dir fr.Direction) error {
+		dirs = append(dirs, dir)
+		return nil
+	}
+	r := fr.NewReader(&wouldBlockMidPayloadReader{wire: wire, blockAfter: 3}, fr.WithReadTCP(), fr.WithWaitFunc(wait))
+	buf := make([]byte, 8)
+	n, err := r.Read(buf)
+	if err != nil || string(buf[:n]) != "hello" {
+		t.Fatalf("read: n=%d err=%v payload=%q", n, err, buf[:n])
+	}
+	if len(dirs) != 1 || dirs[0] != fr.DirRead {
+		t.Fatalf("wait calls=%v want [read]", dirs)
+	}
+
+	out := &wbOnceWriter{}
+	dirs = nil
+	w := fr.NewWriter(out, fr.WithWriteTCP(), fr.WithWaitFunc(wait))
+	if _, err := w.Write([]byte("hi")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if len(dirs) != 1 || dirs[0] != fr.DirWrite || !bytes.Equal(out.buf.Bytes(), []byte{2, 'h', 'i'}) {
+		t.Fatalf("wait calls=%v wire=%v", dirs, out.buf.Bytes())
+	}
+}
+
+func TestWaitFunc_ErrorAbortsAndOverridesRetryDelay(t *testing.T) {
+	errStop := errors.New("stop")
+	calls := 0
+	r := fr.NewReader(&wbOnceReader{b: []byte{1, 'x'}}, fr.WithReadTCP(), fr.WithBlock(), fr.WithWaitFunc(func(fr.Direction) error {
+		calls++
+		return errStop
+	}))
+	buf := make([]byte, 4)
+	if _, err := r.Read(buf); err != errStop {
+		t.Fatalf("want errStop, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("wait calls=%d want 1", calls)
+	}
+	n, err := r.Read(buf)
+	if err != nil || string(buf[:n]) != "x" {
+		t.Fatalf("resume: n=%d err=%v", n, err)
+	}
+}
+
+func TestSpinPolicy_EscalatesAndResetsOnProgress(t *testing.T) {
+	b := fr.SpinPolicy{Spins: 3, Yields: 2, MinSleep: time.Millisecond, MaxSleep: 2 * time.Millisecond}
+	chunks := make([][]byte, 0, 20)
+	for range 2 {
+		chunks = append(chunks, nil, nil, nil, nil, nil, nil, nil, nil, []byte{2, 'h', 'i'})
+	}
+	r := fr.NewReader(&trickleReader{chunks: chunks}, fr.WithSpinPolicy(b)).(*fr.Reader)
+	buf := make([]byte, 4)
+	for i := range 2 {
+		// 3 spins and 2 yields, then sleeps of 1ms, 2ms and 2ms.
+		start := time.Now()
+		if n, err := r.Read(buf); err != nil || string(buf[:n]) != "hi" {
+			t.Fatalf("read %d: got (%q, %v)", i, buf[:n], err)
+		}
+		if d := time.Since(start); d < 5*time.Millisecond {
+			t.Fatalf("read %d took %v, want at least 5ms of sleeps", i, d)
+		}
+	}
+	if got := r.Stats().ReadRetries; got != 16 {
+		t.Fatalf("retries=%d want 16", got)
+	}
+
+	// A non-zero retry delay replaces the spin policy.
+	r = fr.NewReader(&trickleReader{chunks: [][]byte{nil}}, fr.WithSpinPolicy(b), fr.WithNonblock()).(*fr.Reader)
+	if _, err := r.Read(buf); err != fr.ErrWouldBlock {
+		t.Fatalf("nonblock: err=%v", err)
+	}
+}
+
+// --- Error mapping ---
+
+// errOnceReader fails its first Read with err, then reads from r.
+type errOnceReader struct {
+	err error
+	r   io.Reader
+}
+
+func (e *errOnceReader) Read(p []byte) (int, error) {
+	if err := e.err; err != nil {
+		e.err = nil
+		return 0, err
+	}
+	return e.r.Read(p)
+}
+
+func TestErrorMapper_NormalizesTransportErrors(t *testing.T) {
+	mapper := fr.WithErrorMapper(func(err error) error {
+		switch {
+		case errors.Is(err, syscall.EAGAIN):
+			return fr.ErrWouldBlock
+		case errors.Is(err, os.ErrDeadlineExceeded):
+			return fr.ErrTimeout
+		}
+		return err
+	})
+	wire := []byte{2, 'h', 'i'}
+	buf := make([]byte, 8)
+
+	// EAGAIN becomes ErrWouldBlock, so the retry policy applies.
+	r := fr.NewReader(&errOnceReader{err: syscall.EAGAIN, r: bytes.NewReader(wire)}, mapper, fr.WithBlock())
+	if n, err := r.Read(buf); err != nil || string(buf[:n]) != "hi" {
+		t.Fatalf("read through EAGAIN: got (%q, %v)", buf[:n], err)
+	}
+	r = fr.NewReader(&errOnceReader{err: os.ErrDeadlineExceeded, r: bytes.NewReader(wire)}, mapper)
+	if _, err := r.Read(buf); err != fr.ErrTimeout {
+		t.Fatalf("deadline: err=%v, want ErrTimeout", err)
+	}
+	w := fr.NewWriter(wbWriter2{}, fr.WithErrorMapper(func(err error) error {
+		if err == fr.ErrWouldBlock {
+			return io.ErrClosedPipe
+		}
+		return err
+	}))
+	if _, err := w.Write([]byte("x")); err != io.ErrClosedPipe {
+		t.Fatalf("write: err=%v, want io.ErrClosedPipe", err)
+	}
+}
+
+// --- Chunked forwarding ---
+
+func TestForward_Chunked_RelaysOversizedMessage(t *testing.T) {
+	big := bytes.Repeat([]byte("0123456789"), 100) // 1000 bytes
+	var src bytes.Buffer
+	w := fr.NewWriter(&src, fr.WithProtocol(fr.BinaryStream))
+	for _, m := range [][]byte{[]byte("small"), big, nil} {
+		if _, err := w.Write(m); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	// Without chunking a 64-byte buffer cannot hold the 1000-byte message.
+	plain := fr.NewForwarder(io.Discard, bytes.NewReader(src.Bytes()), fr.WithProtocol(fr.BinaryStream), fr.WithReadLimit(64))
+	if _, err := plain.ForwardOnce(); err != nil {
+		t.Fatalf("small message: %v", err)
+	}
+	if _, err := plain.ForwardOnce(); err != fr.ErrTooLong {
+		t.Fatalf("oversized without chunking: %v", err)
+	}
+
+	// Chunked relay through a 64-byte buffer, with would-block on both sides.
+	dst := &wouldBlockWriter2{limit: 48}
+	fwd := fr.NewForwarder(dst, &wouldBlockEveryOther{r: bytes.NewReader(src.Bytes())}, fr.WithProtocol(fr.BinaryStream), fr.WithChunkedForward(64))
+	blocks := 0
+	for {
+		_, err := fwd.ForwardOnce()
+		if err == io.EOF {
+			break
+		}
+		if err == fr.ErrWouldBlock {
+			blocks++
+			continue
+		}
+		if err != nil {
+			t.Fatalf("forward: %v", err)
+		}
+	}
+	if blocks == 0 {
+		t.Fatal("expected would-block resumes")
+	}
+	if !bytes.Equal(dst.buf.Bytes(), src.Bytes()) {
+		t.Fatalf("relayed wire differs: got %d bytes want %d", dst.buf.Len(), src.Len())
+	}
+	if st := fwd.Stats(); st.FramesRead != 3 || st.FramesWritten != 3 || st.BytesWritten != uint64(5+len(big)) {
+		t.Fatalf("stats=%+v", st)
+	}
+}
+
+func TestForward_Chunked_TruncatedSource(t *testing.T) {
+	wire := []byte{0xFE, 0x01, 0x00, 'a', 'b', 'c'} // 256-byte message cut short
+	fwd := fr.NewForwarder(io.Discard, bytes.NewReader(wire), fr.WithProtocol(fr.BinaryStream), fr.WithChunkedForward(2))
+	var err error
+	for range 8 {
+		if _, err = fwd.ForwardOnce(); err != nil {
+			break
+		}
+	}
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("err=%v want io.ErrUnexpectedEOF", err)
+	}
+}
+
+// --- Truncated delivery ---
+
+func TestReader_TruncatedDelivery(t *testing.T) {
+	wire := []byte{10, 'p', 'a', 'r', 't'} // 10-byte message, 4 bytes present
+
+	// Default: bare io.ErrUnexpectedEOF.
+	r := fr.NewReader(bytes.NewReader(wire), fr.WithProtocol(fr.BinaryStream))
+	if _, err := r.Read(make([]byte, 16)); err != io.ErrUnexpectedEOF {
+		t.Fatalf("default: err=%v want io.ErrUnexpectedEOF", err)
+	}
+
+	r = fr.NewReader(bytes.NewReader(wire), fr.WithProtocol(fr.BinaryStream), fr.WithTruncatedDelivery())
+	buf := make([]byte, 16)
+	n, err := r.Read(buf)
+	var te *fr.TruncatedError
+	if !errors.As(err, &te) || te.Length != 10 || te.Received != 4 {
+		t.Fatalf("err=%v want TruncatedError{10, 4}", err)
+	}
+	if !errors.Is(err, fr.ErrTruncated) || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("err=%v does not match ErrTruncated and io.ErrUnexpectedEOF", err)
+	}
+	if string(buf[:n]) != "part" {
+		t.Fatalf("delivered %q want %q", buf[:n], "part")
+	}
+	if _, err := r.Read(buf); err != io.EOF {
+		t.Fatalf("after truncation: err=%v want io.EOF", err)
+	}
+
+	// WriteTo hands the partial payload to dst.
+	var dst bytes.Buffer
+	wt := fr.NewReader(bytes.NewReader(append([]byte{2, 'o', 'k'}, wire...)), fr.WithProtocol(fr.BinaryStream), fr.WithTruncatedDelivery()).(*fr.Reader)
+	total, err := wt.WriteTo(&dst)
+	if !errors.Is(err, fr.ErrTruncated) || total != 6 || dst.String() != "okpart" {
+		t.Fatalf("WriteTo: total=%d err=%v dst=%q", total, err, dst.String())
+	}
+}
+
+// --- Writev ---
+
+func TestWriter_Writev(t *testing.T) {
+	parts := [][]byte{[]byte("head:"), nil, bytes.Repeat([]byte{'b'}, 300), []byte(":tail")}
+	want := bytes.Join(parts, nil)
+
+	// Stream mode with short writes: retry with the same slices.
+	dst := &wouldBlockWriter2{limit: 7}
+	w := fr.NewWriter(dst, fr.WithProtocol(fr.BinaryStream)).(*fr.Writer)
+	total := 0
+	for i := 0; ; i++ {
+		n, err := w.Writev(parts...)
+		total += n
+		if err == nil {
+			break
+		}
+		if err != fr.ErrWouldBlock || i > 100 {
+			t.Fatalf("Writev: %v", err)
+		}
+	}
+	if total != len(want) {
+		t.Fatalf("payload count=%d want %d", total, len(want))
+	}
+	r := fr.NewReader(&dst.buf, fr.WithProtocol(fr.BinaryStream))
+	buf := make([]byte, 512)
+	n, err := r.Read(buf)
+	if err != nil || !bytes.Equal(buf[:n], want) {
+		t.Fatalf("read back: n=%d err=%v", n, err)
+	}
+
+	// Empty message and packet mode.
+	var out bytes.Buffer
+	sw := fr.NewWriter(&out, fr.WithProtocol(fr.BinaryStream)).(*fr.Writer)
+	if n, err := sw.Writev(); n != 0 || err != nil || !bytes.Equal(out.Bytes(), []byte{0}) {
+		t.Fatalf("empty Writev: n=%d err=%v wire=% x", n, err, out.Bytes())
+	}
+	out.Reset()
+	pw := fr.NewWriter(&out, fr.WithProtocol(fr.Datagram)).(*fr.Writer)
+	if n, err := pw.Writev([]byte("ab"), []byte("cd")); n != 4 || err != nil || out.String() != "abcd" {
+		t.Fatalf("packet Writev: n=%d err=%v out=%q", n, err, out.String())
+	}
+}
+
+// --- WriteFrom ---
+
+func TestWriter_WriteFrom(t *testing.T) {
+	payload := make([]byte, 100000)
+	for i := range payload {
+		payload[i] = byte(i % 251)
+	}
+
+	// Stream mode with short writes: retry with the same reader and length.
+	dst := &wouldBlockWriter2{limit: 4096}
+	w := fr.NewWriter(dst, fr.WithProtocol(fr.BinaryStream)).(*fr.Writer)
+	src := bytes.NewReader(payload)
+	var total int64
+	for i := 0; ; i++ {
+		n, err := w.WriteFrom(src, int64(len(payload)))
+		total += n
+		if err == nil {
+			break
+		}
+		if err != fr.ErrWouldBlock || i > 1000 {
+			t.Fatalf("WriteFrom: %v", err)
+		}
+	}
+	if total != int64(len(payload)) {
+		t.Fatalf("payload count=%d want %d", total, len(payload))
+	}
+	r := fr.NewReader(&dst.buf, fr.WithProtocol(fr.BinaryStream), fr.WithReadLimit(len(payload)))
+	buf := make([]byte, len(payload))
+	n, err := r.Read(buf)
+	if err != nil || !bytes.Equal(buf[:n], payload) {
+		t.Fatalf("read back: n=%d err=%v", n, err)
+	}
+
+	// Only n bytes are taken from the source.
+	var out bytes.Buffer
+	sw := fr.NewWriter(&out, fr.WithProtocol(fr.BinaryStream)).(*fr.Writer)
+	src = bytes.NewReader([]byte("abcdef"))
+	if n, err := sw.WriteFrom(src, 3); n != 3 || err != nil || !bytes.Equal(out.Bytes(), []byte{3, 'a', 'b', 'c'}) {
+		t.Fatalf("WriteFrom(3): n=%d err=%v wire=% x", n, err, out.Bytes())
+	}
+	if src.Len() != 3 {
+		t.Fatalf("source remaining=%d want 3", src.Len())
+	}
+
+	// Empty message.
+	out.Reset()
+	if n, err := sw.WriteFrom(src, 0); n != 0 || err != nil || !bytes.Equal(out.Bytes(), []byte{0}) {
+		t.Fatalf("WriteFrom(0): n=%d err=%v wire=% x", n, err, out.Bytes())
+	}
+
+	// Short source.
+	out.Reset()
+	if n, err := sw.WriteFrom(bytes.NewReader([]byte("xy")), 5); n != 2 || err != io.ErrUnexpectedEOF {
+		t.Fatalf("short source: n=%d err=%v", n, err)
+	}
+	if _, err := sw.WriteFrom(nil, 1); err != fr.ErrInvalidArgument {
+		t.Fatalf("nil source: err=%v", err)
+	}
+}
+
+func TestWriter_WriteFrom_PacketMode(t *testing.T) {
+	var out bytes.Buffer
+	w := fr.NewWriter(&out, fr.WithProtocol(fr.Datagram)).(*fr.Writer)
+	src := &wbOnceReader{b: []byte("packet")}
+	if _, err := w.WriteFrom(src, 6); err != fr.ErrWouldBlock {
+		t.Fatalf("first WriteFrom: err=%v", err)
+	}
+	if n, err := w.WriteFrom(src, 6); n != 6 || err != nil || out.String() != "packet" {
+		t.Fatalf("WriteFrom: n=%d err=%v out=%q", n, err, out.String())
+	}
+	if _, err := w.WriteFrom(bytes.NewReader([]byte("ab")), 3); err != io.ErrUnexpectedEOF {
+		t.Fatalf("short source: err=%v", err)
+	}
+}
+
+// --- ReadTo ---
+
+func TestReader_ReadTo_OneMessage(t *testing.T) {
+	big := make([]byte, 200000)
+	for i := range big {
+		big[i] = byte(i % 251)
+	}
+	var wire bytes.Buffer
+	w := fr.NewWriter(&wire, fr.WithProtocol(fr.BinaryStream))
+	for _, m := range [][]byte{big, nil, []byte("next")} {
+		if _, err := w.Write(m); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	// Would-block on both sides; the large message streams through the
+	// 64KiB scratch buffer.
+	r := fr.NewReader(&wouldBlockEveryOther{r: &wire}, fr.WithProtocol(fr.BinaryStream)).(*fr.Reader)
+	dst := &wouldBlockWriter2{limit: 5000}
+	var total int64
+	for i := 0; ; i++ {
+		n, err := r.ReadTo(dst)
+		total += n
+		if err == nil {
+			break
+		}
+		if err != fr.ErrWouldBlock || i > 1000000 {
+			t.Fatalf("ReadTo: %v", err)
+		}
+	}
+	if total != int64(len(big)) || !bytes.Equal(dst.buf.Bytes(), big) {
+		t.Fatalf("first message: total=%d len=%d", total, dst.buf.Len())
+	}
+
+	// The following messages stay in the stream.
+	for _, want := range []string{"", "next"} {
+		var out bytes.Buffer
+		var n int64
+		var err error
+		for err = fr.ErrWouldBlock; err == fr.ErrWouldBlock; {
+			var k int64
+			k, err = r.ReadTo(&out)
+			n += k
+		}
+		if err != nil || n != int64(len(want)) || out.String() != want {
+			t.Fatalf("ReadTo: n=%d err=%v out=%q want %q", n, err, out.String(), want)
+		}
+	}
+	for {
+		if _, err := r.ReadTo(io.Discard); err != fr.ErrWouldBlock {
+			if err != io.EOF {
+				t.Fatalf("at end: err=%v want io.EOF", err)
+			}
+			break
+		}
+	}
+}
+
+func TestReader_ReadTo_PacketAndTruncated(t *testing.T) {
+	r := fr.NewReader(bytes.NewReader([]byte("dgram")), fr.WithProtocol(fr.Datagram)).(*fr.Reader)
+	var out bytes.Buffer
+	if n, err := r.ReadTo(&out); n != 5 || err != nil || out.String() != "dgram" {
+		t.Fatalf("packet ReadTo: n=%d err=%v out=%q", n, err, out.String())
+	}
+
+	out.Reset()
+	r = fr.NewReader(bytes.NewReader([]byte{5, 'a', 'b'}), fr.WithProtocol(fr.BinaryStream)).(*fr.Reader)
+	if n, err := r.ReadTo(&out); n != 2 || err != io.ErrUnexpectedEOF || out.String() != "ab" {
+		t.Fatalf("truncated ReadTo: n=%d err=%v out=%q", n, err, out.String())
+	}
+	if _, err := r.ReadTo(nil); err != fr.ErrInvalidArgument {
+		t.Fatalf("nil dst: err=%v", err)
+	}
+}
+
+// --- CopyFrames ---
+
+// packetRecorder keeps each Write as one packet.
+type packetRecorder struct{ msgs [][]byte }
+
+func (w *packetRecorder) Write(p []byte) (int, error) {
+	w.msgs = append(w.msgs, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func TestCopyFrames_StreamPreservesBoundaries(t *testing.T) {
+	msgs := [][]byte{[]byte("one"), nil, bytes.Repeat([]byte{'x'}, 150000), []byte("four")}
+	var wire bytes.Buffer
+	w := fr.NewWriter(&wire, fr.WithProtocol(fr.BinaryStream))
+	for _, m := range msgs {
+		if _, err := w.Write(m); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	src := fr.NewReader(&wouldBlockEveryOther{r: &wire}, fr.WithProtocol(fr.BinaryStream)).(*fr.Reader)
+	out := &wouldBlockWriter2{limit: 3000}
+	dst := fr.NewWriter(out, fr.WithProtocol(fr.BinaryStream)).(*fr.Writer)
+
+	// Copy two messages, then the rest until EOF.
+	var frames int
+	var total int64
+	for i := 0; frames < 2; i++ {
+		k, b, err := fr.CopyFrames(dst, src, 2-frames)
+		frames += k
+		total += b
+		if err != nil && (err != fr.ErrWouldBlock || i > 1000) {
+			t.Fatalf("CopyFrames(2): %v", err)
+		}
+	}
+	if frames != 2 || total != 3 {
+		t.Fatalf("first copy: frames=%d bytes=%d", frames, total)
+	}
+	for i := 0; ; i++ {
+		k, b, err := fr.CopyFrames(dst, src, -1)
+		frames += k
+		total += b
+		if err == nil {
+			break
+		}
+		if err != fr.ErrWouldBlock || i > 1000000 {
+			t.Fatalf("CopyFrames(-1): %v", err)
+		}
+	}
+	if frames != len(msgs) || total != 150007 {
+		t.Fatalf("copied frames=%d bytes=%d", frames, total)
+	}
+
+	r := fr.NewReader(&out.buf, fr.WithProtocol(fr.BinaryStream))
+	buf := make([]byte, 150000)
+	for i, want := range msgs {
+		n, err := r.Read(buf)
+		if err != nil || !bytes.Equal(buf[:n], want) {
+			t.Fatalf("message %d: n=%d err=%v", i, n, err)
+		}
+	}
+}
+
+func TestCopyFrames_PacketModes(t *testing.T) {
+	// Stream to datagram: one packet per message.
+	var wire bytes.Buffer
+	w := fr.NewWriter(&wire, fr.WithProtocol(fr.BinaryStream))
+	_, _ = w.Write([]byte("ab"))
+	_, _ = w.Write([]byte("cde"))
+	var pk packetRecorder
+	src := fr.NewReader(&wire, fr.WithProtocol(fr.BinaryStream)).(*fr.Reader)
+	dst := fr.NewWriter(&pk, fr.WithProtocol(fr.Datagram)).(*fr.Writer)
+	if k, b, err := fr.CopyFrames(dst, src, -1); k != 2 || b != 5 || err != nil {
+		t.Fatalf("stream to datagram: frames=%d bytes=%d err=%v", k, b, err)
+	}
+	if len(pk.msgs) != 2 || string(pk.msgs[0]) != "ab" || string(pk.msgs[1]) != "cde" {
+		t.Fatalf("packets=%q", pk.msgs)
+	}
+
+	// Datagram to stream: one frame per packet; EOF is reported for n >= 0.
+	var out bytes.Buffer
+	src = fr.NewReader(bytes.NewReader([]byte("pkt")), fr.WithProtocol(fr.Datagram)).(*fr.Reader)
+	dst = fr.NewWriter(&out, fr.WithProtocol(fr.BinaryStream)).(*fr.Writer)
+	if k, b, err := fr.CopyFrames(dst, src, 1); k != 1 || b != 3 || err != nil {
+		t.Fatalf("datagram to stream: frames=%d bytes=%d err=%v", k, b, err)
+	}
+	if !bytes.Equal(out.Bytes(), []byte{3, 'p', 'k', 't'}) {
+		t.Fatalf("wire=% x", out.Bytes())
+	}
+	if _, _, err := fr.CopyFrames(dst, src, 1); err != io.EOF {
+		t.Fatalf("at end: err=%v want io.EOF", err)
+	}
+	if _, _, err := fr.CopyFrames(nil, src, 1); err != fr.ErrInvalidArgument {
+		t.Fatalf("nil dst: err=%v", err)
+	}
+}
+
+func TestTranscode_UvarintToCompact(t *testing.T) {
+	big := bytes.Repeat([]byte("q"), 70000)
+	var in bytes.Buffer
+	w := fr.NewWriter(&in, fr.WithProfile(fr.ProfileProtobufDelimited))
+	_, _ = w.Write([]byte("legacy"))
+	_, _ = w.Write(big)
+	b := in.Bytes()
+
+	var out bytes.Buffer
+	src := fr.NewReader(&trickleReader{chunks: [][]byte{b[:1], nil, b[1:20], nil, b[20:]}},
+		fr.WithProfile(fr.ProfileProtobufDelimited)).(*fr.Reader)
+	dst := fr.NewWriter(&out, fr.WithByteOrder(binary.LittleEndian)).(*fr.Writer)
+	// The drained trickle source keeps reporting ErrWouldBlock.
+	frames := 0
+	for frames < 2 {
+		k, _, err := fr.Transcode(dst, src)
+		frames += k
+		if err != fr.ErrWouldBlock {
+			t.Fatalf("Transcode: frames=%d err=%v", frames, err)
+		}
+	}
+	var want bytes.Buffer
+	ww := fr.NewWriter(&want, fr.WithByteOrder(binary.LittleEndian))
+	_, _ = ww.Write([]byte("legacy"))
+	_, _ = ww.Write(big)
+	if !bytes.Equal(out.Bytes(), want.Bytes()) {
+		t.Fatalf("transcoded %d bytes, want %d", out.Len(), want.Len())
+	}
+}
+
+// --- Close ---
+
+// countingCloser counts Close calls on a transport that never has data.
+type countingCloser struct{ closes atomic.Int32 }
+
+func (c *countingCloser) Read([]byte) (int, error)  { return 0, iox.ErrWouldBlock }
+func (c *countingCloser) Write([]byte) (int, error) { return 0, iox.ErrWouldBlock }
+func (c *countingCloser) Close() error              { c.closes.Add(1); return nil }
+
+func TestClose_ReturnsErrClosed(t *testing.T) {
+	tr := &countingCloser{}
+	rw := fr.NewReadWriter(tr, tr).(*fr.ReadWriter)
+	if err := rw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := rw.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+	if n := tr.closes.Load(); n != 1 {
+		t.Fatalf("shared transport closed %d times, want 1", n)
+	}
+	buf := make([]byte, 4)
+	if _, err := rw.Read(buf); err != fr.ErrClosed {
+		t.Fatalf("Read: err=%v", err)
+	}
+	if _, err := rw.Write(buf); err != fr.ErrClosed {
+		t.Fatalf("Write: err=%v", err)
+	}
+	if _, err := rw.ReadTo(io.Discard); err != fr.ErrClosed {
+		t.Fatalf("ReadTo: err=%v", err)
+	}
+	if _, err := rw.WriteFrom(bytes.NewReader(buf), 4); err != fr.ErrClosed {
+		t.Fatalf("WriteFrom: err=%v", err)
+	}
+	if _, _, err := fr.CopyFrames(rw.Writer, rw.Reader, 1); err != fr.ErrClosed {
+		t.Fatalf("CopyFrames: err=%v", err)
+	}
+}
+
+func TestClose_UnblocksRetryLoop(t *testing.T) {
+	tr := &countingCloser{}
+	r := fr.NewReader(tr, fr.WithRetryDelay(time.Millisecond)).(*fr.Reader)
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.Read(make([]byte, 4))
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	_ = r.Close()
+	select {
+	case err := <-done:
+		if err != fr.ErrClosed {
+			t.Fatalf("blocked Read: err=%v want ErrClosed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Read did not observe Close")
+	}
+}
+
+// closeBuffer is a bytes.Buffer recording Close.
+type closeBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *closeBuffer) Close() error { b.closed = true; return nil }
+
+func TestNewReadCloser_NewWriteCloser(t *testing.T) {
+	tr := &closeBuffer{}
+	w := fr.NewWriteCloser(tr, fr.WithWriteBuffer(64))
+	_, _ = w.Write([]byte("one"))
+	_, _ = w.Write([]byte("two"))
+	if tr.Len() != 0 {
+		t.Fatalf("frames written before Close: %d bytes", tr.Len())
+	}
+	if err := w.Close(); err != nil || !tr.closed {
+		t.Fatalf("Close: err=%v closed=%v", err, tr.closed)
+	}
+	if !bytes.Equal(tr.Bytes(), []byte{3, 'o', 'n', 'e', 3, 't', 'w', 'o'}) {
+		t.Fatalf("wire=% x", tr.Bytes())
+	}
+
+	src := &closeBuffer{}
+	src.Write(tr.Bytes())
+	r := fr.NewReadCloser(src)
+	buf := make([]byte, 2)
+	if _, err := r.Read(buf); err != io.ErrShortBuffer {
+		t.Fatalf("short Read: err=%v", err)
+	}
+	if err := r.Close(); err != nil || !src.closed {
+		t.Fatalf("Close: err=%v closed=%v", err, src.closed)
+	}
+	if _, err := r.Read(buf); err != fr.ErrClosed {
+		t.Fatalf("Read after Close: err=%v", err)
+	}
+}
+
+// --- LimitedReader ---
+
+func TestLimitReader_WholeMessagesWithinBudget(t *testing.T) {
+	var wire bytes.Buffer
+	w := fr.NewWriter(&wire)
+	for _, m := range []string{"abc", "defg", "hijkl"} {
+		_, _ = w.Write([]byte(m))
+	}
+	lr := fr.LimitReader(fr.NewReader(&wouldBlockEveryOther{r: &wire}).(*fr.Reader), 8)
+	buf := make([]byte, 16)
+	readMsg := func() (string, error) {
+		got := 0
+		for {
+			n, err := lr.Read(buf)
+			got += n
+			if err != fr.ErrWouldBlock {
+				return string(buf[:got]), err
+			}
+		}
+	}
+	for _, want := range []string{"abc", "defg"} {
+		if m, err := readMsg(); err != nil || m != want {
+			t.Fatalf("Read: %q err=%v want %q", m, err, want)
+		}
+	}
+	for range 2 {
+		_, err := readMsg()
+		var le *fr.LimitError
+		if !errors.As(err, &le) || !errors.Is(err, fr.ErrTooLong) || le.Length != 5 || le.Remaining != 1 {
+			t.Fatalf("over budget: err=%v", err)
+		}
+	}
+	lr.N += 4
+	if m, err := readMsg(); err != nil || m != "hijkl" || lr.N != 0 {
+		t.Fatalf("after raising the budget: %q err=%v N=%d", m, err, lr.N)
+	}
+
+	a, b := lossyPair(nil)
+	pw := fr.NewWriter(a, fr.WithProtocol(fr.Datagram))
+	_, _ = pw.Write([]byte("toolong"))
+	_, _ = pw.Write([]byte("ok"))
+	plr := fr.LimitReader(fr.NewReader(b, fr.WithProtocol(fr.Datagram)).(*fr.Reader), 4)
+	if _, err := plr.Read(buf); !errors.Is(err, fr.ErrTooLong) {
+		t.Fatalf("packet over budget: err=%v", err)
+	}
+	if n, err := plr.Read(buf); err != nil || string(buf[:n]) != "ok" || plr.N != 2 {
+		t.Fatalf("packet within budget: %q err=%v N=%d", buf[:n], err, plr.N)
+	}
+}
+
+// --- Minimum read rate ---
+
+// trickleReader returns its chunks in order, never crossing into the next
+// one in a single Read, and ErrWouldBlock for a nil chunk or at the end.
+type trickleReader struct{ chunks [][]byte }
+
+func (r *trickleReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, fr.ErrWouldBlock
+	}
+	c := r.chunks[0]
+	if c == nil {
+		r.chunks = r.chunks[1:]
+		return 0, fr.ErrWouldBlock
+	}
+	n := copy(p, c)
+	if r.chunks[0] = c[n:]; n == len(c) {
+		r.chunks = r.chunks[1:]
+	}
+	return n, nil
+}
+
+func TestMinReadRate_AbortsTrickledMessage(t *testing.T) {
+	const grace = 20 * time.Millisecond
+	buf := make([]byte, 200)
+
+	// Idle time before the first byte is not counted.
+	src := &trickleReader{chunks: [][]byte{nil, {3, 'a', 'b', 'c'}}}
+	r := fr.NewReader(src, fr.WithMinReadRate(1000, grace))
+	if _, err := r.Read(buf); err != fr.ErrWouldBlock {
+		t.Fatalf("idle read: err=%v", err)
+	}
+	time.Sleep(2 * grace)
+	if n, err := r.Read(buf); err != nil || n != 3 {
+		t.Fatalf("read after idle: n=%d err=%v", n, err)
+	}
+
+	// A message that stalls past the grace period is aborted.
+	src = &trickleReader{chunks: [][]byte{{200, 'x'}, nil, {'y'}}}
+	r = fr.NewReader(src, fr.WithMinReadRate(1000, grace))
+	if n, err := r.Read(buf); err != fr.ErrWouldBlock || n != 1 {
+		t.Fatalf("first read: n=%d err=%v", n, err)
+	}
+	time.Sleep(2 * grace)
+	_, err := r.Read(buf)
+	var re *fr.RateError
+	if !errors.Is(err, fr.ErrTooSlow) || !errors.As(err, &re) || re.Received != 3 || re.Elapsed < 2*grace {
+		t.Fatalf("trickled read: err=%v", err)
+	}
+}
+
+func TestMessageDeadline_AbortsIncompleteMessage(t *testing.T) {
+	const d = 20 * time.Millisecond
+	buf := make([]byte, 8)
+
+	src := &trickleReader{chunks: [][]byte{nil, {5, 'h'}, nil, {'e', 'l', 'l', 'o'}, nil, {1, 'x'}}}
+	r := fr.NewReader(src, fr.WithMessageDeadline(d))
+	if _, err := r.Read(buf); err != fr.ErrWouldBlock {
+		t.Fatalf("idle read: err=%v", err)
+	}
+	time.Sleep(2 * d) // idle time is not counted
+	if n, err := r.Read(buf); err != fr.ErrWouldBlock || n != 1 {
+		t.Fatalf("first read: n=%d err=%v", n, err)
+	}
+	if n, err := r.Read(buf); err != nil || n != 4 || string(buf[:5]) != "hello" {
+		t.Fatalf("read within deadline: got (%d, %q, %v)", n, buf[:5], err)
+	}
+	if _, err := r.Read(buf); err != fr.ErrWouldBlock {
+		t.Fatalf("idle read: err=%v", err)
+	}
+	time.Sleep(2 * d)
+	if n, err := r.Read(buf); err != nil || string(buf[:n]) != "x" {
+		t.Fatalf("read after idle: got (%q, %v)", buf[:n], err)
+	}
+
+	src = &trickleReader{chunks: [][]byte{{5, 'h'}, nil, {'e', 'l', 'l', 'o'}}}
+	r = fr.NewReader(src, fr.WithMessageDeadline(d))
+	if _, err := r.Read(buf); err != fr.ErrWouldBlock {
+		t.Fatalf("first read: err=%v", err)
+	}
+	time.Sleep(2 * d)
+	for i := range 2 {
+		if _, err := r.Read(buf); err != fr.ErrTimeout {
+			t.Fatalf("read %d past the deadline: err=%v, want ErrTimeout", i, err)
+		}
+	}
+}
+
+// stallWriter reports ErrWouldBlock block times before accepting each write.
+type stallWriter struct {
+	block, left int
+	bytes.Buffer
+}
+
+func (w *stallWriter) Write(p []byte) (int, error) {
+	if w.left < w.block {
+		w.left++
+		return 0, fr.ErrWouldBlock
+	}
+	w.left = 0
+	return w.Buffer.Write(p)
+}
+
+func TestWriteStallLimit_SlowConsumer(t *testing.T) {
+	// The retry budget counts per message and restarts with the next one.
+	sw := &stallWriter{block: 2}
+	w := fr.NewWriter(sw, fr.WithProtocol(fr.SeqPacket), fr.WithBlock(), fr.WithWriteStallLimit(0, 2))
+	for i := range 3 {
+		if _, err := w.Write([]byte("pkt")); err != nil {
+			t.Fatalf("write %d: %v", i, err)
+		}
+	}
+	sw = &stallWriter{block: 3}
+	w = fr.NewWriter(sw, fr.WithProtocol(fr.SeqPacket), fr.WithBlock(), fr.WithWriteStallLimit(0, 2))
+	_, err := w.Write([]byte("pkt"))
+	var se *fr.StallError
+	if !errors.Is(err, fr.ErrSlowConsumer) || !errors.As(err, &se) || se.Retries != 3 {
+		t.Fatalf("over budget: err=%v", err)
+	}
+
+	// In non-blocking mode the stalls of one message add up across calls,
+	// and the duration bound applies too.
+	w = fr.NewWriter(wbWriter2{}, fr.WithWriteStallLimit(10*time.Millisecond, 0))
+	if _, err := w.Write([]byte("hello")); err != fr.ErrWouldBlock {
+		t.Fatalf("first attempt: err=%v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, err := w.Write([]byte("hello")); !errors.Is(err, fr.ErrSlowConsumer) {
+		t.Fatalf("after the stall timeout: err=%v", err)
+	}
+}
+
+// --- Concurrent use ---
+
+// gateConn blocks every Read and Write until release is closed,
+// signalling entered (without blocking) as each call arrives.
+type gateConn struct {
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (g *gateConn) Read(p []byte) (int, error) {
+	select {
+	case g.entered <- struct{}{}:
+	default:
+	}
+	<-g.release
+	return 0, io.EOF
+}
+
+func (g *gateConn) Write(p []byte) (int, error) {
+	select {
+	case g.entered <- struct{}{}:
+	default:
+	}
+	<-g.release
+	return len(p), nil
+}
+
+func TestConcurrentUse_Detected(t *testing.T) {
+	g := &gateConn{entered: make(chan struct{}, 2), release: make(chan struct{})}
+	rw := fr.NewReadWriter(g, g).(*fr.ReadWriter)
+	done := make(chan error, 2)
+	go func() { _, err := rw.Read(make([]byte, 8)); done <- err }()
+	go func() { _, err := rw.Write([]byte("x")); done <- err }()
+	<-g.entered
+	<-g.entered // both directions may be busy at once
+
+	if _, err := rw.Read(make([]byte, 8)); err != fr.ErrConcurrentUse {
+		t.Fatalf("overlapping Read: err=%v", err)
+	}
+	if _, err := rw.WriteTo(io.Discard); err != fr.ErrConcurrentUse {
+		t.Fatalf("overlapping WriteTo: err=%v", err)
+	}
+	if _, err := rw.Write([]byte("y")); err != fr.ErrConcurrentUse {
+		t.Fatalf("overlapping Write: err=%v", err)
+	}
+	if _, err := rw.ReadFrom(bytes.NewReader(nil)); err != fr.ErrConcurrentUse {
+		t.Fatalf("overlapping ReadFrom: err=%v", err)
+	}
+	close(g.release)
+	<-done
+	<-done
+	if _, err := rw.Write([]byte("z")); err != nil {
+		t.Fatalf("Write after the first returned: %v", err)
+	}
+}
+
+// --- Allocator ---
+
+// trackingAllocator records outstanding buffers by their first byte address.
+type trackingAllocator struct {
+	allocs, frees int
+	live          map[*byte]bool
+}
+
+func (a *trackingAllocator) Alloc(n int) []byte {
+	a.allocs++
+	p := make([]byte, n+16) // larger than asked, as arenas often are
+	a.live[&p[0]] = true
+	return p
+}
+
+func (a *trackingAllocator) Free(p []byte) {
+	if !a.live[&p[0]] {
+		panic("Free of a buffer not from Alloc")
+	}
+	delete(a.live, &p[0])
+	a.frees++
+}
+
+func TestAllocator_ScratchBuffersAndClose(t *testing.T) {
+	a := &trackingAllocator{live: map[*byte]bool{}}
+	var wire bytes.Buffer
+	w := fr.NewWriter(&wire, fr.WithAllocator(a)).(*fr.Writer)
+	if _, err := w.WriteFrom(bytes.NewReader([]byte("hello")), 5); err != nil {
+		t.Fatalf("WriteFrom: %v", err)
+	}
+	if _, err := w.WriteTyped(1, []byte("x")); err != nil {
+		t.Fatalf("WriteTyped: %v", err)
+	}
+	if _, err := w.WriteTyped(2, bytes.Repeat([]byte("y"), 64)); err != nil {
+		t.Fatalf("WriteTyped grow: %v", err)
+	}
+
+	r := fr.NewReader(&wire, fr.WithAllocator(a), fr.WithReadLimit(128)).(*fr.Reader)
+	var out bytes.Buffer
+	if _, err := r.ReadTo(&out); err != nil || out.String() != "hello" {
+		t.Fatalf("ReadTo: %q err=%v", out.String(), err)
+	}
+	r.SetReadLimit(4096) // grows the scratch buffer
+	if a.allocs != 5 || a.frees != 2 {
+		t.Fatalf("allocs=%d frees=%d", a.allocs, a.frees)
+	}
+	_ = r.Close()
+	_ = w.Close()
+	if len(a.live) != 0 {
+		t.Fatalf("%d buffers not freed after Close", len(a.live))
+	}
+
+	f := fr.NewForwarder(io.Discard, &wire, fr.WithAllocator(a))
+	if a.allocs != 6 || f == nil {
+		t.Fatalf("Forwarder buffer not allocated: allocs=%d", a.allocs)
+	}
+}
+
+func TestSizeConstructors_PreallocateScratch(t *testing.T) {
+	a := &trackingAllocator{live: map[*byte]bool{}}
+	var wire bytes.Buffer
+	w := fr.NewWriterSize(&wire, 256, fr.WithAllocator(a)).(*fr.Writer)
+	r := fr.NewReaderSize(&wire, 256, fr.WithAllocator(a), fr.WithReadLimit(128)).(*fr.Reader)
+	if a.allocs != 3 {
+		t.Fatalf("allocs at construction=%d want 3", a.allocs)
+	}
+	if _, err := w.WriteFrom(bytes.NewReader([]byte("hello")), 5); err != nil {
+		t.Fatalf("WriteFrom: %v", err)
+	}
+	if _, err := w.WriteTyped(1, bytes.Repeat([]byte("y"), 128)); err != nil {
+		t.Fatalf("WriteTyped: %v", err)
+	}
+	var out bytes.Buffer
+	if _, err := r.ReadTo(&out); err != nil || out.String() != "hello" {
+		t.Fatalf("ReadTo: %q err=%v", out.String(), err)
+	}
+	if a.allocs != 3 {
+		t.Fatalf("fast paths allocated: allocs=%d", a.allocs)
+	}
+}
+
+func TestMemoryBudget_SharedAcrossFramers(t *testing.T) {
+	budget := fr.NewMemoryBudget(100 << 10)
+	wire := func() *bytes.Buffer {
+		var b bytes.Buffer
+		_, _ = fr.NewWriter(&b).Write([]byte("hello"))
+		return &b
+	}
+	r1 := fr.NewReader(wire(), fr.WithMemoryBudget(budget)).(*fr.Reader)
+	if _, err := r1.WriteTo(io.Discard); err != nil {
+		t.Fatalf("first WriteTo: %v", err)
+	}
+	if budget.Used() != 64<<10 {
+		t.Fatalf("used=%d after one scratch buffer", budget.Used())
+	}
+
+	r2 := fr.NewReader(wire(), fr.WithMemoryBudget(budget)).(*fr.Reader)
+	_, err := r2.WriteTo(io.Discard)
+	var be *fr.BudgetError
+	if !errors.Is(err, fr.ErrMemoryBudget) || !errors.As(err, &be) || be.Requested != 64<<10 || be.Limit != 100<<10 {
+		t.Fatalf("second WriteTo: err=%v, want a *BudgetError", err)
+	}
+
+	// Close returns the buffer; the denied call can then be retried.
+	_ = r1.Close()
+	if n, err := r2.WriteTo(io.Discard); err != nil || n != 5 {
+		t.Fatalf("retried WriteTo: n=%d err=%v", n, err)
+	}
+	_ = r2.Close()
+	if budget.Used() != 0 {
+		t.Fatalf("used=%d after Close", budget.Used())
+	}
+}
+
+// addrWriter records the address of every buffer written to it.
+type addrWriter struct{ addrs []uintptr }
+
+func (w *addrWriter) Write(p []byte) (int, error) {
+	w.addrs = append(w.addrs, uintptr(unsafe.Pointer(unsafe.SliceData(p))))
+	return len(p), nil
+}
+
+func TestAlignedBuffers_PageAligned(t *testing.T) {
+	page := uintptr(os.Getpagesize())
+	for name, opt := range map[string]fr.Option{"aligned": fr.WithAlignedBuffers(), "hugepage": fr.WithHugePageBuffers()} {
+		for i := range 3 {
+			var wire bytes.Buffer
+			_, _ = fr.NewWriter(&wire).Write([]byte("hello"))
+			r := fr.NewReader(&wire, opt, fr.WithReadLimit(1000*(i+1))).(*fr.Reader)
+			dst := &addrWriter{}
+			if _, err := r.WriteTo(dst); err != nil {
+				t.Fatalf("%s: WriteTo: %v", name, err)
+			}
+			if len(dst.addrs) != 1 || dst.addrs[0]%page != 0 {
+				t.Fatalf("%s: payload buffers at %#x, want page-aligned", name, dst.addrs)
+			}
+			_ = r.Close() // recycles the buffer
+		}
+	}
+}
+
+func TestHugePageAllocator_Release(t *testing.T) {
+	page := uintptr(os.Getpagesize())
+	a := fr.NewHugePageAllocator()
+	for round := range 2 {
+		for _, n := range []int{100, 3 << 20} {
+			b := a.Alloc(n)
+			if len(b) < n || uintptr(unsafe.Pointer(unsafe.SliceData(b)))%page != 0 {
+				t.Fatalf("round %d: Alloc(%d) len=%d at %p", round, n, len(b), unsafe.SliceData(b))
+			}
+			b[n-1] = 1
+			a.Free(b)
+		}
+		// Free of a buffer the allocator did not hand out is ignored.
+		a.Free(make([]byte, 4096))
+		a.Release()
+	}
+}
+
+// --- Write buffer ---
+
+// countingSink records transport writes.
+type countingSink struct {
+	syncBuffer
+	writes atomic.Int32
+}
+
+func (s *countingSink) Write(p []byte) (int, error) {
+	s.writes.Add(1)
+	return s.syncBuffer.Write(p)
+}
+
+func TestWriteBuffer_CoalescesUntilFlush(t *testing.T) {
+	sink := &countingSink{}
+	w := fr.NewWriter(sink, fr.WithWriteBuffer(16)).(*fr.Writer)
+	for _, m := range []string{"ab", "cd", "ef"} {
+		if _, err := w.Write([]byte(m)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if n := sink.writes.Load(); n != 0 {
+		t.Fatalf("transport writes before Flush=%d", n)
+	}
+	// A frame that does not fit pushes the buffer out first.
+	if _, err := w.Write(bytes.Repeat([]byte{'z'}, 10)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if n := sink.writes.Load(); n != 1 {
+		t.Fatalf("transport writes after overflow=%d", n)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	r := fr.NewReader(bytes.NewReader(sink.Bytes()))
+	buf := make([]byte, 16)
+	for _, want := range []string{"ab", "cd", "ef", "zzzzzzzzzz"} {
+		if n, err := r.Read(buf); err != nil || string(buf[:n]) != want {
+			t.Fatalf("Read: %q err=%v want %q", buf[:n], err, want)
+		}
+	}
+	if err := fr.NewWriter(io.Discard).(*fr.Writer).Flush(); err != nil {
+		t.Fatalf("unbuffered Flush: %v", err)
+	}
+}
+
+func TestWriteBuffer_FlushLatencyBound(t *testing.T) {
+	sink := &countingSink{}
+	w := fr.NewWriter(sink, fr.WithFlushLatency(5*time.Millisecond)).(*fr.Writer)
+	if _, err := w.Write([]byte("ping")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for sink.writes.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("buffered frame was not flushed within the latency bound")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := sink.Bytes(); !bytes.Equal(got, []byte{4, 'p', 'i', 'n', 'g'}) {
+		t.Fatalf("wire=% x", got)
+	}
+
+	// Close flushes what is still buffered.
+	_, _ = w.Write([]byte("x"))
+	_ = w.Close()
+	if got := sink.Bytes(); !bytes.HasSuffix(got, []byte{1, 'x'}) {
+		t.Fatalf("after Close wire=% x", got)
+	}
+}
+
+func TestWriteBuffer_WriteUrgentBypasses(t *testing.T) {
+	sink := &countingSink{}
+	w := fr.NewWriter(sink, fr.WithWriteBuffer(64)).(*fr.Writer)
+	_, _ = w.Write([]byte("bulk1"))
+	_, _ = w.Write([]byte("bulk2"))
+	if n, err := w.WriteUrgent([]byte("pause")); n != 5 || err != nil {
+		t.Fatalf("WriteUrgent: n=%d err=%v", n, err)
+	}
+	// The buffered frames go first, then the urgent frame, unbuffered.
+	want := []byte{5, 'b', 'u', 'l', 'k', '1', 5, 'b', 'u', 'l', 'k', '2', 5, 'p', 'a', 'u', 's', 'e'}
+	if got := sink.Bytes(); !bytes.Equal(got, want) {
+		t.Fatalf("wire=% x", got)
+	}
+	_, _ = w.Write([]byte("later"))
+	if got := sink.Bytes(); len(got) != len(want) {
+		t.Fatalf("frame after WriteUrgent was not buffered: wire=% x", got)
+	}
+}
+
+func TestInterceptors_ChainAndResume(t *testing.T) {
+	calls := 0
+	tag := func(s string) fr.Interceptor {
+		return func(p []byte) ([]byte, error) {
+			calls++
+			return append(append([]byte(nil), p...), s...), nil
+		}
+	}
+	// Interceptors run once per message in the order added, even when
+	// the write is retried after ErrWouldBlock.
+	sw := &stallWriter{block: 1}
+	w := fr.NewWriter(sw, fr.WithWriteInterceptor(tag("-a")), fr.WithWriteInterceptor(tag("-b")))
+	var n int
+	var err error
+	for {
+		if n, err = w.Write([]byte("msg")); err != fr.ErrWouldBlock {
+			break
+		}
+		if n != 0 {
+			t.Fatalf("partial write reported n=%d", n)
+		}
+	}
+	if err != nil || n != 3 || calls != 2 {
+		t.Fatalf("Write n=%d err=%v calls=%d", n, err, calls)
+	}
+
+	wire := sw.Bytes()
+	r := fr.NewReader(&trickleReader{chunks: [][]byte{wire[:4], nil, wire[4:]}},
+		fr.WithReadInterceptor(func(p []byte) ([]byte, error) { return bytes.ToUpper(p), nil }),
+		fr.WithReadInterceptor(func(p []byte) ([]byte, error) { return p[:len(p)-2], nil }))
+	buf := make([]byte, 16)
+	for {
+		if n, err = r.Read(buf); err != fr.ErrWouldBlock {
+			break
+		}
+		if n != 0 {
+			t.Fatalf("partial read reported n=%d", n)
+		}
+	}
+	if err != nil || string(buf[:n]) != "MSG-A" {
+		t.Fatalf("Read %q err=%v", buf[:n], err)
+	}
+
+	reject := errors.New("rejected")
+	w = fr.NewWriter(&bytes.Buffer{}, fr.WithWriteInterceptor(func([]byte) ([]byte, error) { return nil, reject }))
+	if _, err := w.Write([]byte("x")); err != reject {
+		t.Fatalf("interceptor error: %v", err)
+	}
+}
+
+func TestBufioReader_ViewsAndRead(t *testing.T) {
+	var wire bytes.Buffer
+	w := fr.NewWriter(&wire)
+	big := bytes.Repeat([]byte("z"), 40)
+	for _, m := range [][]byte{[]byte("one"), {}, big, []byte("two")} {
+		if _, err := w.Write(m); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	b := wire.Bytes()
+	br := bufio.NewReaderSize(&trickleReader{chunks: [][]byte{b[:2], nil, b[2:20], nil, b[20:]}}, 16)
+	r := fr.FromBufioReader(br)
+
+	next := func() []byte {
+		for {
+			v, err := r.Next()
+			if err == fr.ErrWouldBlock {
+				continue
+			}
+			if err != nil {
+				t.Fatalf("Next: %v", err)
+			}
+			return v
+		}
+	}
+	if v := next(); string(v) != "one" {
+		t.Fatalf("first view %q", v)
+	}
+	if v := next(); len(v) != 0 {
+		t.Fatalf("empty message view %q", v)
+	}
+	if _, err := r.Next(); err != bufio.ErrBufferFull {
+		t.Fatalf("message larger than buffer: err=%v", err)
+	}
+	buf := make([]byte, 64)
+	got := 0
+	for {
+		n, err := r.Read(buf[:len(big)])
+		got += n
+		if err == nil {
+			break
+		}
+		if err != fr.ErrWouldBlock {
+			t.Fatalf("Read: %v", err)
+		}
+	}
+	if !bytes.Equal(buf[:got], big) {
+		t.Fatalf("Read %d bytes %q", got, buf[:got])
+	}
+	if v := next(); string(v) != "two" {
+		t.Fatalf("last view %q", v)
+	}
+	if _, err := r.Next(); err != fr.ErrWouldBlock {
+		t.Fatalf("drained trickle: err=%v", err)
+	}
+}
+
+func TestReadAlloc_ExactSizeAndResume(t *testing.T) {
+	var wire bytes.Buffer
+	w := fr.NewWriter(&wire)
+	msgs := [][]byte{[]byte("hello"), {}, bytes.Repeat([]byte("b"), 60000)}
+	for _, m := range msgs {
+		if _, err := w.Write(m); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	b := wire.Bytes()
+	r := fr.NewReader(&trickleReader{chunks: [][]byte{b[:3], nil, b[3:9], nil, b[9:100], nil, b[100:]}}).(*fr.Reader)
+	for i, want := range msgs {
+		var got []byte
+		var err error
+		for {
+			if got, err = r.ReadAlloc(); err != fr.ErrWouldBlock {
+				break
+			}
+		}
+		if err != nil || !bytes.Equal(got, want) || cap(got) != len(want) {
+			t.Fatalf("message %d: len=%d cap=%d err=%v", i, len(got), cap(got), err)
+		}
+	}
+	if _, err := r.ReadAlloc(); err != fr.ErrWouldBlock {
+		t.Fatalf("drained: err=%v", err)
+	}
+
+	pr := fr.NewReader(bytes.NewReader([]byte("datagram")), fr.WithReadUDP(),
+		fr.WithReadInterceptor(func(p []byte) ([]byte, error) { return bytes.ToUpper(p), nil })).(*fr.Reader)
+	if got, err := pr.ReadAlloc(); err != nil || string(got) != "DATAGRAM" {
+		t.Fatalf("packet ReadAlloc %q err=%v", got, err)
+	}
+}
+
+func TestReadAlloc_BoundsHostileHeader(t *testing.T) {
+	// An 8-byte compact header claiming a payload of nearly 2^56 bytes.
+	hostile := []byte{0xFF, 0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+	r := fr.NewReader(bytes.NewReader(hostile)).(*fr.Reader)
+	if got, err := r.ReadAlloc(); err != fr.ErrTooLong || got != nil {
+		t.Fatalf("hostile header: len=%d err=%v", len(got), err)
+	}
+
+	// ReadLimit raises the bound above 64KiB.
+	var wire bytes.Buffer
+	if _, err := fr.NewWriter(&wire).Write(make([]byte, 70000)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if _, err := fr.NewReader(bytes.NewReader(wire.Bytes())).(*fr.Reader).ReadAlloc(); err != fr.ErrTooLong {
+		t.Fatalf("over default bound: err=%v", err)
+	}
+	r = fr.NewReader(bytes.NewReader(wire.Bytes()), fr.WithReadLimit(1<<17)).(*fr.Reader)
+	if got, err := r.ReadAlloc(); err != nil || len(got) != 70000 {
+		t.Fatalf("under ReadLimit: len=%d err=%v", len(got), err)
+	}
+}
+
+func TestReadBuffer_AppendsAndResumes(t *testing.T) {
+	var wire bytes.Buffer
+	w := fr.NewWriter(&wire)
+	for _, m := range []string{"alpha", "", "omega"} {
+		if _, err := w.Write([]byte(m)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	b := wire.Bytes()
+	r := fr.NewReader(&trickleReader{chunks: [][]byte{b[:3], nil, b[3:8], nil, b[8:]}}).(*fr.Reader)
+	out := bytes.NewBufferString("> ")
+	for i, want := range []int{5, 0, 5} {
+		var n int
+		var err error
+		for {
+			if n, err = r.ReadBuffer(out); err != fr.ErrWouldBlock {
+				break
+			}
+			if n != 0 {
+				t.Fatalf("partial message appended: n=%d", n)
+			}
+		}
+		if err != nil || n != want {
+			t.Fatalf("message %d: n=%d err=%v", i, n, err)
+		}
+	}
+	if out.String() != "> alphaomega" {
+		t.Fatalf("buffer %q", out.String())
+	}
+
+	pr := fr.NewReader(bytes.NewReader([]byte("pkt")), fr.WithReadUDP()).(*fr.Reader)
+	out.Reset()
+	if n, err := pr.ReadBuffer(out); err != nil || n != 3 || out.String() != "pkt" {
+		t.Fatalf("packet ReadBuffer n=%d %q err=%v", n, out.String(), err)
+	}
+
+	hr := fr.NewReader(bytes.NewReader([]byte{0xFF, 0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF})).(*fr.Reader)
+	var small bytes.Buffer
+	if n, err := hr.ReadBuffer(&small); err != fr.ErrTooLong || n != 0 || small.Cap() != 0 {
+		t.Fatalf("hostile header: n=%d cap=%d err=%v", n, small.Cap(), err)
+	}
+}
+
+// gatedBuffer holds every Write until release is closed.
+type gatedBuffer struct {
+	release chan struct{}
+	mu      sync.Mutex
+	buf     bytes.Buffer
+}
+
+func (g *gatedBuffer) Write(p []byte) (int, error) {
+	<-g.release
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.buf.Write(p)
+}
+
+func decodeAll(t *testing.T, wire []byte) []string {
+	t.Helper()
+	r := fr.NewReader(bytes.NewReader(wire))
+	var msgs []string
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		if err == io.EOF {
+			return msgs
+		}
+		if err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+		msgs = append(msgs, string(buf[:n]))
+	}
+}
+
+func TestSendQueue_BudgetAndBackpressure(t *testing.T) {
+	g := &gatedBuffer{release: make(chan struct{})}
+	q := fr.NewSendQueue(g, fr.SendQueueConfig{MaxPending: 10})
+	for _, m := range []string{"hello", "world"} {
+		if _, err := q.Write([]byte(m)); err != nil {
+			t.Fatalf("Write %q: %v", m, err)
+		}
+	}
+	if _, err := q.Write([]byte("x")); err != fr.ErrWouldBlock {
+		t.Fatalf("full budget: err=%v", err)
+	}
+	if _, err := q.Write(make([]byte, 11)); err != fr.ErrTooLong {
+		t.Fatalf("over budget: err=%v", err)
+	}
+	if q.Pending() != 10 {
+		t.Fatalf("Pending=%d", q.Pending())
+	}
+	close(g.release)
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if got := decodeAll(t, g.buf.Bytes()); len(got) != 2 || got[0] != "hello" || got[1] != "world" {
+		t.Fatalf("drained %q", got)
+	}
+	if _, err := q.Write([]byte("late")); err != fr.ErrClosed {
+		t.Fatalf("Write after Close: err=%v", err)
+	}
+
+	g = &gatedBuffer{release: make(chan struct{})}
+	q = fr.NewSendQueue(g, fr.SendQueueConfig{MaxPending: 5, Block: true})
+	if _, err := q.Write([]byte("abcde")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := q.Write([]byte("fghij"))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("Write did not wait for room: err=%v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(g.release)
+	if err := <-done; err != nil {
+		t.Fatalf("blocked Write: %v", err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if got := decodeAll(t, g.buf.Bytes()); len(got) != 2 || got[1] != "fghij" {
+		t.Fatalf("drained %q", got)
+	}
+}
+
+func TestSendQueue_AbortUnblocksClose(t *testing.T) {
+	for _, opts := range [][]fr.Option{
+		nil, // the Writer waits out ErrWouldBlock itself
+		{fr.WithWaitFunc(func(fr.Direction) error { return fr.ErrWouldBlock })},
+	} {
+		q := fr.NewSendQueue(&wouldBlockWriter2{}, fr.SendQueueConfig{}, opts...)
+		if _, err := q.Write([]byte("stuck")); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		closed := make(chan error, 1)
+		go func() { closed <- q.Close() }()
+		select {
+		case err := <-closed:
+			t.Fatalf("Close returned while the peer does not drain: %v", err)
+		case <-time.After(20 * time.Millisecond):
+		}
+		if err := q.Abort(); err != nil {
+			t.Fatalf("Abort: %v", err)
+		}
+		select {
+		case err := <-closed:
+			if err != nil {
+				t.Fatalf("Close after Abort: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Abort did not unblock Close")
+		}
+		if q.Pending() != 0 {
+			t.Fatalf("Pending=%d after Abort", q.Pending())
+		}
+	}
+}
+
+func TestFollowReader_WaitsForAppendedFrames(t *testing.T) {
+	path := t.TempDir() + "/log"
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	in, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+
+	var frames bytes.Buffer
+	w := fr.NewWriter(&frames)
+	_, _ = w.Write([]byte("first"))
+	_, _ = w.Write([]byte("second"))
+	wire := frames.Bytes()
+	go func() {
+		// Append the second frame in two parts, splitting its payload.
+		for _, part := range [][]byte{wire[:9], wire[9:]} {
+			time.Sleep(10 * time.Millisecond)
+			_, _ = out.Write(part)
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := fr.NewFollowReader(ctx, in, time.Millisecond)
+	buf := make([]byte, 16)
+	for _, want := range []string{"first", "second"} {
+		n, err := r.Read(buf)
+		if err != nil || string(buf[:n]) != want {
+			t.Fatalf("Read %q err=%v want %q", buf[:n], err, want)
+		}
+	}
+	time.AfterFunc(5*time.Millisecond, cancel)
+	if _, err := r.Read(buf); err != context.Canceled {
+		t.Fatalf("cancelled follow: err=%v", err)
+	}
+}
+
+func TestFileUtilities_ConcatFilterCompact(t *testing.T) {
+	opts := []fr.Option{fr.WithHeaderFormat(fr.HeaderUvarint)}
+	file := func(msgs ...string) *bytes.Buffer {
+		var b bytes.Buffer
+		w := fr.NewWriter(&b, opts...)
+		for _, m := range msgs {
+			_, _ = w.Write([]byte(m))
+		}
+		return &b
+	}
+	var merged bytes.Buffer
+	n, err := fr.ConcatFrames(&merged, []io.Reader{file("a1", "a2"), file(), file("b1", "del:a1")}, opts...)
+	if err != nil || n != 4 {
+		t.Fatalf("ConcatFrames n=%d err=%v", n, err)
+	}
+	if !bytes.Equal(merged.Bytes(), file("a1", "a2", "b1", "del:a1").Bytes()) {
+		t.Fatalf("merged % x", merged.Bytes())
+	}
+
+	var compacted bytes.Buffer
+	kept, dropped, err := fr.CompactFrames(&compacted, bytes.NewReader(merged.Bytes()),
+		func(p []byte) bool { return bytes.HasPrefix(p, []byte("del:")) }, opts...)
+	if err != nil || kept != 3 || dropped != 1 {
+		t.Fatalf("CompactFrames kept=%d dropped=%d err=%v", kept, dropped, err)
+	}
+	if !bytes.Equal(compacted.Bytes(), file("a1", "a2", "b1").Bytes()) {
+		t.Fatalf("compacted % x", compacted.Bytes())
+	}
+
+	_, _, err = fr.FilterFrames(io.Discard, bytes.NewReader(merged.Bytes()),
+		func([]byte) bool { return true }, append(opts, fr.WithReadLimit(1))...)
+	if err != fr.ErrTooLong {
+		t.Fatalf("message over the buffer: err=%v", err)
+	}
+	_, err = fr.ConcatFrames(io.Discard, []io.Reader{bytes.NewReader(merged.Bytes()[:4])}, opts...)
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("truncated source: err=%v", err)
+	}
+}
+
+func TestHybrid_FramedThenRaw(t *testing.T) {
+	var wire bytes.Buffer
+	w := fr.NewWriter(&wire, fr.WithWriteBuffer(64)).(*fr.Writer)
+	_, _ = w.Write([]byte("CONNECT"))
+	if _, err := w.Raw().Write([]byte("tunnel bytes")); err != nil {
+		t.Fatalf("raw Write: %v", err)
+	}
+	_, _ = w.Write([]byte("framed again"))
+	_ = w.Flush()
+
+	r := fr.NewReader(&wire).(*fr.Reader)
+	buf := make([]byte, 16)
+	if n, err := r.Read(buf); err != nil || string(buf[:n]) != "CONNECT" {
+		t.Fatalf("Read %q err=%v", buf[:n], err)
+	}
+	raw := make([]byte, 12)
+	if _, err := io.ReadFull(r.RawRemainder(), raw); err != nil || string(raw) != "tunnel bytes" {
+		t.Fatalf("raw remainder %q err=%v", raw, err)
+	}
+	if n, err := r.Read(buf); err != nil || string(buf[:n]) != "framed again" {
+		t.Fatalf("Read after raw phase %q err=%v", buf[:n], err)
+	}
+
+	// Mid-message the handover is refused.
+	r = fr.NewReader(&trickleReader{chunks: [][]byte{{5, 'a'}}}).(*fr.Reader)
+	if _, err := r.Read(buf); err != fr.ErrWouldBlock {
+		t.Fatalf("partial message: err=%v", err)
+	}
+	if _, err := r.RawRemainder().Read(buf); err != fr.ErrInvalidArgument {
+		t.Fatalf("RawRemainder mid-message: err=%v", err)
+	}
+}
+
+// countingReader counts the Read calls reaching r.
+type countingReader struct {
+	r     io.Reader
+	reads int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	c.reads++
+	return c.r.Read(p)
+}
+
+func TestPrefetch_ServesPipelinedMessages(t *testing.T) {
+	var wire bytes.Buffer
+	w := fr.NewWriter(&wire).(*fr.Writer)
+	for i := range 10 {
+		_, _ = w.Write([]byte{'m', byte('0' + i)})
+	}
+	_, _ = w.Raw().Write([]byte("raw"))
+	cr := &countingReader{r: &trickleReader{chunks: [][]byte{wire.Bytes()[:3], wire.Bytes()[3:]}}}
+	r := fr.NewReader(cr, fr.WithPrefetch(4096)).(*fr.Reader)
+	buf := make([]byte, 8)
+	n, err := r.Read(buf)
+	if err != nil || string(buf[:n]) != "m0" {
+		t.Fatalf("Read %q err=%v", buf[:n], err)
+	}
+	// Delivering m0 read the rest ahead: the next messages need no transport read.
+	reads := cr.reads
+	for i := 1; i < 10; i++ {
+		if n, err := r.Read(buf); err != nil || string(buf[:n]) != string([]byte{'m', byte('0' + i)}) {
+			t.Fatalf("Read %d: %q err=%v", i, buf[:n], err)
+		}
+	}
+	if cr.reads != reads || reads != 2 {
+		t.Fatalf("transport reads=%d, %d after the first message", cr.reads, reads)
+	}
+	raw := make([]byte, 3)
+	if _, err := io.ReadFull(r.RawRemainder(), raw); err != nil || string(raw) != "raw" {
+		t.Fatalf("buffered raw remainder %q err=%v", raw, err)
+	}
+}
+
+// lockedWriter is a transport safe for the background flusher: it reports
+// ErrWouldBlock blocks times, then err when set, and records the writes.
+type lockedWriter struct {
+	mu     sync.Mutex
+	blocks int
+	err    error
+	writes int
+	buf    bytes.Buffer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.blocks > 0 {
+		w.blocks--
+		return 0, fr.ErrWouldBlock
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.writes++
+	return w.buf.Write(p)
+}
+
+func (w *lockedWriter) snapshot() (int, []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.writes, bytes.Clone(w.buf.Bytes())
+}
+
+func TestStartFlusher_BatchesAndRetries(t *testing.T) {
+	lw := &lockedWriter{blocks: 2}
+	w := fr.NewWriter(lw, fr.WithWriteBuffer(1024), fr.WithFlushLatency(20*time.Millisecond)).(*fr.Writer)
+	if err := w.StartFlusher(nil); err != nil {
+		t.Fatalf("StartFlusher: %v", err)
+	}
+	if err := w.StartFlusher(nil); err != fr.ErrInvalidArgument {
+		t.Fatalf("second StartFlusher: err=%v", err)
+	}
+	_, _ = w.Write([]byte("one"))
+	_, _ = w.Write([]byte("two"))
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		writes, wire := lw.snapshot()
+		if len(wire) == 8 {
+			if writes != 1 || !bytes.Equal(wire, []byte("\x03one\x03two")) {
+				t.Fatalf("writes=%d wire=%q", writes, wire)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("flusher did not drain the buffer: %q", wire)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	failed := make(chan error, 1)
+	if err := w.StopFlusher(); err != nil {
+		t.Fatalf("StopFlusher: %v", err)
+	}
+	if err := w.StartFlusher(func(err error) { failed <- err }); err != nil {
+		t.Fatalf("restart: %v", err)
+	}
+	boom := errors.New("broken pipe")
+	lw.mu.Lock()
+	lw.err = boom
+	lw.mu.Unlock()
+	_, _ = w.Write([]byte("three"))
+	select {
+	case err := <-failed:
+		if err != boom {
+			t.Fatalf("onError got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("onError not called")
+	}
+	_ = w.Close()
+}
+
+func TestForwarder_TTLDropsStaleMessages(t *testing.T) {
+	// Payloads carry their send time as 8 big-endian UnixNano bytes.
+	stamp := func(p []byte) (time.Time, bool) {
+		if len(p) < 8 {
+			return time.Time{}, false
+		}
+		return time.Unix(0, int64(binary.BigEndian.Uint64(p))), true
+	}
+	msg := func(at time.Time, body string) []byte {
+		return append(binary.BigEndian.AppendUint64(nil, uint64(at.UnixNano())), body...)
+	}
+	now := time.Now()
+	var src bytes.Buffer
+	w := fr.NewWriter(&src)
+	for _, m := range [][]byte{msg(now, "fresh"), msg(now.Add(-time.Minute), "stale"), []byte("untimed"), msg(now, "last")} {
+		if _, err := w.Write(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var dst bytes.Buffer
+	fwd := fr.NewForwarder(&dst, &src, fr.WithTTL(time.Second, stamp))
+	for {
+		if _, err := fwd.ForwardOnce(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if st := fwd.Stats(); st.Expired != 1 || st.FramesWritten != 3 {
+		t.Fatalf("stats: %+v", st)
+	}
+	rd := fr.NewReader(&dst)
+	buf := make([]byte, 64)
+	for _, want := range []string{"fresh", "untimed", "last"} {
+		n, err := rd.Read(buf)
+		if err != nil || !bytes.HasSuffix(buf[:n], []byte(want)) {
+			t.Fatalf("got %q, %v; want %q", buf[:n], err, want)
+		}
+	}
+}
+
+func TestForwarder_RoutePrefixAndStrip(t *testing.T) {
+	var src bytes.Buffer
+	w := fr.NewWriter(&src)
+	for _, m := range []string{"alpha", "", "gamma"} {
+		if _, err := w.Write([]byte(m)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Ingress hop: tag with the connection ID, across a buffer regrowth.
+	var mid bytes.Buffer
+	in := fr.NewForwarder(&mid, &src, fr.WithReadLimit(2), fr.WithRoutePrefix([]byte("c7:")))
+	in.SetReadLimit(64)
+	for {
+		if _, err := in.ForwardOnce(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := decodeAll(t, mid.Bytes()); len(got) != 3 || got[0] != "c7:alpha" || got[1] != "c7:" || got[2] != "c7:gamma" {
+		t.Fatalf("ingress: %q", got)
+	}
+
+	// Egress hop: strip the tag and stamp a per-message sequence byte,
+	// relaying into packets.
+	var seq byte
+	var pkts packetRecorder
+	out := fr.NewForwarder(&pkts, bytes.NewReader(mid.Bytes()), fr.WithWriteProtocol(fr.SeqPacket),
+		fr.WithRouteStrip(3),
+		fr.WithRoutePrefixFunc(1, func(prefix, payload []byte) { prefix[0] = '0' + seq; seq++ }))
+	for {
+		if _, err := out.ForwardOnce(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(pkts.msgs) != 3 || string(pkts.msgs[0]) != "0alpha" || string(pkts.msgs[1]) != "1" || string(pkts.msgs[2]) != "2gamma" {
+		t.Fatalf("egress: %q", pkts.msgs)
+	}
+
+	// A message shorter than the stripped prefix is dropped.
+	short := fr.NewForwarder(io.Discard, bytes.NewReader([]byte{2, 'h', 'i', 1, 'x'}), fr.WithRouteStrip(3))
+	if _, err := short.ForwardOnce(); err != fr.ErrInvalidArgument {
+		t.Fatalf("short message: %v", err)
+	}
+	if _, err := short.ForwardOnce(); err != fr.ErrInvalidArgument {
+		t.Fatalf("next short message: %v", err)
+	}
+	if _, err := short.ForwardOnce(); err != io.EOF {
+		t.Fatalf("after drops: %v", err)
+	}
+}
+
+func TestReader_BufferedAndInFrame(t *testing.T) {
+	// Two pipelined messages arrive together, then half of a third.
+	wire := []byte{2, 'a', 'b', 1, 'c', 3, 'd'}
+	r := fr.NewReader(&trickleReader{chunks: [][]byte{wire, nil, []byte("ef")}}, fr.WithPrefetch(64)).(*fr.Reader)
+	buf := make([]byte, 8)
+	if r.Buffered() != 0 || r.InFrame() {
+		t.Fatalf("idle: buffered=%d inFrame=%v", r.Buffered(), r.InFrame())
+	}
+	if n, err := r.Read(buf); err != nil || string(buf[:n]) != "ab" {
+		t.Fatalf("Read %q err=%v", buf[:n], err)
+	}
+	// The rest is buffered: the event loop reads again without waiting.
+	if r.Buffered() != 4 || r.InFrame() {
+		t.Fatalf("after first: buffered=%d inFrame=%v", r.Buffered(), r.InFrame())
+	}
+	if n, err := r.Read(buf); err != nil || string(buf[:n]) != "c" {
+		t.Fatalf("Read %q err=%v", buf[:n], err)
+	}
+	if n, err := r.Read(buf); err != fr.ErrWouldBlock || n != 1 {
+		t.Fatalf("partial Read n=%d err=%v", n, err)
+	}
+	// Nothing is buffered, but the third message is in progress.
+	if r.Buffered() != 0 || !r.InFrame() {
+		t.Fatalf("mid-message: buffered=%d inFrame=%v", r.Buffered(), r.InFrame())
+	}
+	if n, err := r.Read(buf); err != nil || n != 2 || string(buf[:3]) != "def" || r.InFrame() {
+		t.Fatalf("resumed Read n=%d %q err=%v inFrame=%v", n, buf[:3], err, r.InFrame())
+	}
+}
+
+func TestStrictHeaders_RejectsFromFirstByte(t *testing.T) {
+	// A 0xFF header with a ReadLimit below 64KiB is refused after one byte.
+	cr := &countingReader{r: bytes.NewReader([]byte{0xFF, 0, 0, 0, 0, 0, 0x10, 0})}
+	r := fr.NewReader(cr, fr.WithStrictHeaders(), fr.WithReadLimit(1024))
+	buf := make([]byte, 16)
+	var oe *fr.OversizeError
+	for range 2 {
+		_, err := r.Read(buf)
+		if !errors.As(err, &oe) || !errors.Is(err, fr.ErrTooLong) || oe.LengthBytes != 7 || oe.MinLength != 1<<16 {
+			t.Fatalf("err=%v", err)
+		}
+	}
+	if cr.reads != 1 {
+		t.Fatalf("transport reads=%d; want 1", cr.reads)
+	}
+
+	// A limit the header byte cannot rule out reads the length as usual.
+	wire := []byte{0xFE, 0x01, 0x00}
+	if _, err := fr.NewReader(bytes.NewReader(wire), fr.WithStrictHeaders(), fr.WithReadLimit(300)).Read(buf); err != io.ErrShortBuffer {
+		t.Fatalf("in-limit header: %v", err)
+	}
+
+	// Non-minimal headers are rejected, though accepted by default.
+	var wide bytes.Buffer
+	if _, err := fr.NewWriter(&wide, fr.WithFixedHeader()).Write([]byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fr.NewReader(bytes.NewReader(wide.Bytes()), fr.WithStrictHeaders()).Read(buf); err != fr.ErrInvalidHeader {
+		t.Fatalf("wide header: %v", err)
+	}
+	if n, err := fr.NewReader(bytes.NewReader(wide.Bytes())).Read(buf); err != nil || string(buf[:n]) != "hi" {
+		t.Fatalf("default reader: %q %v", buf[:n], err)
+	}
+}
+
+func TestPing_MeasuresRoundTrip(t *testing.T) {
+	r1, w1 := io.Pipe()
+	r2, w2 := io.Pipe()
+	var mu sync.Mutex
+	var ctl []string
+	handler := func(p []byte) error {
+		mu.Lock()
+		ctl = append(ctl, string(p))
+		mu.Unlock()
+		return nil
+	}
+	a := fr.NewReadWriter(r1, w2, fr.WithControlFrames(nil)).(*fr.ReadWriter)
+	b := fr.NewReadWriter(r2, w1, fr.WithControlFrames(handler)).(*fr.ReadWriter)
+	defer w1.Close()
+	defer w2.Close()
+
+	// Both sides keep reading; B echoes data messages.
+	got := make(chan string, 4)
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, err := a.Read(buf)
+			if err != nil {
+				return
+			}
+			got <- string(buf[:n])
+		}
+	}()
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, err := b.Read(buf)
+			if err != nil {
+				return
+			}
+			_, _ = b.Write(buf[:n])
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for range 3 {
+		rtt, err := a.Ping(ctx)
+		if err != nil || rtt <= 0 {
+			t.Fatalf("Ping: rtt=%v err=%v", rtt, err)
+		}
+	}
+	// Data and application control frames flow as before.
+	if _, err := a.WriteControl([]byte("k")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Write([]byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	if s := <-got; s != "data" {
+		t.Fatalf("echo %q", s)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(ctl) != 1 || ctl[0] != "k" {
+		t.Fatalf("control frames seen by the handler: %q", ctl)
+	}
+
+	plain := fr.NewReadWriter(r1, w2).(*fr.ReadWriter)
+	if _, err := plain.Ping(ctx); err != fr.ErrInvalidArgument {
+		t.Fatalf("Ping without control frames: %v", err)
+	}
+}
+
+func TestWriteValue_ReachesValueInterceptors(t *testing.T) {
+	type span struct{ id string }
+	var seen []any
+	tag := func(v any, p []byte) ([]byte, error) {
+		seen = append(seen, v)
+		if s, ok := v.(*span); ok {
+			return append([]byte(s.id+"|"), p...), nil
+		}
+		return p, nil
+	}
+	upper := func(p []byte) ([]byte, error) { return bytes.ToUpper(p), nil }
+	sw := &stallWriter{block: 1}
+	w := fr.NewWriter(sw, fr.WithWriteValueInterceptor(tag), fr.WithWriteInterceptor(upper)).(*fr.Writer)
+
+	s := &span{id: "t1"}
+	for {
+		n, err := w.WriteValue(s, []byte("ab"))
+		if err == nil && n == 2 {
+			break
+		}
+		if err != fr.ErrWouldBlock || n != 0 {
+			t.Fatalf("WriteValue n=%d err=%v", n, err)
+		}
+	}
+	for {
+		if _, err := w.Write([]byte("cd")); err != fr.ErrWouldBlock {
+			break
+		}
+	}
+	// The value interceptor ran once per message, after the plain one.
+	if len(seen) != 2 || seen[0] != s || seen[1] != nil {
+		t.Fatalf("values seen: %v", seen)
+	}
+	if got := decodeAll(t, sw.Bytes()); len(got) != 2 || got[0] != "t1|AB" || got[1] != "CD" {
+		t.Fatalf("messages %q", got)
+	}
+}
+
+// failAfterWriter accepts n bytes, then fails every write.
+type failAfterWriter struct {
+	n   int
+	buf bytes.Buffer
+}
+
+func (w *failAfterWriter) Write(p []byte) (int, error) {
+	if w.n <= 0 {
+		return 0, io.ErrClosedPipe
+	}
+	m := min(len(p), w.n)
+	w.n -= m
+	w.buf.Write(p[:m])
+	if m < len(p) {
+		return m, io.ErrClosedPipe
+	}
+	return m, nil
+}
+
+func TestRewriteLast_ResendsAfterSwap(t *testing.T) {
+	broken := &failAfterWriter{n: 4}
+	w := fr.NewWriter(broken, fr.WithRetransmitBuffer(), fr.WithWriteInterceptor(func(p []byte) ([]byte, error) {
+		return append([]byte("v1:"), p...), nil
+	})).(*fr.Writer)
+	if _, err := w.RewriteLast(); err != fr.ErrInvalidArgument {
+		t.Fatalf("nothing kept: %v", err)
+	}
+	msg := []byte("payload")
+	if _, err := w.Write(msg); err != io.ErrClosedPipe {
+		t.Fatalf("Write on broken transport: %v", err)
+	}
+	// Mid-message on the failed transport: reconnect first.
+	if _, err := w.RewriteLast(); err != fr.ErrInvalidArgument {
+		t.Fatalf("RewriteLast mid-message: %v", err)
+	}
+	msg[0] = 'X' // the application may reuse its buffer
+
+	fresh := &stallWriter{block: 1}
+	w.SwapWriter(fresh)
+	for {
+		n, err := w.RewriteLast()
+		if err == nil {
+			if n != len("v1:payload") {
+				t.Fatalf("n=%d", n)
+			}
+			break
+		}
+		if err != fr.ErrWouldBlock {
+			t.Fatal(err)
+		}
+	}
+	for {
+		if _, err := w.Write([]byte("next")); err != fr.ErrWouldBlock {
+			break
+		}
+	}
+	if got := decodeAll(t, fresh.Bytes()); len(got) != 2 || got[0] != "v1:payload" || got[1] != "v1:next" {
+		t.Fatalf("messages %q", got)
+	}
+}
+
+func TestWithLogger_StructuredEvents(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	count := func(msg string) int { return strings.Count(logs.String(), "msg=\""+msg+"\"") }
+	buf := make([]byte, 16)
+
+	// A sticky limit rejection is logged once, not on every retry.
+	r := fr.NewReader(bytes.NewReader([]byte{200}), fr.WithReadLimit(100), fr.WithLogger(logger)).(*fr.Reader)
+	for range 3 {
+		if _, err := r.Read(buf); err != fr.ErrTooLong {
+			t.Fatalf("Read: %v", err)
+		}
+	}
+	// A stream cut mid-message is a frame error; the swap abandoning it a
+	// resync.
+	r.SwapReader(bytes.NewReader([]byte{5, 'a'}))
+	if _, err := r.Read(buf); err != io.ErrUnexpectedEOF {
+		t.Fatalf("Read: %v", err)
+	}
+	r.SwapReader(bytes.NewReader(nil))
+	// Would-block results without progress add up to one storm event.
+	r.SwapReader(&trickleReader{})
+	for range 2 * 1000 {
+		if _, err := r.Read(buf); err != fr.ErrWouldBlock {
+			t.Fatalf("Read: %v", err)
+		}
+	}
+	if count("framer: limit rejection") != 1 || count("framer: frame error") != 1 ||
+		count("framer: resync") != 2 || count("framer: retry storm") != 1 {
+		t.Fatalf("logs:\n%s", logs.String())
+	}
+	if !strings.Contains(logs.String(), "dir=read length=200") {
+		t.Fatalf("limit rejection attributes:\n%s", logs.String())
+	}
+}
+
+func TestEventConn_DeliversEventsAsFrames(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		msgs []string
+		opts []fr.Option
+	}{
+		{"stream", []string{"alpha", "", "gamma"}, nil},
+		{"packet", []string{"alpha", "beta", "gamma"}, []fr.Option{fr.WithReadWebSocket(), fr.WithWriteWebSocket()}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var sent [][]byte
+			src := fr.NewEventConn(func(p []byte) error {
+				sent = append(sent, append([]byte(nil), p...))
+				return nil
+			}, nil, tc.opts...)
+			dst := fr.NewEventConn(func([]byte) error { return nil }, nil, tc.opts...)
+			buf := make([]byte, 64)
+			if _, err := dst.Read(buf); err != fr.ErrWouldBlock {
+				t.Fatalf("empty read: %v", err)
+			}
+			for _, m := range tc.msgs {
+				if _, err := src.Write([]byte(m)); err != nil {
+					t.Fatalf("write %q: %v", m, err)
+				}
+			}
+			if tc.name == "stream" {
+				// Re-split the stream so frames span events.
+				wire := bytes.Join(sent, nil)
+				sent = [][]byte{wire[:3], wire[3:4], wire[4:]}
+			}
+			for _, ev := range sent {
+				dst.Deliver(ev)
+			}
+			dst.CloseRead(nil)
+			select {
+			case <-dst.Ready():
+			default:
+				t.Fatal("Ready not signaled")
+			}
+			for _, want := range tc.msgs {
+				n, err := dst.Read(buf)
+				if err != nil || string(buf[:n]) != want {
+					t.Fatalf("read = %q, %v; want %q", buf[:n], err, want)
+				}
+			}
+			if _, err := dst.Read(buf); err != io.EOF {
+				t.Fatalf("after CloseRead: %v", err)
+			}
+		})
+	}
+}
+
+func TestEventConn_SendBackpressure(t *testing.T) {
+	blocked := true
+	var out bytes.Buffer
+	c := fr.NewEventConn(func(p []byte) error {
+		if blocked {
+			return fr.ErrWouldBlock
+		}
+		out.Write(p)
+		return nil
+	}, func() error { return nil })
+	if _, err := c.Write([]byte("hi")); err != fr.ErrWouldBlock {
+		t.Fatalf("blocked write: %v", err)
+	}
+	blocked = false
+	if n, err := c.Write([]byte("hi")); err != nil || n != 2 {
+		t.Fatalf("retry = %d, %v", n, err)
+	}
+	if got := decodeAll(t, out.Bytes()); len(got) != 1 || got[0] != "hi" {
+		t.Fatalf("wire decodes to %q", got)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	c.Deliver([]byte("late"))
+	if _, err := c.Read(make([]byte, 8)); err == nil {
+		t.Fatal("read after Close succeeded")
+	}
+}
+
+func TestReadAvailable_DrainsReadyMessages(t *testing.T) {
+	var wire bytes.Buffer
+	w := fr.NewWriter(&wire).(*fr.Writer)
+	for _, m := range []string{"a1", "b22", "c333", "d4444"} {
+		_, _ = w.Write([]byte(m))
+	}
+	split := 10 // inside "c333"
+	src := &countingReader{r: &trickleReader{chunks: [][]byte{wire.Bytes()[:split], wire.Bytes()[split:]}}}
+	r := fr.NewReader(src, fr.WithPrefetch(64), fr.WithRetryDelay(time.Hour)).(*fr.Reader)
+
+	var got []string
+	collect := func(p []byte) error { got = append(got, string(p)); return nil }
+	for i, want := range [][]string{{"a1", "b22"}, {"c333", "d4444"}, nil} {
+		got, src.reads = nil, 0
+		n, err := r.ReadAvailable(collect)
+		if err != fr.ErrWouldBlock || n != len(want) || strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Fatalf("call %d = %d %q, %v; want %q", i, n, got, err, want)
+		}
+		if src.reads > 1 {
+			t.Fatalf("call %d made %d transport reads", i, src.reads)
+		}
+	}
+
+	stop := errors.New("stop")
+	wire.Reset()
+	_, _ = w.Write([]byte("x"))
+	_, _ = w.Write([]byte("y"))
+	r = fr.NewReader(bytes.NewReader(wire.Bytes()), fr.WithPrefetch(64)).(*fr.Reader)
+	n, err := r.ReadAvailable(func([]byte) error { return stop })
+	if n != 1 || err != stop {
+		t.Fatalf("callback error: %d, %v", n, err)
+	}
+	if n, err = r.ReadAvailable(collect); n != 1 || err != io.EOF {
+		t.Fatalf("after callback error: %d, %v", n, err)
+	}
+}
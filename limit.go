@@ -0,0 +1,48 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import "fmt"
+
+// LimitError reports a message that a LimitedReader refused because its
+// payload would exceed the remaining budget. It matches ErrTooLong with
+// errors.Is.
+type LimitError struct {
+	Length    int64 // payload length of the refused message
+	Remaining int64 // budget left when it arrived
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("framer: message of %d bytes exceeds the remaining read budget of %d bytes", e.Length, e.Remaining)
+}
+
+func (e *LimitError) Is(target error) bool { return target == ErrTooLong }
+
+// LimitedReader reads whole messages from R while their payloads fit in the
+// remaining budget N, like io.LimitedReader but without ever splitting a
+// message. N shrinks by the payload bytes each Read returns.
+//
+// A message longer than the remaining budget is refused with a *LimitError
+// before any of its payload is read; in stream mode it stays unread, so
+// every further Read refuses it again. In packet mode the datagram has been
+// received by then and is dropped.
+type LimitedReader struct {
+	R *Reader
+	N int64
+}
+
+// LimitReader returns a LimitedReader that delivers at most n payload bytes
+// from r in total.
+func LimitReader(r *Reader, n int64) *LimitedReader { return &LimitedReader{R: r, N: n} }
+
+// Read reads the next message, or continues the one in flight, into p.
+func (l *LimitedReader) Read(p []byte) (int, error) {
+	fr := l.R.fr
+	fr.budget = max(l.N, 0)
+	n, err := l.R.Read(p)
+	fr.budget = -1
+	l.N -= int64(n)
+	return n, err
+}
@@ -0,0 +1,82 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+)
+
+const (
+	// stormRetries is the number of ErrWouldBlock results without progress
+	// that makes a retry storm.
+	stormRetries = 1000
+
+	// logInterval rate-limits retry storm events and repeats of the same
+	// error.
+	logInterval = time.Second
+)
+
+// WithLogger makes the framer log structured events to l, so operators see
+// what goes wrong inside framing without logging at every call site:
+//
+//   - "framer: frame error" (Warn) for read and write errors other than
+//     would-block, more-data, io.EOF, ErrClosed and io.ErrShortBuffer;
+//   - "framer: limit rejection" (Warn) for messages refused with ErrTooLong;
+//   - "framer: resync" (Info) when SwapReader or SwapWriter abandons a
+//     partially transferred message;
+//   - "framer: retry storm" (Warn) when 1000 would-block results in a row
+//     meet no progress.
+//
+// Events carry the direction ("dir"), and the error ("err") or the message
+// length ("length") where they apply. Retry storms, and repeats of the same
+// error, are logged at most once per second per direction. A nil l disables
+// logging.
+func WithLogger(l *slog.Logger) Option {
+	return func(o *Options) { o.Logger = l }
+}
+
+// logError logs a read or write error returned to the caller.
+func (fr *framer) logError(dir Direction, err error) {
+	switch err {
+	case ErrWouldBlock, ErrMore, io.EOF, ErrClosed, io.ErrShortBuffer:
+		return
+	}
+	now := time.Now()
+	if err == fr.logErr && now.Before(fr.logErrNext) {
+		return
+	}
+	fr.logErr, fr.logErrNext = err, now.Add(logInterval)
+	if errors.Is(err, ErrTooLong) {
+		fr.log.Warn("framer: limit rejection", "dir", dir.String(), "length", fr.length, "err", err)
+		return
+	}
+	fr.log.Warn("framer: frame error", "dir", dir.String(), "err", err)
+}
+
+// logResync logs a partially transferred message abandoned by a transport
+// swap.
+func (fr *framer) logResync(dir Direction) {
+	if fr.log != nil && fr.offset > 0 {
+		fr.log.Info("framer: resync", "dir", dir.String(), "length", fr.length, "transferred", fr.offset)
+	}
+}
+
+// noteWouldBlock counts an ErrWouldBlock without progress and logs a retry
+// storm.
+func (fr *framer) noteWouldBlock(dir Direction) {
+	fr.streak++
+	if fr.log == nil || fr.streak%stormRetries != 0 {
+		return
+	}
+	now := time.Now()
+	if now.Before(fr.stormNext) {
+		return
+	}
+	fr.stormNext = now.Add(logInterval)
+	fr.log.Warn("framer: retry storm", "dir", dir.String(), "retries", fr.streak)
+}
@@ -0,0 +1,78 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import "io"
+
+// MQTT fixed header limits: a packet type byte followed by a Remaining
+// Length of at most 4 varint bytes.
+const (
+	mqttMaxHeaderLen = 5
+	mqttMaxLength    = 1<<28 - 1
+)
+
+// A HeaderMQTT header is the fixed header of MQTT control packets: the packet
+// type and flags byte, then the Remaining Length as a little-endian base-128
+// varint of 1 to 4 bytes. The type byte is not part of the payload: the
+// Reader records it in Meta.Type, and the Writer takes it from WriteMeta.
+// The payload is the variable header and the packet payload, which are left
+// to the caller. A Remaining Length longer than 4 bytes fails with
+// ErrInvalidHeader.
+
+// putMQTTHeader encodes the header of a v-byte payload of packet type typ and
+// returns its size.
+func putMQTTHeader(dst []byte, typ byte, v int64) int {
+	dst[0] = typ
+	n := 1
+	for ; v >= 0x80; v >>= 7 {
+		dst[n] = byte(v) | 0x80
+		n++
+	}
+	dst[n] = byte(v)
+	return n + 1
+}
+
+// mqttLength decodes the Remaining Length at the start of b and returns it
+// with its size. It returns io.ErrUnexpectedEOF when b ends inside it.
+func mqttLength(b []byte) (int64, int, error) {
+	var v int64
+	for i := 0; i < mqttMaxHeaderLen-1; i++ {
+		if i == len(b) {
+			return 0, 0, io.ErrUnexpectedEOF
+		}
+		v |= int64(b[i]&0x7F) << (7 * i)
+		if b[i] < 0x80 {
+			return v, i + 1, nil
+		}
+	}
+	return 0, 0, ErrInvalidHeader
+}
+
+// readMQTTHeader parses a HeaderMQTT header one byte per read, so no payload
+// byte is consumed, and records its type byte in fr.rmeta. The header size
+// is kept in fr.hlen like for HeaderUvarint.
+func (fr *framer) readMQTTHeader() (int64, error) {
+	if fr.hlen > 0 {
+		return fr.hlen, nil
+	}
+	for fr.offset < 2 || fr.header[fr.offset-1] >= 0x80 {
+		if fr.offset >= mqttMaxHeaderLen {
+			return 0, ErrInvalidHeader
+		}
+		if err := fr.readHeaderBytes(fr.offset + 1); err != nil {
+			return 0, err
+		}
+	}
+	v, _, err := mqttLength(fr.header[1:fr.offset])
+	if err != nil {
+		return 0, err
+	}
+	if err := fr.parsedLength(v, fr.offset); err != nil {
+		return 0, err
+	}
+	fr.rmeta = Meta{Type: fr.header[0]}
+	fr.hlen = fr.offset
+	return fr.hlen, nil
+}
@@ -0,0 +1,136 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// mysqlHeaderLen is the size of a HeaderMySQL header.
+const mysqlHeaderLen = 4
+
+// A HeaderMySQL header is a 3-byte little-endian payload length followed by
+// the sequence ID, whatever the configured byte order. The sequence ID is
+// not part of the payload: the Writer stamps the next ID into each header
+// and advances it when the message completes, and the Reader checks each
+// received ID against the one it expects and advances it when the header is
+// accepted. A ReadWriter shares one counter between both directions, as a
+// MySQL command and its response use one sequence; it restarts at 0 with
+// SetSequence at each new command.
+//
+// MySQL splits payloads of 2^24-1 bytes or more into several packets; each
+// packet is one framed message, and joining them is left to the caller.
+
+// SequenceError reports a HeaderMySQL packet whose sequence ID is not the
+// expected one. It matches ErrInvalidHeader with errors.Is.
+//
+// The packet stays unread: the Reader keeps reporting it until SetSequence
+// accepts Got, after which Read resumes with that packet.
+type SequenceError struct {
+	Got  byte // sequence ID of the packet
+	Want byte // sequence ID the Reader expected
+}
+
+func (e *SequenceError) Error() string {
+	return fmt.Sprintf("framer: packet sequence %d, want %d", e.Got, e.Want)
+}
+
+func (e *SequenceError) Is(target error) bool { return target == ErrInvalidHeader }
+
+// Sequence returns the sequence ID the Reader expects in the next
+// HeaderMySQL packet. It returns 0 for other header formats.
+func (r *Reader) Sequence() byte { return r.fr.sequence() }
+
+// SetSequence sets the sequence ID the Reader expects in the next
+// HeaderMySQL packet, typically 0 at the start of a command. It has no
+// effect with other header formats.
+func (r *Reader) SetSequence(id byte) { r.fr.setSequence(id) }
+
+// Sequence returns the sequence ID the Writer stamps into the next
+// HeaderMySQL packet. It returns 0 for other header formats.
+func (w *Writer) Sequence() byte { return w.fr.sequence() }
+
+// SetSequence sets the sequence ID the Writer stamps into the next
+// HeaderMySQL packet. It has no effect with other header formats.
+func (w *Writer) SetSequence(id byte) { w.fr.setSequence(id) }
+
+// Sequence returns the next sequence ID of the counter shared by both
+// directions with HeaderMySQL.
+func (rw *ReadWriter) Sequence() byte { return rw.Reader.Sequence() }
+
+// SetSequence sets the counter shared by both directions with HeaderMySQL,
+// typically to 0 at the start of a command.
+func (rw *ReadWriter) SetSequence(id byte) {
+	rw.Reader.SetSequence(id)
+	rw.Writer.SetSequence(id)
+}
+
+// linkSequence makes both sides of a ReadWriter share one sequence counter.
+func linkSequence(r, w *framer) {
+	if r.seq != nil && w.seq != nil {
+		w.seq = r.seq
+	}
+}
+
+func (fr *framer) sequence() byte {
+	if fr.seq == nil {
+		return 0
+	}
+	return byte(fr.seq.Load())
+}
+
+func (fr *framer) setSequence(id byte) {
+	if fr.seq != nil {
+		fr.seq.Store(uint32(id))
+	}
+}
+
+// newSequence returns the sequence counter of a framer whose read or write
+// side uses HeaderMySQL, or nil.
+func newSequence(o *Options) *atomic.Uint32 {
+	if o.ReadHeader != HeaderMySQL && o.WriteHeader != HeaderMySQL {
+		return nil
+	}
+	return new(atomic.Uint32)
+}
+
+// putMySQLHeader encodes the header of a v-byte payload with sequence ID id.
+func putMySQLHeader(dst []byte, id byte, v int64) {
+	dst[0], dst[1], dst[2], dst[3] = byte(v), byte(v>>8), byte(v>>16), id
+}
+
+// mysqlLength returns the payload length of a complete HeaderMySQL header.
+func mysqlLength(hdr []byte) int64 {
+	return int64(hdr[0]) | int64(hdr[1])<<8 | int64(hdr[2])<<16
+}
+
+// readMySQLHeader parses a HeaderMySQL header, checks its sequence ID and
+// advances the counter. The header size is kept in fr.hlen so the ID is
+// checked once per packet.
+func (fr *framer) readMySQLHeader() (int64, error) {
+	if fr.hlen > 0 {
+		return fr.hlen, nil
+	}
+	if err := fr.readHeaderBytes(mysqlHeaderLen); err != nil {
+		return 0, err
+	}
+	if id, want := fr.header[3], fr.sequence(); id != want {
+		return 0, &SequenceError{Got: id, Want: want}
+	}
+	if err := fr.parsedLength(mysqlLength(fr.header[:mysqlHeaderLen]), mysqlHeaderLen); err != nil {
+		return 0, err
+	}
+	fr.setSequence(fr.header[3] + 1)
+	fr.hlen = mysqlHeaderLen
+	return fr.hlen, nil
+}
+
+// advanceSequence moves the write-side sequence past a completed packet.
+func (fr *framer) advanceSequence() {
+	if fr.whf == HeaderMySQL {
+		fr.setSequence(fr.header[3] + 1)
+	}
+}
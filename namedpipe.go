@@ -0,0 +1,58 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"errors"
+	"syscall"
+)
+
+// Win32 error codes of overlapped and nonblocking named pipe I/O. They are
+// matched by value, so NamedPipeErrors builds and is testable everywhere.
+const (
+	errorNoData       = 232 // ERROR_NO_DATA: nonblocking pipe, nothing to read
+	errorMoreData     = 234 // ERROR_MORE_DATA: message larger than the buffer
+	errorIOIncomplete = 996 // ERROR_IO_INCOMPLETE: overlapped result not ready
+	errorIOPending    = 997 // ERROR_IO_PENDING: overlapped operation queued
+)
+
+// NamedPipeErrors is an error mapper (see WithErrorMapper) for Windows named
+// pipes, installed by the NamedPipe transport helpers. It translates the
+// conditions of overlapped (IOCP) and PIPE_NOWAIT I/O into the framer's
+// semantic errors, so a pipe handle wrapped in a nonblocking io.ReadWriter
+// behaves like a nonblocking socket:
+//
+//   - ERROR_IO_PENDING, ERROR_IO_INCOMPLETE and ERROR_NO_DATA become
+//     ErrWouldBlock: the operation is in progress or nothing is available,
+//     and the caller retries once the completion arrives;
+//   - ERROR_MORE_DATA becomes ErrMore: a message-mode read returned part of
+//     a message and the rest follows on the next read.
+//
+// The codes are recognized as syscall.Errno values, also when wrapped, for
+// example in an *os.PathError or *net.OpError. Other errors pass unchanged.
+func NamedPipeErrors(err error) error {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return err
+	}
+	switch errno {
+	case errorIOPending, errorIOIncomplete, errorNoData:
+		return ErrWouldBlock
+	case errorMoreData:
+		return ErrMore
+	}
+	return err
+}
+
+// withNamedPipeErrors chains NamedPipeErrors in front of the configured
+// error mapper.
+func withNamedPipeErrors(o *Options) {
+	next := o.ErrorMapper
+	if next == nil {
+		o.ErrorMapper = NamedPipeErrors
+		return
+	}
+	o.ErrorMapper = func(err error) error { return next(NamedPipeErrors(err)) }
+}
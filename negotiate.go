@@ -0,0 +1,135 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Features is a capability bitmap exchanged by Negotiate.
+type Features uint64
+
+// Capability bits. FeatureControlFrames selects WithControlFrames for the
+// traffic after the handshake; the other bits carry layers built on top of
+// framer and are only agreed upon, for the application to act on. Unknown
+// bits are kept in the exchange so newer peers can add capabilities.
+const (
+	FeatureControlFrames Features = 1 << iota
+	FeatureChecksum
+	FeatureCompression
+	FeatureMux
+	FeatureSequence
+)
+
+// WireVersion identifies a revision of the stream wire format.
+type WireVersion uint8
+
+const (
+	// WireV1 is the current format: length prefixes as selected by
+	// HeaderFormat, with the flags byte of WithControlFrames when enabled.
+	WireV1 WireVersion = 1
+
+	// wireMin and wireMax bound the versions this package speaks. A later
+	// format, such as 64-bit lengths with TLV extensions, raises wireMax.
+	wireMin = WireV1
+	wireMax = WireV1
+)
+
+// Negotiated is the outcome of Negotiate.
+type Negotiated struct {
+	// Features holds the capabilities both peers offered.
+	Features Features
+
+	// Version is the highest wire version both peers speak.
+	Version WireVersion
+
+	// Options are the caller's options extended by the settings Features
+	// and Version select. Frame the connection with them.
+	Options []Option
+}
+
+// Handshake frame payload: magic, the sender's Features, then the lowest and
+// highest wire version it speaks. A payload without the version bytes comes
+// from a peer predating them and speaks WireV1 only. Readers ignore further
+// bytes so later revisions can append fields.
+const (
+	negotiateMagic  = "frng"
+	negotiateSize   = len(negotiateMagic) + 8
+	negotiateSizeV  = negotiateSize + 2
+	negotiateOffVer = negotiateSize
+)
+
+// errNegotiated stops the handshake read after the hello control frame.
+var errNegotiated = errors.New("framer: negotiated")
+
+// Negotiate exchanges capability bitmaps and wire versions with the peer at
+// the start of a stream connection, so peers of different releases agree on
+// what both support and a newer peer falls back to the format of an older
+// one. It writes a hello control frame carrying local and the supported
+// version range, reads the peer's, and returns the common features, the
+// highest common version and opts extended accordingly.
+//
+// The hello frame is always control-framed, regardless of opts; both peers
+// call Negotiate before any other traffic. Each side writes before it reads,
+// so the transport must buffer one small frame, as sockets do. Negotiate
+// waits on ErrWouldBlock like WithBlock unless opts set another retry policy.
+// It returns ErrNegotiation when the peer's first frame is not a hello or
+// the version ranges do not overlap, and ErrInvalidArgument in
+// packet-preserving modes.
+func Negotiate(rw io.ReadWriter, local Features, opts ...Option) (Negotiated, error) {
+	var peer Features
+	var peerMin, peerMax WireVersion
+	var got bool
+	hello := func(p []byte) error {
+		if len(p) < negotiateSize || string(p[:len(negotiateMagic)]) != negotiateMagic {
+			return ErrNegotiation
+		}
+		peer = Features(binary.BigEndian.Uint64(p[len(negotiateMagic):]))
+		peerMin, peerMax = WireV1, WireV1
+		if len(p) >= negotiateSizeV {
+			peerMin, peerMax = WireVersion(p[negotiateOffVer]), WireVersion(p[negotiateOffVer+1])
+		}
+		got = true
+		return errNegotiated
+	}
+	all := make([]Option, 0, len(opts)+2)
+	all = append(all, WithBlock())
+	all = append(all, opts...)
+	all = append(all, WithControlFrames(hello))
+
+	var msg [negotiateSizeV]byte
+	copy(msg[:], negotiateMagic)
+	binary.BigEndian.PutUint64(msg[len(negotiateMagic):], uint64(local))
+	msg[negotiateOffVer], msg[negotiateOffVer+1] = byte(wireMin), byte(wireMax)
+	w := NewWriter(rw, all...).(*Writer)
+	if _, err := w.WriteControl(msg[:]); err != nil {
+		return Negotiated{}, err
+	}
+	if err := w.Flush(); err != nil {
+		return Negotiated{}, err
+	}
+
+	r := NewReader(rw, all...)
+	var b [1]byte
+	switch _, err := r.Read(b[:]); {
+	case err == errNegotiated && got:
+	case err == nil, err == io.ErrShortBuffer:
+		return Negotiated{}, ErrNegotiation
+	default:
+		return Negotiated{}, err
+	}
+
+	version := min(wireMax, peerMax)
+	if version < max(wireMin, peerMin) {
+		return Negotiated{}, ErrNegotiation
+	}
+	agreed := local & peer
+	out := make([]Option, 0, len(opts)+1)
+	out = append(out, opts...)
+	out = append(out, func(o *Options) { o.ControlFrames = agreed&FeatureControlFrames != 0 })
+	return Negotiated{Features: agreed, Version: version, Options: out}, nil
+}
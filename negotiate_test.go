@@ -0,0 +1,151 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer_test
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	fr "code.hybscloud.com/framer"
+)
+
+func tcpPair(t *testing.T) (a, b net.Conn) {
+	t.Helper()
+	ln := listenTCP(t)
+	defer ln.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		nc, err := ln.Accept()
+		if err != nil {
+			nc = nil
+		}
+		accepted <- nc
+	}()
+	a, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	if b = <-accepted; b == nil {
+		t.Fatal("accept failed")
+	}
+	t.Cleanup(func() { a.Close(); b.Close() })
+	return a, b
+}
+
+func TestNegotiate_AgreesOnCommonFeatures(t *testing.T) {
+	a, b := tcpPair(t)
+
+	type result struct {
+		fr.Negotiated
+		err error
+	}
+	done := make(chan result, 1)
+	var ctl []string
+	go func() {
+		n, err := fr.Negotiate(b, fr.FeatureControlFrames|fr.FeatureChecksum|fr.FeatureSequence,
+			fr.WithControlFrames(func(p []byte) error {
+				ctl = append(ctl, string(p))
+				return nil
+			}))
+		done <- result{n, err}
+	}()
+	got, err := fr.Negotiate(a, fr.FeatureControlFrames|fr.FeatureChecksum|fr.FeatureMux)
+	if err != nil {
+		t.Fatalf("Negotiate: %v", err)
+	}
+	peer := <-done
+	if peer.err != nil {
+		t.Fatalf("peer Negotiate: %v", peer.err)
+	}
+	want := fr.FeatureControlFrames | fr.FeatureChecksum
+	if got.Features != want || peer.Features != want {
+		t.Fatalf("agreed=%b peer=%b want %b", got.Features, peer.Features, want)
+	}
+	if got.Version != fr.WireV1 || peer.Version != fr.WireV1 {
+		t.Fatalf("version=%d peer=%d", got.Version, peer.Version)
+	}
+
+	w := fr.NewWriter(a, got.Options...).(*fr.Writer)
+	if _, err := w.WriteControl([]byte("ping")); err != nil {
+		t.Fatalf("WriteControl: %v", err)
+	}
+	if _, err := w.Write([]byte("data")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	r := fr.NewReader(b, peer.Options...)
+	buf := make([]byte, 16)
+	n, err := r.Read(buf)
+	if err != nil || string(buf[:n]) != "data" {
+		t.Fatalf("Read: %q err=%v", buf[:n], err)
+	}
+	if len(ctl) != 1 || ctl[0] != "ping" {
+		t.Fatalf("control frames=%q", ctl)
+	}
+}
+
+func TestNegotiate_DisablesControlFramesAndRejectsData(t *testing.T) {
+	a, b := tcpPair(t)
+
+	done := make(chan error, 1)
+	go func() {
+		n, err := fr.Negotiate(b, fr.FeatureChecksum, fr.WithControlFrames(nil))
+		if err == nil {
+			_, err = fr.NewWriter(b, n.Options...).Write([]byte("x"))
+		}
+		done <- err
+	}()
+	got, err := fr.Negotiate(a, fr.FeatureControlFrames|fr.FeatureChecksum)
+	if err != nil || got.Features != fr.FeatureChecksum {
+		t.Fatalf("Negotiate: agreed=%b err=%v", got.Features, err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("peer: %v", err)
+	}
+	buf := make([]byte, 4)
+	if n, err := fr.NewReader(a, got.Options...).Read(buf); err != nil || string(buf[:n]) != "x" {
+		t.Fatalf("plain frame after negotiation: %q err=%v", buf[:n], err)
+	}
+
+	var wire bytes.Buffer
+	_, _ = fr.NewWriter(&wire).Write([]byte("not a hello"))
+	if _, err := fr.Negotiate(&loopbackRW{in: &wire}, fr.FeatureChecksum); err != fr.ErrNegotiation {
+		t.Fatalf("data before hello: err=%v", err)
+	}
+}
+
+func TestNegotiate_WireVersionDowngrade(t *testing.T) {
+	hello := func(tail ...byte) *loopbackRW {
+		var wire bytes.Buffer
+		p := append([]byte("frng"), 0, 0, 0, 0, 0, 0, 0, byte(fr.FeatureMux))
+		_, _ = fr.NewWriter(&wire, fr.WithControlFrames(nil)).(*fr.Writer).WriteControl(append(p, tail...))
+		return &loopbackRW{in: &wire}
+	}
+	cases := []struct {
+		name string
+		peer *loopbackRW
+		err  error
+	}{
+		{"newer peer", hello(1, 2), nil},
+		{"peer without versions", hello(), nil},
+		{"peer with trailing fields", hello(1, 3, 0xff, 0xff), nil},
+		{"no common version", hello(2, 3), fr.ErrNegotiation},
+	}
+	for _, tc := range cases {
+		n, err := fr.Negotiate(tc.peer, fr.FeatureMux|fr.FeatureChecksum)
+		if err != tc.err {
+			t.Fatalf("%s: err=%v want %v", tc.name, err, tc.err)
+		}
+		if err == nil && (n.Version != fr.WireV1 || n.Features != fr.FeatureMux) {
+			t.Fatalf("%s: version=%d features=%b", tc.name, n.Version, n.Features)
+		}
+	}
+}
+
+// loopbackRW reads from in and discards writes.
+type loopbackRW struct{ in *bytes.Buffer }
+
+func (l *loopbackRW) Read(p []byte) (int, error)  { return l.in.Read(p) }
+func (l *loopbackRW) Write(p []byte) (int, error) { return len(p), nil }
@@ -17,12 +17,16 @@ import (
 //   - UDP         → Datagram,     BigEndian
 //   - WebSocket   → SeqPacket,    BigEndian  // boundaries preserved; pass-through
 //   - SCTP        → SeqPacket,    BigEndian  // boundaries preserved
+//   - KCP         → SeqPacket,    BigEndian  // message-mode sessions preserve boundaries
 //   - Unix (stream)     → BinaryStream, BigEndian
 //   - UnixPacket  → Datagram,     BigEndian
+//   - UnixSeqpacket → SeqPacket,  BigEndian  // reliable, boundaries preserved
 //   - Local (stream)    → BinaryStream, native byte order
+//   - NamedPipe (byte mode)    → BinaryStream, BigEndian
+//   - NamedPipe (message mode) → SeqPacket,    BigEndian
 //
 // Byte-order policy:
-//   - Network-named helpers (TCP/UDP/WebSocket/SCTP/Unix/UnixPacket) use BigEndian.
+//   - Network-named helpers (TCP/UDP/WebSocket/SCTP/KCP/Unix/UnixPacket/UnixSeqpacket/NamedPipe) use BigEndian.
 //   - Local helpers use native byte order (multi-arch friendly).
 
 type netKind uint8
@@ -32,9 +36,13 @@ const (
 	netUDP
 	netWebSocket
 	netSCTP
+	netKCP
 	netUnixStream
 	netUnixPacket
+	netUnixSeqpacket
 	netLocalStream
+	netNamedPipe
+	netNamedPipeMessage
 )
 
 func defaultsFor(kind netKind) (Protocol, binary.ByteOrder) {
@@ -49,12 +57,23 @@ func defaultsFor(kind netKind) (Protocol, binary.ByteOrder) {
 	case netSCTP:
 		// SCTP preserves message boundaries.
 		return SeqPacket, binary.BigEndian
+	case netKCP:
+		// A KCP session in message mode delivers one message per Read.
+		return SeqPacket, binary.BigEndian
 	case netUnixStream:
 		return BinaryStream, binary.BigEndian
 	case netUnixPacket:
 		return Datagram, binary.BigEndian
+	case netUnixSeqpacket:
+		// SOCK_SEQPACKET is reliable and preserves message boundaries.
+		return SeqPacket, binary.BigEndian
 	case netLocalStream:
 		return BinaryStream, bo.Native()
+	case netNamedPipe:
+		return BinaryStream, binary.BigEndian
+	case netNamedPipeMessage:
+		// A message-mode pipe delivers one message per read.
+		return SeqPacket, binary.BigEndian
 	default:
 		return BinaryStream, binary.BigEndian
 	}
@@ -132,6 +151,31 @@ func WithWriteSCTP() Option {
 	}
 }
 
+// WithReadKCP configures the reader side for KCP reliable-UDP sessions, such
+// as kcp-go's UDPSession: SeqPacket (boundaries preserved), BigEndian.
+//
+// It assumes the session is in message mode, the kcp-go default, where each
+// Read returns one message sent by one Write. A session in stream mode
+// (SetStreamMode(true)) coalesces messages like TCP; use WithReadTCP for it.
+// The message size is bounded by the session's window and MTU, not by framer.
+func WithReadKCP() Option {
+	return func(o *Options) {
+		p, bo := defaultsFor(netKCP)
+		o.ReadProto = p
+		o.ReadByteOrder = bo
+	}
+}
+
+// WithWriteKCP configures the writer side for KCP reliable-UDP sessions:
+// SeqPacket (boundaries preserved), BigEndian. See WithReadKCP.
+func WithWriteKCP() Option {
+	return func(o *Options) {
+		p, bo := defaultsFor(netKCP)
+		o.WriteProto = p
+		o.WriteByteOrder = bo
+	}
+}
+
 // WithReadUnix configures the reader side for Unix stream sockets: BinaryStream, BigEndian.
 func WithReadUnix() Option {
 	return func(o *Options) {
@@ -168,6 +212,26 @@ func WithWriteUnixPacket() Option {
 	}
 }
 
+// WithReadUnixSeqpacket configures the reader side for Unix SOCK_SEQPACKET
+// sockets ("unixpacket"): SeqPacket (reliable, boundaries preserved), BigEndian.
+func WithReadUnixSeqpacket() Option {
+	return func(o *Options) {
+		p, bo := defaultsFor(netUnixSeqpacket)
+		o.ReadProto = p
+		o.ReadByteOrder = bo
+	}
+}
+
+// WithWriteUnixSeqpacket configures the writer side for Unix SOCK_SEQPACKET
+// sockets ("unixpacket"): SeqPacket (reliable, boundaries preserved), BigEndian.
+func WithWriteUnixSeqpacket() Option {
+	return func(o *Options) {
+		p, bo := defaultsFor(netUnixSeqpacket)
+		o.WriteProto = p
+		o.WriteByteOrder = bo
+	}
+}
+
 // WithReadLocal configures the reader side for local (stream) transports: BinaryStream, native byte order.
 func WithReadLocal() Option {
 	return func(o *Options) {
@@ -185,3 +249,49 @@ func WithWriteLocal() Option {
 		o.WriteByteOrder = bo
 	}
 }
+
+// WithReadNamedPipe configures the reader side for Windows named pipes in
+// byte mode: BinaryStream, BigEndian, with NamedPipeErrors as error mapper.
+func WithReadNamedPipe() Option {
+	return func(o *Options) {
+		p, bo := defaultsFor(netNamedPipe)
+		o.ReadProto = p
+		o.ReadByteOrder = bo
+		withNamedPipeErrors(o)
+	}
+}
+
+// WithWriteNamedPipe configures the writer side for Windows named pipes in
+// byte mode: BinaryStream, BigEndian, with NamedPipeErrors as error mapper.
+func WithWriteNamedPipe() Option {
+	return func(o *Options) {
+		p, bo := defaultsFor(netNamedPipe)
+		o.WriteProto = p
+		o.WriteByteOrder = bo
+		withNamedPipeErrors(o)
+	}
+}
+
+// WithReadNamedPipeMessage configures the reader side for Windows named pipes
+// in message mode (PIPE_READMODE_MESSAGE): SeqPacket (pass-through), BigEndian,
+// with NamedPipeErrors as error mapper.
+func WithReadNamedPipeMessage() Option {
+	return func(o *Options) {
+		p, bo := defaultsFor(netNamedPipeMessage)
+		o.ReadProto = p
+		o.ReadByteOrder = bo
+		withNamedPipeErrors(o)
+	}
+}
+
+// WithWriteNamedPipeMessage configures the writer side for Windows named
+// pipes in message mode (PIPE_TYPE_MESSAGE): SeqPacket (pass-through),
+// BigEndian, with NamedPipeErrors as error mapper.
+func WithWriteNamedPipeMessage() Option {
+	return func(o *Options) {
+		p, bo := defaultsFor(netNamedPipeMessage)
+		o.WriteProto = p
+		o.WriteByteOrder = bo
+		withNamedPipeErrors(o)
+	}
+}
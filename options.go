@@ -6,6 +6,7 @@ package framer
 
 import (
 	"encoding/binary"
+	"log/slog"
 	"time"
 )
 
@@ -14,17 +15,24 @@ import (
 // The framer logic adapts its algorithm based on this setting:
 //   - BinaryStream: boundaries are not preserved (e.g., TCP). Framer adds a length prefix.
 //   - SeqPacket / Datagram: boundaries are preserved. Framer is pass-through.
+//   - Delimited: boundaries are not preserved. Framer ends each message with a
+//     delimiter and delivers it whole, like a packet (see WithDelimiter).
+//   - COBS / SLIP: like Delimited, with the payload escaped so it never
+//     contains the delimiter (see WithCOBS and WithSLIP).
 type Protocol uint8
 
 const (
 	BinaryStream Protocol = 1
 	SeqPacket    Protocol = 2
 	Datagram     Protocol = 3
+	Delimited    Protocol = 4
+	COBS         Protocol = 5
+	SLIP         Protocol = 6
 )
 
 func (p Protocol) preserveBoundary() bool {
 	switch p {
-	case SeqPacket, Datagram:
+	case SeqPacket, Datagram, Delimited, COBS, SLIP:
 		return true
 	default:
 		return false
@@ -38,14 +46,163 @@ type Options struct {
 	ReadProto      Protocol
 	WriteProto     Protocol
 
+	// Delimiter terminates each message of the Delimited protocol (see
+	// WithDelimiter); empty means '\n'.
+	Delimiter []byte
+
+	// ReadHeader and WriteHeader select the stream-mode length prefix
+	// (default HeaderCompact).
+	ReadHeader  HeaderFormat
+	WriteHeader HeaderFormat
+
+	// ReadLengthIncludesHeader and WriteLengthIncludesHeader make the length
+	// prefix count the header bytes too (see WithLengthIncludesHeader).
+	ReadLengthIncludesHeader  bool
+	WriteLengthIncludesHeader bool
+
+	// FixedHeader makes the Writer emit the widest compact header for every
+	// message (see WithFixedHeader).
+	FixedHeader bool
+
+	// StrictHeaders makes the Reader accept only minimal compact headers and
+	// refuse oversized frames from their first header byte (see
+	// WithStrictHeaders).
+	StrictHeaders bool
+
 	// ReadLimit caps the maximum allowed payload size (bytes). Zero means no limit.
 	ReadLimit int
 
+	// MinReadRate, when positive, is the slowest average rate in bytes per
+	// second at which a stream message may arrive once MinReadGrace has
+	// passed (see WithMinReadRate).
+	MinReadRate  int
+	MinReadGrace time.Duration
+
+	// WriteStallTimeout and WriteStallRetries bound how long, and how many
+	// times, a message write may meet ErrWouldBlock (see
+	// WithWriteStallLimit).
+	WriteStallTimeout time.Duration
+	WriteStallRetries int
+
+	// MessageDeadline, when positive, bounds the time from the first byte of
+	// a stream message to its last (see WithMessageDeadline).
+	MessageDeadline time.Duration
+
 	// RetryDelay controls how the framer handles iox.ErrWouldBlock from the underlying transport:
 	//   - negative: nonblock, return ErrWouldBlock immediately
 	//   - zero: yield (runtime.Gosched) and retry
 	//   - positive: sleep for the duration and retry
 	RetryDelay time.Duration
+
+	// SpinPolicy, when configured, replaces the fixed yield of a zero
+	// RetryDelay with an escalating wait (see WithSpinPolicy).
+	SpinPolicy SpinPolicy
+
+	// ReadMore and WriteMore select how iox.ErrMore from the transport is
+	// handled in each direction (default MoreSurface, see WithMorePolicy).
+	ReadMore  MorePolicy
+	WriteMore MorePolicy
+
+	// DeliverTruncated makes a stream Reader hand over the payload bytes of a
+	// message cut short by EOF with a *TruncatedError (see
+	// WithTruncatedDelivery).
+	DeliverTruncated bool
+
+	// ForwardChunkSize, when positive, sizes the Forwarder buffer and lets it
+	// relay stream messages larger than the buffer in chunks (see
+	// WithChunkedForward).
+	ForwardChunkSize int
+
+	// TTL, when positive, makes a Forwarder drop messages older than TTL,
+	// dated by TTLStamp (see WithTTL).
+	TTL      time.Duration
+	TTLStamp func(payload []byte) (time.Time, bool)
+
+	// RoutePrefixLen and RoutePrefix make a Forwarder prepend a prefix to
+	// every relayed payload, and RouteStrip makes it remove that many
+	// leading bytes first (see WithRoutePrefix and WithRouteStrip).
+	RoutePrefixLen int
+	RoutePrefix    func(prefix, payload []byte)
+	RouteStrip     int
+
+	// ControlFrames adds a flags byte after the length prefix of every
+	// stream frame, and ControlHandler receives the payloads of control
+	// frames (see WithControlFrames).
+	ControlFrames  bool
+	ControlHandler func(payload []byte) error
+
+	// WriteBufferSize and FlushLatency configure the stream write buffer
+	// (see WithWriteBuffer and WithFlushLatency).
+	WriteBufferSize int
+	FlushLatency    time.Duration
+
+	// FECGroup, when positive, adds a parity packet after every FECGroup
+	// messages in packet-preserving modes (see WithFEC).
+	FECGroup int
+
+	// BundleSize, when positive, packs packets into datagrams of up to that
+	// many bytes, flushed after FlushLatency (see WithBundling).
+	BundleSize int
+
+	// SegmentMTU, when positive, splits packets larger than that many bytes
+	// into segments reassembled by the Reader (see WithSegmentation).
+	SegmentMTU int
+
+	// DedupWindow, when positive, numbers packets and makes the Reader drop
+	// duplicates within that many sequence numbers (see WithDedup).
+	DedupWindow int
+
+	// MemoryBudget, when non-nil, accounts the internal buffers against a
+	// limit shared with other framers (see WithMemoryBudget).
+	MemoryBudget *MemoryBudget
+
+	// AutoDetect lists the header formats a stream Reader chooses from by
+	// sniffing the first message (see WithAutoDetect); empty means
+	// ReadHeader is used as is.
+	AutoDetect []HeaderFormat
+
+	// Prefetch, when positive, is the size of the stream read-ahead buffer
+	// (see WithPrefetch).
+	Prefetch int
+
+	// WriteInterceptors transform each payload given to Writer.Write before
+	// framing, in order (see WithWriteInterceptor).
+	WriteInterceptors []Interceptor
+
+	// WriteValueInterceptors run after WriteInterceptors and also receive
+	// the value attached by Writer.WriteValue (see
+	// WithWriteValueInterceptor).
+	WriteValueInterceptors []ValueInterceptor
+
+	// ReadInterceptors transform each payload returned by Reader.Read after
+	// deframing, in order (see WithReadInterceptor).
+	ReadInterceptors []Interceptor
+
+	// RetransmitBuffer makes the Writer keep the last message for
+	// Writer.RewriteLast (see WithRetransmitBuffer).
+	RetransmitBuffer bool
+
+	// Logger, when non-nil, receives structured events about framing
+	// errors, resyncs and retry storms (see WithLogger).
+	Logger *slog.Logger
+
+	// Allocator, when non-nil, supplies the internal buffers (see
+	// WithAllocator).
+	Allocator Allocator
+
+	// WaitFunc, when non-nil, replaces the RetryDelay policy: it is called on
+	// every iox.ErrWouldBlock with the blocked direction. Returning nil retries
+	// the transport operation; returning an error aborts the wait and the error
+	// is returned to the caller.
+	WaitFunc func(dir Direction) error
+
+	// RawConn performs transport I/O directly on the file descriptor of a
+	// syscall.Conn (see WithRawConn).
+	RawConn bool
+
+	// ErrorMapper, when non-nil, translates every error returned by the
+	// transport before the framer looks at it (see WithErrorMapper).
+	ErrorMapper func(error) error
 }
 
 var defaultOptions = Options{
@@ -57,6 +214,26 @@ var defaultOptions = Options{
 	RetryDelay:     -1, // default: nonblock
 }
 
+// Direction identifies the side of a framer waiting on the transport.
+type Direction uint8
+
+const (
+	DirRead  Direction = 1
+	DirWrite Direction = 2
+)
+
+// String returns "read" or "write".
+func (d Direction) String() string {
+	switch d {
+	case DirRead:
+		return "read"
+	case DirWrite:
+		return "write"
+	default:
+		return "unknown"
+	}
+}
+
 type Option func(*Options)
 
 func WithByteOrder(order binary.ByteOrder) Option {
@@ -111,3 +288,95 @@ func WithBlock() Option {
 func WithNonblock() Option {
 	return func(o *Options) { o.RetryDelay = -1 }
 }
+
+// MorePolicy selects how a framer handles iox.ErrMore from its transport.
+type MorePolicy uint8
+
+const (
+	// MoreSurface returns ErrMore to the caller unless the framer can keep
+	// going toward the message boundary with the progress it reported.
+	MoreSurface MorePolicy = iota
+
+	// MoreAbsorb treats ErrMore as "call again now": the framer consumes the
+	// reported bytes and issues the next transport call itself, so callers
+	// see whole messages. A packet write the transport accepted only in part
+	// still returns ErrMore, since it cannot be completed by another call.
+	MoreAbsorb
+)
+
+// WithMorePolicy sets the iox.ErrMore policy for both directions.
+// Multishot transports such as io_uring report ErrMore on most completions,
+// and callers that only want whole frames use MoreAbsorb.
+func WithMorePolicy(p MorePolicy) Option {
+	return func(o *Options) {
+		o.ReadMore = p
+		o.WriteMore = p
+	}
+}
+
+// WithReadMorePolicy sets the iox.ErrMore policy of the read side.
+func WithReadMorePolicy(p MorePolicy) Option {
+	return func(o *Options) { o.ReadMore = p }
+}
+
+// WithWriteMorePolicy sets the iox.ErrMore policy of the write side.
+func WithWriteMorePolicy(p MorePolicy) Option {
+	return func(o *Options) { o.WriteMore = p }
+}
+
+// WithWaitFunc installs a wait hook called when the underlying transport
+// returns iox.ErrWouldBlock, taking precedence over WithRetryDelay.
+//
+// Event-loop applications use it to park on their poller until the
+// connection is ready in direction dir. The in-flight frame state is kept
+// across the wait, so the operation resumes where it stopped. A non-nil error
+// aborts the operation and is returned as is. A nil fn restores the
+// RetryDelay policy.
+func WithWaitFunc(fn func(dir Direction) error) Option {
+	return func(o *Options) { o.WaitFunc = fn }
+}
+
+// WithRawConn makes the framer read and write a transport that implements
+// syscall.Conn, such as *net.TCPConn or *os.File, with single system calls
+// on its file descriptor instead of through its Read and Write methods. The
+// Go netpoller then never parks the caller: an operation that cannot proceed
+// returns ErrWouldBlock at once and the retry policy (WithNonblock,
+// WithWaitFunc, ...) alone decides how to wait, as a single-threaded reactor
+// requires. Deadlines set on the connection no longer apply.
+//
+// Other transports, and platforms other than Unix, are used as usual. In
+// packet mode, datagram truncation is then not detected.
+func WithRawConn() Option {
+	return func(o *Options) { o.RawConn = true }
+}
+
+// WithErrorMapper installs fn to translate every non-nil error returned by
+// the transport before the framer interprets it, so transport-specific
+// conditions can be normalized without wrapping the connection in an
+// adapter: syscall.EAGAIN to ErrWouldBlock, os.ErrDeadlineExceeded or a
+// library sentinel to ErrTimeout, and so on. fn should return errors it does
+// not recognize unchanged; a nil result keeps the original error.
+func WithErrorMapper(fn func(error) error) Option {
+	return func(o *Options) { o.ErrorMapper = fn }
+}
+
+// WithChunkedForward lets a Forwarder relay stream-mode messages larger than
+// its buffer in chunks of up to size bytes instead of failing with
+// io.ErrShortBuffer. The buffer is then size bytes regardless of ReadLimit,
+// which still bounds the accepted message length. A non-positive size means
+// 64KiB. It has no effect on Reader and Writer.
+func WithChunkedForward(size int) Option {
+	if size <= 0 {
+		size = 64 * 1024
+	}
+	return func(o *Options) { o.ForwardChunkSize = size }
+}
+
+// WithTruncatedDelivery makes the stream Reader deliver a message cut short by
+// EOF instead of reporting a bare io.ErrUnexpectedEOF: the received payload
+// bytes stay in the buffer, Read returns a *TruncatedError carrying the
+// declared and received lengths, and the next Read reports io.EOF. WriteTo
+// writes the partial payload to its destination before returning the error.
+func WithTruncatedDelivery() Option {
+	return func(o *Options) { o.DeliverTruncated = true }
+}
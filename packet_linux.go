@@ -0,0 +1,47 @@
+//go:build linux
+
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"io"
+	"net"
+	"syscall"
+)
+
+// recvPacket reads one datagram from a UDP or Unix datagram socket with
+// MSG_TRUNC, so that size is the original length of a datagram longer than p.
+// ok is false for other transports, which are read as usual.
+func recvPacket(rd io.Reader, p []byte) (n, size int, ok bool, err error) {
+	var sc syscall.Conn
+	switch c := rd.(type) {
+	case *net.UDPConn:
+		sc = c
+	case *net.UnixConn:
+		if a, _ := c.LocalAddr().(*net.UnixAddr); a == nil || a.Net == "unix" {
+			return 0, 0, false, nil
+		}
+		sc = c
+	default:
+		return 0, 0, false, nil
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return 0, 0, true, err
+	}
+	var rerr error
+	err = rc.Read(func(fd uintptr) bool {
+		size, _, _, _, rerr = syscall.Recvmsg(int(fd), p, nil, syscall.MSG_TRUNC)
+		return rerr != syscall.EAGAIN
+	})
+	if err == nil {
+		err = rerr
+	}
+	if err != nil {
+		return 0, 0, true, err
+	}
+	return min(size, len(p)), size, true, nil
+}
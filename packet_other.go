@@ -0,0 +1,15 @@
+//go:build !linux
+
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import "io"
+
+// recvPacket reports ok == false: datagram truncation is not detected on this
+// platform.
+func recvPacket(rd io.Reader, p []byte) (n, size int, ok bool, err error) {
+	return 0, 0, false, nil
+}
@@ -0,0 +1,201 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// Ping and pong control frames carry these flags next to flagControl and
+// an 8-byte ping ID as payload. They never reach the control handler.
+const (
+	flagPing = 0x02
+	flagPong = 0x04
+)
+
+// pingRetry is how often Ping retries sending its frame while the write
+// side is busy or the transport would block.
+const pingRetry = time.Millisecond
+
+// Ping sends a ping control frame and returns the round-trip time until the
+// peer's pong arrives, so applications need no side channel to measure RTT.
+// Both peers must enable WithControlFrames in stream mode; otherwise Ping
+// returns ErrInvalidArgument.
+//
+// The pong is received by the read side, so another goroutine must be
+// reading from rw meanwhile; Ping only waits for it, until ctx is done. The
+// receiving side answers pings transparently: the pong goes out from its
+// read path when its write side is idle, and otherwise before the next
+// message written with Write or WriteControl. A ping frame the write side is
+// busy with is sent the same way.
+func (rw *ReadWriter) Ping(ctx context.Context) (time.Duration, error) {
+	w := rw.Writer.fr
+	ps := w.ping
+	if ps == nil {
+		return 0, ErrInvalidArgument
+	}
+	start := time.Now()
+	id, done := ps.ping()
+	defer ps.forget(id)
+	var retry *time.Timer
+	for {
+		var tick <-chan time.Time
+		if ps.flushFrom(w) != nil {
+			if retry == nil {
+				retry = time.NewTimer(pingRetry)
+				defer retry.Stop()
+			} else {
+				retry.Reset(pingRetry)
+			}
+			tick = retry.C
+		}
+		select {
+		case <-done:
+			return time.Since(start), nil
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-tick:
+		}
+	}
+}
+
+// pingState is shared by the read and write side of a ReadWriter with
+// control frames: it tracks pings waiting for their pong and queues the ping
+// and pong frames owed to the peer.
+type pingState struct {
+	w *framer // write side
+
+	mu      sync.Mutex
+	next    uint64
+	waiters map[uint64]chan struct{}
+	queue   []ctlFrame // frames to send, oldest first
+
+	// Owned by the holder of the write side.
+	sending  bool // queue[0] is partially written
+	flushing bool
+}
+
+// ctlFrame is a queued ping or pong frame.
+type ctlFrame struct {
+	flags   byte
+	payload [8]byte
+}
+
+// linkPing gives the two sides of a ReadWriter a shared pingState when both
+// carry control frames in stream mode.
+func linkPing(r, w *framer) {
+	if !r.rflags || !w.wflags || r.rpr.preserveBoundary() || w.wpr.preserveBoundary() {
+		return
+	}
+	ps := &pingState{w: w, waiters: make(map[uint64]chan struct{})}
+	r.ping, w.ping = ps, ps
+}
+
+// ping queues a new ping frame and returns its ID and a channel closed when
+// the pong arrives.
+func (ps *pingState) ping() (uint64, chan struct{}) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	id := ps.next
+	ps.next++
+	done := make(chan struct{})
+	ps.waiters[id] = done
+	f := ctlFrame{flags: flagControl | flagPing}
+	binary.BigEndian.PutUint64(f.payload[:], id)
+	ps.queue = append(ps.queue, f)
+	return id, done
+}
+
+// forget drops the waiter of ping id.
+func (ps *pingState) forget(id uint64) {
+	ps.mu.Lock()
+	delete(ps.waiters, id)
+	ps.mu.Unlock()
+}
+
+// received handles a ping or pong frame read by the read side: a pong wakes
+// its waiter, a ping is answered.
+func (ps *pingState) received(flags byte, payload []byte) {
+	if len(payload) != 8 {
+		return
+	}
+	ps.mu.Lock()
+	if flags&flagPong != 0 {
+		id := binary.BigEndian.Uint64(payload)
+		if done, ok := ps.waiters[id]; ok {
+			close(done)
+			delete(ps.waiters, id)
+		}
+		ps.mu.Unlock()
+		return
+	}
+	f := ctlFrame{flags: flagControl | flagPong}
+	copy(f.payload[:], payload)
+	ps.queue = append(ps.queue, f)
+	ps.mu.Unlock()
+	_ = ps.flushFrom(ps.w)
+}
+
+// flushFrom sends the queued frames if the write side w is idle. Errors are
+// left for the next write to meet.
+func (ps *pingState) flushFrom(w *framer) error {
+	if !w.enter() {
+		return ErrConcurrentUse
+	}
+	defer w.leave()
+	if w.offset != 0 && !ps.sending {
+		// A message is partially written.
+		return ErrWouldBlock
+	}
+	return ps.flush(w)
+}
+
+// flush sends the queued frames through the write side w, which the caller
+// holds. A frame cut short by an error is resumed by the next flush.
+func (ps *pingState) flush(w *framer) error {
+	ctl, urgent := w.wctl, w.urgent
+	ps.flushing = true
+	defer func() { ps.flushing, w.wctl, w.urgent = false, ctl, urgent }()
+	for {
+		ps.mu.Lock()
+		if len(ps.queue) == 0 {
+			ps.mu.Unlock()
+			return nil
+		}
+		f := ps.queue[0]
+		ps.mu.Unlock()
+
+		if w.coal != nil {
+			if err := w.flush(); err != nil {
+				return err
+			}
+		}
+		ps.sending = true
+		w.wctl, w.urgent = f.flags, true
+		_, err := w.write(f.payload[:])
+		if err != nil {
+			ps.sending = w.offset != 0
+			return err
+		}
+		ps.sending = false
+		ps.mu.Lock()
+		ps.queue = ps.queue[1:]
+		ps.mu.Unlock()
+	}
+}
+
+// owed reports whether queued frames must go out before the next message
+// of the write side w.
+func (ps *pingState) owed(w *framer) bool {
+	if ps.flushing || (w.offset != 0 && !ps.sending) {
+		return false
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	return len(ps.queue) > 0
+}
@@ -0,0 +1,134 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"io"
+	"sync"
+)
+
+// defaultPipeCapacity is the BufferedPipe capacity when none is given.
+const defaultPipeCapacity = 64 * 1024
+
+// BufferedPipe is an in-memory message pipe with a fixed byte capacity.
+//
+// Unlike NewPipe, it never blocks: each Write queues one complete message or
+// fails with ErrWouldBlock when the remaining capacity is too small, and each
+// Read returns one whole message or ErrWouldBlock when the pipe is empty.
+// Message boundaries are kept, including empty messages. BufferedPipe is safe
+// for concurrent use.
+//
+// After Close or CloseWithError, Write fails with ErrClosed while Read
+// keeps returning the queued messages and then the close error.
+type BufferedPipe struct {
+	mu        sync.Mutex
+	buf       []byte // ring of queued payload bytes
+	head      int    // start of the oldest message in buf
+	used      int    // queued payload bytes
+	lens      []int  // queued message lengths, oldest first from lhead
+	lhead     int
+	readLimit int64
+	closeErr  error // non-nil once closed
+}
+
+// NewBufferedPipe returns a pipe holding up to capacityBytes payload bytes.
+// A non-positive capacity selects 64KiB. Of opts, ReadLimit applies: longer
+// messages are rejected by Write with ErrTooLong.
+func NewBufferedPipe(capacityBytes int, opts ...Option) *BufferedPipe {
+	o := defaultOptions
+	for _, fn := range opts {
+		fn(&o)
+	}
+	if capacityBytes <= 0 {
+		capacityBytes = defaultPipeCapacity
+	}
+	return &BufferedPipe{buf: make([]byte, capacityBytes), readLimit: int64(o.ReadLimit)}
+}
+
+// Write queues p as one message. It returns ErrTooLong when p can never fit,
+// and ErrWouldBlock, with nothing queued, when the pipe is too full for now.
+func (bp *BufferedPipe) Write(p []byte) (int, error) {
+	if len(p) > len(bp.buf) || (bp.readLimit > 0 && int64(len(p)) > bp.readLimit) {
+		return 0, ErrTooLong
+	}
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	if bp.closeErr != nil {
+		return 0, ErrClosed
+	}
+	if len(p) > len(bp.buf)-bp.used {
+		return 0, ErrWouldBlock
+	}
+	tail := (bp.head + bp.used) % len(bp.buf)
+	n := copy(bp.buf[tail:], p)
+	copy(bp.buf, p[n:])
+	bp.used += len(p)
+	if bp.lhead == len(bp.lens) {
+		bp.lens, bp.lhead = bp.lens[:0], 0
+	}
+	bp.lens = append(bp.lens, len(p))
+	return len(p), nil
+}
+
+// Read dequeues the oldest message into p. It returns ErrWouldBlock when the
+// pipe is empty and io.ErrShortBuffer, leaving the message queued, when p is
+// smaller than the message.
+func (bp *BufferedPipe) Read(p []byte) (int, error) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	if bp.lhead == len(bp.lens) {
+		if bp.closeErr != nil {
+			return 0, bp.closeErr
+		}
+		return 0, ErrWouldBlock
+	}
+	size := bp.lens[bp.lhead]
+	if len(p) < size {
+		return 0, io.ErrShortBuffer
+	}
+	n := copy(p[:size], bp.buf[bp.head:])
+	copy(p[n:size], bp.buf)
+	bp.head = (bp.head + size) % len(bp.buf)
+	bp.used -= size
+	bp.lhead++
+	return size, nil
+}
+
+// Len returns the number of queued messages and their payload bytes.
+func (bp *BufferedPipe) Len() (messages, bytes int) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	return len(bp.lens) - bp.lhead, bp.used
+}
+
+// Close closes the pipe; Read returns io.EOF once the queue is drained.
+func (bp *BufferedPipe) Close() error { return bp.CloseWithError(nil) }
+
+// CloseWithError closes the pipe; Read returns err once the queue is drained,
+// or io.EOF when err is nil. Only the first close takes effect.
+func (bp *BufferedPipe) CloseWithError(err error) error {
+	if err == nil {
+		err = io.EOF
+	}
+	bp.mu.Lock()
+	if bp.closeErr == nil {
+		bp.closeErr = err
+	}
+	bp.mu.Unlock()
+	return nil
+}
+
+// syncPipe is the framing pipe returned by NewPipe.
+type syncPipe struct {
+	*ReadWriter
+	pw *io.PipeWriter
+}
+
+// Close closes the writing end; the reading end observes io.EOF.
+func (p *syncPipe) Close() error { return p.pw.Close() }
+
+// CloseWithError closes the writing end; the reading end observes err, or
+// io.EOF when err is nil.
+func (p *syncPipe) CloseWithError(err error) error { return p.pw.CloseWithError(err) }
@@ -0,0 +1,144 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+	"testing"
+
+	fr "code.hybscloud.com/framer"
+)
+
+func TestBufferedPipe_BoundariesAndCapacity(t *testing.T) {
+	p := fr.NewBufferedPipe(10)
+	buf := make([]byte, 16)
+	if _, err := p.Read(buf); err != fr.ErrWouldBlock {
+		t.Fatalf("empty Read: err=%v", err)
+	}
+	for _, m := range []string{"abcd", "", "efg"} {
+		if n, err := p.Write([]byte(m)); n != len(m) || err != nil {
+			t.Fatalf("Write(%q): n=%d err=%v", m, n, err)
+		}
+	}
+	if _, err := p.Write([]byte("hijk")); err != fr.ErrWouldBlock {
+		t.Fatalf("full Write: err=%v", err)
+	}
+	if _, err := p.Write(make([]byte, 11)); err != fr.ErrTooLong {
+		t.Fatalf("oversized Write: err=%v", err)
+	}
+	if m, b := p.Len(); m != 3 || b != 7 {
+		t.Fatalf("Len=%d,%d", m, b)
+	}
+	if _, err := p.Read(buf[:2]); err != io.ErrShortBuffer {
+		t.Fatalf("short Read: err=%v", err)
+	}
+	if n, err := p.Read(buf); err != nil || string(buf[:n]) != "abcd" {
+		t.Fatalf("Read: n=%d err=%v", n, err)
+	}
+
+	// The next message wraps around the end of the ring.
+	if _, err := p.Write([]byte("hijkl")); err != nil {
+		t.Fatalf("wrapping Write: %v", err)
+	}
+	for _, want := range []string{"", "efg", "hijkl"} {
+		if n, err := p.Read(buf); err != nil || string(buf[:n]) != want {
+			t.Fatalf("Read: %q err=%v want %q", buf[:n], err, want)
+		}
+	}
+
+	limited := fr.NewBufferedPipe(0, fr.WithReadLimit(4))
+	if _, err := limited.Write([]byte("12345")); err != fr.ErrTooLong {
+		t.Fatalf("ReadLimit: err=%v", err)
+	}
+}
+
+func TestBufferedPipe_Concurrent(t *testing.T) {
+	p := fr.NewBufferedPipe(64)
+	const count = 1000
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < count; i++ {
+			msg := bytes.Repeat([]byte{byte(i)}, i%20)
+			for {
+				if _, err := p.Write(msg); err != fr.ErrWouldBlock {
+					if err != nil {
+						t.Errorf("Write: %v", err)
+					}
+					break
+				}
+				runtime.Gosched()
+			}
+		}
+	}()
+	buf := make([]byte, 32)
+	for i := 0; i < count; {
+		n, err := p.Read(buf)
+		if err == fr.ErrWouldBlock {
+			runtime.Gosched()
+			continue
+		}
+		if err != nil || !bytes.Equal(buf[:n], bytes.Repeat([]byte{byte(i)}, i%20)) {
+			t.Fatalf("message %d: %x err=%v", i, buf[:n], err)
+		}
+		i++
+	}
+	wg.Wait()
+}
+
+func TestBufferedPipe_CloseDrainsThenReportsError(t *testing.T) {
+	p := fr.NewBufferedPipe(16)
+	_, _ = p.Write([]byte("last"))
+	cause := errors.New("cancelled")
+	_ = p.CloseWithError(cause)
+	_ = p.Close() // only the first close counts
+	if _, err := p.Write([]byte("x")); err != fr.ErrClosed {
+		t.Fatalf("Write after close: err=%v", err)
+	}
+	buf := make([]byte, 8)
+	if n, err := p.Read(buf); err != nil || string(buf[:n]) != "last" {
+		t.Fatalf("Read: %q err=%v", buf[:n], err)
+	}
+	if _, err := p.Read(buf); err != cause {
+		t.Fatalf("drained Read: err=%v want %v", err, cause)
+	}
+
+	p = fr.NewBufferedPipe(16)
+	_ = p.Close()
+	if _, err := p.Read(buf); err != io.EOF {
+		t.Fatalf("closed Read: err=%v want io.EOF", err)
+	}
+}
+
+func TestPipe_CloseWithError(t *testing.T) {
+	type closer interface {
+		io.Closer
+		CloseWithError(error) error
+	}
+	r, w := fr.NewPipe()
+	cause := errors.New("cancelled")
+	go func() {
+		_, _ = w.Write([]byte("hello"))
+		_ = w.(closer).CloseWithError(cause)
+	}()
+	buf := make([]byte, 8)
+	if n, err := r.Read(buf); err != nil || string(buf[:n]) != "hello" {
+		t.Fatalf("Read: %q err=%v", buf[:n], err)
+	}
+	if _, err := r.Read(buf); err != cause {
+		t.Fatalf("after close: err=%v want %v", err, cause)
+	}
+
+	r, w = fr.NewPipe()
+	go func() { _ = w.(closer).Close() }()
+	if _, err := r.Read(buf); err != io.EOF {
+		t.Fatalf("after Close: err=%v want io.EOF", err)
+	}
+}
@@ -0,0 +1,277 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"sync"
+	"time"
+)
+
+// PoolConfig configures a Pool.
+type PoolConfig struct {
+	// Size caps the number of connections, idle and checked out. It must be positive.
+	Size int
+
+	// Network and Address name the target when Dial is nil. Connections are
+	// then established with net.Dial and framed with the transport helpers
+	// matching Network (see Dial).
+	Network string
+	Address string
+
+	// Dial establishes a new transport. It overrides Network and Address.
+	Dial DialFunc
+
+	// HealthCheck validates an idle connection before Get hands it out. A
+	// failing connection is closed and replaced. Nil disables health checks.
+	HealthCheck func(*PoolConn) error
+
+	// HealthInterval skips the health check for connections used within the
+	// interval. Zero checks on every Get.
+	HealthInterval time.Duration
+}
+
+// PoolConnStats reports per-connection pool statistics.
+type PoolConnStats struct {
+	Created        time.Time
+	LastUsed       time.Time
+	Checkouts      uint64
+	HealthChecks   uint64
+	HealthFailures uint64
+
+	// Traffic holds the framing counters of the connection.
+	Traffic Stats
+}
+
+// PoolConn is a framed connection owned by a Pool.
+//
+// A PoolConn is used by one holder at a time: obtain it with Pool.Get and
+// return it with Pool.Put, or with Pool.Discard when it is broken.
+type PoolConn struct {
+	*ReadWriter
+	pool  *Pool
+	stats PoolConnStats // guarded by pool.mu
+	idle  bool          // in pool.idle; guarded by pool.mu
+	gone  bool          // closed and its slot released; guarded by pool.mu
+}
+
+// Stats returns a snapshot of the connection's pool statistics.
+func (c *PoolConn) Stats() PoolConnStats {
+	c.pool.mu.Lock()
+	st := c.stats
+	c.pool.mu.Unlock()
+	st.Traffic = c.ReadWriter.Stats()
+	return st
+}
+
+// Close discards the connection, see Pool.Discard. Closing it again has no
+// effect.
+func (c *PoolConn) Close() error { return c.pool.Discard(c) }
+
+// Keepalive is a HealthCheck that writes an empty frame. With
+// WithControlFrames in stream mode it is an empty control frame, which never
+// reaches the peer's Read. Otherwise it is an empty data frame: the peer reads
+// it as an empty message and must treat empty messages as no-ops. A
+// would-block transport is reported healthy because no frame byte was
+// written.
+func Keepalive(c *PoolConn) error {
+	var err error
+	if w := c.Writer.fr; w.wflags && !w.wpr.preserveBoundary() {
+		_, err = c.WriteControl(nil)
+	} else {
+		_, err = c.Write(nil)
+	}
+	if err == ErrWouldBlock {
+		return nil
+	}
+	return err
+}
+
+// Pool manages up to Size framed connections to one target.
+//
+// Get never waits: when every connection is checked out it returns
+// ErrWouldBlock, consistent with the non-blocking semantics of the package.
+// Pool is safe for concurrent use.
+type Pool struct {
+	cfg  PoolConfig
+	opts []Option
+
+	mu     sync.Mutex
+	idle   []*PoolConn
+	total  int
+	closed bool
+}
+
+// NewPool returns a pool for cfg. Connections are dialed lazily and framed
+// with opts.
+func NewPool(cfg PoolConfig, opts ...Option) (*Pool, error) {
+	if cfg.Size <= 0 || (cfg.Dial == nil && cfg.Network == "") {
+		return nil, ErrInvalidArgument
+	}
+	p := &Pool{cfg: cfg}
+	if cfg.Dial == nil {
+		p.opts = append(networkOptions(cfg.Network), opts...)
+	} else {
+		p.opts = opts
+	}
+	return p, nil
+}
+
+// Get checks out a connection, reusing an idle one when possible.
+func (p *Pool) Get() (*PoolConn, error) {
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrClosed
+		}
+		if n := len(p.idle); n > 0 {
+			c := p.idle[n-1]
+			p.idle[n-1] = nil
+			p.idle = p.idle[:n-1]
+			c.idle = false
+			check := p.cfg.HealthCheck != nil && time.Since(c.stats.LastUsed) >= p.cfg.HealthInterval
+			if check {
+				c.stats.HealthChecks++
+			}
+			p.mu.Unlock()
+
+			if check {
+				if err := p.cfg.HealthCheck(c); err != nil {
+					p.mu.Lock()
+					c.stats.HealthFailures++
+					p.mu.Unlock()
+					_ = p.Discard(c)
+					continue
+				}
+			}
+			p.checkout(c)
+			return c, nil
+		}
+		if p.total >= p.cfg.Size {
+			p.mu.Unlock()
+			return nil, ErrWouldBlock
+		}
+		p.total++
+		p.mu.Unlock()
+
+		c, err := p.dial()
+		if err != nil {
+			p.mu.Lock()
+			p.total--
+			p.mu.Unlock()
+			return nil, err
+		}
+		p.checkout(c)
+		return c, nil
+	}
+}
+
+// Put returns a healthy connection to the pool. After Close, the connection
+// is closed instead. A connection returned or discarded already is ignored.
+func (p *Pool) Put(c *PoolConn) {
+	p.mu.Lock()
+	if c.idle || c.gone {
+		p.mu.Unlock()
+		return
+	}
+	if p.closed {
+		c.gone = true
+		p.total--
+		p.mu.Unlock()
+		_ = c.ReadWriter.Close()
+		return
+	}
+	c.stats.LastUsed = time.Now()
+	c.idle = true
+	p.idle = append(p.idle, c)
+	p.mu.Unlock()
+}
+
+// Discard closes a connection and releases its slot. Discarding a connection
+// again has no effect, so its slot is released once.
+func (p *Pool) Discard(c *PoolConn) error {
+	p.mu.Lock()
+	if c.gone {
+		p.mu.Unlock()
+		return nil
+	}
+	if c.idle {
+		c.idle = false
+		if i := slices.Index(p.idle, c); i >= 0 {
+			p.idle = slices.Delete(p.idle, i, i+1)
+		}
+	}
+	c.gone = true
+	p.total--
+	p.mu.Unlock()
+	return c.ReadWriter.Close()
+}
+
+// Len returns the number of idle connections and the total number of
+// connections, idle and checked out.
+func (p *Pool) Len() (idle, total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.idle), p.total
+}
+
+// Close closes idle connections and makes Get fail. Checked-out connections
+// are closed when they are returned.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.total -= len(idle)
+	for _, c := range idle {
+		c.idle, c.gone = false, true
+	}
+	p.mu.Unlock()
+
+	var errs []error
+	for _, c := range idle {
+		if err := c.ReadWriter.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Pool) checkout(c *PoolConn) {
+	p.mu.Lock()
+	c.stats.Checkouts++
+	c.stats.LastUsed = time.Now()
+	p.mu.Unlock()
+}
+
+func (p *Pool) dial() (*PoolConn, error) {
+	var conn io.ReadWriter
+	var err error
+	if p.cfg.Dial != nil {
+		conn, err = p.cfg.Dial()
+	} else {
+		conn, err = net.Dial(p.cfg.Network, p.cfg.Address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if conn == nil {
+		return nil, ErrInvalidArgument
+	}
+	now := time.Now()
+	return &PoolConn{
+		ReadWriter: NewReadWriter(conn, conn, p.opts...).(*ReadWriter),
+		pool:       p,
+		stats:      PoolConnStats{Created: now, LastUsed: now},
+	}, nil
+}
@@ -0,0 +1,255 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	fr "code.hybscloud.com/framer"
+)
+
+func TestDial_TCPRoundTrip(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		nc, err := ln.Accept()
+		if err != nil {
+			done <- err
+			return
+		}
+		defer nc.Close()
+		c := fr.NewConn(nc, fr.WithBlock())
+		buf := make([]byte, 64)
+		n, err := c.Read(buf)
+		if err == nil {
+			_, err = c.Write(buf[:n])
+		}
+		done <- err
+	}()
+
+	c, err := fr.Dial("tcp", ln.Addr().String(), fr.WithBlock())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer c.Close()
+	if c.NetConn() == nil {
+		t.Fatalf("NetConn is nil")
+	}
+	if _, err := c.Write([]byte("ping")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	buf := make([]byte, 64)
+	n, err := c.Read(buf)
+	if err != nil || string(buf[:n]) != "ping" {
+		t.Fatalf("read: n=%d err=%v", n, err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("server: %v", err)
+	}
+}
+
+func TestPool_CheckoutCheckinAndExhaustion(t *testing.T) {
+	dials := 0
+	p, err := fr.NewPool(fr.PoolConfig{
+		Size: 2,
+		Dial: func() (io.ReadWriter, error) {
+			dials++
+			return &fakeConn{Reader: bytes.NewReader(nil), Writer: io.Discard}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	c1, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get 1: %v", err)
+	}
+	if _, err := p.Get(); err != nil {
+		t.Fatalf("Get 2: %v", err)
+	}
+	if _, err := p.Get(); !errors.Is(err, fr.ErrWouldBlock) {
+		t.Fatalf("Get 3: want ErrWouldBlock, got %v", err)
+	}
+	p.Put(c1)
+	if idle, total := p.Len(); idle != 1 || total != 2 {
+		t.Fatalf("Len=(%d, %d) want (1, 2)", idle, total)
+	}
+	c, err := p.Get()
+	if err != nil || c != c1 {
+		t.Fatalf("Get after Put: c=%p err=%v want reused %p", c, err, c1)
+	}
+	if s := c.Stats(); s.Checkouts != 2 || s.Created.IsZero() {
+		t.Fatalf("stats=%+v", s)
+	}
+	if dials != 2 {
+		t.Fatalf("dials=%d want 2", dials)
+	}
+}
+
+func TestPool_HealthCheckReplacesBrokenConnection(t *testing.T) {
+	var conns []*fakeConn
+	p, err := fr.NewPool(fr.PoolConfig{
+		Size: 1,
+		Dial: func() (io.ReadWriter, error) {
+			c := &fakeConn{Reader: bytes.NewReader(nil), Writer: &bytes.Buffer{}}
+			conns = append(conns, c)
+			return c, nil
+		},
+		HealthCheck: fr.Keepalive,
+	})
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	c, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	p.Put(c)
+
+	// Healthy: keepalive frame written, same connection handed out.
+	c, err = p.Get()
+	if err != nil || len(conns) != 1 {
+		t.Fatalf("Get healthy: err=%v dials=%d", err, len(conns))
+	}
+	if got := conns[0].Writer.(*bytes.Buffer).Bytes(); !bytes.Equal(got, []byte{0}) {
+		t.Fatalf("keepalive wire=%v want [0]", got)
+	}
+	if s := c.Stats(); s.HealthChecks != 1 || s.HealthFailures != 0 {
+		t.Fatalf("stats=%+v", s)
+	}
+	p.Put(c)
+
+	// Broken: keepalive fails, connection is closed and replaced.
+	conns[0].Writer = failWriter{err: errors.New("reset")}
+	c, err = p.Get()
+	if err != nil {
+		t.Fatalf("Get after failure: %v", err)
+	}
+	if len(conns) != 2 || !conns[0].closed {
+		t.Fatalf("broken connection not replaced: dials=%d closed=%v", len(conns), conns[0].closed)
+	}
+	if s := c.Stats(); s.Checkouts != 1 {
+		t.Fatalf("replacement stats=%+v", s)
+	}
+}
+
+func TestPool_CloseAndDiscard(t *testing.T) {
+	var conns []*fakeConn
+	p, err := fr.NewPool(fr.PoolConfig{
+		Size: 2,
+		Dial: func() (io.ReadWriter, error) {
+			c := &fakeConn{Reader: bytes.NewReader(nil), Writer: io.Discard}
+			conns = append(conns, c)
+			return c, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	c1, _ := p.Get()
+	c2, _ := p.Get()
+	if err := p.Discard(c1); err != nil || !conns[0].closed {
+		t.Fatalf("Discard: err=%v closed=%v", err, conns[0].closed)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := p.Get(); !errors.Is(err, fr.ErrClosed) {
+		t.Fatalf("Get after Close: %v", err)
+	}
+	p.Put(c2)
+	if !conns[1].closed {
+		t.Fatalf("connection returned after Close was not closed")
+	}
+	if idle, total := p.Len(); idle != 0 || total != 0 {
+		t.Fatalf("Len=(%d, %d) want (0, 0)", idle, total)
+	}
+}
+
+func TestPool_CloseTwiceReleasesSlotOnce(t *testing.T) {
+	p, err := fr.NewPool(fr.PoolConfig{
+		Size: 1,
+		Dial: func() (io.ReadWriter, error) {
+			return &fakeConn{Reader: bytes.NewReader(nil), Writer: io.Discard}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	c, _ := p.Get()
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+	p.Put(c)
+	if idle, total := p.Len(); idle != 0 || total != 0 {
+		t.Fatalf("Len=(%d, %d) want (0, 0)", idle, total)
+	}
+	if _, err := p.Get(); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if _, err := p.Get(); err != fr.ErrWouldBlock {
+		t.Fatalf("Get beyond Size: err=%v", err)
+	}
+
+	// An idle connection discarded directly leaves the idle list.
+	p2, _ := fr.NewPool(fr.PoolConfig{Size: 1, Dial: func() (io.ReadWriter, error) {
+		return &fakeConn{Reader: bytes.NewReader(nil), Writer: io.Discard}, nil
+	}})
+	c, _ = p2.Get()
+	p2.Put(c)
+	p2.Put(c)
+	if idle, total := p2.Len(); idle != 1 || total != 1 {
+		t.Fatalf("double Put: Len=(%d, %d) want (1, 1)", idle, total)
+	}
+	_ = c.Close()
+	if idle, total := p2.Len(); idle != 0 || total != 0 {
+		t.Fatalf("Close of idle: Len=(%d, %d) want (0, 0)", idle, total)
+	}
+}
+
+func TestKeepalive_UsesControlFrame(t *testing.T) {
+	var wire bytes.Buffer
+	p, err := fr.NewPool(fr.PoolConfig{
+		Size: 1,
+		Dial: func() (io.ReadWriter, error) {
+			return &fakeConn{Reader: bytes.NewReader(nil), Writer: &wire}, nil
+		},
+		HealthCheck: fr.Keepalive,
+	}, fr.WithControlFrames(nil))
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	c, _ := p.Get()
+	p.Put(c)
+	if _, err := p.Get(); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	// The peer's Read sees no message, only the control frame.
+	r := fr.NewReader(bytes.NewReader(wire.Bytes()), fr.WithControlFrames(nil))
+	if n, err := r.Read(make([]byte, 4)); err != io.EOF {
+		t.Fatalf("peer Read: n=%d err=%v, wire=%v", n, err, wire.Bytes())
+	}
+}
+
+func TestNewPool_InvalidConfig(t *testing.T) {
+	if _, err := fr.NewPool(fr.PoolConfig{Network: "tcp"}); !errors.Is(err, fr.ErrInvalidArgument) {
+		t.Fatalf("zero size: %v", err)
+	}
+	if _, err := fr.NewPool(fr.PoolConfig{Size: 1}); !errors.Is(err, fr.ErrInvalidArgument) {
+		t.Fatalf("no target: %v", err)
+	}
+}
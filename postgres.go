@@ -0,0 +1,69 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// HeaderPostgres limits: the header size and the largest payload.
+const (
+	pgHeaderLen = 5
+	pgMaxLength = math.MaxUint32 - 4
+)
+
+// A HeaderPostgres header is the header of PostgreSQL frontend/backend
+// protocol messages: a message type byte, such as 'Q' or 'D', and a 4-byte
+// big-endian length that counts itself but not the type byte. The type is
+// not part of the payload: the Reader records it in Meta.Type, and the
+// Writer takes it from WriteMeta. A length below 4 fails with
+// ErrInvalidHeader.
+//
+// The StartupMessage, SSLRequest, GSSENCRequest and CancelRequest sent by a
+// client before the first typed message have no type byte. A proxy reads
+// them with a Reader configured with WithHeaderFormat(HeaderFixed32) and
+// WithLengthIncludesHeader, then frames the rest of the connection with
+// HeaderPostgres; without WithPrefetch, the first Reader consumes nothing
+// past its messages.
+
+// putPostgresHeader encodes the header of a v-byte payload of message type
+// typ.
+func putPostgresHeader(dst []byte, typ byte, v int64) {
+	dst[0] = typ
+	binary.BigEndian.PutUint32(dst[1:pgHeaderLen], uint32(v+4))
+}
+
+// postgresLength returns the payload length of a complete HeaderPostgres
+// header.
+func postgresLength(hdr []byte) (int64, error) {
+	v := int64(binary.BigEndian.Uint32(hdr[1:pgHeaderLen]))
+	if v < 4 {
+		return 0, ErrInvalidHeader
+	}
+	return v - 4, nil
+}
+
+// readPostgresHeader parses a HeaderPostgres header and records its type in
+// fr.rmeta. The header size is kept in fr.hlen so it is parsed once per
+// message.
+func (fr *framer) readPostgresHeader() (int64, error) {
+	if fr.hlen > 0 {
+		return fr.hlen, nil
+	}
+	if err := fr.readHeaderBytes(pgHeaderLen); err != nil {
+		return 0, err
+	}
+	v, err := postgresLength(fr.header[:pgHeaderLen])
+	if err != nil {
+		return 0, err
+	}
+	if err := fr.parsedLength(v, pgHeaderLen); err != nil {
+		return 0, err
+	}
+	fr.rmeta = Meta{Type: fr.header[0]}
+	fr.hlen = pgHeaderLen
+	return fr.hlen, nil
+}
@@ -0,0 +1,94 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+// WithPrefetch gives a stream Reader a read-ahead buffer of size bytes. Reads
+// smaller than the buffer, such as headers and small payloads, fill it with
+// one transport read and are then served from it, so pipelined messages
+// arriving together cost one read instead of two per message. After a
+// message is delivered, a nonblocking Reader (the default RetryDelay and no
+// WaitFunc) also makes one attempt to read the next frame into the empty
+// buffer, so the next Read often completes without touching the transport;
+// the transport must then be nonblocking too, since the attempt would
+// otherwise wait for the next message.
+//
+// A transport error met while reading ahead is reported once the buffered
+// bytes are consumed. RawRemainder hands the buffered bytes over first. It
+// has no effect in packet-preserving modes.
+func WithPrefetch(size int) Option {
+	return func(o *Options) { o.Prefetch = size }
+}
+
+// readSource reads transport bytes for readOnce, through the prefetch buffer
+// when there is one.
+func (fr *framer) readSource(p []byte) (int, error) {
+	if fr.snOff < fr.snLen && fr.auto == nil {
+		// Bytes sniffed by WithAutoDetect come first.
+		n := copy(p, fr.sniff[fr.snOff:fr.snLen])
+		fr.snOff += n
+		return n, nil
+	}
+	if fr.pf == nil {
+		if !fr.spendRead() {
+			return 0, ErrWouldBlock
+		}
+		return fr.rio.Read(p)
+	}
+	if fr.pfOff == fr.pfLen {
+		if err := fr.pfErr; err != nil {
+			fr.pfErr = nil
+			return 0, err
+		}
+		if !fr.spendRead() {
+			return 0, ErrWouldBlock
+		}
+		if len(p) >= len(fr.pf) {
+			return fr.rio.Read(p)
+		}
+		n, err := fr.rio.Read(fr.pf)
+		fr.pfOff, fr.pfLen = 0, n
+		if n == 0 {
+			return 0, err
+		}
+		fr.keepPrefetchErr(err)
+	}
+	return fr.takePrefetched(p), nil
+}
+
+// prefetch makes one attempt to read ahead into the empty prefetch buffer
+// after a message completes.
+func (fr *framer) prefetch() {
+	if fr.pf == nil || fr.pfOff < fr.pfLen || fr.pfErr != nil || fr.retryDelay >= 0 || fr.waitFunc != nil || !fr.spendRead() {
+		return
+	}
+	n, err := fr.rio.Read(fr.pf)
+	fr.pfOff, fr.pfLen = 0, n
+	fr.keepPrefetchErr(err)
+}
+
+// keepPrefetchErr holds a transport error met while reading ahead until the
+// buffered bytes are consumed. Would-block and more-data signals describe
+// the transport at the time of the read and are dropped.
+func (fr *framer) keepPrefetchErr(err error) {
+	if err != nil && err != ErrWouldBlock && err != ErrMore {
+		fr.pfErr = err
+	}
+}
+
+// takePrefetched moves buffered read-ahead bytes to p.
+func (fr *framer) takePrefetched(p []byte) int {
+	n := copy(p, fr.pf[fr.pfOff:fr.pfLen])
+	fr.pfOff += n
+	return n
+}
+
+// buffered returns the bytes read from the transport and not yet delivered.
+func (fr *framer) buffered() int {
+	n := fr.pfLen - fr.pfOff + fr.dlen - fr.doff + fr.snLen - fr.snOff
+	if fr.rbun != nil {
+		n += len(fr.rbun.data)
+	}
+	return n
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import "io"
+
+// rawIO is not available on this platform; WithRawConn has no effect.
+type rawIO struct{ io.ReadWriter }
+
+func newRawIO(any, bool) *rawIO { return nil }
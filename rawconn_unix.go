@@ -0,0 +1,81 @@
+//go:build unix
+
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"io"
+	"syscall"
+)
+
+// rawIO performs reads and writes with single system calls on the file
+// descriptor of a syscall.Conn. The RawConn callbacks always report done, so
+// the Go netpoller never parks the caller: EAGAIN surfaces as ErrWouldBlock
+// and the framer's retry policy decides what happens next.
+type rawIO struct {
+	rc     syscall.RawConn
+	packet bool // a zero-byte read is an empty datagram, not EOF
+}
+
+// newRawIO returns a rawIO on the descriptor of t, or nil when t does not
+// implement syscall.Conn.
+func newRawIO(t any, packet bool) *rawIO {
+	sc, ok := t.(syscall.Conn)
+	if !ok {
+		return nil
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return nil
+	}
+	return &rawIO{rc: rc, packet: packet}
+}
+
+func (r *rawIO) Read(p []byte) (n int, err error) {
+	cerr := r.rc.Read(func(fd uintptr) bool {
+		for {
+			n, err = syscall.Read(int(fd), p)
+			if err != syscall.EINTR {
+				return true
+			}
+		}
+	})
+	if cerr != nil {
+		return 0, cerr
+	}
+	if err != nil {
+		return 0, rawErr(err)
+	}
+	if n == 0 && len(p) > 0 && !r.packet {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+func (r *rawIO) Write(p []byte) (n int, err error) {
+	cerr := r.rc.Write(func(fd uintptr) bool {
+		for {
+			n, err = syscall.Write(int(fd), p)
+			if err != syscall.EINTR {
+				return true
+			}
+		}
+	})
+	if cerr != nil {
+		return 0, cerr
+	}
+	if err != nil {
+		return 0, rawErr(err)
+	}
+	return n, nil
+}
+
+func rawErr(err error) error {
+	if err == syscall.EAGAIN || err == syscall.EWOULDBLOCK {
+		return ErrWouldBlock
+	}
+	return err
+}
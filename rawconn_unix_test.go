@@ -0,0 +1,70 @@
+//go:build unix
+
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer_test
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	fr "code.hybscloud.com/framer"
+)
+
+func TestRawConn_NonblockingOnDescriptor(t *testing.T) {
+	a, b := tcpPair(t)
+	ra := fr.NewReadWriter(a, a, fr.WithRawConn(), fr.WithNonblock()).(*fr.ReadWriter)
+	buf := make([]byte, 16)
+
+	// Nothing to read: the netpoller does not park the caller.
+	done := make(chan error, 1)
+	go func() { _, err := ra.Read(buf); done <- err }()
+	select {
+	case err := <-done:
+		if err != fr.ErrWouldBlock {
+			t.Fatalf("empty read: err=%v, want ErrWouldBlock", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("raw read blocked")
+	}
+
+	if _, err := fr.NewWriter(b).Write([]byte("ping")); err != nil {
+		t.Fatalf("peer write: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		n, err := ra.Read(buf)
+		if err == nil {
+			if string(buf[:n]) != "ping" {
+				t.Fatalf("read %q, want ping", buf[:n])
+			}
+			break
+		}
+		if err != fr.ErrWouldBlock || time.Now().After(deadline) {
+			t.Fatalf("read: err=%v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := ra.Write([]byte("pong")); err != nil {
+		t.Fatalf("raw write: %v", err)
+	}
+	if n, err := fr.NewReader(b, fr.WithBlock()).Read(buf); err != nil || string(buf[:n]) != "pong" {
+		t.Fatalf("peer read: got (%q, %v)", buf[:n], err)
+	}
+
+	b.Close()
+	for {
+		_, err := ra.Read(buf)
+		if err == io.EOF {
+			break
+		}
+		if err != fr.ErrWouldBlock || time.Now().After(deadline) {
+			t.Fatalf("read after peer close: err=%v, want io.EOF", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
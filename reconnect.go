@@ -0,0 +1,264 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// DialFunc establishes a new transport for a Reconnector.
+type DialFunc func() (io.ReadWriter, error)
+
+// ReconnectPolicy controls how a Reconnector re-establishes its transport.
+type ReconnectPolicy struct {
+	// MaxAttempts bounds the dial attempts of one reconnect. Zero means unlimited.
+	MaxAttempts int
+
+	// Backoff is the delay after the first failed dial attempt. It doubles after
+	// each further failure, capped by MaxBackoff when MaxBackoff is positive.
+	// Zero retries immediately.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// Replay resends the message whose Write failed on the previous transport.
+	// Without Replay, Write reconnects and then reports the original error so
+	// the caller can decide whether to resend.
+	Replay bool
+}
+
+// Reconnector is a framed ReadWriter that re-dials its transport on EOF or on
+// fatal transport errors and continues on the new connection.
+//
+// Semantic errors (ErrWouldBlock, ErrMore), message-level errors
+// (ErrTooLong, io.ErrShortBuffer, ErrInvalidArgument), ErrConcurrentUse and
+// ErrClosed are returned unchanged and never trigger a reconnect. A message partially received when the read
+// side failed is discarded; the next message is read from the new transport.
+//
+// Read and Write may be called from different goroutines; each direction
+// switches to the new transport on its next call. The previous transport is
+// closed when it implements io.Closer, which also unblocks the other direction.
+// A direction that fails while the other is dialing waits for that dial
+// instead of dialing again. Close does not wait for a dial in progress: it
+// interrupts the backoff, and the dialing call returns ErrClosed.
+type Reconnector struct {
+	dial   DialFunc
+	policy ReconnectPolicy
+
+	mu      sync.Mutex
+	conn    io.ReadWriter
+	gen     uint64
+	closed  bool
+	dialing chan struct{} // closed when the reconnect in progress ends
+	quit    chan struct{} // closed by Close to interrupt the backoff
+
+	// Per-direction state, owned by the reading and the writing goroutine.
+	r    *Reader
+	rgen uint64
+	w    *Writer
+	wgen uint64
+}
+
+// NewReconnector dials the initial transport and returns a Reconnector that
+// frames it with opts.
+func NewReconnector(dial DialFunc, policy ReconnectPolicy, opts ...Option) (*Reconnector, error) {
+	if dial == nil {
+		return nil, ErrInvalidArgument
+	}
+	c := &Reconnector{dial: dial, policy: policy, quit: make(chan struct{})}
+	conn, err := c.dialWithPolicy()
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	c.r = &Reader{fr: newFramer(conn, nil, opts...)}
+	c.w = &Writer{fr: newFramer(nil, conn, opts...)}
+	return c, nil
+}
+
+// Read reads one message, reconnecting as needed.
+func (c *Reconnector) Read(p []byte) (int, error) {
+	for {
+		gen, err := c.syncReader()
+		if err != nil {
+			return 0, err
+		}
+		n, err := c.r.Read(p)
+		if !reconnectable(err) {
+			return n, err
+		}
+		if rerr := c.reconnect(gen); rerr != nil {
+			return n, rerr
+		}
+	}
+}
+
+// Write writes one message, reconnecting when the transport fails. With
+// ReconnectPolicy.Replay the message is written again on the new transport.
+func (c *Reconnector) Write(p []byte) (int, error) {
+	for {
+		gen, err := c.syncWriter()
+		if err != nil {
+			return 0, err
+		}
+		n, err := c.w.Write(p)
+		if !reconnectable(err) {
+			return n, err
+		}
+		if rerr := c.reconnect(gen); rerr != nil {
+			return n, rerr
+		}
+		if !c.policy.Replay {
+			return n, err
+		}
+	}
+}
+
+// Close closes the current transport. Subsequent Read and Write calls return
+// ErrClosed.
+func (c *Reconnector) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	close(c.quit)
+	return closeTransport(c.conn)
+}
+
+func (c *Reconnector) syncReader() (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, ErrClosed
+	}
+	if c.rgen != c.gen {
+		c.r.SwapReader(c.conn)
+		c.rgen = c.gen
+	}
+	return c.rgen, nil
+}
+
+func (c *Reconnector) syncWriter() (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, ErrClosed
+	}
+	if c.wgen != c.gen {
+		c.w.SwapWriter(c.conn)
+		c.wgen = c.gen
+	}
+	return c.wgen, nil
+}
+
+// reconnect replaces the transport of generation failed. When the other
+// direction already reconnected, it returns immediately, and while it is
+// dialing, reconnect waits for it. The dial runs without c.mu held, so Close
+// and the other direction are not blocked behind the backoff.
+func (c *Reconnector) reconnect(failed uint64) error {
+	c.mu.Lock()
+	for c.dialing != nil && !c.closed && c.gen == failed {
+		ch := c.dialing
+		c.mu.Unlock()
+		<-ch
+		c.mu.Lock()
+	}
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	if c.gen != failed {
+		c.mu.Unlock()
+		return nil
+	}
+	ch := make(chan struct{})
+	c.dialing = ch
+	old := c.conn
+	c.mu.Unlock()
+
+	_ = closeTransport(old)
+	conn, err := c.dialWithPolicy()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dialing = nil
+	close(ch)
+	if err != nil {
+		return err
+	}
+	if c.closed {
+		_ = closeTransport(conn)
+		return ErrClosed
+	}
+	c.conn = conn
+	c.gen++
+	return nil
+}
+
+// dialWithPolicy dials until it succeeds or the policy gives up. Close ends it
+// between attempts with ErrClosed.
+func (c *Reconnector) dialWithPolicy() (io.ReadWriter, error) {
+	delay := c.policy.Backoff
+	for attempt := 1; ; attempt++ {
+		conn, err := c.dial()
+		if err == nil {
+			if conn == nil {
+				return nil, ErrInvalidArgument
+			}
+			return conn, nil
+		}
+		if c.policy.MaxAttempts > 0 && attempt >= c.policy.MaxAttempts {
+			return nil, err
+		}
+		if delay > 0 {
+			t := time.NewTimer(delay)
+			select {
+			case <-t.C:
+			case <-c.quit:
+				t.Stop()
+				return nil, ErrClosed
+			}
+			delay *= 2
+			if c.policy.MaxBackoff > 0 && delay > c.policy.MaxBackoff {
+				delay = c.policy.MaxBackoff
+			}
+		} else {
+			select {
+			case <-c.quit:
+				return nil, ErrClosed
+			default:
+			}
+		}
+	}
+}
+
+// reconnectable reports whether err indicates a broken transport rather than
+// a control-flow signal or a message-level failure.
+func reconnectable(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, ErrWouldBlock), errors.Is(err, ErrMore):
+		return false
+	case errors.Is(err, ErrTooLong), errors.Is(err, io.ErrShortBuffer), errors.Is(err, ErrInvalidArgument):
+		return false
+	case errors.Is(err, ErrConcurrentUse), errors.Is(err, ErrClosed):
+		// Misuse, or a Close of this side, is not a broken transport.
+		return false
+	default:
+		return true
+	}
+}
+
+func closeTransport(t any) error {
+	if c, ok := t.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	fr "code.hybscloud.com/framer"
+)
+
+type fakeConn struct {
+	io.Reader
+	io.Writer
+	closed bool
+}
+
+func (c *fakeConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+// dialSeq returns a DialFunc handing out conns in order, then failing with errDialExhausted.
+func dialSeq(calls *int, conns ...*fakeConn) fr.DialFunc {
+	return func() (io.ReadWriter, error) {
+		*calls++
+		if len(conns) == 0 {
+			return nil, errDialExhausted
+		}
+		c := conns[0]
+		conns = conns[1:]
+		return c, nil
+	}
+}
+
+var errDialExhausted = errors.New("dial exhausted")
+
+func TestReconnector_Read_ReconnectsOnEOF(t *testing.T) {
+	c1 := &fakeConn{Reader: bytes.NewReader([]byte{1, 'a'}), Writer: io.Discard}
+	c2 := &fakeConn{Reader: bytes.NewReader([]byte{1, 'b'}), Writer: io.Discard}
+	calls := 0
+	rc, err := fr.NewReconnector(dialSeq(&calls, c1, c2), fr.ReconnectPolicy{MaxAttempts: 1}, fr.WithProtocol(fr.BinaryStream))
+	if err != nil {
+		t.Fatalf("NewReconnector: %v", err)
+	}
+	buf := make([]byte, 4)
+	for _, want := range []string{"a", "b"} {
+		n, err := rc.Read(buf)
+		if err != nil || string(buf[:n]) != want {
+			t.Fatalf("read: n=%d err=%v want %q", n, err, want)
+		}
+	}
+	if !c1.closed {
+		t.Fatalf("previous transport not closed on reconnect")
+	}
+	if _, err := rc.Read(buf); !errors.Is(err, errDialExhausted) {
+		t.Fatalf("read after last conn: want dial error, got %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("dial calls=%d want 3", calls)
+	}
+}
+
+func TestReconnector_Write_ReplaysInFlightMessage(t *testing.T) {
+	broken := errors.New("broken pipe")
+	c1 := &fakeConn{Reader: bytes.NewReader(nil), Writer: failWriter{err: broken}}
+	var out bytes.Buffer
+	c2 := &fakeConn{Reader: bytes.NewReader(nil), Writer: &out}
+	calls := 0
+	rc, err := fr.NewReconnector(dialSeq(&calls, c1, c2), fr.ReconnectPolicy{Replay: true}, fr.WithProtocol(fr.BinaryStream))
+	if err != nil {
+		t.Fatalf("NewReconnector: %v", err)
+	}
+	n, err := rc.Write([]byte("hi"))
+	if err != nil || n != 2 {
+		t.Fatalf("write: n=%d err=%v", n, err)
+	}
+	if !bytes.Equal(out.Bytes(), []byte{2, 'h', 'i'}) {
+		t.Fatalf("wire=%v", out.Bytes())
+	}
+}
+
+func TestReconnector_Write_WithoutReplayReportsError(t *testing.T) {
+	broken := errors.New("broken pipe")
+	c1 := &fakeConn{Reader: bytes.NewReader(nil), Writer: failWriter{err: broken}}
+	var out bytes.Buffer
+	c2 := &fakeConn{Reader: bytes.NewReader(nil), Writer: &out}
+	calls := 0
+	rc, err := fr.NewReconnector(dialSeq(&calls, c1, c2), fr.ReconnectPolicy{}, fr.WithProtocol(fr.BinaryStream))
+	if err != nil {
+		t.Fatalf("NewReconnector: %v", err)
+	}
+	if _, err := rc.Write([]byte("hi")); !errors.Is(err, broken) {
+		t.Fatalf("write: want original error, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("message replayed without Replay policy")
+	}
+	if n, err := rc.Write([]byte("hi")); err != nil || n != 2 {
+		t.Fatalf("write on new transport: n=%d err=%v", n, err)
+	}
+}
+
+func TestReconnector_SemanticErrorsDoNotReconnect(t *testing.T) {
+	c1 := &fakeConn{Reader: &wbOnceReader{b: []byte{1, 'x'}}, Writer: io.Discard}
+	calls := 0
+	rc, err := fr.NewReconnector(dialSeq(&calls, c1), fr.ReconnectPolicy{}, fr.WithProtocol(fr.BinaryStream))
+	if err != nil {
+		t.Fatalf("NewReconnector: %v", err)
+	}
+	buf := make([]byte, 4)
+	if _, err := rc.Read(buf); !errors.Is(err, fr.ErrWouldBlock) {
+		t.Fatalf("read: want ErrWouldBlock, got %v", err)
+	}
+	if n, err := rc.Read(buf); err != nil || string(buf[:n]) != "x" {
+		t.Fatalf("retry: n=%d err=%v", n, err)
+	}
+	if calls != 1 {
+		t.Fatalf("dial calls=%d want 1", calls)
+	}
+}
+
+func TestReconnector_MaxAttempts(t *testing.T) {
+	calls := 0
+	dial := func() (io.ReadWriter, error) {
+		calls++
+		return nil, errDialExhausted
+	}
+	if _, err := fr.NewReconnector(dial, fr.ReconnectPolicy{MaxAttempts: 3}); !errors.Is(err, errDialExhausted) {
+		t.Fatalf("want dial error, got %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("dial calls=%d want 3", calls)
+	}
+	if _, err := fr.NewReconnector(nil, fr.ReconnectPolicy{}); !errors.Is(err, fr.ErrInvalidArgument) {
+		t.Fatalf("nil dial: want ErrInvalidArgument, got %v", err)
+	}
+}
+
+func TestReconnector_Close(t *testing.T) {
+	c1 := &fakeConn{Reader: bytes.NewReader(nil), Writer: io.Discard}
+	calls := 0
+	rc, err := fr.NewReconnector(dialSeq(&calls, c1), fr.ReconnectPolicy{})
+	if err != nil {
+		t.Fatalf("NewReconnector: %v", err)
+	}
+	if err := rc.Close(); err != nil || !c1.closed {
+		t.Fatalf("Close: err=%v closed=%v", err, c1.closed)
+	}
+	if _, err := rc.Read(make([]byte, 1)); !errors.Is(err, fr.ErrClosed) {
+		t.Fatalf("read after close: %v", err)
+	}
+	if _, err := rc.Write([]byte("x")); !errors.Is(err, fr.ErrClosed) {
+		t.Fatalf("write after close: %v", err)
+	}
+}
+
+func TestReconnector_CloseInterruptsBackoff(t *testing.T) {
+	var calls atomic.Int32
+	c1 := &fakeConn{Reader: bytes.NewReader(nil), Writer: io.Discard}
+	dial := func() (io.ReadWriter, error) {
+		if calls.Add(1) == 1 {
+			return c1, nil
+		}
+		return nil, errDialExhausted
+	}
+	rc, err := fr.NewReconnector(dial, fr.ReconnectPolicy{Backoff: time.Hour})
+	if err != nil {
+		t.Fatalf("NewReconnector: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := rc.Read(make([]byte, 4))
+		done <- err
+	}()
+	for calls.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	// The reader is in its backoff; neither the writer nor Close waits for it.
+	if _, err := rc.Write([]byte("x")); err != nil {
+		t.Fatalf("write during reconnect: %v", err)
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	select {
+	case err := <-done:
+		if !errors.Is(err, fr.ErrClosed) {
+			t.Fatalf("read: want ErrClosed, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not interrupt the reconnect backoff")
+	}
+}
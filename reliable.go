@@ -0,0 +1,399 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// reliableHeader is the size of the Reliable packet header: a flags byte and
+// a big-endian sequence number. A data packet carries message seq; an ACK,
+// flagged flagControl, carries the next sequence number the receiver expects
+// and so acknowledges every earlier one. A NACK is an ACK also flagged
+// reliableNack, followed by the big-endian sequence numbers found missing.
+const reliableHeader = 5
+
+const (
+	reliableNack    = 0x02
+	reliableMaxNack = 64 // missing sequence numbers listed per NACK
+
+	defaultReliableWindow  = 64
+	defaultReliableTimeout = 200 * time.Millisecond
+	defaultReliableRetries = 10
+	defaultReliableBuffer  = 4 << 20
+)
+
+// ReliableConfig configures a Reliable.
+type ReliableConfig struct {
+	// Window caps the messages sent but not yet acknowledged; Write waits for
+	// acknowledgements while it is full. The receiver buffers out-of-order
+	// messages up to the same distance, so both peers should agree on it.
+	// Zero selects 64.
+	Window int
+
+	// Timeout is the retransmission timeout. Zero selects 200ms.
+	Timeout time.Duration
+
+	// MaxRetries bounds the retransmissions of one message before the
+	// Reliable fails with ErrNotAcknowledged. Zero selects 10.
+	MaxRetries int
+
+	// MaxBufferBytes bounds the payload bytes held for retransmission, and
+	// separately those held for reordering, so sustained loss cannot grow
+	// memory without limit: Write waits while the retransmit buffer is
+	// full, and early arrivals beyond the reorder budget are dropped and
+	// later retransmitted. Zero selects 4MiB.
+	MaxBufferBytes int
+}
+
+// Reliable provides ordered, reliable delivery of messages over a datagram
+// transport such as UDP: each message carries a sequence number, the
+// receiver returns cumulative ACK packets, at most Window messages are in
+// flight, and unacknowledged messages are sent again after Timeout.
+// Duplicates are dropped and early arrivals are held until the gap is filled.
+//
+// Retransmission is selective: each message has its own timer, and a
+// receiver that sees a gap sends a NACK listing the missing messages, which
+// the sender retransmits at once instead of waiting for their timers.
+//
+// Read returns whole messages in send order. Write queues a message, sends
+// it, and returns once it is in flight; Flush waits until everything written
+// is acknowledged. Both peers must use Reliable.
+//
+// Timers advance only inside Read, Write and Flush, which also process the
+// packets of the other direction, so a Reliable is driven by one goroutine.
+// On transports with SetReadDeadline, such as net.Conn, Reliable sets the
+// read deadline to the next retransmission time and clears it when nothing is
+// in flight. On other transports ErrWouldBlock follows the RetryDelay and
+// WaitFunc policy of opts.
+type Reliable struct {
+	rw         *ReadWriter
+	deadline   interface{ SetReadDeadline(time.Time) error }
+	cfg        ReliableConfig
+	waitFunc   func(Direction) error
+	retryDelay time.Duration
+	buf        []byte // receive scratch
+
+	seq          uint32         // sequence number of the next new message
+	pending      []*reliableMsg // in flight, oldest first, consecutive
+	pendingBytes int
+
+	expect     uint32            // next in-order sequence number
+	highest    uint32            // highest sequence number received; stale once expect passes it
+	early      map[uint32][]byte // messages received ahead of expect
+	earlyBytes int
+	ready      [][]byte // in-order messages not yet read
+
+	err error // sticky transport or retransmission failure
+}
+
+type reliableMsg struct {
+	seq   uint32
+	pkt   []byte    // header and payload
+	sent  time.Time // last transmission; zero until sent
+	tries int       // transmissions so far
+}
+
+// NewReliable returns a Reliable over the datagram transport t. The
+// transport is framed with opts in Datagram mode unless opts select another
+// packet-preserving protocol. ReadLimit bounds the received message size;
+// larger packets are dropped.
+func NewReliable(t io.ReadWriter, cfg ReliableConfig, opts ...Option) *Reliable {
+	o := defaultOptions
+	for _, fn := range opts {
+		fn(&o)
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = defaultReliableWindow
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultReliableTimeout
+	}
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = defaultReliableRetries
+	}
+	if cfg.MaxBufferBytes <= 0 {
+		cfg.MaxBufferBytes = defaultReliableBuffer
+	}
+	size := 64 * 1024
+	all := make([]Option, 0, len(opts)+4)
+	all = append(all, WithProtocol(Datagram))
+	all = append(all, opts...)
+	all = append(all, WithNonblock(), WithWaitFunc(nil))
+	if o.ReadLimit > 0 {
+		// ReadLimit bounds the message; the packet adds its header.
+		size = o.ReadLimit + reliableHeader
+		all = append(all, WithReadLimit(size))
+	}
+	c := &Reliable{
+		rw:         NewReadWriter(t, t, all...).(*ReadWriter),
+		cfg:        cfg,
+		waitFunc:   o.WaitFunc,
+		retryDelay: o.RetryDelay,
+		buf:        make([]byte, size),
+	}
+	c.deadline, _ = t.(interface{ SetReadDeadline(time.Time) error })
+	return c
+}
+
+// Write sends p as one message. It waits for acknowledgements while the
+// window or the retransmit buffer is full; with a non-blocking policy it then
+// returns ErrWouldBlock with nothing queued.
+func (c *Reliable) Write(p []byte) (int, error) {
+	for len(c.pending) >= c.cfg.Window ||
+		(len(c.pending) > 0 && c.pendingBytes+len(p) > c.cfg.MaxBufferBytes) {
+		if c.err != nil {
+			return 0, c.err
+		}
+		if err := c.poll(DirWrite); err != nil {
+			return 0, err
+		}
+	}
+	if c.err != nil {
+		return 0, c.err
+	}
+	m := &reliableMsg{seq: c.seq, pkt: make([]byte, reliableHeader+len(p))}
+	binary.BigEndian.PutUint32(m.pkt[1:], m.seq)
+	copy(m.pkt[reliableHeader:], p)
+	c.seq++
+	c.pending = append(c.pending, m)
+	c.pendingBytes += len(p)
+	if err := c.send(m, time.Now()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Read receives the next message in order into p. It returns
+// io.ErrShortBuffer, keeping the message, when p is too small.
+func (c *Reliable) Read(p []byte) (int, error) {
+	for len(c.ready) == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+		if err := c.poll(DirRead); err != nil {
+			return 0, err
+		}
+	}
+	m := c.ready[0]
+	if len(p) < len(m) {
+		return 0, io.ErrShortBuffer
+	}
+	n := copy(p, m)
+	c.ready[0] = nil
+	c.ready = c.ready[1:]
+	return n, nil
+}
+
+// Flush waits until every written message is acknowledged.
+func (c *Reliable) Flush() error {
+	for len(c.pending) > 0 {
+		if c.err != nil {
+			return c.err
+		}
+		if err := c.poll(DirWrite); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the transport. Unacknowledged messages are abandoned.
+func (c *Reliable) Close() error {
+	if c.err == nil {
+		c.err = ErrClosed
+	}
+	return c.rw.Close()
+}
+
+// poll retransmits what is due and processes at most one incoming packet.
+func (c *Reliable) poll(dir Direction) error {
+	now := time.Now()
+	if err := c.retransmit(now); err != nil {
+		return err
+	}
+	if c.deadline != nil {
+		_ = c.deadline.SetReadDeadline(c.nextDue(now))
+	}
+	n, err := c.rw.Reader.Read(c.buf)
+	switch {
+	case err == nil:
+		return c.handle(c.buf[:n])
+	case errors.Is(err, os.ErrDeadlineExceeded), errors.Is(err, ErrTruncated), err == ErrTooLong:
+		// A retransmission is due, or an oversized packet was dropped.
+		return nil
+	case err == ErrWouldBlock:
+		retry, werr := waitOnce(c.waitFunc, c.retryDelay, dir)
+		if werr != nil {
+			return werr
+		}
+		if !retry {
+			return ErrWouldBlock
+		}
+		return nil
+	default:
+		c.err = err
+		return err
+	}
+}
+
+// handle processes one received packet.
+func (c *Reliable) handle(pkt []byte) error {
+	if len(pkt) < reliableHeader {
+		return nil
+	}
+	seq := binary.BigEndian.Uint32(pkt[1:])
+	if pkt[0]&flagControl != 0 {
+		c.acknowledge(seq)
+		if pkt[0]&reliableNack != 0 {
+			return c.resend(pkt[reliableHeader:])
+		}
+		return nil
+	}
+	var missing []uint32
+	switch d := int32(seq - c.expect); {
+	case d == 0:
+		c.ready = append(c.ready, append([]byte(nil), pkt[reliableHeader:]...))
+		c.expect++
+		for {
+			m, ok := c.early[c.expect]
+			if !ok {
+				break
+			}
+			delete(c.early, c.expect)
+			c.earlyBytes -= len(m)
+			c.ready = append(c.ready, m)
+			c.expect++
+		}
+	case d > 0 && int(d) < c.cfg.Window:
+		// Report the sequence numbers this arrival newly shows missing.
+		from := c.expect
+		if int32(c.highest-c.expect) >= 0 {
+			from = c.highest + 1
+		}
+		for s := from; int32(seq-s) > 0 && len(missing) < reliableMaxNack; s++ {
+			missing = append(missing, s)
+		}
+		if c.early == nil {
+			c.early = make(map[uint32][]byte)
+		}
+		body := pkt[reliableHeader:]
+		if _, dup := c.early[seq]; !dup && c.earlyBytes+len(body) <= c.cfg.MaxBufferBytes {
+			c.early[seq] = append([]byte(nil), body...)
+			c.earlyBytes += len(body)
+		}
+	}
+	if int32(seq-c.highest) > 0 || int32(c.expect-c.highest) > 0 {
+		c.highest = seq
+	}
+	// Acknowledge duplicates too: the previous ACK may have been lost.
+	return c.sendAck(missing)
+}
+
+// sendAck sends a cumulative ACK, as a NACK when missing is not empty.
+func (c *Reliable) sendAck(missing []uint32) error {
+	var buf [reliableHeader + 4*reliableMaxNack]byte
+	buf[0] = flagControl
+	if len(missing) > 0 {
+		buf[0] |= reliableNack
+	}
+	binary.BigEndian.PutUint32(buf[1:], c.expect)
+	for i, s := range missing {
+		binary.BigEndian.PutUint32(buf[reliableHeader+4*i:], s)
+	}
+	if _, err := c.rw.Writer.Write(buf[:reliableHeader+4*len(missing)]); err != nil && err != ErrWouldBlock {
+		c.err = err
+		return err
+	}
+	return nil
+}
+
+// acknowledge drops the in-flight messages before next.
+func (c *Reliable) acknowledge(next uint32) {
+	i := 0
+	for i < len(c.pending) && int32(c.pending[i].seq-next) < 0 {
+		c.pendingBytes -= len(c.pending[i].pkt) - reliableHeader
+		c.pending[i] = nil
+		i++
+	}
+	c.pending = c.pending[i:]
+}
+
+// resend retransmits the in-flight messages listed in a NACK.
+func (c *Reliable) resend(list []byte) error {
+	if len(c.pending) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for ; len(list) >= 4; list = list[4:] {
+		i := int(int32(binary.BigEndian.Uint32(list) - c.pending[0].seq))
+		if i < 0 || i >= len(c.pending) {
+			continue
+		}
+		m := c.pending[i]
+		if m.tries > c.cfg.MaxRetries {
+			c.err = ErrNotAcknowledged
+			return c.err
+		}
+		if err := c.send(m, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retransmit sends the in-flight messages whose timeout has expired.
+func (c *Reliable) retransmit(now time.Time) error {
+	for _, m := range c.pending {
+		if !m.sent.IsZero() && now.Sub(m.sent) < c.cfg.Timeout {
+			continue
+		}
+		if m.tries > c.cfg.MaxRetries {
+			c.err = ErrNotAcknowledged
+			return c.err
+		}
+		if err := c.send(m, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send transmits m. A transport that would block leaves m due for the next
+// retransmission pass.
+func (c *Reliable) send(m *reliableMsg, now time.Time) error {
+	_, err := c.rw.Writer.Write(m.pkt)
+	switch err {
+	case nil:
+		m.sent = now
+		m.tries++
+		return nil
+	case ErrWouldBlock:
+		return nil
+	default:
+		c.err = err
+		return err
+	}
+}
+
+// nextDue returns when the earliest retransmission is due, or the zero time
+// when nothing is in flight.
+func (c *Reliable) nextDue(now time.Time) time.Time {
+	var due time.Time
+	for _, m := range c.pending {
+		t := m.sent.Add(c.cfg.Timeout)
+		if m.sent.IsZero() {
+			t = now
+		}
+		if due.IsZero() || t.Before(due) {
+			due = t
+		}
+	}
+	return due
+}
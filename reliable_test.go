@@ -0,0 +1,263 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	fr "code.hybscloud.com/framer"
+)
+
+// lossyLink is one direction of an in-memory datagram link that drops the
+// packets for which drop returns true.
+type lossyLink struct {
+	mu   sync.Mutex
+	q    [][]byte
+	sent int
+	drop func(n int) bool
+}
+
+// lossyEnd is one endpoint of a pair of lossyLinks. Read never blocks.
+type lossyEnd struct{ in, out *lossyLink }
+
+func (e *lossyEnd) Read(p []byte) (int, error) {
+	e.in.mu.Lock()
+	defer e.in.mu.Unlock()
+	if len(e.in.q) == 0 {
+		return 0, fr.ErrWouldBlock
+	}
+	n := copy(p, e.in.q[0])
+	e.in.q = e.in.q[1:]
+	return n, nil
+}
+
+func (e *lossyEnd) Write(p []byte) (int, error) {
+	e.out.mu.Lock()
+	defer e.out.mu.Unlock()
+	e.out.sent++
+	if e.out.drop == nil || !e.out.drop(e.out.sent) {
+		e.out.q = append(e.out.q, append([]byte(nil), p...))
+	}
+	return len(p), nil
+}
+
+func lossyPair(drop func(n int) bool) (a, b *lossyEnd) {
+	ab, ba := &lossyLink{drop: drop}, &lossyLink{drop: drop}
+	return &lossyEnd{in: ba, out: ab}, &lossyEnd{in: ab, out: ba}
+}
+
+func TestReliable_OrderedDeliveryOverLossyLink(t *testing.T) {
+	a, b := lossyPair(func(n int) bool { return n%3 == 0 })
+	cfg := fr.ReliableConfig{Window: 8, Timeout: 2 * time.Millisecond, MaxRetries: 50}
+	const count = 100
+
+	done := make(chan struct{})
+	got := make(chan error, 1)
+	go func() {
+		r := fr.NewReliable(b, cfg)
+		buf := make([]byte, 16)
+		for i := 0; i < count; {
+			n, err := r.Read(buf)
+			if err == fr.ErrWouldBlock {
+				continue
+			}
+			if err != nil || n != 4 || binary.BigEndian.Uint32(buf) != uint32(i) {
+				got <- err
+				return
+			}
+			i++
+		}
+		got <- nil
+		// Keep acknowledging retransmissions until the sender is done.
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				_, _ = r.Read(buf)
+			}
+		}
+	}()
+
+	w := fr.NewReliable(a, cfg, fr.WithBlock())
+	var msg [4]byte
+	for i := 0; i < count; i++ {
+		binary.BigEndian.PutUint32(msg[:], uint32(i))
+		if _, err := w.Write(msg[:]); err != nil {
+			t.Fatalf("Write %d: %v", i, err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	close(done)
+	if err := <-got; err != nil {
+		t.Fatalf("receiver: %v", err)
+	}
+}
+
+func TestReliable_WindowAndRetryBudget(t *testing.T) {
+	a, _ := lossyPair(func(int) bool { return true })
+	w := fr.NewReliable(a, fr.ReliableConfig{Window: 2, Timeout: time.Millisecond, MaxRetries: 2})
+	for i := 0; i < 2; i++ {
+		if _, err := w.Write([]byte("x")); err != nil {
+			t.Fatalf("Write %d: %v", i, err)
+		}
+	}
+	if _, err := w.Write([]byte("x")); err != fr.ErrWouldBlock {
+		t.Fatalf("full window: err=%v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	var err error
+	for err = w.Flush(); err == fr.ErrWouldBlock && time.Now().Before(deadline); err = w.Flush() {
+		time.Sleep(time.Millisecond)
+	}
+	if err != fr.ErrNotAcknowledged {
+		t.Fatalf("Flush: err=%v", err)
+	}
+	if _, err := w.Write([]byte("x")); err != fr.ErrNotAcknowledged {
+		t.Fatalf("Write after failure: err=%v", err)
+	}
+}
+
+// udpPeer writes to a fixed address through an unconnected socket.
+type udpPeer struct {
+	*net.UDPConn
+	to net.Addr
+}
+
+func (u udpPeer) Write(p []byte) (int, error) { return u.WriteTo(p, u.to) }
+
+func TestReliable_UDPWithDeadlines(t *testing.T) {
+	srv, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skipf("udp unavailable: %v", err)
+	}
+	cli, err := net.DialUDP("udp", nil, srv.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		_ = srv.Close()
+		t.Skipf("udp unavailable: %v", err)
+	}
+	r := fr.NewReliable(udpPeer{srv, cli.LocalAddr()}, fr.ReliableConfig{Timeout: 20 * time.Millisecond})
+	w := fr.NewReliable(cli, fr.ReliableConfig{Timeout: 20 * time.Millisecond})
+	defer r.Close()
+	defer w.Close()
+
+	const count = 50
+	got := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 64)
+		for i := 0; i < count; i++ {
+			n, err := r.Read(buf)
+			if err != nil {
+				got <- err
+				return
+			}
+			if !bytes.Equal(buf[:n], bytes.Repeat([]byte{byte(i)}, i)) {
+				got <- fr.ErrInvalidHeader
+				return
+			}
+		}
+		got <- nil
+	}()
+	for i := 0; i < count; i++ {
+		if _, err := w.Write(bytes.Repeat([]byte{byte(i)}, i)); err != nil {
+			t.Fatalf("Write %d: %v", i, err)
+		}
+	}
+	if err := <-got; err != nil {
+		t.Fatalf("receiver: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+}
+
+func TestReliable_NackTriggersSelectiveRetransmit(t *testing.T) {
+	// Lose the second data packet once; ACKs flow freely. The timeout is far
+	// beyond the test, so only a NACK can recover the message in time.
+	ab, ba := &lossyLink{drop: func(n int) bool { return n == 2 }}, &lossyLink{}
+	a, b := &lossyEnd{in: ba, out: ab}, &lossyEnd{in: ab, out: ba}
+	cfg := fr.ReliableConfig{Timeout: time.Minute}
+
+	got := make(chan []string, 1)
+	go func() {
+		r := fr.NewReliable(b, cfg, fr.WithBlock())
+		var msgs []string
+		buf := make([]byte, 16)
+		for len(msgs) < 5 {
+			n, err := r.Read(buf)
+			if err != nil {
+				break
+			}
+			msgs = append(msgs, string(buf[:n]))
+		}
+		got <- msgs
+	}()
+
+	w := fr.NewReliable(a, cfg, fr.WithBlock())
+	for _, m := range []string{"m0", "m1", "m2", "m3", "m4"} {
+		if _, err := w.Write([]byte(m)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	start := time.Now()
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Fatalf("recovery waited %v", d)
+	}
+	msgs := <-got
+	if len(msgs) != 5 || msgs[1] != "m1" || msgs[4] != "m4" {
+		t.Fatalf("messages=%q", msgs)
+	}
+	if ab.sent != 6 {
+		t.Fatalf("data packets sent=%d want 6 (one retransmission)", ab.sent)
+	}
+}
+
+func TestReliable_RetransmitBufferBound(t *testing.T) {
+	a, _ := lossyPair(func(int) bool { return true })
+	w := fr.NewReliable(a, fr.ReliableConfig{MaxBufferBytes: 10})
+	if _, err := w.Write(make([]byte, 6)); err != nil {
+		t.Fatalf("first Write: %v", err)
+	}
+	if _, err := w.Write(make([]byte, 6)); err != fr.ErrWouldBlock {
+		t.Fatalf("over budget: err=%v", err)
+	}
+	if _, err := w.Write(make([]byte, 4)); err != nil {
+		t.Fatalf("within budget: %v", err)
+	}
+}
+
+func TestReliable_ReadLimitCountsPayloadOnly(t *testing.T) {
+	a, b := lossyPair(nil)
+	cfg := fr.ReliableConfig{Timeout: time.Millisecond}
+	w := fr.NewReliable(a, cfg, fr.WithReadLimit(10))
+	r := fr.NewReliable(b, cfg, fr.WithReadLimit(10))
+	msgs := []string{"0123456789", "abcdefghij"}
+	for _, m := range msgs {
+		if _, err := w.Write([]byte(m)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	buf := make([]byte, 10)
+	deadline := time.Now().Add(5 * time.Second)
+	for _, want := range msgs {
+		n, err := r.Read(buf)
+		for err == fr.ErrWouldBlock && time.Now().Before(deadline) {
+			n, err = r.Read(buf)
+		}
+		if err != nil || string(buf[:n]) != want {
+			t.Fatalf("Read %q err=%v; want %q", buf[:n], err, want)
+		}
+	}
+}
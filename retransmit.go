@@ -0,0 +1,54 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+// WithRetransmitBuffer makes the Writer keep a copy of the last message
+// given to Write, WriteValue, WriteTyped or WriteUrgent, after interceptors,
+// in a reusable buffer, so that Writer.RewriteLast can resend it after a
+// transport error and a reconnect through SwapWriter without the
+// application keeping its own copy. The buffer grows to the largest
+// message and is accounted against the memory budget. Only the last message
+// is kept: with a write buffer, earlier messages buffered for the failed
+// transport are lost with it.
+func WithRetransmitBuffer() Option {
+	return func(o *Options) { o.RetransmitBuffer = true }
+}
+
+// RewriteLast writes the message kept by WithRetransmitBuffer again, framed
+// as before, typically to the transport installed by SwapWriter after the
+// previous one failed mid-message. It returns the payload bytes written, and
+// ErrInvalidArgument when no message is kept or another message is partially
+// written to the current transport. On ErrWouldBlock or ErrMore, call
+// RewriteLast again to resume.
+func (w *Writer) RewriteLast() (int, error) {
+	fr := w.fr
+	if !fr.enter() {
+		return 0, ErrConcurrentUse
+	}
+	defer fr.leave()
+	if !fr.rtx || !fr.rtxOn || (fr.offset != 0 && !fr.rewriting) {
+		return 0, ErrInvalidArgument
+	}
+	fr.rewriting = true
+	n, err := fr.write(fr.rtxBuf)
+	if err != ErrWouldBlock && err != ErrMore {
+		fr.rewriting = false
+	}
+	return n, err
+}
+
+// retain copies the message p being written into the retransmit buffer.
+func (fr *framer) retain(p []byte) error {
+	if cap(fr.rtxBuf) < len(p) {
+		var err error
+		if fr.rtxBuf, err = fr.growBuf(fr.rtxBuf, len(p)); err != nil {
+			return err
+		}
+	}
+	fr.rtxBuf = fr.rtxBuf[:len(p)]
+	copy(fr.rtxBuf, p)
+	fr.rtxOn = true
+	return nil
+}
@@ -0,0 +1,115 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"encoding/binary"
+	"io"
+	"sync/atomic"
+)
+
+// ringRecordHeader is the size of the length prefix of each queued message.
+const ringRecordHeader = 4
+
+// Ring is a lock-free single-producer, single-consumer message queue for
+// passing messages between two goroutines without channel overhead.
+//
+// Write queues one whole message or returns ErrWouldBlock when it does not
+// fit yet; Read returns one whole message or ErrWouldBlock when the ring is
+// empty. Each message occupies its payload plus a 4-byte length prefix of
+// the capacity. Neither side allocates or takes a lock.
+//
+// Exactly one goroutine may write and exactly one may read at a time; for
+// several producers or consumers use BufferedPipe. After Close, Write returns
+// ErrClosed while Read drains the queued messages and then returns io.EOF.
+type Ring struct {
+	buf       []byte
+	readLimit int64
+
+	_    [64]byte // keep the consumer and producer counters on separate cache lines
+	head atomic.Uint64
+	_    [56]byte
+	tail atomic.Uint64
+	_    [56]byte
+
+	closed atomic.Bool
+}
+
+// NewRing returns a ring of capacityBytes bytes. A non-positive capacity
+// selects 64KiB. Of opts, ReadLimit applies: longer messages are rejected by
+// Write with ErrTooLong.
+func NewRing(capacityBytes int, opts ...Option) *Ring {
+	o := defaultOptions
+	for _, fn := range opts {
+		fn(&o)
+	}
+	if capacityBytes <= 0 {
+		capacityBytes = defaultPipeCapacity
+	}
+	return &Ring{buf: make([]byte, capacityBytes), readLimit: int64(o.ReadLimit)}
+}
+
+// Write queues p as one message. It returns ErrTooLong when p can never fit
+// and ErrWouldBlock, with nothing queued, when the ring is too full for now.
+func (r *Ring) Write(p []byte) (int, error) {
+	if r.closed.Load() {
+		return 0, ErrClosed
+	}
+	need := uint64(ringRecordHeader + len(p))
+	size := uint64(len(r.buf))
+	if need > size || (r.readLimit > 0 && int64(len(p)) > r.readLimit) {
+		return 0, ErrTooLong
+	}
+	tail := r.tail.Load()
+	if need > size-(tail-r.head.Load()) {
+		return 0, ErrWouldBlock
+	}
+	var hdr [ringRecordHeader]byte
+	binary.LittleEndian.PutUint32(hdr[:], uint32(len(p)))
+	r.put(tail, hdr[:])
+	r.put(tail+ringRecordHeader, p)
+	r.tail.Store(tail + need)
+	return len(p), nil
+}
+
+// Read dequeues the oldest message into p. It returns ErrWouldBlock when the
+// ring is empty and io.ErrShortBuffer, leaving the message queued, when p is
+// smaller than the message.
+func (r *Ring) Read(p []byte) (int, error) {
+	head := r.head.Load()
+	if r.tail.Load() == head {
+		if r.closed.Load() && r.tail.Load() == head {
+			return 0, io.EOF
+		}
+		return 0, ErrWouldBlock
+	}
+	var hdr [ringRecordHeader]byte
+	r.get(head, hdr[:])
+	n := int(binary.LittleEndian.Uint32(hdr[:]))
+	if len(p) < n {
+		return 0, io.ErrShortBuffer
+	}
+	r.get(head+ringRecordHeader, p[:n])
+	r.head.Store(head + ringRecordHeader + uint64(n))
+	return n, nil
+}
+
+// Close stops further writes. Read returns io.EOF once the ring is drained.
+func (r *Ring) Close() error {
+	r.closed.Store(true)
+	return nil
+}
+
+// put copies p into the ring at logical position pos, wrapping around.
+func (r *Ring) put(pos uint64, p []byte) {
+	n := copy(r.buf[pos%uint64(len(r.buf)):], p)
+	copy(r.buf, p[n:])
+}
+
+// get copies len(p) bytes at logical position pos out of the ring.
+func (r *Ring) get(pos uint64, p []byte) {
+	n := copy(p, r.buf[pos%uint64(len(r.buf)):])
+	copy(p[n:], r.buf)
+}
@@ -0,0 +1,91 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer_test
+
+import (
+	"bytes"
+	"io"
+	"runtime"
+	"testing"
+
+	fr "code.hybscloud.com/framer"
+)
+
+func TestRing_WholeMessages(t *testing.T) {
+	r := fr.NewRing(16)
+	buf := make([]byte, 16)
+	if _, err := r.Read(buf); err != fr.ErrWouldBlock {
+		t.Fatalf("empty Read: err=%v", err)
+	}
+	if _, err := r.Write([]byte("abcdef")); err != nil { // 10 of 16 bytes
+		t.Fatalf("Write: %v", err)
+	}
+	if _, err := r.Write(nil); err != nil { // 14 of 16 bytes
+		t.Fatalf("empty Write: %v", err)
+	}
+	if _, err := r.Write([]byte("x")); err != fr.ErrWouldBlock {
+		t.Fatalf("full Write: err=%v", err)
+	}
+	if _, err := r.Write(make([]byte, 13)); err != fr.ErrTooLong {
+		t.Fatalf("oversized Write: err=%v", err)
+	}
+	if _, err := r.Read(buf[:3]); err != io.ErrShortBuffer {
+		t.Fatalf("short Read: err=%v", err)
+	}
+	if n, err := r.Read(buf); err != nil || string(buf[:n]) != "abcdef" {
+		t.Fatalf("Read: %q err=%v", buf[:n], err)
+	}
+	// The length prefix and payload of the next message wrap around.
+	if _, err := r.Write([]byte("wrapped")); err != nil {
+		t.Fatalf("wrapping Write: %v", err)
+	}
+	_ = r.Close()
+	if _, err := r.Write([]byte("late")); err != fr.ErrClosed {
+		t.Fatalf("Write after Close: err=%v", err)
+	}
+	for _, want := range []string{"", "wrapped"} {
+		if n, err := r.Read(buf); err != nil || string(buf[:n]) != want {
+			t.Fatalf("Read: %q err=%v want %q", buf[:n], err, want)
+		}
+	}
+	if _, err := r.Read(buf); err != io.EOF {
+		t.Fatalf("drained Read: err=%v want io.EOF", err)
+	}
+}
+
+func TestRing_ProducerConsumer(t *testing.T) {
+	r := fr.NewRing(100)
+	const count = 5000
+	go func() {
+		for i := 0; i < count; i++ {
+			msg := bytes.Repeat([]byte{byte(i)}, i%40)
+			for {
+				if _, err := r.Write(msg); err != fr.ErrWouldBlock {
+					break
+				}
+				runtime.Gosched()
+			}
+		}
+		_ = r.Close()
+	}()
+	buf := make([]byte, 64)
+	for i := 0; ; {
+		n, err := r.Read(buf)
+		if err == fr.ErrWouldBlock {
+			runtime.Gosched()
+			continue
+		}
+		if err == io.EOF {
+			if i != count {
+				t.Fatalf("EOF after %d messages", i)
+			}
+			return
+		}
+		if err != nil || !bytes.Equal(buf[:n], bytes.Repeat([]byte{byte(i)}, i%40)) {
+			t.Fatalf("message %d: %x err=%v", i, buf[:n], err)
+		}
+		i++
+	}
+}
@@ -0,0 +1,50 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+// WithRoutePrefix makes a Forwarder prepend prefix to every relayed payload,
+// for example to tag messages with the ingress connection ID before they
+// reach a shared backend. The prefix is copied.
+//
+// The Forwarder reserves room for the prefix in front of its buffer, so
+// routing costs no copy beyond the relay itself. Messages too large for the
+// buffer are not relayed in chunks (WithChunkedForward) while routing is
+// enabled, and fail with io.ErrShortBuffer as without it.
+func WithRoutePrefix(prefix []byte) Option {
+	prefix = append([]byte(nil), prefix...)
+	return WithRoutePrefixFunc(len(prefix), func(dst, _ []byte) { copy(dst, prefix) })
+}
+
+// WithRoutePrefixFunc makes a Forwarder prepend a size-byte prefix to every
+// relayed payload, filled by fn from the payload, such as a shard key or a
+// per-message sequence number. fn must not retain either slice. See
+// WithRoutePrefix.
+func WithRoutePrefixFunc(size int, fn func(prefix, payload []byte)) Option {
+	return func(o *Options) { o.RoutePrefixLen, o.RoutePrefix = size, fn }
+}
+
+// WithRouteStrip makes a Forwarder remove the first n bytes of every relayed
+// payload, such as a routing prefix added by an upstream hop, before any
+// WithRoutePrefix prefix is added. A message shorter than n is dropped and
+// ForwardOnce fails with ErrInvalidArgument.
+func WithRouteStrip(n int) Option {
+	return func(o *Options) { o.RouteStrip = n }
+}
+
+// routed reports whether the Forwarder rewrites payloads.
+func (f *Forwarder) routed() bool { return f.room > 0 || f.strip > 0 }
+
+// route prepares the framed payload of the buffered message: the kept bytes
+// behind the routing prefix, which is written into the headroom.
+func (f *Forwarder) route() error {
+	if f.need < f.strip {
+		return ErrInvalidArgument
+	}
+	f.out = f.head[f.strip : f.room+f.need]
+	if f.room > 0 {
+		f.prefix(f.out[:f.room], f.out[f.room:])
+	}
+	return nil
+}
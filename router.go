@@ -0,0 +1,168 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"fmt"
+	"io"
+	"runtime/debug"
+)
+
+// Typed messages carry a one-byte type as the first payload byte, followed by
+// the type-specific body. The type byte is part of the payload, so typed
+// messages use the regular wire format and ReadLimit counts it.
+
+// WriteTyped writes body as one message whose first payload byte is typ.
+//
+// The message is assembled in a reusable scratch buffer. On ErrWouldBlock or
+// ErrMore, retry with the same typ and body. The returned count is the number
+// of body bytes written; the type byte is not counted.
+func (w *Writer) WriteTyped(typ byte, body []byte) (int, error) {
+	fr := w.fr
+	if !fr.enter() {
+		return 0, ErrConcurrentUse
+	}
+	defer fr.leave()
+	need := 1 + len(body)
+	if cap(fr.tbuf) < need {
+		var err error
+		if fr.tbuf, err = fr.growBuf(fr.tbuf, need); err != nil {
+			return 0, err
+		}
+	}
+	msg := fr.tbuf[:need]
+	msg[0] = typ
+	copy(msg[1:], body)
+	n, err := fr.write(msg)
+	return max(n-1, 0), err
+}
+
+// PanicError reports a handler panic recovered by a Router.
+type PanicError struct {
+	Type  byte
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("framer: handler for type 0x%02x panicked: %v", e.Type, e.Value)
+}
+
+// routerBufSize is the initial size of the Router read buffer, and its bound
+// when the Reader has no ReadLimit.
+const routerBufSize = 64 * 1024
+
+// Router dispatches typed messages to handlers registered per type byte.
+//
+// Handlers receive the message body, without the type byte. The body aliases
+// the Router's read buffer and is only valid until the handler returns.
+// Register handlers before Serve or Dispatch; a Router is not safe for
+// concurrent registration and dispatch.
+type Router struct {
+	handlers [256]func(body []byte) error
+	unknown  func(typ byte, body []byte) error
+
+	// OnPanic, when non-nil, is called by Serve for every recovered handler
+	// panic. Serve continues with the next message either way.
+	OnPanic func(err *PanicError)
+
+	buf []byte
+	got int // payload bytes of the in-flight message read by earlier calls
+}
+
+// NewRouter returns an empty Router.
+func NewRouter() *Router { return &Router{} }
+
+// Handle registers fn for messages of type typ, replacing any previous
+// handler. A nil fn removes the handler.
+func (rt *Router) Handle(typ byte, fn func(body []byte) error) {
+	rt.handlers[typ] = fn
+}
+
+// HandleUnknown registers the fallback for types without a handler. Without a
+// fallback such messages are dropped.
+func (rt *Router) HandleUnknown(fn func(typ byte, body []byte) error) {
+	rt.unknown = fn
+}
+
+// Dispatch routes one message payload. Empty messages carry no type and are
+// ignored. A handler panic is recovered and returned as a *PanicError.
+func (rt *Router) Dispatch(msg []byte) (err error) {
+	if len(msg) == 0 {
+		return nil
+	}
+	typ, body := msg[0], msg[1:]
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{Type: typ, Value: v, Stack: debug.Stack()}
+		}
+	}()
+	if fn := rt.handlers[typ]; fn != nil {
+		return fn(body)
+	}
+	if rt.unknown != nil {
+		return rt.unknown(typ, body)
+	}
+	return nil
+}
+
+// Serve reads messages from r and dispatches them until r fails or a handler
+// returns an error. A clean io.EOF at a message boundary returns nil.
+//
+// The read buffer starts at 64KiB and doubles on io.ErrShortBuffer up to the
+// ReadLimit of r, and a larger message fails with ErrTooLong; without a
+// ReadLimit, or when r is not a framer Reader, it stays at 64KiB. On
+// ErrWouldBlock or ErrMore, Serve returns the error and the next call resumes
+// the in-flight message; call it again on the same Router with the same r.
+func (rt *Router) Serve(r io.Reader) error {
+	if r == nil {
+		return ErrInvalidArgument
+	}
+	limit := routerBufSize
+	if l, ok := r.(interface{ messageLimit() int }); ok {
+		limit = l.messageLimit()
+	}
+	if rt.buf == nil {
+		rt.buf = make([]byte, routerBufSize)
+	}
+	for {
+		n, err := r.Read(rt.buf)
+		if err == io.ErrShortBuffer && n == 0 {
+			if len(rt.buf) >= limit {
+				return ErrTooLong
+			}
+			rt.buf = make([]byte, min(2*len(rt.buf), limit))
+			continue
+		}
+		if err != nil {
+			if err == io.EOF && n == 0 {
+				return nil
+			}
+			if err != io.EOF {
+				// Read reports per-call progress; keep it for the resumed call.
+				rt.got += n
+				return err
+			}
+		}
+		n += rt.got
+		rt.got = 0
+		if derr := rt.Dispatch(rt.buf[:n]); derr != nil {
+			pe, ok := derr.(*PanicError)
+			if !ok {
+				return derr
+			}
+			if rt.OnPanic != nil {
+				rt.OnPanic(pe)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// messageLimit returns the largest message buffer Router.Serve grows for r:
+// ReadLimit, or 64KiB when there is none.
+func (r *Reader) messageLimit() int { return r.fr.scratchSize() }
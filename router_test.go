@@ -0,0 +1,117 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	fr "code.hybscloud.com/framer"
+)
+
+func TestRouter_DispatchesByType(t *testing.T) {
+	var wire bytes.Buffer
+	w := fr.NewWriter(&wire, fr.WithWriteTCP()).(*fr.Writer)
+	big := bytes.Repeat([]byte("z"), 70*1024) // forces the read buffer to grow
+	for _, m := range []struct {
+		typ  byte
+		body []byte
+	}{{0x01, []byte("ping")}, {0x02, []byte("data")}, {0x7f, []byte("odd")}, {0x02, big}} {
+		n, err := w.WriteTyped(m.typ, m.body)
+		if err != nil || n != len(m.body) {
+			t.Fatalf("WriteTyped(0x%02x): n=%d err=%v", m.typ, n, err)
+		}
+	}
+	if _, err := w.Write(nil); err != nil { // untyped keepalive
+		t.Fatalf("write: %v", err)
+	}
+
+	var got []string
+	rt := fr.NewRouter()
+	rt.Handle(0x01, func(b []byte) error { got = append(got, "1:"+string(b)); return nil })
+	rt.Handle(0x02, func(b []byte) error { got = append(got, "2:"+string(b[:4])); return nil })
+	rt.HandleUnknown(func(typ byte, b []byte) error {
+		got = append(got, "?:"+string(b))
+		return nil
+	})
+	if err := rt.Serve(fr.NewReader(&wire, fr.WithReadTCP(), fr.WithReadLimit(1<<17))); err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+	want := []string{"1:ping", "2:data", "?:odd", "2:zzzz"}
+	if len(got) != len(want) {
+		t.Fatalf("got %q want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %q want %q", got, want)
+		}
+	}
+}
+
+func TestRouter_PanicIsolationAndHandlerError(t *testing.T) {
+	var wire bytes.Buffer
+	w := fr.NewWriter(&wire, fr.WithWriteTCP()).(*fr.Writer)
+	for _, typ := range []byte{0x01, 0x02, 0x03, 0x02} {
+		if _, err := w.WriteTyped(typ, []byte{typ}); err != nil {
+			t.Fatalf("WriteTyped: %v", err)
+		}
+	}
+
+	errStop := errors.New("stop")
+	var panics []*fr.PanicError
+	seen := 0
+	rt := fr.NewRouter()
+	rt.OnPanic = func(pe *fr.PanicError) { panics = append(panics, pe) }
+	rt.Handle(0x01, func([]byte) error { panic("bad message") })
+	rt.Handle(0x02, func([]byte) error { seen++; return nil })
+	rt.Handle(0x03, func([]byte) error { return errStop })
+
+	if err := rt.Serve(fr.NewReader(&wire, fr.WithReadTCP())); err != errStop {
+		t.Fatalf("Serve: want errStop, got %v", err)
+	}
+	if seen != 1 || len(panics) != 1 || panics[0].Type != 0x01 || panics[0].Value != "bad message" {
+		t.Fatalf("seen=%d panics=%v", seen, panics)
+	}
+
+	if err := rt.Dispatch([]byte{0x01}); err == nil {
+		t.Fatal("Dispatch: want PanicError")
+	} else if pe, ok := err.(*fr.PanicError); !ok || len(pe.Stack) == 0 {
+		t.Fatalf("Dispatch: got %T %v", err, err)
+	}
+	if err := rt.Dispatch(nil); err != nil {
+		t.Fatalf("Dispatch(empty): %v", err)
+	}
+}
+
+func TestRouter_ResumesAfterWouldBlock(t *testing.T) {
+	wire := []byte{4, 0x09, 'a', 'b', 'c'}
+	var got string
+	rt := fr.NewRouter()
+	rt.Handle(0x09, func(b []byte) error { got = string(b); return nil })
+	r := fr.NewReader(&wouldBlockMidPayloadReader{wire: wire, blockAfter: 3}, fr.WithReadTCP())
+	if err := rt.Serve(r); err != fr.ErrWouldBlock {
+		t.Fatalf("first Serve: want ErrWouldBlock, got %v", err)
+	}
+	if err := rt.Serve(r); err != nil || got != "abc" {
+		t.Fatalf("second Serve: err=%v got=%q", err, got)
+	}
+}
+
+func TestRouter_BufferGrowthBoundedByReadLimit(t *testing.T) {
+	var wire bytes.Buffer
+	w := fr.NewWriter(&wire).(*fr.Writer)
+	if _, err := w.WriteTyped(0x01, make([]byte, 100000)); err != nil {
+		t.Fatalf("WriteTyped: %v", err)
+	}
+	rt := fr.NewRouter()
+	rt.Handle(0x01, func([]byte) error { t.Fatal("oversized message dispatched"); return nil })
+	if err := rt.Serve(fr.NewReader(bytes.NewReader(wire.Bytes()))); err != fr.ErrTooLong {
+		t.Fatalf("without ReadLimit: err=%v", err)
+	}
+	if err := rt.Serve(fr.NewReader(bytes.NewReader(wire.Bytes()), fr.WithReadLimit(1<<16+1))); err != fr.ErrTooLong {
+		t.Fatalf("over ReadLimit: err=%v", err)
+	}
+}
@@ -0,0 +1,159 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import "encoding/binary"
+
+// segHeader precedes every segment: big-endian message number, segment index
+// and segment count.
+const (
+	segHeader   = 8
+	segMaxCount = 1<<16 - 1
+)
+
+// WithSegmentation lets packet-preserving modes carry messages larger than
+// the path MTU: the Writer splits every message into numbered segments whose
+// datagrams, headers of this and the other packet options included, fit in
+// mtu bytes, and the Reader reassembles them into one message. Both peers
+// must enable it; each segment costs 8 bytes.
+//
+// The Reader assembles one message at a time. A message that loses a
+// segment, or is overtaken by the next one before it is complete, is
+// dropped; combine it with WithFEC or Reliable on lossy paths. ReadLimit
+// applies to the reassembled message, and a message exceeding it is dropped
+// with ErrTooLong. On ErrWouldBlock or ErrMore, Write reports the bytes
+// already sent; retry with the same p to send the rest. It has no effect in
+// stream mode.
+func WithSegmentation(mtu int) Option {
+	return func(o *Options) { o.SegmentMTU = mtu }
+}
+
+// segWriter splits messages into segments, resuming a message interrupted by
+// ErrWouldBlock at the next unsent segment.
+type segWriter struct {
+	size int    // payload bytes per segment
+	id   uint32 // number of the current message
+	next int    // next segment of the current message to send
+	pkt  []byte // segment scratch
+}
+
+func newSegWriter(o Options) *segWriter {
+	overhead := segHeader
+	if o.DedupWindow > 0 {
+		overhead += dedupHeader
+	}
+	if o.FECGroup > 0 {
+		overhead += fecHeader + 4 // parity packets add a length prefix
+	}
+	return &segWriter{size: max(1, o.SegmentMTU-overhead)}
+}
+
+func (s *segWriter) write(fr *framer, p []byte) (int, error) {
+	count := max(1, (len(p)+s.size-1)/s.size)
+	if count > segMaxCount {
+		return 0, ErrTooLong
+	}
+	for s.next < count {
+		chunk := p[s.next*s.size : min(len(p), (s.next+1)*s.size)]
+		s.pkt = binary.BigEndian.AppendUint32(s.pkt[:0], s.id)
+		s.pkt = binary.BigEndian.AppendUint16(s.pkt, uint16(s.next))
+		s.pkt = binary.BigEndian.AppendUint16(s.pkt, uint16(count))
+		s.pkt = append(s.pkt, chunk...)
+		if sent, err := fr.writeUnit(s.pkt); err != nil {
+			if sent == len(s.pkt) {
+				s.next++
+			}
+			n := min(len(p), s.next*s.size)
+			if err != ErrWouldBlock && err != ErrMore {
+				s.next = 0
+				s.id++
+			}
+			return n, err
+		}
+		s.next++
+	}
+	s.next = 0
+	s.id++
+	return len(p), nil
+}
+
+// segReader reassembles the segments of one message.
+type segReader struct {
+	buf    []byte // packet scratch
+	active bool
+	id     uint32
+	count  int
+	got    int
+	total  int
+	spans  [][2]int // offset and length of each segment in data; length -1 until received
+	data   []byte
+}
+
+func (s *segReader) read(fr *framer, p []byte) (int, error) {
+	for {
+		n, err := fr.readUnit(s.buf)
+		if _, ok := err.(*TruncatedError); ok {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if n < segHeader {
+			continue
+		}
+		id := binary.BigEndian.Uint32(s.buf)
+		index := int(binary.BigEndian.Uint16(s.buf[4:]))
+		count := int(binary.BigEndian.Uint16(s.buf[6:]))
+		body := s.buf[segHeader:n]
+		if count == 0 || index >= count {
+			continue
+		}
+		if count == 1 {
+			s.active = false
+			return deliverPacket(p, body)
+		}
+		if !s.active || id != s.id {
+			if s.active && int32(id-s.id) < 0 {
+				continue // a straggler of a message already given up
+			}
+			s.begin(id, count)
+		}
+		if count != s.count || s.spans[index][1] >= 0 {
+			continue
+		}
+		if fr.readLimit > 0 && int64(s.total+len(body)) > fr.readLimit {
+			s.active = false
+			return 0, ErrTooLong
+		}
+		s.spans[index] = [2]int{len(s.data), len(body)}
+		s.data = append(s.data, body...)
+		s.total += len(body)
+		if s.got++; s.got < s.count {
+			continue
+		}
+		s.active = false
+		off := 0
+		for _, sp := range s.spans {
+			off += copy(p[off:], s.data[sp[0]:sp[0]+sp[1]])
+		}
+		if off < s.total {
+			return off, &TruncatedError{Length: int64(s.total), Received: int64(off)}
+		}
+		return off, nil
+	}
+}
+
+func (s *segReader) begin(id uint32, count int) {
+	s.active = true
+	s.id = id
+	s.count = count
+	s.got = 0
+	s.total = 0
+	s.data = s.data[:0]
+	s.spans = s.spans[:0]
+	for range count {
+		s.spans = append(s.spans, [2]int{0, -1})
+	}
+}
@@ -0,0 +1,102 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer_test
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"testing"
+
+	fr "code.hybscloud.com/framer"
+)
+
+// blockEveryOtherPacket refuses every other packet with ErrWouldBlock.
+type blockEveryOtherPacket struct {
+	*lossyEnd
+	calls int
+}
+
+func (b *blockEveryOtherPacket) Write(p []byte) (int, error) {
+	if b.calls++; b.calls%2 == 1 {
+		return 0, fr.ErrWouldBlock
+	}
+	return b.lossyEnd.Write(p)
+}
+
+func TestSegmentation_SplitsAndReassembles(t *testing.T) {
+	big := bytes.Repeat([]byte("0123456789"), 100)
+	msgs := [][]byte{big, []byte("small"), {}}
+	cases := []struct {
+		name string
+		opts []fr.Option
+		segs int // packets of big, parity included
+		wb   bool
+	}{
+		{"plain, would-block writer", []fr.Option{fr.WithSegmentation(100)}, 11, true},
+		// 100 - 8 - 4 - 10 leaves 78 bytes per segment: 13 segments and 4 parity packets.
+		{"with dedup and FEC", []fr.Option{fr.WithSegmentation(100), fr.WithDedup(0), fr.WithFEC(3)}, 17, false},
+	}
+	for _, tc := range cases {
+		a, b := lossyPair(nil)
+		opts := append([]fr.Option{fr.WithProtocol(fr.Datagram)}, tc.opts...)
+		var tw io.Writer = a
+		if tc.wb {
+			tw = &blockEveryOtherPacket{lossyEnd: a}
+		}
+		w := fr.NewWriter(tw, opts...)
+		for _, m := range msgs {
+			done := 0
+			for {
+				n, err := w.Write(m)
+				if err == nil {
+					break
+				}
+				if err != fr.ErrWouldBlock || n < done {
+					t.Fatalf("%s: Write: n=%d err=%v", tc.name, n, err)
+				}
+				done = n
+			}
+		}
+		for i, p := range a.out.q {
+			if len(p) > 100 {
+				t.Fatalf("%s: packet %d is %d bytes", tc.name, i, len(p))
+			}
+		}
+		// Reverse the packets of the big message.
+		slices.Reverse(a.out.q[:tc.segs])
+
+		r := fr.NewReader(b, opts...)
+		buf := make([]byte, 2000)
+		for _, want := range msgs {
+			n, err := r.Read(buf)
+			if err != nil || !bytes.Equal(buf[:n], want) {
+				t.Fatalf("%s: Read: %d bytes err=%v, want %d bytes", tc.name, n, err, len(want))
+			}
+		}
+		if n, err := r.Read(buf); err != fr.ErrWouldBlock {
+			t.Fatalf("%s: extra message: n=%d err=%v", tc.name, n, err)
+		}
+	}
+}
+
+func TestSegmentation_LossDropsMessageAndReadLimit(t *testing.T) {
+	a, b := lossyPair(func(n int) bool { return n == 2 })
+	opts := []fr.Option{fr.WithProtocol(fr.Datagram), fr.WithSegmentation(20)}
+	w := fr.NewWriter(a, opts...)
+	_, _ = w.Write(bytes.Repeat([]byte{'a'}, 30)) // 3 segments, the second lost
+	_, _ = w.Write(bytes.Repeat([]byte{'b'}, 30))
+	_, _ = w.Write(bytes.Repeat([]byte{'c'}, 50))
+
+	r := fr.NewReader(b, append(opts, fr.WithReadLimit(40))...)
+	buf := make([]byte, 64)
+	n, err := r.Read(buf)
+	if err != nil || !bytes.Equal(buf[:n], bytes.Repeat([]byte{'b'}, 30)) {
+		t.Fatalf("after loss: %q err=%v", buf[:n], err)
+	}
+	if _, err := r.Read(buf); err != fr.ErrTooLong {
+		t.Fatalf("over ReadLimit: err=%v", err)
+	}
+}
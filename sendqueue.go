@@ -0,0 +1,216 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"io"
+	"sync"
+)
+
+// defaultSendQueueBytes is the SendQueue budget when none is given.
+const defaultSendQueueBytes = 1 << 20
+
+// SendQueueConfig configures a SendQueue.
+type SendQueueConfig struct {
+	// MaxPending bounds the payload bytes queued and not yet written,
+	// including the message being written. Zero selects 1MiB.
+	MaxPending int
+
+	// Block makes Write wait for room when the budget is full instead of
+	// returning ErrWouldBlock.
+	Block bool
+}
+
+// SendQueue is an asynchronous front-end to a Writer with a bounded byte
+// budget, the per-connection send queue of servers that must not stall on a
+// slow peer: Write copies the message into the queue and returns at once,
+// and a background goroutine writes queued messages in order, waiting out
+// ErrWouldBlock. Once the budget is full, Write returns ErrWouldBlock, or
+// waits with Block, which is the backpressure signal to the producer.
+//
+// A write failure of the background goroutine drops the queue; Write and
+// Close then report it. SendQueue is safe for concurrent use.
+type SendQueue struct {
+	w     *Writer
+	limit int
+	block bool
+	spin  SpinPolicy // waits out an ErrWouldBlock handed back by the Writer
+
+	mu      sync.Mutex
+	cond    *sync.Cond // signals queued messages and freed budget
+	queue   [][]byte   // copies of pending messages, oldest first
+	pending int        // payload bytes in queue, the one being written included
+	err     error      // background write failure, sticky
+	closed  bool
+	aborted bool          // set by Abort: queued messages are dropped
+	done    chan struct{} // closed when the background goroutine exits
+}
+
+// NewSendQueue starts a SendQueue writing framed messages to w. opts
+// configure the Writer; with the default nonblocking policy, the background
+// goroutine waits out ErrWouldBlock with the SpinPolicy of WithAdaptiveBlock.
+func NewSendQueue(w io.Writer, cfg SendQueueConfig, opts ...Option) *SendQueue {
+	o := defaultOptions
+	for _, fn := range opts {
+		fn(&o)
+	}
+	if o.RetryDelay < 0 && o.WaitFunc == nil {
+		opts = append(opts[:len(opts):len(opts)], WithAdaptiveBlock())
+	}
+	if cfg.MaxPending <= 0 {
+		cfg.MaxPending = defaultSendQueueBytes
+	}
+	q := &SendQueue{
+		w:     NewWriter(w, opts...).(*Writer),
+		limit: cfg.MaxPending,
+		block: cfg.Block,
+		spin:  o.SpinPolicy,
+		done:  make(chan struct{}),
+	}
+	if !q.spin.enabled() {
+		WithAdaptiveBlock()(&o)
+		q.spin = o.SpinPolicy
+	}
+	q.cond = sync.NewCond(&q.mu)
+	go q.drain()
+	return q
+}
+
+// Write queues a copy of p as one message. It returns ErrTooLong when p
+// exceeds the whole budget, and ErrWouldBlock, with nothing queued, when the
+// budget has no room for p now and Block is not set.
+func (q *SendQueue) Write(p []byte) (int, error) {
+	if len(p) > q.limit {
+		return 0, ErrTooLong
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		if q.err != nil {
+			return 0, q.err
+		}
+		if q.closed {
+			return 0, ErrClosed
+		}
+		if q.pending+len(p) <= q.limit {
+			break
+		}
+		if !q.block {
+			return 0, ErrWouldBlock
+		}
+		q.cond.Wait()
+	}
+	q.queue = append(q.queue, append([]byte(nil), p...))
+	q.pending += len(p)
+	q.cond.Broadcast()
+	return len(p), nil
+}
+
+// Pending returns the payload bytes queued and not yet written.
+func (q *SendQueue) Pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.pending
+}
+
+// Close stops accepting messages, waits until the queued ones are written and
+// closes the Writer, which closes w when it implements io.Closer. It returns
+// the background write failure, if any.
+//
+// Close blocks until the peer has taken every queued message; to bound the
+// wait, call Abort, for example from a time.AfterFunc, which makes a Close in
+// progress return.
+func (q *SendQueue) Close() error {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.cond.Broadcast()
+	<-q.done
+	cerr := q.w.Close()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.err != nil {
+		return q.err
+	}
+	return cerr
+}
+
+// Abort stops accepting messages, drops the queued ones and closes the
+// Writer, interrupting the write in progress, then waits for the background
+// goroutine to exit. A message partially written is cut short on the wire.
+func (q *SendQueue) Abort() error {
+	q.mu.Lock()
+	q.closed, q.aborted = true, true
+	q.mu.Unlock()
+	q.cond.Broadcast()
+	err := q.w.Close()
+	<-q.done
+	return err
+}
+
+// drain writes queued messages until the queue is closed and empty, or a
+// write fails.
+func (q *SendQueue) drain() {
+	defer close(q.done)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		for len(q.queue) == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if len(q.queue) == 0 {
+			return
+		}
+		if q.aborted {
+			q.queue, q.pending = nil, 0
+			q.cond.Broadcast()
+			return
+		}
+		msg := q.queue[0]
+		q.mu.Unlock()
+		err := q.write(msg)
+		q.mu.Lock()
+		if q.aborted {
+			q.queue, q.pending = nil, 0
+			q.cond.Broadcast()
+			return
+		}
+		q.queue[0] = nil
+		q.queue = q.queue[1:]
+		q.pending -= len(msg)
+		if err != nil {
+			q.err = err
+			q.queue, q.pending = nil, 0
+		}
+		q.cond.Broadcast()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// write writes msg, retrying after ErrMore and, with the SpinPolicy of the
+// Writer options or that of WithAdaptiveBlock, after an ErrWouldBlock that a
+// custom wait policy hands back. Abort ends the retries with ErrClosed.
+func (q *SendQueue) write(msg []byte) error {
+	for attempt := 1; ; {
+		_, err := q.w.Write(msg)
+		if err == ErrWouldBlock {
+			q.mu.Lock()
+			aborted := q.aborted
+			q.mu.Unlock()
+			if aborted {
+				return ErrClosed
+			}
+			q.spin.wait(attempt)
+			attempt++
+			continue
+		}
+		if err != ErrMore {
+			return err
+		}
+		attempt = 1
+	}
+}
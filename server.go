@@ -0,0 +1,252 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"runtime/debug"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Server accepts connections and serves each with a framed handler in its own
+// goroutine.
+//
+// Accepted connections are framed like NewConn: transport helpers matching the
+// listener's network are applied first, followed by Options. The connection
+// is closed when Handler returns.
+type Server struct {
+	// Handler serves one framed connection. rw is a *Conn.
+	Handler func(rw io.ReadWriter) error
+
+	// Options frame every accepted connection.
+	Options []Option
+
+	// ErrorLog, when non-nil, receives handler errors other than io.EOF,
+	// recovered handler panics and accept errors that are retried.
+	ErrorLog func(error)
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[*Conn]struct{}
+	shutdown  bool
+	quit      chan struct{} // closed on shutdown, ends an accept backoff
+	wg        sync.WaitGroup
+}
+
+// Serve accepts connections on l and serves them with handler until l fails
+// or is closed, returning the accept error. Use a Server for graceful
+// shutdown.
+func Serve(l net.Listener, handler func(rw io.ReadWriter) error, opts ...Option) error {
+	s := &Server{Handler: handler, Options: opts}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l until l fails or the server is shut down.
+// Temporary accept errors (timeouts, an aborted pending connection, or a
+// process or system file descriptor limit) are retried with a backoff from
+// 5ms, doubling up to 1s; Shutdown and Close end the wait. After Shutdown or
+// Close, Serve returns ErrServerClosed.
+func (s *Server) Serve(l net.Listener) error {
+	if l == nil || s.Handler == nil {
+		return ErrInvalidArgument
+	}
+	if !s.trackListener(l, true) {
+		return ErrServerClosed
+	}
+	defer s.trackListener(l, false)
+
+	quit := s.quitChan()
+	var delay time.Duration
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			if s.shuttingDown() {
+				return ErrServerClosed
+			}
+			if temporaryAccept(err) {
+				delay = min(max(2*delay, 5*time.Millisecond), time.Second)
+				s.logf(err)
+				t := time.NewTimer(delay)
+				select {
+				case <-t.C:
+				case <-quit:
+					t.Stop()
+					return ErrServerClosed
+				}
+				continue
+			}
+			return err
+		}
+		delay = 0
+
+		c := NewConn(nc, s.Options...)
+		if !s.trackConn(c, true) {
+			_ = nc.Close()
+			return ErrServerClosed
+		}
+		go s.serveConn(c)
+	}
+}
+
+// temporaryAccept reports whether an accept error leaves the listener usable,
+// so Serve retries it.
+func temporaryAccept(err error) bool {
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EMFILE) || errors.Is(err, syscall.ENFILE)
+}
+
+// serveConn runs the handler for c. A handler panic is recovered and
+// reported to ErrorLog, closing only c.
+func (s *Server) serveConn(c *Conn) {
+	defer s.wg.Done()
+	defer s.trackConn(c, false)
+	defer c.Close()
+	defer func() {
+		if v := recover(); v != nil {
+			s.logf(fmt.Errorf("framer: connection handler panicked: %v\n%s", v, debug.Stack()))
+		}
+	}()
+	if err := s.Handler(c); err != nil && err != io.EOF {
+		s.logf(err)
+	}
+}
+
+// Shutdown closes the listeners and waits for active handlers to return. When
+// ctx is done first, the remaining connections are closed and ctx.Err() is
+// returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	lerr := s.closeListeners()
+
+	finished := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return lerr
+	case <-ctx.Done():
+		s.closeConns()
+		return ctx.Err()
+	}
+}
+
+// Close closes the listeners and all active connections immediately.
+func (s *Server) Close() error {
+	lerr := s.closeListeners()
+	cerr := s.closeConns()
+	return errors.Join(lerr, cerr)
+}
+
+func (s *Server) shuttingDown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.shutdown
+}
+
+// quitChan returns the channel closed on shutdown.
+func (s *Server) quitChan() chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.quit == nil {
+		s.quit = make(chan struct{})
+		if s.shutdown {
+			close(s.quit)
+		}
+	}
+	return s.quit
+}
+
+func (s *Server) trackListener(l net.Listener, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if add {
+		if s.shutdown {
+			return false
+		}
+		if s.listeners == nil {
+			s.listeners = make(map[net.Listener]struct{})
+		}
+		s.listeners[l] = struct{}{}
+	} else {
+		delete(s.listeners, l)
+	}
+	return true
+}
+
+// trackConn registers c and counts its handler; it reports false once the
+// server is shutting down.
+func (s *Server) trackConn(c *Conn, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if add {
+		if s.shutdown {
+			return false
+		}
+		if s.conns == nil {
+			s.conns = make(map[*Conn]struct{})
+		}
+		s.conns[c] = struct{}{}
+		s.wg.Add(1)
+	} else {
+		delete(s.conns, c)
+	}
+	return true
+}
+
+func (s *Server) closeListeners() error {
+	s.mu.Lock()
+	if !s.shutdown {
+		s.shutdown = true
+		if s.quit != nil {
+			close(s.quit)
+		}
+	}
+	ls := make([]net.Listener, 0, len(s.listeners))
+	for l := range s.listeners {
+		ls = append(ls, l)
+	}
+	s.mu.Unlock()
+
+	var errs []error
+	for _, l := range ls {
+		if err := l.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Server) closeConns() error {
+	s.mu.Lock()
+	cs := make([]*Conn, 0, len(s.conns))
+	for c := range s.conns {
+		cs = append(cs, c)
+	}
+	s.mu.Unlock()
+
+	var errs []error
+	for _, c := range cs {
+		if err := c.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Server) logf(err error) {
+	if s.ErrorLog != nil {
+		s.ErrorLog(err)
+	}
+}
@@ -0,0 +1,244 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	fr "code.hybscloud.com/framer"
+)
+
+func listenTCP(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen: %v", err)
+	}
+	return ln
+}
+
+func echoHandler(rw io.ReadWriter) error {
+	buf := make([]byte, 1024)
+	for {
+		n, err := rw.Read(buf)
+		if err != nil {
+			return err
+		}
+		if _, err := rw.Write(buf[:n]); err != nil {
+			return err
+		}
+	}
+}
+
+func TestServer_EchoAndShutdown(t *testing.T) {
+	ln := listenTCP(t)
+	srv := &fr.Server{Handler: echoHandler}
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ln) }()
+
+	for i := range 3 {
+		c, err := fr.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		msg := []byte{'m', byte('0' + i)}
+		if _, err := c.Write(msg); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		buf := make([]byte, 8)
+		n, err := c.Read(buf)
+		if err != nil || string(buf[:n]) != string(msg) {
+			t.Fatalf("echo: n=%d err=%v got=%q", n, err, buf[:n])
+		}
+		_ = c.Close()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if err := <-served; err != fr.ErrServerClosed {
+		t.Fatalf("Serve: want ErrServerClosed, got %v", err)
+	}
+	if err := srv.Serve(listenTCP(t)); err != fr.ErrServerClosed {
+		t.Fatalf("Serve after Shutdown: want ErrServerClosed, got %v", err)
+	}
+}
+
+func TestServer_ShutdownDeadlineClosesConnections(t *testing.T) {
+	ln := listenTCP(t)
+	started := make(chan struct{})
+	handled := make(chan error, 1)
+	srv := &fr.Server{Handler: func(rw io.ReadWriter) error {
+		buf := make([]byte, 8)
+		if _, err := rw.Read(buf); err != nil {
+			return err
+		}
+		close(started)
+		_, err := rw.Read(buf) // blocks until the connection is closed
+		handled <- err
+		return err
+	}}
+	go srv.Serve(ln)
+
+	c, err := fr.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+	if _, err := c.Write([]byte("hi")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := srv.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown: want DeadlineExceeded, got %v", err)
+	}
+	select {
+	case err := <-handled:
+		if err == nil {
+			t.Fatal("handler read succeeded on a closed connection")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler not unblocked by Shutdown deadline")
+	}
+}
+
+func TestServe_ReturnsAcceptError(t *testing.T) {
+	ln := listenTCP(t)
+	served := make(chan error, 1)
+	go func() { served <- fr.Serve(ln, echoHandler) }()
+	_ = ln.Close()
+	if err := <-served; !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("Serve: want net.ErrClosed, got %v", err)
+	}
+	if err := fr.Serve(nil, echoHandler); err != fr.ErrInvalidArgument {
+		t.Fatalf("Serve(nil): %v", err)
+	}
+}
+
+// abortOnceListener fails its first Accept with ECONNABORTED, like a client
+// that reset its connection while it was queued.
+type abortOnceListener struct {
+	net.Listener
+	failed bool
+}
+
+func (l *abortOnceListener) Accept() (net.Conn, error) {
+	if !l.failed {
+		l.failed = true
+		return nil, &net.OpError{Op: "accept", Net: "tcp", Err: os.NewSyscallError("accept", syscall.ECONNABORTED)}
+	}
+	return l.Listener.Accept()
+}
+
+func TestServer_RetriesTemporaryAcceptError(t *testing.T) {
+	ln := &abortOnceListener{Listener: listenTCP(t)}
+	var logged []error
+	srv := &fr.Server{Handler: echoHandler, ErrorLog: func(err error) { logged = append(logged, err) }}
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ln) }()
+
+	c, err := fr.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	if _, err := c.Write([]byte("hi")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	buf := make([]byte, 8)
+	if n, err := c.Read(buf); err != nil || string(buf[:n]) != "hi" {
+		t.Fatalf("echo after ECONNABORTED: n=%d err=%v", n, err)
+	}
+	_ = c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if err := <-served; err != fr.ErrServerClosed {
+		t.Fatalf("Serve: want ErrServerClosed, got %v", err)
+	}
+	if len(logged) != 1 || !errors.Is(logged[0], syscall.ECONNABORTED) {
+		t.Fatalf("logged %v", logged)
+	}
+}
+
+// emfileListener fails every Accept with EMFILE, like a process out of file
+// descriptors.
+type emfileListener struct {
+	net.Listener
+}
+
+func (l emfileListener) Accept() (net.Conn, error) {
+	return nil, &net.OpError{Op: "accept", Net: "tcp", Err: os.NewSyscallError("accept", syscall.EMFILE)}
+}
+
+func TestServer_ShutdownInterruptsAcceptBackoff(t *testing.T) {
+	ln := emfileListener{Listener: listenTCP(t)}
+	logged := make(chan error, 100)
+	srv := &fr.Server{Handler: echoHandler, ErrorLog: func(err error) { logged <- err }}
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ln) }()
+
+	// Wait until the backoff has grown past 100ms before shutting down.
+	for range 6 {
+		<-logged
+	}
+	start := time.Now()
+	if err := srv.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := <-served; err != fr.ErrServerClosed {
+		t.Fatalf("Serve: want ErrServerClosed, got %v", err)
+	}
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Fatalf("Serve returned %v after Close", d)
+	}
+}
+
+func TestServer_RecoversHandlerPanic(t *testing.T) {
+	ln := listenTCP(t)
+	logged := make(chan error, 1)
+	srv := &fr.Server{
+		Handler:  func(io.ReadWriter) error { panic("boom") },
+		ErrorLog: func(err error) { logged <- err },
+	}
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ln) }()
+
+	c, err := fr.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+	if err := <-logged; !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("logged %v", err)
+	}
+	// The connection is closed and the server keeps serving.
+	if _, err := c.Read(make([]byte, 8)); err == nil {
+		t.Fatal("Read after handler panic: want error")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if err := <-served; err != fr.ErrServerClosed {
+		t.Fatalf("Serve: want ErrServerClosed, got %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+//go:build unix
+
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+// Shared ring file layout. The counters sit on separate cache lines so the
+// producer and the consumer do not contend.
+const (
+	shmMagic   = 0x66726d72696e6701 // "frmring" + layout version 1
+	shmOffCap  = 8                  // uint64 data capacity
+	shmOffHead = 64                 // uint64 bytes consumed, written by the reader
+	shmOffTail = 128                // uint64 bytes produced, written by the writer
+	shmOffFlag = 192                // uint64 closed flag
+	shmHdrSize = 256                // start of the data area
+)
+
+// SharedRing is a single-producer, single-consumer byte ring in a shared,
+// file-backed memory mapping. It carries framed messages between co-located
+// processes through the same Reader and Writer API as sockets:
+//
+//	ring, _ := framer.CreateSharedRing(path, 1<<20) // producer
+//	w := framer.NewWriter(ring, framer.WithWriteLocal())
+//
+//	ring, _ := framer.OpenSharedRing(path) // consumer
+//	r := framer.NewReader(ring, framer.WithReadLocal())
+//
+// The ring never blocks. Write copies what fits and returns ErrMore with the
+// count when that is only part of p, and ErrWouldBlock when nothing fits;
+// Read returns ErrWouldBlock when the ring is empty. A Writer keeps its place
+// in the message across both, so with WithRetryDelay or WithWaitFunc it waits
+// for room and a message larger than the ring passes through in one Write. After either side calls Close, Read
+// drains the remaining bytes and then returns io.EOF, and Write returns
+// ErrClosed.
+//
+// One process writes and one process reads; a SharedRing must not be used by
+// several writers or several readers at once.
+type SharedRing struct {
+	f    *os.File
+	mem  []byte
+	data []byte
+	head *atomic.Uint64
+	tail *atomic.Uint64
+	flag *atomic.Uint64
+}
+
+// CreateSharedRing creates, or truncates, the file at path and maps a ring
+// of size data bytes into it.
+func CreateSharedRing(path string, size int) (*SharedRing, error) {
+	if size <= 0 {
+		return nil, ErrInvalidArgument
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(int64(shmHdrSize + size)); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	r, err := mapSharedRing(f, shmHdrSize+size)
+	if err != nil {
+		return nil, err
+	}
+	binary.LittleEndian.PutUint64(r.mem[shmOffCap:], uint64(size))
+	(*atomic.Uint64)(unsafe.Pointer(&r.mem[0])).Store(shmMagic)
+	return r, nil
+}
+
+// OpenSharedRing maps the ring created at path by CreateSharedRing. It
+// returns ErrInvalidArgument when the file does not hold a ring.
+func OpenSharedRing(path string) (*SharedRing, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if fi.Size() <= shmHdrSize {
+		_ = f.Close()
+		return nil, ErrInvalidArgument
+	}
+	r, err := mapSharedRing(f, int(fi.Size()))
+	if err != nil {
+		return nil, err
+	}
+	magic := (*atomic.Uint64)(unsafe.Pointer(&r.mem[0])).Load()
+	if magic != shmMagic || binary.LittleEndian.Uint64(r.mem[shmOffCap:]) != uint64(len(r.data)) {
+		_ = r.unmap()
+		return nil, ErrInvalidArgument
+	}
+	return r, nil
+}
+
+func mapSharedRing(f *os.File, size int) (*SharedRing, error) {
+	mem, err := syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &SharedRing{
+		f:    f,
+		mem:  mem,
+		data: mem[shmHdrSize:],
+		head: (*atomic.Uint64)(unsafe.Pointer(&mem[shmOffHead])),
+		tail: (*atomic.Uint64)(unsafe.Pointer(&mem[shmOffTail])),
+		flag: (*atomic.Uint64)(unsafe.Pointer(&mem[shmOffFlag])),
+	}, nil
+}
+
+// Read copies buffered bytes into p. It returns ErrWouldBlock when the ring
+// is empty, and io.EOF once it is empty and closed.
+func (r *SharedRing) Read(p []byte) (int, error) {
+	if r.mem == nil {
+		return 0, ErrClosed
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	head := r.head.Load()
+	avail := r.tail.Load() - head
+	if avail == 0 {
+		if r.flag.Load() != 0 && r.tail.Load() == head {
+			return 0, io.EOF
+		}
+		return 0, ErrWouldBlock
+	}
+	n := min(uint64(len(p)), avail)
+	size := uint64(len(r.data))
+	off := head % size
+	c := copy(p[:n], r.data[off:])
+	copy(p[c:n], r.data)
+	r.head.Store(head + n)
+	return int(n), nil
+}
+
+// Write copies as much of p as fits into the ring. A short write returns the
+// count with ErrMore, and ErrWouldBlock when the ring is full; the caller
+// retries with the rest.
+func (r *SharedRing) Write(p []byte) (int, error) {
+	if r.mem == nil || r.flag.Load() != 0 {
+		return 0, ErrClosed
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	tail := r.tail.Load()
+	size := uint64(len(r.data))
+	free := size - (tail - r.head.Load())
+	n := min(uint64(len(p)), free)
+	if n == 0 {
+		return 0, ErrWouldBlock
+	}
+	off := tail % size
+	c := copy(r.data[off:], p[:n])
+	copy(r.data, p[c:n])
+	r.tail.Store(tail + n)
+	if n < uint64(len(p)) {
+		return int(n), ErrMore
+	}
+	return int(n), nil
+}
+
+// Close marks the ring closed for both sides and unmaps it. The file stays
+// in place; remove it when neither side needs it.
+func (r *SharedRing) Close() error {
+	if r.mem == nil {
+		return nil
+	}
+	r.flag.Store(1)
+	return r.unmap()
+}
+
+func (r *SharedRing) unmap() error {
+	err := syscall.Munmap(r.mem)
+	r.mem, r.data = nil, nil
+	if cerr := r.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
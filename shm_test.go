@@ -0,0 +1,129 @@
+//go:build unix
+
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	fr "code.hybscloud.com/framer"
+)
+
+func TestSharedRing_FramedAcrossMappings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ring")
+	prod, err := fr.CreateSharedRing(path, 64)
+	if err != nil {
+		t.Fatalf("CreateSharedRing: %v", err)
+	}
+	cons, err := fr.OpenSharedRing(path)
+	if err != nil {
+		t.Fatalf("OpenSharedRing: %v", err)
+	}
+	defer cons.Close()
+
+	w := fr.NewWriter(prod, fr.WithWriteLocal())
+	r := fr.NewReader(cons, fr.WithReadLocal())
+	buf := make([]byte, 256)
+	if _, err := r.Read(buf); err != fr.ErrWouldBlock {
+		t.Fatalf("empty ring: err=%v", err)
+	}
+
+	// Messages larger than the ring pass through by alternating sides,
+	// wrapping around the data area many times.
+	for i := 0; i < 50; i++ {
+		msg := bytes.Repeat([]byte{byte(i)}, 10+i*3)
+		// Read reports per-call progress and fills buf across calls.
+		got := 0
+		for {
+			_, werr := w.Write(msg)
+			rn, rerr := r.Read(buf)
+			got += rn
+			if rerr == nil {
+				break
+			}
+			if werr != nil && werr != fr.ErrWouldBlock || rerr != fr.ErrWouldBlock {
+				t.Fatalf("message %d: write err=%v read err=%v", i, werr, rerr)
+			}
+		}
+		if got != len(msg) || !bytes.Equal(buf[:got], msg) {
+			t.Fatalf("message %d: got %d bytes", i, got)
+		}
+	}
+
+	_, _ = w.Write([]byte("bye"))
+	if err := prod.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if n, err := r.Read(buf); err != nil || string(buf[:n]) != "bye" {
+		t.Fatalf("drain after Close: %q err=%v", buf[:n], err)
+	}
+	if _, err := r.Read(buf); err != io.EOF {
+		t.Fatalf("after drain: err=%v want io.EOF", err)
+	}
+	if _, err := cons.Write([]byte("x")); err != fr.ErrClosed {
+		t.Fatalf("Write after Close: err=%v", err)
+	}
+}
+
+func TestSharedRing_ShortWriteResumesInBlockingMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ring")
+	prod, err := fr.CreateSharedRing(path, 64)
+	if err != nil {
+		t.Fatalf("CreateSharedRing: %v", err)
+	}
+	defer prod.Close()
+	cons, err := fr.OpenSharedRing(path)
+	if err != nil {
+		t.Fatalf("OpenSharedRing: %v", err)
+	}
+	defer cons.Close()
+
+	if n, err := prod.Write(make([]byte, 100)); n != 64 || err != fr.ErrMore {
+		t.Fatalf("short write: n=%d err=%v want 64, ErrMore", n, err)
+	}
+	if n, err := prod.Write([]byte("x")); n != 0 || err != fr.ErrWouldBlock {
+		t.Fatalf("full ring: n=%d err=%v want 0, ErrWouldBlock", n, err)
+	}
+	if _, err := cons.Read(make([]byte, 64)); err != nil {
+		t.Fatalf("drain: %v", err)
+	}
+
+	// A blocking Writer resumes after the short write instead of handing
+	// ErrWouldBlock back with the message half written.
+	w := fr.NewWriter(prod, fr.WithWriteLocal(), fr.WithRetryDelay(time.Millisecond))
+	r := fr.NewReader(cons, fr.WithReadLocal(), fr.WithRetryDelay(time.Millisecond))
+	msg := bytes.Repeat([]byte("shm"), 100)
+	errc := make(chan error, 1)
+	go func() {
+		_, err := w.Write(msg)
+		errc <- err
+	}()
+	buf := make([]byte, len(msg))
+	if n, err := r.Read(buf); err != nil || !bytes.Equal(buf[:n], msg) {
+		t.Fatalf("Read: n=%d err=%v", n, err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+}
+
+func TestSharedRing_OpenRejectsForeignFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plain")
+	if err := os.WriteFile(path, make([]byte, 1024), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fr.OpenSharedRing(path); err != fr.ErrInvalidArgument {
+		t.Fatalf("err=%v want ErrInvalidArgument", err)
+	}
+	if _, err := fr.CreateSharedRing(path, 0); err != fr.ErrInvalidArgument {
+		t.Fatalf("zero size: err=%v", err)
+	}
+}
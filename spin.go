@@ -0,0 +1,69 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"runtime"
+	"time"
+)
+
+// SpinPolicy is an escalating wait strategy for iox.ErrWouldBlock in
+// blocking mode: the framer first retries at once (busy-spin), then yields
+// the processor, then sleeps for exponentially growing intervals. The
+// sequence restarts whenever the transport makes progress, so short stalls
+// cost microseconds while long idle periods do not burn a core.
+//
+// Unlike iox.Backoff, which only sleeps, for linearly growing jittered
+// intervals and keeps its own state, a SpinPolicy is a stateless
+// configuration: it spins and yields before sleeping, doubles the sleep,
+// and the framer counts the attempts.
+type SpinPolicy struct {
+	Spins    int           // immediate retries before yielding
+	Yields   int           // runtime.Gosched retries before sleeping
+	MinSleep time.Duration // first sleep, doubled on each further retry
+	MaxSleep time.Duration // cap on the sleep; zero means MinSleep
+}
+
+// WithSpinPolicy enables blocking mode with the escalating wait strategy p in
+// place of a fixed yield or sleep. WithWaitFunc takes precedence over it,
+// and WithNonblock, WithRetryDelay or SetRetryDelay with a non-zero delay
+// replace it.
+func WithSpinPolicy(p SpinPolicy) Option {
+	return func(o *Options) {
+		o.RetryDelay = 0
+		o.SpinPolicy = p
+	}
+}
+
+// WithAdaptiveBlock enables blocking mode with a SpinPolicy suited to
+// low-latency transports: 100 spins, 10 yields, then sleeps from 10µs up to
+// 1ms.
+func WithAdaptiveBlock() Option {
+	return WithSpinPolicy(SpinPolicy{Spins: 100, Yields: 10, MinSleep: 10 * time.Microsecond, MaxSleep: time.Millisecond})
+}
+
+func (b *SpinPolicy) enabled() bool {
+	return b.Spins > 0 || b.Yields > 0 || b.MinSleep > 0
+}
+
+// wait performs the wait before retry number attempt, counted from 1.
+func (b *SpinPolicy) wait(attempt int) {
+	switch {
+	case attempt <= b.Spins:
+	case attempt <= b.Spins+b.Yields:
+		runtime.Gosched()
+	default:
+		maxSleep := max(b.MaxSleep, b.MinSleep)
+		d := b.MinSleep << min(attempt-b.Spins-b.Yields-1, 30)
+		if d <= 0 || d > maxSleep {
+			d = maxSleep
+		}
+		if d <= 0 {
+			runtime.Gosched()
+			return
+		}
+		time.Sleep(d)
+	}
+}
@@ -0,0 +1,97 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats reports cumulative framing counters.
+//
+// Counters are maintained with atomic operations on every framer and may be
+// read concurrently with I/O. Byte counts are payload bytes; framing headers
+// are not included.
+type Stats struct {
+	FramesRead    uint64
+	BytesRead     uint64
+	FramesWritten uint64
+	BytesWritten  uint64
+
+	// ReadRetries and WriteRetries count ErrWouldBlock results from the
+	// transport, whether retried internally or returned to the caller.
+	ReadRetries  uint64
+	WriteRetries uint64
+
+	// Duplicates counts packets dropped as duplicates (see WithDedup).
+	Duplicates uint64
+
+	// Expired counts messages a Forwarder dropped as older than its TTL
+	// (see WithTTL).
+	Expired uint64
+
+	// LastRead and LastWrite are the times of the latest transport progress
+	// in each direction; zero when there was none.
+	LastRead  time.Time
+	LastWrite time.Time
+}
+
+// dirStats holds the counters of one direction.
+type dirStats struct {
+	frames  atomic.Uint64
+	bytes   atomic.Uint64
+	retries atomic.Uint64
+	dups    atomic.Uint64
+	expired atomic.Uint64
+	last    atomic.Int64 // UnixNano of the latest progress
+}
+
+func (s *dirStats) frame(n int64) {
+	s.frames.Add(1)
+	s.bytes.Add(uint64(n))
+}
+
+func (s *dirStats) touch() { s.last.Store(time.Now().UnixNano()) }
+
+func (s *dirStats) lastTime() time.Time {
+	ns := s.last.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// collectStats combines the read side of rd and the write side of wr.
+func collectStats(rd, wr *framer) Stats {
+	var st Stats
+	if rd != nil {
+		st.FramesRead = rd.rstats.frames.Load()
+		st.BytesRead = rd.rstats.bytes.Load()
+		st.ReadRetries = rd.rstats.retries.Load()
+		st.Duplicates = rd.rstats.dups.Load()
+		st.Expired = rd.rstats.expired.Load()
+		st.LastRead = rd.rstats.lastTime()
+	}
+	if wr != nil {
+		st.FramesWritten = wr.wstats.frames.Load()
+		st.BytesWritten = wr.wstats.bytes.Load()
+		st.WriteRetries = wr.wstats.retries.Load()
+		st.LastWrite = wr.wstats.lastTime()
+	}
+	return st
+}
+
+// Stats returns the read-side counters.
+func (r *Reader) Stats() Stats { return collectStats(r.fr, nil) }
+
+// Stats returns the write-side counters.
+func (w *Writer) Stats() Stats { return collectStats(nil, w.fr) }
+
+// Stats returns the counters of both directions.
+func (rw *ReadWriter) Stats() Stats { return collectStats(rw.Reader.fr, rw.Writer.fr) }
+
+// Stats returns the source-side read counters and the destination-side write
+// counters.
+func (f *Forwarder) Stats() Stats { return collectStats(f.rr, f.ww) }
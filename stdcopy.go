@@ -0,0 +1,98 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import "encoding/binary"
+
+// Stream IDs of HeaderStdcopy messages, as used by Docker's stdcopy package.
+const (
+	StreamStdin     byte = 0
+	StreamStdout    byte = 1
+	StreamStderr    byte = 2
+	StreamSystemErr byte = 3
+)
+
+// stdcopyHeaderLen is the size of a HeaderStdcopy header.
+const stdcopyHeaderLen = 8
+
+// A HeaderStdcopy message is a typed message whose type byte travels as the
+// first header byte instead of in front of the body: Read returns the stream
+// ID followed by the body, so Router dispatches stdout and stderr to
+// separate handlers, and WriteTyped(StreamStderr, body) writes a stderr
+// frame. Empty messages carry no stream ID and cannot be written.
+//
+// Internally the header is 7 bytes long and the stream ID is the first
+// payload byte: the Reader parses the 8 wire bytes and steps back over the
+// ID, which readOnce then delivers from fr.header; the Writer holds the
+// header back until the first payload byte is written and sends both
+// together from writeOnce. WithControlFrames, WithFixedHeader and
+// WithLengthIncludesHeader do not apply to this format.
+
+// putStdcopyHeader encodes the header of a v-byte body on stream id.
+func putStdcopyHeader(dst []byte, id byte, v int64) {
+	dst[0], dst[1], dst[2], dst[3] = id, 0, 0, 0
+	binary.BigEndian.PutUint32(dst[4:stdcopyHeaderLen], uint32(v))
+}
+
+// stdcopyLength returns the body length of a complete HeaderStdcopy header.
+func stdcopyLength(hdr []byte) (int64, error) {
+	if hdr[1]|hdr[2]|hdr[3] != 0 {
+		return 0, ErrInvalidHeader
+	}
+	return int64(binary.BigEndian.Uint32(hdr[4:stdcopyHeaderLen])), nil
+}
+
+// readStdcopyHeader parses a HeaderStdcopy header and returns the size of
+// the header without the stream ID, which is left pending in fr.header[0].
+func (fr *framer) readStdcopyHeader() (int64, error) {
+	if fr.hlen > 0 {
+		return fr.hlen, nil
+	}
+	if err := fr.readHeaderBytes(stdcopyHeaderLen); err != nil {
+		return 0, err
+	}
+	v, err := stdcopyLength(fr.header[:stdcopyHeaderLen])
+	if err != nil {
+		return 0, err
+	}
+	if err := fr.parsedLength(v+1, stdcopyHeaderLen); err != nil {
+		return 0, err
+	}
+	fr.offset, fr.hlen, fr.rid = stdcopyHeaderLen-1, stdcopyHeaderLen-1, true
+	return fr.hlen, nil
+}
+
+// readStreamID delivers the pending stream ID as the first payload byte.
+func (fr *framer) readStreamID(p []byte) int {
+	if len(p) == 0 {
+		return 0
+	}
+	p[0], fr.rid = fr.header[0], false
+	return 1
+}
+
+// writeStdcopyHeader writes the held-back header with the first payload
+// byte p[0] as its stream ID, and reports that byte as written once the
+// whole header is.
+func (fr *framer) writeStdcopyHeader(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if fr.hoff == 0 {
+		fr.header[0] = p[0]
+	}
+	for fr.hoff < stdcopyHeaderLen {
+		wn, we := fr.writeTransport(fr.header[fr.hoff:stdcopyHeaderLen])
+		fr.hoff += wn
+		if we != nil {
+			if we == ErrMore && wn > 0 {
+				continue
+			}
+			return 0, we
+		}
+	}
+	fr.whold, fr.hoff = false, 0
+	return 1, nil
+}
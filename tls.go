@@ -0,0 +1,58 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import "encoding/binary"
+
+// TLS record layer limits (RFC 8446, section 5).
+const (
+	tlsHeaderLen = 5
+	tlsMaxRecord = 1<<14 + 2048 // largest TLSCiphertext fragment
+)
+
+// A HeaderTLS header is the TLS record header: a content type byte, the
+// 2-byte big-endian legacy record version and a 2-byte big-endian fragment
+// length. Type and version are not part of the payload: the Reader records
+// them in Meta, and the Writer takes them from WriteMeta, so a Forwarder
+// relays records unchanged. Records are not decrypted, reassembled or
+// validated beyond their length, which may not exceed 2^14+2048 bytes.
+
+// putTLSHeader encodes the header of a v-byte fragment.
+func putTLSHeader(dst []byte, typ byte, version uint16, v int64) {
+	dst[0] = typ
+	binary.BigEndian.PutUint16(dst[1:3], version)
+	binary.BigEndian.PutUint16(dst[3:tlsHeaderLen], uint16(v))
+}
+
+// tlsLength returns the fragment length of a complete HeaderTLS header.
+func tlsLength(hdr []byte) (int64, error) {
+	v := int64(binary.BigEndian.Uint16(hdr[3:tlsHeaderLen]))
+	if v > tlsMaxRecord {
+		return 0, ErrTooLong
+	}
+	return v, nil
+}
+
+// readTLSHeader parses a HeaderTLS header and records its type and version
+// in fr.rmeta. The header size is kept in fr.hlen so it is parsed once per
+// record.
+func (fr *framer) readTLSHeader() (int64, error) {
+	if fr.hlen > 0 {
+		return fr.hlen, nil
+	}
+	if err := fr.readHeaderBytes(tlsHeaderLen); err != nil {
+		return 0, err
+	}
+	v, err := tlsLength(fr.header[:tlsHeaderLen])
+	if err != nil {
+		return 0, err
+	}
+	if err := fr.parsedLength(v, tlsHeaderLen); err != nil {
+		return 0, err
+	}
+	fr.rmeta = Meta{Type: fr.header[0], Version: binary.BigEndian.Uint16(fr.header[1:3])}
+	fr.hlen = tlsHeaderLen
+	return fr.hlen, nil
+}
@@ -10,9 +10,13 @@ import (
 	"errors"
 	"io"
 	"net"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	fr "code.hybscloud.com/framer"
+	"code.hybscloud.com/framer/framertest"
 	"code.hybscloud.com/iox"
 )
 
@@ -994,3 +998,1173 @@ func TestStreamWrite_AbsorbsErrMoreWithProgressAndResetsState(t *testing.T) {
 		t.Fatalf("decode msg2: got (%d, %v, %q), want (%d, nil, %q)", n, err, buf2[:n], len(msg2), msg2)
 	}
 }
+
+func TestMorePolicy_Absorb(t *testing.T) {
+	type step = struct {
+		b   []byte
+		err error
+	}
+	under := &scriptedReader2{steps: []step{
+		{err: iox.ErrMore},
+		{b: []byte{5, 'h', 'e'}, err: iox.ErrMore},
+		{err: iox.ErrMore},
+		{b: []byte("llo")},
+	}}
+	r := fr.NewReader(under, fr.WithMorePolicy(fr.MoreAbsorb))
+	buf := make([]byte, 8)
+	if n, err := r.Read(buf); err != nil || string(buf[:n]) != "hello" {
+		t.Fatalf("stream read: got (%q, %v), want (hello, nil)", buf[:n], err)
+	}
+
+	type wstep = struct {
+		n   int
+		err error
+	}
+	dst := &scriptedErrMoreWriter2{steps: []wstep{{n: 0, err: iox.ErrMore}, {n: 1}, {n: 0, err: iox.ErrMore}}}
+	w := fr.NewWriter(dst, fr.WithWriteMorePolicy(fr.MoreAbsorb))
+	if n, err := w.Write([]byte("hello")); err != nil || n != 5 {
+		t.Fatalf("stream write: got (%d, %v), want (5, nil)", n, err)
+	}
+
+	// A whole packet with ErrMore is delivered; a packet accepted in part
+	// cannot be completed and still reports ErrMore.
+	pr := fr.NewReader(&scriptedReader2{steps: []step{{b: []byte("pkt"), err: iox.ErrMore}}}, fr.WithProtocol(fr.SeqPacket), fr.WithReadMorePolicy(fr.MoreAbsorb))
+	if n, err := pr.Read(buf); err != nil || string(buf[:n]) != "pkt" {
+		t.Fatalf("packet read: got (%q, %v), want (pkt, nil)", buf[:n], err)
+	}
+	pdst := &scriptedErrMoreWriter2{steps: []wstep{{n: 2, err: iox.ErrMore}}}
+	pw := fr.NewWriter(pdst, fr.WithProtocol(fr.SeqPacket), fr.WithMorePolicy(fr.MoreAbsorb))
+	if _, err := pw.Write([]byte("pkt")); !errors.Is(err, iox.ErrMore) {
+		t.Fatalf("partial packet write: err=%v, want ErrMore", err)
+	}
+}
+
+// --- Header formats and profiles ---
+
+func TestProfile_WireFormats(t *testing.T) {
+	cases := []struct {
+		name    string
+		profile fr.Profile
+		payload []byte
+		wire    []byte
+	}{
+		{"erlang2", fr.ProfileErlangPacket2, []byte("abc"), []byte{0, 3, 'a', 'b', 'c'}},
+		{"erlang4", fr.ProfileErlangPacket4, []byte("abc"), []byte{0, 0, 0, 3, 'a', 'b', 'c'}},
+		{"java", fr.ProfileJavaDataStream, nil, []byte{0, 0, 0, 0}},
+		{"protobuf", fr.ProfileProtobufDelimited, bytes.Repeat([]byte{'p'}, 300), append([]byte{0xAC, 0x02}, bytes.Repeat([]byte{'p'}, 300)...)},
+		{"docker", fr.ProfileDockerStdcopy, []byte{fr.StreamStdout, 'o', 'k'}, []byte{1, 0, 0, 0, 0, 0, 0, 2, 'o', 'k'}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			// Little-endian and packet settings before the profile are overridden.
+			w := fr.NewWriter(&out, fr.WithByteOrder(binary.LittleEndian), fr.WithProtocol(fr.Datagram), fr.WithProfile(tc.profile))
+			if _, err := w.Write(tc.payload); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			if !bytes.Equal(out.Bytes(), tc.wire) {
+				t.Fatalf("wire=% x want % x", out.Bytes()[:min(out.Len(), 8)], tc.wire[:min(len(tc.wire), 8)])
+			}
+			// Deliver one byte at a time with would-block between bytes.
+			r := fr.NewReader(&wouldBlockEveryOther{r: bytes.NewReader(tc.wire)}, fr.WithProfile(tc.profile))
+			buf := make([]byte, 512)
+			total := 0
+			for {
+				n, err := r.Read(buf)
+				total += n
+				if err == nil {
+					break
+				}
+				if err != fr.ErrWouldBlock {
+					t.Fatalf("read: %v", err)
+				}
+			}
+			if !bytes.Equal(buf[:total], tc.payload) {
+				t.Fatalf("payload mismatch: got %d bytes want %d", total, len(tc.payload))
+			}
+		})
+	}
+}
+
+func TestWithFixedLengthHeader(t *testing.T) {
+	var wire bytes.Buffer
+	w := fr.NewWriter(&wire, fr.WithFixedLengthHeader(4))
+	if _, err := w.Write(bytes.Repeat([]byte{'k'}, 300)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if hdr := wire.Bytes()[:4]; !bytes.Equal(hdr, []byte{0, 0, 1, 0x2C}) || wire.Len() != 304 {
+		t.Fatalf("wire head % x len %d", hdr, wire.Len())
+	}
+	r := fr.NewReader(bytes.NewReader(wire.Bytes()), fr.WithFixedLengthHeader(4))
+	if n, err := r.Read(make([]byte, 512)); n != 300 || err != nil {
+		t.Fatalf("read: n=%d err=%v", n, err)
+	}
+
+	// A DNS-over-TCP style stream passes through a Forwarder unchanged.
+	wire.Reset()
+	w = fr.NewWriter(&wire, fr.WithFixedLengthHeader(2))
+	for _, m := range []string{"query", ""} {
+		if _, err := w.Write([]byte(m)); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if !bytes.HasPrefix(wire.Bytes(), []byte{0, 5, 'q'}) {
+		t.Fatalf("fixed16 wire % x", wire.Bytes())
+	}
+	if _, err := w.Write(make([]byte, 65536)); err != fr.ErrTooLong {
+		t.Fatalf("fixed16 over range: err=%v want ErrTooLong", err)
+	}
+	var out bytes.Buffer
+	f := fr.NewForwarder(&out, bytes.NewReader(wire.Bytes()), fr.WithFixedLengthHeader(2))
+	for range 2 {
+		if _, err := f.ForwardOnce(); err != nil {
+			t.Fatalf("forward: %v", err)
+		}
+	}
+	if !bytes.Equal(out.Bytes(), wire.Bytes()) {
+		t.Fatalf("forwarded % x want % x", out.Bytes(), wire.Bytes())
+	}
+
+	// An unsupported size keeps the configured format.
+	wire.Reset()
+	w = fr.NewWriter(&wire, fr.WithFixedLengthHeader(3))
+	if _, err := w.Write([]byte("ab")); err != nil || !bytes.Equal(wire.Bytes(), []byte{2, 'a', 'b'}) {
+		t.Fatalf("unsupported size: wire % x err=%v", wire.Bytes(), err)
+	}
+}
+
+func TestMySQLPackets_Sequence(t *testing.T) {
+	var wire bytes.Buffer
+	w := fr.NewWriter(&wire, fr.WithProfile(fr.ProfileMySQL)).(*fr.Writer)
+	for _, m := range []string{"abc", "", "de"} {
+		if _, err := w.Write([]byte(m)); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	want := []byte{3, 0, 0, 0, 'a', 'b', 'c', 0, 0, 0, 1, 2, 0, 0, 2, 'd', 'e'}
+	if !bytes.Equal(wire.Bytes(), want) || w.Sequence() != 3 {
+		t.Fatalf("wire % x seq %d", wire.Bytes(), w.Sequence())
+	}
+	hdr, err := fr.DecodeHeader(wire.Bytes()[7:], fr.WithProfile(fr.ProfileMySQL))
+	if err != nil || hdr.Sequence != 1 || hdr.PayloadLen != 0 || hdr.HeaderLen != 4 {
+		t.Fatalf("DecodeHeader: %+v err=%v", hdr, err)
+	}
+	if _, err := (&fr.Header{PayloadLen: 1 << 24, Format: fr.HeaderMySQL}).Encode(make([]byte, 8)); err != fr.ErrTooLong {
+		t.Fatalf("over 2^24-1: err=%v", err)
+	}
+
+	// The Reader validates IDs; SetSequence accepts an unexpected one.
+	r := fr.NewReader(&wouldBlockEveryOther{r: bytes.NewReader(wire.Bytes()[7:])}, fr.WithProfile(fr.ProfileMySQL)).(*fr.Reader)
+	buf := make([]byte, 8)
+	var se *fr.SequenceError
+	for {
+		_, err = r.Read(buf)
+		if err != fr.ErrWouldBlock {
+			break
+		}
+	}
+	if !errors.As(err, &se) || !errors.Is(err, fr.ErrInvalidHeader) || se.Got != 1 || se.Want != 0 {
+		t.Fatalf("unexpected ID: err=%v", err)
+	}
+	r.SetSequence(se.Got)
+	for _, m := range []string{"", "de"} {
+		n, err := r.Read(buf)
+		total := n
+		for err == fr.ErrWouldBlock {
+			n, err = r.Read(buf)
+			total += n
+		}
+		if err != nil || string(buf[:total]) != m {
+			t.Fatalf("read = %q, %v; want %q", buf[:total], err, m)
+		}
+	}
+	if r.Sequence() != 3 {
+		t.Fatalf("reader sequence %d", r.Sequence())
+	}
+
+	// A ReadWriter answers a command with the following IDs.
+	var out bytes.Buffer
+	rw := fr.NewReadWriter(bytes.NewReader([]byte{1, 0, 0, 0, 0x0E}), &out, fr.WithProfile(fr.ProfileMySQL)).(*fr.ReadWriter)
+	if _, err := rw.Read(buf); err != nil {
+		t.Fatalf("command: %v", err)
+	}
+	_, _ = rw.Write([]byte("ok"))
+	_, _ = rw.Write([]byte("eof"))
+	if out.Bytes()[3] != 1 || out.Bytes()[9] != 2 || rw.Sequence() != 3 {
+		t.Fatalf("response % x seq %d", out.Bytes(), rw.Sequence())
+	}
+	rw.SetSequence(0)
+	if rw.Reader.Sequence() != 0 || rw.Writer.Sequence() != 0 {
+		t.Fatal("SetSequence did not reset both directions")
+	}
+}
+
+func TestGRPCMessages_CompressedFlag(t *testing.T) {
+	var wire bytes.Buffer
+	w := fr.NewWriter(&wire, fr.WithProfile(fr.ProfileGRPC)).(*fr.Writer)
+	if _, err := w.WriteMeta(fr.Meta{Compressed: true}, []byte("zz")); err != nil {
+		t.Fatalf("WriteMeta: %v", err)
+	}
+	if _, err := w.Write([]byte("abc")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	want := []byte{1, 0, 0, 0, 2, 'z', 'z', 0, 0, 0, 0, 3, 'a', 'b', 'c'}
+	if !bytes.Equal(wire.Bytes(), want) {
+		t.Fatalf("wire % x want % x", wire.Bytes(), want)
+	}
+	hdr, err := fr.DecodeHeader(wire.Bytes(), fr.WithProfile(fr.ProfileGRPC))
+	if err != nil || !hdr.Compressed || hdr.PayloadLen != 2 || hdr.HeaderLen != 5 {
+		t.Fatalf("DecodeHeader: %+v err=%v", hdr, err)
+	}
+
+	r := fr.NewReader(&wouldBlockEveryOther{r: bytes.NewReader(wire.Bytes())}, fr.WithProfile(fr.ProfileGRPC)).(*fr.Reader)
+	buf := make([]byte, 8)
+	for _, m := range []struct {
+		payload    string
+		compressed bool
+	}{{"zz", true}, {"abc", false}} {
+		n, err := r.Read(buf)
+		total := n
+		for err == fr.ErrWouldBlock {
+			n, err = r.Read(buf)
+			total += n
+		}
+		if err != nil || string(buf[:total]) != m.payload || r.Meta().Compressed != m.compressed {
+			t.Fatalf("read = %q, %v, %+v; want %q", buf[:total], err, r.Meta(), m.payload)
+		}
+	}
+
+	// Flag values other than 0 and 1 are reserved.
+	bad := fr.NewReader(bytes.NewReader([]byte{2, 0, 0, 0, 0}), fr.WithProfile(fr.ProfileGRPC))
+	if _, err := bad.Read(buf); !errors.Is(err, fr.ErrInvalidHeader) {
+		t.Fatalf("reserved flag: err=%v", err)
+	}
+}
+
+func TestMQTTPackets_TypeAndRemainingLength(t *testing.T) {
+	var wire bytes.Buffer
+	w := fr.NewWriter(&wire, fr.WithProfile(fr.ProfileMQTT)).(*fr.Writer)
+	big := bytes.Repeat([]byte{'x'}, 321)
+	if _, err := w.WriteMeta(fr.Meta{Type: 0x30}, []byte("pub")); err != nil {
+		t.Fatalf("WriteMeta: %v", err)
+	}
+	if _, err := w.WriteMeta(fr.Meta{Type: 0xC0}, nil); err != nil {
+		t.Fatalf("PINGREQ: %v", err)
+	}
+	if _, err := w.WriteMeta(fr.Meta{Type: 0x32}, big); err != nil {
+		t.Fatalf("WriteMeta big: %v", err)
+	}
+	want := []byte{0x30, 3, 'p', 'u', 'b', 0xC0, 0, 0x32, 0xC1, 0x02}
+	if !bytes.Equal(wire.Bytes()[:len(want)], want) {
+		t.Fatalf("wire % x want % x", wire.Bytes()[:len(want)], want)
+	}
+	hdr, err := fr.DecodeHeader(wire.Bytes()[7:], fr.WithProfile(fr.ProfileMQTT))
+	if err != nil || hdr.Type != 0x32 || hdr.PayloadLen != 321 || hdr.HeaderLen != 3 {
+		t.Fatalf("DecodeHeader: %+v err=%v", hdr, err)
+	}
+	if _, err := (&fr.Header{PayloadLen: 1 << 28, Format: fr.HeaderMQTT}).Encode(make([]byte, 8)); err != fr.ErrTooLong {
+		t.Fatalf("over 2^28-1: err=%v", err)
+	}
+
+	r := fr.NewReader(&wouldBlockEveryOther{r: bytes.NewReader(wire.Bytes())}, fr.WithProfile(fr.ProfileMQTT)).(*fr.Reader)
+	buf := make([]byte, 512)
+	for _, m := range []struct {
+		payload []byte
+		typ     byte
+	}{{[]byte("pub"), 0x30}, {nil, 0xC0}, {big, 0x32}} {
+		n, err := r.Read(buf)
+		total := n
+		for err == fr.ErrWouldBlock {
+			n, err = r.Read(buf)
+			total += n
+		}
+		if err != nil || !bytes.Equal(buf[:total], m.payload) || r.Meta().Type != m.typ {
+			t.Fatalf("read %d bytes, %v, %+v; want type %#x", total, err, r.Meta(), m.typ)
+		}
+	}
+
+	// A Remaining Length has at most 4 bytes.
+	bad := fr.NewReader(bytes.NewReader([]byte{0x30, 0xFF, 0xFF, 0xFF, 0xFF, 0x01}), fr.WithProfile(fr.ProfileMQTT))
+	if _, err := bad.Read(buf); !errors.Is(err, fr.ErrInvalidHeader) {
+		t.Fatalf("5-byte length: err=%v", err)
+	}
+}
+
+func TestTLSRecords_InspectAndForward(t *testing.T) {
+	hello := bytes.Repeat([]byte{0xAB}, 300)
+	var wire bytes.Buffer
+	w := fr.NewWriter(&wire, fr.WithProfile(fr.ProfileTLS)).(*fr.Writer)
+	if _, err := w.WriteMeta(fr.Meta{Type: 0x16, Version: 0x0301}, hello); err != nil {
+		t.Fatalf("WriteMeta: %v", err)
+	}
+	if _, err := w.WriteMeta(fr.Meta{Type: 0x17, Version: 0x0303}, []byte("data")); err != nil {
+		t.Fatalf("WriteMeta: %v", err)
+	}
+	if want := []byte{0x16, 0x03, 0x01, 0x01, 0x2C}; !bytes.Equal(wire.Bytes()[:5], want) {
+		t.Fatalf("header % x want % x", wire.Bytes()[:5], want)
+	}
+	hdr, err := fr.DecodeHeader(wire.Bytes()[305:], fr.WithProfile(fr.ProfileTLS))
+	if err != nil || hdr.Type != 0x17 || hdr.Version != 0x0303 || hdr.PayloadLen != 4 {
+		t.Fatalf("DecodeHeader: %+v err=%v", hdr, err)
+	}
+
+	r := fr.NewReader(bytes.NewReader(wire.Bytes()), fr.WithProfile(fr.ProfileTLS)).(*fr.Reader)
+	buf := make([]byte, 512)
+	if n, err := r.Read(buf); err != nil || n != len(hello) || r.Meta() != (fr.Meta{Type: 0x16, Version: 0x0301}) {
+		t.Fatalf("read: n=%d err=%v meta=%+v", n, err, r.Meta())
+	}
+
+	// The Forwarder relays records with their type and version.
+	var out bytes.Buffer
+	fwd := fr.NewForwarder(&out, &wouldBlockEveryOther{r: bytes.NewReader(wire.Bytes())}, fr.WithProfile(fr.ProfileTLS))
+	for {
+		if _, err := fwd.ForwardOnce(); err == io.EOF {
+			break
+		} else if err != nil && err != fr.ErrWouldBlock {
+			t.Fatalf("forward: %v", err)
+		}
+	}
+	if !bytes.Equal(out.Bytes(), wire.Bytes()) {
+		t.Fatalf("forwarded records differ")
+	}
+
+	// CopyFrames and Transcode keep them too.
+	out.Reset()
+	src := fr.NewReader(&wouldBlockEveryOther{r: bytes.NewReader(wire.Bytes())}, fr.WithProfile(fr.ProfileTLS)).(*fr.Reader)
+	dst := fr.NewWriter(&out, fr.WithProfile(fr.ProfileTLS)).(*fr.Writer)
+	for {
+		if _, _, err := fr.CopyFrames(dst, src, -1); err == nil {
+			break
+		} else if err != fr.ErrWouldBlock {
+			t.Fatalf("CopyFrames: %v", err)
+		}
+	}
+	if !bytes.Equal(out.Bytes(), wire.Bytes()) {
+		t.Fatalf("copied records differ: % x", out.Bytes()[:5])
+	}
+
+	// A fragment over 2^14+2048 bytes is a record overflow.
+	bad := fr.NewReader(bytes.NewReader([]byte{0x17, 0x03, 0x03, 0x48, 0x01}), fr.WithProfile(fr.ProfileTLS))
+	if _, err := bad.Read(buf); err != fr.ErrTooLong {
+		t.Fatalf("record overflow: err=%v", err)
+	}
+}
+
+func TestPostgresMessages_TypeByte(t *testing.T) {
+	// An SSLRequest without a type byte, then a typed Query.
+	wire := []byte{0, 0, 0, 8, 0x04, 0xD2, 0x16, 0x2F, 'Q', 0, 0, 0, 7, 'S', 'E', 0}
+	src := &wouldBlockEveryOther{r: bytes.NewReader(wire)}
+	buf := make([]byte, 16)
+	read := func(r io.Reader) []byte {
+		n, err := r.Read(buf)
+		total := n
+		for err == fr.ErrWouldBlock {
+			n, err = r.Read(buf)
+			total += n
+		}
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return buf[:total]
+	}
+	startup := fr.NewReader(src, fr.WithHeaderFormat(fr.HeaderFixed32), fr.WithLengthIncludesHeader())
+	if got := read(startup); !bytes.Equal(got, []byte{0x04, 0xD2, 0x16, 0x2F}) {
+		t.Fatalf("startup % x", got)
+	}
+	r := fr.NewReader(src, fr.WithProfile(fr.ProfilePostgres)).(*fr.Reader)
+	if got := read(r); string(got) != "SE\x00" || r.Meta().Type != 'Q' {
+		t.Fatalf("query %q type %q", got, r.Meta().Type)
+	}
+
+	var out bytes.Buffer
+	w := fr.NewWriter(&out, fr.WithProfile(fr.ProfilePostgres)).(*fr.Writer)
+	if _, err := w.WriteMeta(fr.Meta{Type: 'Q'}, []byte("SE\x00")); err != nil {
+		t.Fatalf("WriteMeta: %v", err)
+	}
+	if _, err := w.WriteMeta(fr.Meta{Type: 'S'}, nil); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if want := append(wire[8:], 'S', 0, 0, 0, 4); !bytes.Equal(out.Bytes(), want) {
+		t.Fatalf("wire % x want % x", out.Bytes(), want)
+	}
+	hdr, err := fr.DecodeHeader(out.Bytes()[8:], fr.WithProfile(fr.ProfilePostgres))
+	if err != nil || hdr.Type != 'S' || hdr.PayloadLen != 0 || hdr.HeaderLen != 5 {
+		t.Fatalf("DecodeHeader: %+v err=%v", hdr, err)
+	}
+
+	// The length counts its own 4 bytes.
+	bad := fr.NewReader(bytes.NewReader([]byte{'Q', 0, 0, 0, 3}), fr.WithProfile(fr.ProfilePostgres))
+	if _, err := bad.Read(buf); !errors.Is(err, fr.ErrInvalidHeader) {
+		t.Fatalf("length 3: err=%v", err)
+	}
+}
+
+func TestAMQPFrames_FrameEnd(t *testing.T) {
+	// Every other transport write would block, including the frame end.
+	out := &alternatingWriter{}
+	w := fr.NewWriter(out, fr.WithProfile(fr.ProfileAMQP)).(*fr.Writer)
+	write := func(m fr.Meta, parts ...[]byte) {
+		var err error
+		for i := 0; i < 100; i++ {
+			if len(parts) == 1 {
+				_, err = w.WriteMeta(m, parts[0])
+			} else {
+				_, err = w.Writev(parts...)
+			}
+			if err != fr.ErrWouldBlock {
+				break
+			}
+		}
+		if err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	write(fr.Meta{Type: 1, Channel: 5}, []byte("method"))
+	write(fr.Meta{Type: 8}, nil)
+	write(fr.Meta{}, []byte("ab"), []byte("c"))
+	want := []byte{1, 0, 5, 0, 0, 0, 6, 'm', 'e', 't', 'h', 'o', 'd', 0xCE, 8, 0, 0, 0, 0, 0, 0, 0xCE,
+		0, 0, 0, 0, 0, 0, 3, 'a', 'b', 'c', 0xCE}
+	if !bytes.Equal(out.buf.Bytes(), want) {
+		t.Fatalf("wire % x\nwant % x", out.buf.Bytes(), want)
+	}
+	hdr, err := fr.DecodeHeader(want, fr.WithProfile(fr.ProfileAMQP))
+	if err != nil || hdr.Type != 1 || hdr.Channel != 5 || hdr.PayloadLen != 6 || hdr.HeaderLen != 7 {
+		t.Fatalf("DecodeHeader: %+v err=%v", hdr, err)
+	}
+
+	r := fr.NewReader(&wouldBlockEveryOther{r: bytes.NewReader(want)}, fr.WithProfile(fr.ProfileAMQP)).(*fr.Reader)
+	buf := make([]byte, 16)
+	for _, m := range []struct {
+		payload string
+		meta    fr.Meta
+	}{{"method", fr.Meta{Type: 1, Channel: 5}}, {"", fr.Meta{Type: 8}}, {"abc", fr.Meta{}}} {
+		n, err := r.Read(buf)
+		total := n
+		for err == fr.ErrWouldBlock {
+			n, err = r.Read(buf)
+			total += n
+		}
+		if err != nil || string(buf[:total]) != m.payload || r.Meta() != m.meta {
+			t.Fatalf("read %q, %v, %+v; want %q %+v", buf[:total], err, r.Meta(), m.payload, m.meta)
+		}
+	}
+
+	// A chunked Forwarder relays frames with their channel and frame end.
+	var relayed bytes.Buffer
+	fwd := fr.NewForwarder(&relayed, bytes.NewReader(want), fr.WithProfile(fr.ProfileAMQP), fr.WithChunkedForward(4))
+	for {
+		if _, err := fwd.ForwardOnce(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("forward: %v", err)
+		}
+	}
+	if !bytes.Equal(relayed.Bytes(), want) {
+		t.Fatalf("relayed % x", relayed.Bytes())
+	}
+
+	bad := append([]byte(nil), want[:14]...)
+	bad[13] = 0
+	br := fr.NewReader(bytes.NewReader(bad), fr.WithProfile(fr.ProfileAMQP))
+	if _, err := br.Read(buf); err != fr.ErrInvalidFrameEnd {
+		t.Fatalf("bad frame end: err=%v", err)
+	}
+	tr := fr.NewReader(bytes.NewReader(want[:13]), fr.WithProfile(fr.ProfileAMQP))
+	if _, err := tr.Read(buf); err != io.ErrUnexpectedEOF {
+		t.Fatalf("missing frame end: err=%v", err)
+	}
+}
+
+// alternatingWriter accepts every other write whole and fails the others
+// with ErrWouldBlock.
+type alternatingWriter struct {
+	buf   bytes.Buffer
+	calls int
+}
+
+func (w *alternatingWriter) Write(p []byte) (int, error) {
+	if w.calls++; w.calls%2 == 1 {
+		return 0, iox.ErrWouldBlock
+	}
+	return w.buf.Write(p)
+}
+
+func TestWithAutoDetect_LocksOntoClientFormat(t *testing.T) {
+	for _, tc := range []struct {
+		h       fr.HeaderFormat
+		formats []fr.HeaderFormat
+	}{
+		{fr.HeaderCompact, nil},
+		{fr.HeaderFixed32, nil},
+		{fr.HeaderFixed32, []fr.HeaderFormat{fr.HeaderFixed32, fr.HeaderUvarint}},
+		{fr.HeaderUvarint, []fr.HeaderFormat{fr.HeaderFixed32, fr.HeaderUvarint}},
+	} {
+		h := tc.h
+		wire, err := framertest.EncodeWire([][]byte{[]byte("hello"), bytes.Repeat([]byte{'x'}, 300)}, fr.WithHeaderFormat(h))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		rw := fr.NewReadWriter(&wouldBlockEveryOther{r: bytes.NewReader(wire)}, &out, fr.WithAutoDetect(tc.formats...)).(*fr.ReadWriter)
+		if _, ok := rw.ReadFormat(); ok {
+			t.Fatalf("format %d: settled before the first message", h)
+		}
+		buf := make([]byte, 512)
+		for _, want := range []int{5, 300} {
+			n, err := rw.Read(buf)
+			total := n
+			for err == fr.ErrWouldBlock {
+				n, err = rw.Read(buf)
+				total += n
+			}
+			if err != nil || total != want {
+				t.Fatalf("format %d: read %d bytes, %v; want %d", h, total, err, want)
+			}
+		}
+		if got, ok := rw.ReadFormat(); !ok || got != h {
+			t.Fatalf("detected %d, %v; want %d", got, ok, h)
+		}
+		// The reply uses the client's format.
+		if _, err := rw.Write([]byte("hello")); err != nil {
+			t.Fatalf("reply: %v", err)
+		}
+		if !bytes.Equal(out.Bytes(), wire[:len(out.Bytes())]) || out.Len() == 0 {
+			t.Fatalf("format %d: reply % x", h, out.Bytes())
+		}
+	}
+
+	// Nothing fits a 0xFF header under a small ReadLimit.
+	r := fr.NewReader(bytes.NewReader([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 1}),
+		fr.WithAutoDetect(fr.HeaderCompact, fr.HeaderFixed32, fr.HeaderUvarint), fr.WithReadLimit(16))
+	if _, err := r.Read(make([]byte, 16)); err != fr.ErrInvalidHeader {
+		t.Fatalf("no format: err=%v", err)
+	}
+}
+
+func TestWithVarintLength(t *testing.T) {
+	var wire bytes.Buffer
+	w := fr.NewWriter(&wire, fr.WithVarintLength())
+	for _, size := range []int{0, 127, 300} {
+		if _, err := w.Write(bytes.Repeat([]byte{'v'}, size)); err != nil {
+			t.Fatalf("write %d: %v", size, err)
+		}
+	}
+	if want := []byte{0x00, 0x7F}; !bytes.HasPrefix(wire.Bytes(), want) {
+		t.Fatalf("wire head % x", wire.Bytes()[:2])
+	}
+	if hdr := wire.Bytes()[2+127:][:2]; !bytes.Equal(hdr, []byte{0xAC, 0x02}) {
+		t.Fatalf("300-byte prefix % x want ac 02", hdr)
+	}
+	// The WriteTo fast path and ReadLimit work unchanged.
+	var out bytes.Buffer
+	r := fr.NewReader(bytes.NewReader(wire.Bytes()), fr.WithVarintLength(), fr.WithReadLimit(200))
+	if _, err := r.(io.WriterTo).WriteTo(&out); err != fr.ErrTooLong {
+		t.Fatalf("WriteTo past limit: err=%v want ErrTooLong", err)
+	}
+	if out.Len() != 127 {
+		t.Fatalf("copied %d payload bytes before the limit, want 127", out.Len())
+	}
+}
+
+func TestHeaderFormat_Limits(t *testing.T) {
+	// ReadLimit is checked against the parsed prefix before any payload byte.
+	r := fr.NewReader(bytes.NewReader([]byte{0, 0, 1, 0}), fr.WithHeaderFormat(fr.HeaderFixed32), fr.WithReadLimit(255))
+	if _, err := r.Read(make([]byte, 512)); err != fr.ErrTooLong {
+		t.Fatalf("fixed32 over limit: err=%v want ErrTooLong", err)
+	}
+	// An over-long varint is rejected instead of being read forever.
+	long := bytes.Repeat([]byte{0x80}, 11)
+	r = fr.NewReader(bytes.NewReader(long), fr.WithHeaderFormat(fr.HeaderUvarint))
+	if _, err := r.Read(make([]byte, 8)); err != fr.ErrTooLong {
+		t.Fatalf("uvarint overflow: err=%v want ErrTooLong", err)
+	}
+	// A 64-bit length beyond MaxPayloadLen is representable but refused.
+	huge := []byte{0x01, 0, 0, 0, 0, 0, 0, 0}
+	r = fr.NewReader(bytes.NewReader(huge), fr.WithFixedLengthHeader(8))
+	_, err := r.Read(make([]byte, 8))
+	var le *fr.LengthOverflowError
+	if !errors.As(err, &le) || !errors.Is(err, fr.ErrTooLong) || le.Length != 1<<56 {
+		t.Fatalf("fixed64 overflow: err=%v", err)
+	}
+	if _, err := fr.DecodeHeader(huge, fr.WithFixedLengthHeader(8)); !errors.As(err, &le) {
+		t.Fatalf("DecodeHeader fixed64 overflow: err=%v", err)
+	}
+	// Truncated prefixes report io.ErrUnexpectedEOF; an empty stream is io.EOF.
+	for _, h := range []fr.HeaderFormat{fr.HeaderFixed16, fr.HeaderFixed32, fr.HeaderFixed64, fr.HeaderUvarint} {
+		r = fr.NewReader(bytes.NewReader([]byte{0x80}), fr.WithHeaderFormat(h))
+		if _, err := r.Read(make([]byte, 8)); err != io.ErrUnexpectedEOF {
+			t.Fatalf("format %d truncated: err=%v want io.ErrUnexpectedEOF", h, err)
+		}
+		r = fr.NewReader(bytes.NewReader(nil), fr.WithHeaderFormat(h))
+		if _, err := r.Read(make([]byte, 8)); err != io.EOF {
+			t.Fatalf("format %d empty: err=%v want io.EOF", h, err)
+		}
+	}
+}
+
+func TestStdcopy_DemultiplexesStreams(t *testing.T) {
+	var wire bytes.Buffer
+	w := fr.NewWriter(&wire, fr.WithProfile(fr.ProfileDockerStdcopy)).(*fr.Writer)
+	if _, err := w.WriteTyped(fr.StreamStdout, []byte("hello")); err != nil {
+		t.Fatalf("WriteTyped: %v", err)
+	}
+	if _, err := w.WriteTyped(fr.StreamStderr, []byte("oops")); err != nil {
+		t.Fatalf("WriteTyped: %v", err)
+	}
+	if _, err := w.WriteTyped(fr.StreamStdout, nil); err != nil {
+		t.Fatalf("WriteTyped empty body: %v", err)
+	}
+	if _, err := w.Write(nil); err != fr.ErrInvalidArgument {
+		t.Fatalf("message without stream ID: err=%v", err)
+	}
+	want := []byte{1, 0, 0, 0, 0, 0, 0, 5, 'h', 'e', 'l', 'l', 'o', 2, 0, 0, 0, 0, 0, 0, 4, 'o', 'o', 'p', 's', 1, 0, 0, 0, 0, 0, 0, 0}
+	if !bytes.Equal(wire.Bytes(), want) {
+		t.Fatalf("wire=% x", wire.Bytes())
+	}
+	h, err := fr.DecodeHeader(want[13:], fr.WithProfile(fr.ProfileDockerStdcopy))
+	if err != nil || h.StreamID != fr.StreamStderr || h.PayloadLen != 4 || h.HeaderLen != 8 {
+		t.Fatalf("DecodeHeader=%+v err=%v", h, err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	rt := fr.NewRouter()
+	rt.Handle(fr.StreamStdout, func(b []byte) error { stdout.Write(b); return nil })
+	rt.Handle(fr.StreamStderr, func(b []byte) error { stderr.Write(b); return nil })
+	r := fr.NewReader(&wouldBlockEveryOther{r: bytes.NewReader(want)}, fr.WithProfile(fr.ProfileDockerStdcopy))
+	for {
+		if err := rt.Serve(r); err != fr.ErrWouldBlock {
+			if err != nil {
+				t.Fatalf("Serve: %v", err)
+			}
+			break
+		}
+	}
+	if stdout.String() != "hello" || stderr.String() != "oops" {
+		t.Fatalf("stdout=%q stderr=%q", stdout.String(), stderr.String())
+	}
+
+	r = fr.NewReader(bytes.NewReader([]byte{1, 0, 1, 0, 0, 0, 0, 0}), fr.WithProfile(fr.ProfileDockerStdcopy))
+	if _, err := r.Read(make([]byte, 8)); err != fr.ErrInvalidHeader {
+		t.Fatalf("nonzero padding: err=%v", err)
+	}
+}
+
+func TestDecodeHeader_MatchesWriter(t *testing.T) {
+	for _, h := range []fr.HeaderFormat{fr.HeaderCompact, fr.HeaderFixed32, fr.HeaderFixed64, fr.HeaderUvarint} {
+		for _, bo := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+			for _, size := range []int{0, 253, 254, 65535, 65536} {
+				opts := []fr.Option{fr.WithHeaderFormat(h), fr.WithByteOrder(bo)}
+				var out bytes.Buffer
+				if _, err := fr.NewWriter(&out, opts...).Write(make([]byte, size)); err != nil {
+					t.Fatalf("write: %v", err)
+				}
+				wire := out.Bytes()
+				hdr, err := fr.DecodeHeader(wire, opts...)
+				if err != nil || hdr.PayloadLen != int64(size) || hdr.HeaderLen+size != len(wire) {
+					t.Fatalf("format %d size %d: header=%+v err=%v wire=%d", h, size, hdr, err, len(wire))
+				}
+				if _, err := fr.DecodeHeader(wire[:hdr.HeaderLen-1], opts...); err != io.ErrUnexpectedEOF {
+					t.Fatalf("format %d size %d truncated: err=%v", h, size, err)
+				}
+				enc := make([]byte, 16)
+				n, err := (&fr.Header{PayloadLen: int64(size), ByteOrder: bo, Format: h}).Encode(enc)
+				if err != nil || !bytes.Equal(enc[:n], wire[:hdr.HeaderLen]) {
+					t.Fatalf("format %d size %d: encoded % x want % x (err=%v)", h, size, enc[:n], wire[:hdr.HeaderLen], err)
+				}
+			}
+		}
+	}
+	if _, err := fr.DecodeHeader(bytes.Repeat([]byte{0x80}, 11), fr.WithHeaderFormat(fr.HeaderUvarint)); err != fr.ErrTooLong {
+		t.Fatalf("uvarint overflow: err=%v want ErrTooLong", err)
+	}
+	if _, err := (&fr.Header{PayloadLen: 1 << 32, Format: fr.HeaderFixed32}).Encode(make([]byte, 8)); err != fr.ErrTooLong {
+		t.Fatalf("fixed32 over range: err=%v want ErrTooLong", err)
+	}
+	if _, err := (&fr.Header{PayloadLen: 1 << 16}).Encode(make([]byte, 3)); err != io.ErrShortBuffer {
+		t.Fatalf("short dst: err=%v want io.ErrShortBuffer", err)
+	}
+}
+
+func TestHeaderSize(t *testing.T) {
+	for _, size := range []int{0, 253, 254, 65535, 65536} {
+		var out bytes.Buffer
+		if _, err := fr.NewWriter(&out).Write(make([]byte, size)); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if got := fr.HeaderSize(size) + size; got != out.Len() {
+			t.Fatalf("size %d: HeaderSize+payload=%d wire=%d", size, got, out.Len())
+		}
+	}
+	if fr.HeaderSize(1<<30) != fr.MaxHeaderLen {
+		t.Fatalf("HeaderSize(1<<30)=%d want %d", fr.HeaderSize(1<<30), fr.MaxHeaderLen)
+	}
+}
+
+func TestFixedHeader_ConstantPayloadOffset(t *testing.T) {
+	for _, bo := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		for _, extra := range [][]fr.Option{nil, {fr.WithLengthIncludesHeader(), fr.WithControlFrames(nil)}} {
+			opts := append([]fr.Option{fr.WithByteOrder(bo)}, extra...)
+			var out bytes.Buffer
+			w := fr.NewWriter(&out, append(opts, fr.WithFixedHeader())...)
+			var want [][]byte
+			for _, size := range []int{0, 5, 300, 70000} {
+				p := bytes.Repeat([]byte{byte(size)}, size)
+				start := out.Len()
+				if _, err := w.Write(p); err != nil {
+					t.Fatalf("write: %v", err)
+				}
+				if out.Bytes()[start] != 0xFF || out.Len()-start-size != fr.MaxHeaderLen+len(extra)/2 {
+					t.Fatalf("size %d: header byte %#x, frame %d bytes", size, out.Bytes()[start], out.Len()-start)
+				}
+				want = append(want, p)
+			}
+			// The reader needs no matching option.
+			r := fr.NewReader(&out, opts...)
+			buf := make([]byte, 70000)
+			for i, p := range want {
+				n, err := r.Read(buf)
+				if err != nil || !bytes.Equal(buf[:n], p) {
+					t.Fatalf("message %d: n=%d err=%v", i, n, err)
+				}
+			}
+		}
+	}
+}
+
+func TestHeaderFormat_ForwarderAndReadFromResume(t *testing.T) {
+	opts := []fr.Option{fr.WithProfile(fr.ProfileProtobufDelimited)}
+	var src bytes.Buffer
+	w := fr.NewWriter(&src, opts...)
+	msgs := [][]byte{[]byte("one"), bytes.Repeat([]byte{'x'}, 200), nil}
+	for _, m := range msgs {
+		if _, err := w.Write(m); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	var dst bytes.Buffer
+	fwd := fr.NewForwarder(&dst, bytes.NewReader(src.Bytes()), opts...)
+	for {
+		if _, err := fwd.ForwardOnce(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("forward: %v", err)
+		}
+	}
+	if !bytes.Equal(dst.Bytes(), src.Bytes()) {
+		t.Fatalf("forwarded wire differs")
+	}
+
+	// ReadFrom resumes an in-flight message across would-blocks.
+	out := &wouldBlockWriter2{limit: 2}
+	rf := fr.NewWriter(out, fr.WithProfile(fr.ProfileErlangPacket4)).(*fr.Writer)
+	_, err := rf.ReadFrom(bytes.NewReader([]byte("hey")))
+	for i := 0; err == fr.ErrWouldBlock && i < 8; i++ {
+		_, err = rf.ReadFrom(bytes.NewReader(nil))
+	}
+	if err != nil {
+		t.Fatalf("ReadFrom: %v", err)
+	}
+	if want := []byte{0, 0, 0, 3, 'h', 'e', 'y'}; !bytes.Equal(out.buf.Bytes(), want) {
+		t.Fatalf("wire=% x want % x", out.buf.Bytes(), want)
+	}
+}
+
+// wouldBlockEveryOther delivers one byte per call, alternating with ErrWouldBlock.
+type wouldBlockEveryOther struct {
+	r     io.Reader
+	calls int
+}
+
+func (w *wouldBlockEveryOther) Read(p []byte) (int, error) {
+	w.calls++
+	if w.calls%2 == 1 {
+		return 0, iox.ErrWouldBlock
+	}
+	if len(p) > 1 {
+		p = p[:1]
+	}
+	return w.r.Read(p)
+}
+
+func TestLengthIncludesHeader(t *testing.T) {
+	cases := []struct {
+		name string
+		opts []fr.Option
+		size int
+		head []byte
+	}{
+		{"fixed32", []fr.Option{fr.WithHeaderFormat(fr.HeaderFixed32)}, 3, []byte{0, 0, 0, 7}},
+		{"compact", nil, 5, []byte{6}},
+		// 252+1 fits one byte; 253+1 does not, so the 3-byte header counts itself.
+		{"compact-edge-short", nil, 252, []byte{253}},
+		{"compact-edge-long", nil, 253, []byte{0xFE, 0x01, 0x00}},
+		{"uvarint-edge", []fr.Option{fr.WithHeaderFormat(fr.HeaderUvarint)}, 127, []byte{0x81, 0x01}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := append(tc.opts, fr.WithLengthIncludesHeader())
+			payload := bytes.Repeat([]byte{'q'}, tc.size)
+			var out bytes.Buffer
+			if _, err := fr.NewWriter(&out, opts...).Write(payload); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			if !bytes.HasPrefix(out.Bytes(), tc.head) || out.Len() != len(tc.head)+tc.size {
+				t.Fatalf("wire head=% x len=%d want head % x", out.Bytes()[:len(tc.head)], out.Len(), tc.head)
+			}
+			buf := make([]byte, 512)
+			n, err := fr.NewReader(bytes.NewReader(out.Bytes()), opts...).Read(buf)
+			if err != nil || !bytes.Equal(buf[:n], payload) {
+				t.Fatalf("read: n=%d err=%v", n, err)
+			}
+		})
+	}
+
+	// A total length shorter than the header itself is malformed.
+	r := fr.NewReader(bytes.NewReader([]byte{0, 0, 0, 3}), fr.WithHeaderFormat(fr.HeaderFixed32), fr.WithLengthIncludesHeader())
+	if _, err := r.Read(make([]byte, 8)); err != fr.ErrInvalidHeader {
+		t.Fatalf("err=%v want ErrInvalidHeader", err)
+	}
+}
+
+// --- Datagram truncation ---
+
+func udpPair(t *testing.T) (recv, send net.Conn) {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("udp unavailable: %v", err)
+	}
+	recv = pc.(*net.UDPConn)
+	send, err = net.Dial("udp", pc.LocalAddr().String())
+	if err != nil {
+		_ = pc.Close()
+		t.Skipf("udp unavailable: %v", err)
+	}
+	t.Cleanup(func() { _ = recv.Close(); _ = send.Close() })
+	return recv, send
+}
+
+func TestReader_Datagram_Truncated(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("datagram truncation is detected on linux only")
+	}
+	recv, send := udpPair(t)
+	_, _ = send.Write(bytes.Repeat([]byte{'a'}, 100))
+	_, _ = send.Write([]byte("fits"))
+	_ = recv.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	r := fr.NewReader(recv, fr.WithReadUDP())
+	buf := make([]byte, 10)
+	n, err := r.Read(buf)
+	var te *fr.TruncatedError
+	if !errors.As(err, &te) || !errors.Is(err, fr.ErrTruncated) || n != 10 {
+		t.Fatalf("oversized datagram: n=%d err=%v", n, err)
+	}
+	if te.Length != 100 || te.Received != 10 {
+		t.Fatalf("TruncatedError=%+v", te)
+	}
+	if n, err := r.Read(buf); err != nil || string(buf[:n]) != "fits" {
+		t.Fatalf("next datagram: %q err=%v", buf[:n], err)
+	}
+}
+
+func TestForwarder_Datagram_TruncatedByReadLimit(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("datagram truncation is detected on linux only")
+	}
+	recv, send := udpPair(t)
+	_, _ = send.Write(bytes.Repeat([]byte{'a'}, 40))
+	_, _ = send.Write([]byte("small"))
+	_ = recv.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var out bytes.Buffer
+	f := fr.NewForwarder(&out, recv, fr.WithReadUDP(), fr.WithWriteUDP(), fr.WithReadLimit(16))
+	if _, err := f.ForwardOnce(); !errors.Is(err, fr.ErrTruncated) {
+		t.Fatalf("oversized datagram: err=%v", err)
+	}
+	if n, err := f.ForwardOnce(); n != 5 || err != nil || out.String() != "small" {
+		t.Fatalf("next datagram: n=%d err=%v out=%q", n, err, out.String())
+	}
+}
+
+func TestDial_UnixSeqpacketPreservesBoundaries(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("unixpacket sockets are tested on linux only")
+	}
+	addr := "@framer-seqpacket-" + t.Name()
+	ln, err := net.Listen("unixpacket", addr)
+	if err != nil {
+		t.Skipf("unixpacket unavailable: %v", err)
+	}
+	defer ln.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		nc, _ := ln.Accept()
+		accepted <- nc
+	}()
+	c, err := fr.Dial("unixpacket", addr, fr.WithBlock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	peer := <-accepted
+	if peer == nil {
+		t.Fatal("accept failed")
+	}
+	defer peer.Close()
+
+	for _, m := range []string{"one", "three"} {
+		if _, err := c.Write([]byte(m)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// SeqPacket is pass-through: each message is one packet, unprefixed.
+	buf := make([]byte, 16)
+	for _, want := range []string{"one", "three"} {
+		n, err := peer.Read(buf)
+		if err != nil || string(buf[:n]) != want {
+			t.Fatalf("packet = %q, %v; want %q", buf[:n], err, want)
+		}
+	}
+	_, _ = peer.Write([]byte("reply"))
+	if n, err := c.Read(buf); err != nil || string(buf[:n]) != "reply" {
+		t.Fatalf("read = %q, %v", buf[:n], err)
+	}
+}
+
+// --- Control frames ---
+
+func TestControlFrames_SeparatedFromData(t *testing.T) {
+	var wire bytes.Buffer
+	w := fr.NewWriter(&wire, fr.WithControlFrames(nil)).(*fr.Writer)
+	_, _ = w.Write([]byte("ab"))
+	_, _ = w.WriteControl([]byte("k"))
+	_, _ = w.WriteControl(nil)
+	_, _ = w.Write(nil)
+	_, _ = w.Write(bytes.Repeat([]byte{'d'}, 300))
+	want := []byte{3, 0, 'a', 'b', 2, 1, 'k', 1, 1, 1, 0}
+	if !bytes.HasPrefix(wire.Bytes(), want) {
+		t.Fatalf("wire=% x", wire.Bytes()[:len(want)])
+	}
+
+	var ctl []string
+	r := fr.NewReader(&wouldBlockEveryOther{r: bytes.NewReader(wire.Bytes())},
+		fr.WithControlFrames(func(p []byte) error {
+			ctl = append(ctl, string(p))
+			return nil
+		})).(*fr.Reader)
+	buf := make([]byte, 512)
+	for _, want := range []int{2, 0, 300} {
+		got := 0
+		for {
+			n, err := r.Read(buf)
+			got += n
+			if err == nil {
+				break
+			}
+			if err != fr.ErrWouldBlock {
+				t.Fatalf("Read: %v", err)
+			}
+		}
+		if got != want {
+			t.Fatalf("data frame: %d bytes want %d", got, want)
+		}
+	}
+	if len(ctl) != 2 || ctl[0] != "k" || ctl[1] != "" {
+		t.Fatalf("control frames=%q", ctl)
+	}
+}
+
+func TestControlFrames_HandlerErrorAndMisuse(t *testing.T) {
+	var wire bytes.Buffer
+	w := fr.NewWriter(&wire, fr.WithControlFrames(nil)).(*fr.Writer)
+	_, _ = w.WriteControl([]byte("close"))
+	_, _ = w.Write([]byte("data"))
+	stop := errors.New("peer closing")
+	r := fr.NewReader(&wire, fr.WithControlFrames(func([]byte) error { return stop }))
+	buf := make([]byte, 8)
+	if _, err := r.Read(buf); err != stop {
+		t.Fatalf("handler error: %v", err)
+	}
+	if n, err := r.Read(buf); err != nil || string(buf[:n]) != "data" {
+		t.Fatalf("data after control: %q err=%v", buf[:n], err)
+	}
+
+	plain := fr.NewWriter(&wire).(*fr.Writer)
+	if _, err := plain.WriteControl([]byte("x")); err != fr.ErrInvalidArgument {
+		t.Fatalf("WriteControl without option: err=%v", err)
+	}
+	if _, err := fr.NewReader(bytes.NewReader([]byte{0}), fr.WithControlFrames(nil)).Read(buf); err != fr.ErrInvalidHeader {
+		t.Fatalf("zero length without flags byte: err=%v", err)
+	}
+}
+
+func TestDelimited_RoundTrip(t *testing.T) {
+	var wire bytes.Buffer
+	w := fr.NewWriter(&wire, fr.WithDelimiter('\r', '\n'))
+	lines := []string{"PING", "", "SET k v"}
+	for _, l := range lines {
+		if n, err := w.Write([]byte(l)); err != nil || n != len(l) {
+			t.Fatalf("write %q: n=%d err=%v", l, n, err)
+		}
+	}
+	if want := "PING\r\n\r\nSET k v\r\n"; wire.String() != want {
+		t.Fatalf("wire=%q want %q", wire.String(), want)
+	}
+	if _, err := w.Write([]byte("a\r\nb")); !errors.Is(err, fr.ErrInvalidArgument) {
+		t.Fatalf("payload with delimiter: %v", err)
+	}
+
+	// The delimiter is split across reads and messages share reads.
+	src := &wouldBlockEveryOther{r: bytes.NewReader(wire.Bytes())}
+	r := fr.NewReader(src, fr.WithDelimiter('\r', '\n'))
+	buf := make([]byte, 16)
+	for _, want := range lines {
+		n, err := r.Read(buf)
+		for i := 0; err == fr.ErrWouldBlock && i < 100; i++ {
+			n, err = r.Read(buf)
+		}
+		if err != nil || string(buf[:n]) != want {
+			t.Fatalf("read %q: got %q err=%v", want, buf[:n], err)
+		}
+	}
+	_, err := r.Read(buf)
+	for i := 0; err == fr.ErrWouldBlock && i < 100; i++ {
+		_, err = r.Read(buf)
+	}
+	if err != io.EOF {
+		t.Fatalf("end: %v", err)
+	}
+}
+
+func TestDelimited_Limits(t *testing.T) {
+	long := bytes.Repeat([]byte{'x'}, 100)
+	in := "short\n" + string(long) + "\nnext\npartial"
+	r := fr.NewReader(strings.NewReader(in), fr.WithDelimiter(), fr.WithReadLimit(32))
+	buf := make([]byte, 8)
+	if n, err := r.Read(buf); err != nil || string(buf[:n]) != "short" {
+		t.Fatalf("first: %q %v", buf[:n], err)
+	}
+	// The overlong line is dropped and reading resumes after it.
+	_, err := r.Read(make([]byte, 128))
+	if !errors.Is(err, fr.ErrTooLong) {
+		t.Fatalf("overlong: %v", err)
+	}
+	if _, err := r.Read(buf[:2]); err != io.ErrShortBuffer {
+		t.Fatalf("short buffer: %v", err)
+	}
+	if n, err := r.Read(buf); err != nil || string(buf[:n]) != "next" {
+		t.Fatalf("after overlong: %q %v", buf[:n], err)
+	}
+	if _, err := r.Read(buf); err != io.ErrUnexpectedEOF {
+		t.Fatalf("partial: %v", err)
+	}
+}
+
+func TestDelimited_Forwarder(t *testing.T) {
+	var dst bytes.Buffer
+	fwd := fr.NewForwarder(&dst, strings.NewReader("a\nbb\nccc\n"), fr.WithDelimiter())
+	for {
+		if _, err := fwd.ForwardOnce(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("forward: %v", err)
+		}
+	}
+	if dst.String() != "a\nbb\nccc\n" {
+		t.Fatalf("forwarded %q", dst.String())
+	}
+}
+
+func TestCOBS_SLIP_RoundTrip(t *testing.T) {
+	msgs := [][]byte{
+		{0x11, 0x22, 0x00, 0x33},
+		{},
+		{0x00},
+		bytes.Repeat([]byte{0x01}, 254),
+		bytes.Repeat([]byte{0x01}, 255),
+		{0xC0, 0xDB, 0x00, 0xDC},
+		bytes.Repeat([]byte{0x00, 0xC0, 0x07}, 300),
+	}
+	for _, tc := range []struct {
+		name  string
+		opt   fr.Option
+		first []byte // wire encoding of msgs[0]
+	}{
+		{"cobs", fr.WithCOBS(), []byte{0x03, 0x11, 0x22, 0x02, 0x33, 0x00}},
+		{"slip", fr.WithSLIP(), []byte{0x11, 0x22, 0x00, 0x33, 0xC0}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var wire bytes.Buffer
+			w := fr.NewWriter(&wire, tc.opt)
+			for _, m := range msgs {
+				if n, err := w.Write(m); err != nil || n != len(m) {
+					t.Fatalf("write: n=%d err=%v", n, err)
+				}
+			}
+			if !bytes.HasPrefix(wire.Bytes(), tc.first) {
+				t.Fatalf("wire=% x want prefix % x", wire.Bytes()[:8], tc.first)
+			}
+			src := &wouldBlockEveryOther{r: bytes.NewReader(wire.Bytes())}
+			r := fr.NewReader(src, tc.opt)
+			buf := make([]byte, 1024)
+			for i, want := range msgs {
+				if len(want) == 0 && tc.name == "slip" {
+					continue // an empty SLIP frame only separates frames
+				}
+				n, err := r.Read(buf)
+				for j := 0; err == fr.ErrWouldBlock && j < 10000; j++ {
+					n, err = r.Read(buf)
+				}
+				if err != nil || !bytes.Equal(buf[:n], want) {
+					t.Fatalf("msg %d: got % x err=%v", i, buf[:min(n, 8)], err)
+				}
+			}
+		})
+	}
+}
+
+func TestCOBS_InvalidAndShortBuffer(t *testing.T) {
+	// A code byte running past the frame, then a valid frame.
+	in := []byte{0x05, 0x11, 0x00, 0x03, 0x11, 0x22, 0x00}
+	r := fr.NewReader(bytes.NewReader(in), fr.WithCOBS())
+	buf := make([]byte, 8)
+	if _, err := r.Read(buf); !errors.Is(err, fr.ErrInvalidEncoding) {
+		t.Fatalf("invalid: %v", err)
+	}
+	if _, err := r.Read(buf[:1]); err != io.ErrShortBuffer {
+		t.Fatalf("short buffer: %v", err)
+	}
+	if n, err := r.Read(buf); err != nil || !bytes.Equal(buf[:n], []byte{0x11, 0x22}) {
+		t.Fatalf("after short buffer: % x %v", buf[:n], err)
+	}
+
+	sr := fr.NewReader(bytes.NewReader([]byte{0xDB, 0x01, 0xC0}), fr.WithSLIP())
+	if _, err := sr.Read(buf); !errors.Is(err, fr.ErrInvalidEncoding) {
+		t.Fatalf("slip invalid escape: %v", err)
+	}
+}
+
+func TestCOBS_SteadyStateAllocs(t *testing.T) {
+	msg := bytes.Repeat([]byte{0x00, 0x42}, 512)
+	var wire bytes.Buffer
+	w := fr.NewWriter(&wire, fr.WithCOBS())
+	w.Write(msg)
+	frame := append([]byte(nil), wire.Bytes()...)
+	src := bytes.NewReader(nil)
+	r := fr.NewReader(src, fr.WithCOBS())
+	buf := make([]byte, len(msg))
+	allocs := testing.AllocsPerRun(100, func() {
+		wire.Reset()
+		if _, err := w.Write(msg); err != nil {
+			t.Fatal(err)
+		}
+		src.Reset(frame)
+		if _, err := r.Read(buf); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("allocs per message = %v", allocs)
+	}
+}
@@ -0,0 +1,36 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import "time"
+
+// WithTTL makes a Forwarder drop messages older than ttl instead of relaying
+// them, for real-time state where a stale update is worse than a missing
+// one. The wire format carries no send time, so stamp extracts it from the
+// payload, for example from a timestamp the application puts in front; a
+// message for which stamp reports false is relayed. Dropped messages are
+// counted in Stats.Expired; ForwardOnce returns (0, nil) for them, and
+// ForwardFor counts them among the messages it completes.
+//
+// The age is checked once the whole payload has been read, so messages
+// relayed in chunks (WithChunkedForward) are never dropped. Readers and
+// Writers ignore the option.
+func WithTTL(ttl time.Duration, stamp func(payload []byte) (time.Time, bool)) Option {
+	return func(o *Options) { o.TTL, o.TTLStamp = ttl, stamp }
+}
+
+// expired reports whether the buffered message is older than the TTL, and
+// counts it if so.
+func (f *Forwarder) expired() bool {
+	if f.ttl <= 0 || f.stamp == nil {
+		return false
+	}
+	sent, ok := f.stamp(f.buf[:f.need])
+	if !ok || time.Since(sent) <= f.ttl {
+		return false
+	}
+	f.rr.rstats.expired.Add(1)
+	return true
+}
@@ -0,0 +1,120 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package framer
+
+import (
+	"fmt"
+	"time"
+)
+
+// WithMinReadRate makes a stream Reader abort a message whose bytes arrive
+// slower than bytesPerSec on average, once grace has passed since its first
+// header byte. This protects servers from peers that start a large message
+// and then trickle it in forever. The failing read returns a *RateError; the
+// message cannot be resumed and the connection should be closed.
+//
+// The rate is checked whenever the transport returns control to the Reader,
+// including on ErrWouldBlock and on read deadline errors, so with a blocking
+// transport it takes effect together with a read deadline. Idle time between
+// messages is not counted.
+func WithMinReadRate(bytesPerSec int, grace time.Duration) Option {
+	return func(o *Options) {
+		o.MinReadRate = bytesPerSec
+		o.MinReadGrace = grace
+	}
+}
+
+// WithMessageDeadline makes a stream Reader abort a message that is still
+// incomplete d after its first header byte arrived, returning ErrTimeout.
+// Unlike a transport deadline it bounds how long one message may remain
+// partially received, not how long a read may wait; idle time between
+// messages is not counted. Like WithMinReadRate it is checked whenever the
+// transport returns control to the Reader, and the aborted message cannot be
+// resumed.
+func WithMessageDeadline(d time.Duration) Option {
+	return func(o *Options) { o.MessageDeadline = d }
+}
+
+// WithWriteStallLimit makes a Writer give up on a message whose write has
+// met ErrWouldBlock for longer than d since the first time, or more than
+// retries times, so a server can disconnect a slow consumer instead of
+// holding its buffers indefinitely. A zero d or retries disables that bound.
+// The failing write returns a *StallError; the message stays in flight, so
+// the connection should normally be closed.
+//
+// The bounds are checked whenever the transport reports ErrWouldBlock, in
+// blocking and non-blocking mode alike.
+func WithWriteStallLimit(d time.Duration, retries int) Option {
+	return func(o *Options) {
+		o.WriteStallTimeout = d
+		o.WriteStallRetries = retries
+	}
+}
+
+// StallError reports a message the destination did not accept within the
+// write stall limit (see WithWriteStallLimit). It matches ErrSlowConsumer
+// with errors.Is.
+type StallError struct {
+	Retries int           // ErrWouldBlock results met by the message
+	Elapsed time.Duration // time since the first of them
+}
+
+func (e *StallError) Error() string {
+	return fmt.Sprintf("framer: slow consumer: write stalled %d times over %v", e.Retries, e.Elapsed)
+}
+
+func (e *StallError) Is(target error) bool { return target == ErrSlowConsumer }
+
+// checkStall records an ErrWouldBlock met by the message being written and
+// reports whether it exceeds the write stall limit.
+func (fr *framer) checkStall() error {
+	if fr.wstart.IsZero() {
+		fr.wstart = time.Now()
+	}
+	fr.wstalls++
+	elapsed := time.Since(fr.wstart)
+	if (fr.stallTimeout > 0 && elapsed > fr.stallTimeout) || (fr.stallRetries > 0 && fr.wstalls > fr.stallRetries) {
+		return &StallError{Retries: fr.wstalls, Elapsed: elapsed}
+	}
+	return nil
+}
+
+// RateError reports a message whose bytes arrived below the minimum read
+// rate (see WithMinReadRate). It matches ErrTooSlow with errors.Is.
+type RateError struct {
+	Received int64         // header and payload bytes received
+	Elapsed  time.Duration // time since the first byte of the message
+}
+
+func (e *RateError) Error() string {
+	return fmt.Sprintf("framer: message too slow: received %d bytes in %v", e.Received, e.Elapsed)
+}
+
+func (e *RateError) Is(target error) bool { return target == ErrTooSlow }
+
+// checkPace enforces the minimum read rate and the message deadline after a
+// transport read that returned n bytes of the in-flight message. A violation
+// aborts the stream: every further read reports the same error.
+func (fr *framer) checkPace(n int) error {
+	if fr.rabort != nil {
+		return fr.rabort
+	}
+	if n > 0 && fr.rstart.IsZero() {
+		fr.rstart = time.Now()
+		return nil
+	}
+	if fr.rstart.IsZero() {
+		return nil
+	}
+	elapsed := time.Since(fr.rstart)
+	received := fr.offset + int64(n)
+	switch {
+	case fr.msgDeadline > 0 && elapsed > fr.msgDeadline:
+		fr.rabort = ErrTimeout
+	case fr.minRate > 0 && elapsed > fr.rateGrace && float64(received) < float64(fr.minRate)*elapsed.Seconds():
+		fr.rabort = &RateError{Received: received, Elapsed: elapsed}
+	}
+	return fr.rabort
+}